@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runImportRates implements the "import-rates" subcommand:
+//
+//	app import-rates [-format auto|csv|ecb|ptax] [-dry-run] file...
+//
+// It works only with local files so it can run without network access.
+func runImportRates(args []string) error {
+	flags := flag.NewFlagSet("import-rates", flag.ExitOnError)
+	format := flags.String("format", "auto", "rate file format: auto, csv, ecb or ptax")
+	dryRun := flags.Bool("dry-run", false, "only show the changes, without writing them")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: import-rates [-format auto|csv|ecb|ptax] [-dry-run] file...")
+	}
+
+	// Read the files before changing the working directory
+	var quotes []fx.Quote
+	for _, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		parsed, err := fx.Parse(*format, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(name), err)
+		}
+		quotes = append(quotes, parsed...)
+	}
+
+	// Change the working directory to the project root, where .env is
+	if err := os.Chdir("../../"); err != nil {
+		return err
+	}
+
+	result, err := fx.Import(db.GetDB(), quotes, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
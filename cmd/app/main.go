@@ -3,6 +3,7 @@ package main
 import (
 	"finanapp/config"
 	"finanapp/internal/db"
+	"finanapp/internal/handlers"
 	"finanapp/internal/messaging"
 	"finanapp/internal/routes"
 
@@ -15,6 +16,14 @@ import (
 )
 
 func main() {
+	// Subcommands run and exit without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "import-rates" {
+		if err := runImportRates(os.Args[2:]); err != nil {
+			log.Fatalf("Error importing exchange rates: %v", err)
+		}
+		return
+	}
+
 	// Change the working directory to the project root
 	err := os.Chdir("../../") // Change the working directory to the project root
 	if err != nil {
@@ -49,10 +58,13 @@ func main() {
 	log.Printf("NSQ Consumer running")
 	go consumer.StartConsumer()
 
+	// Purge the items that stayed in the trash longer than the retention
+	handlers.StartTrashRetention(cfg.TrashRetentionDays)
+
 	// CORS middleware configuration
 	corsMiddleware := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Content-Type", "Authorization"},
 		AllowCredentials: true,
 	})
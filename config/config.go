@@ -4,14 +4,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
 
 // Config structure to store configuration values
 type Config struct {
-	Port        string
-	DatabaseURL string
+	Port               string
+	DatabaseURL        string
+	TrashRetentionDays int // days deleted items stay in the trash, 0 uses the default
 }
 
 // Load loads the environment variables
@@ -30,8 +32,12 @@ func Load() (*Config, error) {
 		log.Printf("Warning: .env not found at path: %v", envFilePath)
 	}
 
+	// Invalid or missing values fall back to the default retention
+	retentionDays, _ := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+
 	return &Config{
-		Port:        os.Getenv("PORT"), // Agora falha se PORT não estiver definido
-    	DatabaseURL: os.Getenv("DATABASE_URL"),
+		Port:               os.Getenv("PORT"), // Agora falha se PORT não estiver definido
+		DatabaseURL:        os.Getenv("DATABASE_URL"),
+		TrashRetentionDays: retentionDays,
 	}, nil
 }
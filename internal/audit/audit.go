@@ -0,0 +1,62 @@
+// Package audit compares the row snapshots saved in the audit log, to show what each change did
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// ChangedFields lists, sorted, the fields whose value differs between the before and after JSON objects of a row.
+// A missing or null snapshot, as on inserts and deletes, counts as an object without fields.
+func ChangedFields(before, after []byte) ([]string, error) {
+	oldFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for name, value := range oldFields {
+		if other, ok := newFields[name]; !ok || !equal(value, other) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// fields decodes a JSON object keeping the raw values, so numbers keep their exact text
+func fields(data []byte) (map[string]json.RawMessage, error) {
+	values := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return values, nil
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if values == nil { // the JSON was null
+		values = map[string]json.RawMessage{}
+	}
+	return values, nil
+}
+
+// equal compares two JSON values ignoring the formatting, e.g. the spacing inside nested objects
+func equal(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var x, y bytes.Buffer
+	if json.Compact(&x, a) != nil || json.Compact(&y, b) != nil {
+		return false
+	}
+	return bytes.Equal(x.Bytes(), y.Bytes())
+}
@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{"update", `{"id": 1, "amount": 10.50, "note": "a"}`, `{"id": 1, "amount": 12.00, "note": "a"}`, []string{"amount"}},
+		{"same number text", `{"amount": 10.50}`, `{"amount": 10.50}`, []string{}},
+		{"nested formatting", `{"meta": {"a": 1}}`, `{"meta":{"a":1}}`, []string{}},
+		{"insert", ``, `{"id": 1, "name": "Rent"}`, []string{"id", "name"}},
+		{"delete", `{"id": 1, "name": "Rent"}`, `null`, []string{"id", "name"}},
+		{"added and removed", `{"a": 1, "b": 2}`, `{"b": 2, "c": 3}`, []string{"a", "c"}},
+		{"to null", `{"deletedat": "2025-01-01T10:00:00"}`, `{"deletedat": null}`, []string{"deletedat"}},
+	}
+	for _, tt := range tests {
+		got, err := ChangedFields([]byte(tt.before), []byte(tt.after))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChangedFieldsInvalid(t *testing.T) {
+	if _, err := ChangedFields([]byte(`{"a": 1}`), []byte(`[1]`)); err == nil {
+		t.Error("expected an error for a snapshot that isn't an object")
+	}
+}
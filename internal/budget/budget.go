@@ -0,0 +1,146 @@
+// Package budget computes how much of a category budget is consumed and which alert thresholds were crossed.
+package budget
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Budget periods
+const (
+	Monthly = "monthly"
+	Yearly  = "yearly"
+)
+
+// DefaultThresholds are the alert thresholds, in percent of the available amount, when none are configured
+var DefaultThresholds = []int{80, 100}
+
+// Budget is the spending limit of a UserCategory
+type Budget struct {
+	BudgetID   int
+	CategoryID int
+	Period     string
+	Amount     float64
+	Rollover   bool      // the unspent amount of a period is added to the next one
+	Thresholds []int     // percentages of the available amount that raise an alert
+	Start      time.Time // the first period is the one containing Start
+}
+
+// Validate checks the budget parameters
+func (b Budget) Validate() error {
+	if b.Period != Monthly && b.Period != Yearly {
+		return fmt.Errorf("period must be %s or %s", Monthly, Yearly)
+	}
+	if b.Amount <= 0 {
+		return fmt.Errorf("amount must be greater than zero")
+	}
+	for _, t := range b.Thresholds {
+		if t <= 0 || t > 1000 {
+			return fmt.Errorf("thresholds must be between 1 and 1000 percent")
+		}
+	}
+	return nil
+}
+
+// PeriodStart returns the first day of the period containing on
+func PeriodStart(period string, on time.Time) time.Time {
+	if period == Yearly {
+		return time.Date(on.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(on.Year(), on.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// nextPeriod returns the first day of the period after the one starting on start
+func nextPeriod(period string, start time.Time) time.Time {
+	if period == Yearly {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// Spend is an actual booked in the budget category, already in the budget currency
+type Spend struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Status is the consumption of a budget in the period containing a date
+type Status struct {
+	BudgetID    int     `json:"budgetId"`
+	CategoryID  int     `json:"categoryId"`
+	Period      string  `json:"period"`
+	PeriodStart string  `json:"periodStart"`
+	PeriodEnd   string  `json:"periodEnd"`
+	Limit       float64 `json:"limit"`
+	CarriedOver float64 `json:"carriedOver"`
+	Available   float64 `json:"available"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	PercentUsed float64 `json:"percentUsed"`
+	Crossed     []int   `json:"crossedThresholds"`
+}
+
+// Evaluate computes the status of the budget in the period containing on. With rollover, the unspent amount
+// of every period since the start is carried to the next one; overspending is not carried.
+func Evaluate(b Budget, spends []Spend, on time.Time) Status {
+	current := PeriodStart(b.Period, on)
+	spent := map[time.Time]float64{}
+	for _, s := range spends {
+		spent[PeriodStart(b.Period, s.Date)] += s.Amount
+	}
+
+	carried := 0.0
+	if b.Rollover {
+		for p := PeriodStart(b.Period, b.Start); p.Before(current); p = nextPeriod(b.Period, p) {
+			carried = math.Max(carried+b.Amount-spent[p], 0)
+		}
+	}
+
+	st := Status{
+		BudgetID:    b.BudgetID,
+		CategoryID:  b.CategoryID,
+		Period:      b.Period,
+		PeriodStart: current.Format("2006-01-02"),
+		PeriodEnd:   nextPeriod(b.Period, current).AddDate(0, 0, -1).Format("2006-01-02"),
+		Limit:       b.Amount,
+		CarriedOver: round2(carried),
+		Available:   round2(b.Amount + carried),
+		Spent:       round2(spent[current]),
+		Crossed:     []int{},
+	}
+	st.Remaining = round2(st.Available - st.Spent)
+	st.PercentUsed = round2(st.Spent / st.Available * 100)
+
+	thresholds := b.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = DefaultThresholds
+	}
+	for _, t := range thresholds {
+		if st.PercentUsed >= float64(t) {
+			st.Crossed = append(st.Crossed, t)
+		}
+	}
+	sort.Ints(st.Crossed)
+	return st
+}
+
+// NewAlerts returns the crossed thresholds that weren't alerted yet in the period
+func NewAlerts(st Status, alerted []int) []int {
+	sent := map[int]bool{}
+	for _, t := range alerted {
+		sent[t] = true
+	}
+	var fresh []int
+	for _, t := range st.Crossed {
+		if !sent[t] {
+			fresh = append(fresh, t)
+		}
+	}
+	return fresh
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
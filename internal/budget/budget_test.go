@@ -0,0 +1,59 @@
+package budget
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	b := Budget{Period: Monthly, Amount: 500, Start: day("2025-01-01")}
+	spends := []Spend{
+		{Date: day("2025-03-02"), Amount: 300},
+		{Date: day("2025-03-20"), Amount: 120},
+		{Date: day("2025-04-01"), Amount: 999}, // next month
+	}
+
+	st := Evaluate(b, spends, day("2025-03-25"))
+	if st.Spent != 420 || st.Remaining != 80 || st.PercentUsed != 84 || st.PeriodEnd != "2025-03-31" {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if len(st.Crossed) != 1 || st.Crossed[0] != 80 {
+		t.Errorf("crossed = %v, want [80]", st.Crossed)
+	}
+
+	if fresh := NewAlerts(st, []int{80}); len(fresh) != 0 {
+		t.Errorf("expected no new alert, got %v", fresh)
+	}
+}
+
+func TestEvaluateRollover(t *testing.T) {
+	b := Budget{Period: Monthly, Amount: 100, Rollover: true, Start: day("2025-01-15")}
+	spends := []Spend{
+		{Date: day("2025-01-10"), Amount: 40},  // January: 60 unspent
+		{Date: day("2025-02-10"), Amount: 200}, // February: overspent, nothing carried
+		{Date: day("2025-03-10"), Amount: 70},  // March: 30 unspent
+	}
+
+	st := Evaluate(b, spends, day("2025-04-05"))
+	if st.CarriedOver != 30 || st.Available != 130 || st.Spent != 0 {
+		t.Errorf("unexpected status %+v", st)
+	}
+
+	b.Rollover = false
+	if st := Evaluate(b, spends, day("2025-04-05")); st.CarriedOver != 0 || st.Available != 100 {
+		t.Errorf("expected no rollover, got %+v", st)
+	}
+}
+
+func TestYearly(t *testing.T) {
+	b := Budget{Period: Yearly, Amount: 1000, Thresholds: []int{50, 100}, Start: day("2025-01-01")}
+	st := Evaluate(b, []Spend{{Date: day("2025-02-01"), Amount: 600}, {Date: day("2025-11-30"), Amount: 500}}, day("2025-12-01"))
+	if st.PeriodStart != "2025-01-01" || st.PeriodEnd != "2025-12-31" || len(st.Crossed) != 2 {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
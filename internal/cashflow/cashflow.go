@@ -0,0 +1,160 @@
+// Package cashflow adds up the expected payments of the financial items per month.
+package cashflow
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Kinds of items, by their EntityID
+const (
+	KindInflow  = "inflow"
+	KindTax     = "tax"
+	KindExpense = "expense"
+)
+
+// KindOf returns the kind of the user and asset entities (EntityIDs 5-13), "" for the others
+func KindOf(entityID int) string {
+	switch entityID {
+	case 5, 11: // User Income, Asset Income
+		return KindInflow
+	case 7, 9, 12: // User Income Tax, Asset Tax, Asset Income Tax
+		return KindTax
+	case 6, 8, 10, 13: // User Expense, User Income Expense, Asset Expense, Asset Income Expense
+		return KindExpense
+	}
+	return ""
+}
+
+// Entry is one expected payment of an item
+type Entry struct {
+	ItemID       int
+	ItemName     string
+	EntityID     int
+	EntityType   string
+	ParentItemID *int
+	Date         time.Time
+	Amount       float64
+}
+
+// Item is the total of one item in a month. Parent items carry their children, and their net
+// is reduced by the children's taxes and expenses.
+type Item struct {
+	FinancialUserItemID       int     `json:"financialUserItemId"`
+	FinancialUserItemName     string  `json:"financialUserItemName"`
+	EntityID                  int     `json:"entityId"`
+	EntityType                string  `json:"entityType"`
+	Kind                      string  `json:"kind"`
+	ParentFinancialUserItemID *int    `json:"parentFinancialUserItemId,omitempty"`
+	Amount                    float64 `json:"amount"`
+	Net                       float64 `json:"net"`
+	Children                  []*Item `json:"children,omitempty"`
+}
+
+// Month is the cash flow of one month (YYYY-MM)
+type Month struct {
+	Month       string  `json:"month"`
+	GrossInflow float64 `json:"grossInflow"`
+	Taxes       float64 `json:"taxes"`
+	Expenses    float64 `json:"expenses"`
+	Net         float64 `json:"net"`
+	Items       []*Item `json:"items"`
+}
+
+// Project groups the entries in the given number of months starting on the month of start
+func Project(entries []Entry, start time.Time, months int) []Month {
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	result := make([]Month, months)
+	byItem := make([]map[int]*Item, months)
+	for i := range result {
+		result[i] = Month{Month: first.AddDate(0, i, 0).Format("2006-01"), Items: []*Item{}}
+		byItem[i] = map[int]*Item{}
+	}
+
+	for _, e := range entries {
+		kind := KindOf(e.EntityID)
+		if kind == "" {
+			continue
+		}
+		i := (e.Date.Year()-first.Year())*12 + int(e.Date.Month()) - int(first.Month())
+		if i < 0 || i >= months {
+			continue
+		}
+
+		item, ok := byItem[i][e.ItemID]
+		if !ok {
+			item = &Item{
+				FinancialUserItemID:       e.ItemID,
+				FinancialUserItemName:     e.ItemName,
+				EntityID:                  e.EntityID,
+				EntityType:                e.EntityType,
+				Kind:                      kind,
+				ParentFinancialUserItemID: e.ParentItemID,
+			}
+			byItem[i][e.ItemID] = item
+		}
+		item.Amount += e.Amount
+
+		m := &result[i]
+		switch kind {
+		case KindInflow:
+			m.GrossInflow += e.Amount
+		case KindTax:
+			m.Taxes += e.Amount
+		case KindExpense:
+			m.Expenses += e.Amount
+		}
+	}
+
+	for i := range result {
+		m := &result[i]
+
+		// Attach the children to their parents, children whose parent has no entry in the month stay on top
+		var top []*Item
+		for _, item := range byItem[i] {
+			item.Amount = round2(item.Amount)
+			item.Net = signed(item)
+		}
+		for _, item := range byItem[i] {
+			if item.ParentFinancialUserItemID != nil {
+				if parent, ok := byItem[i][*item.ParentFinancialUserItemID]; ok {
+					parent.Children = append(parent.Children, item)
+					parent.Net = round2(parent.Net + item.Net)
+					continue
+				}
+			}
+			top = append(top, item)
+		}
+
+		for _, item := range byItem[i] {
+			sort.Slice(item.Children, func(a, b int) bool {
+				return item.Children[a].FinancialUserItemID < item.Children[b].FinancialUserItemID
+			})
+		}
+		sort.Slice(top, func(a, b int) bool { return top[a].FinancialUserItemID < top[b].FinancialUserItemID })
+		if top != nil {
+			m.Items = top
+		}
+
+		m.GrossInflow = round2(m.GrossInflow)
+		m.Taxes = round2(m.Taxes)
+		m.Expenses = round2(m.Expenses)
+		m.Net = round2(m.GrossInflow - m.Taxes - m.Expenses)
+	}
+
+	return result
+}
+
+// signed returns the amount as it affects the net: positive for inflows, negative for taxes and expenses
+func signed(item *Item) float64 {
+	if item.Kind == KindInflow {
+		return item.Amount
+	}
+	return -item.Amount
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
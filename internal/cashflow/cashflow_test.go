@@ -0,0 +1,46 @@
+package cashflow
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestProjectChildTaxReducesParentNet(t *testing.T) {
+	salary := 1
+	entries := []Entry{
+		{ItemID: 1, ItemName: "Salary", EntityID: 5, Date: day("2025-01-05"), Amount: 10000},
+		{ItemID: 2, ItemName: "IRPF", EntityID: 7, ParentItemID: &salary, Date: day("2025-01-05"), Amount: 2750},
+		{ItemID: 3, ItemName: "Rent", EntityID: 6, Date: day("2025-01-10"), Amount: 3000},
+		{ItemID: 1, ItemName: "Salary", EntityID: 5, Date: day("2025-02-05"), Amount: 10000},
+		{ItemID: 9, ItemName: "Group", EntityID: 1, Date: day("2025-01-05"), Amount: 500}, // not a user entity
+		{ItemID: 3, ItemName: "Rent", EntityID: 6, Date: day("2025-04-10"), Amount: 3000}, // outside the window
+	}
+
+	months := Project(entries, day("2025-01-20"), 2)
+	if len(months) != 2 || months[0].Month != "2025-01" || months[1].Month != "2025-02" {
+		t.Fatalf("unexpected months %+v", months)
+	}
+
+	jan := months[0]
+	if jan.GrossInflow != 10000 || jan.Taxes != 2750 || jan.Expenses != 3000 || jan.Net != 4250 {
+		t.Errorf("unexpected January totals %+v", jan)
+	}
+	if len(jan.Items) != 2 {
+		t.Fatalf("expected the tax nested under the salary, got %d top items", len(jan.Items))
+	}
+	if salaryItem := jan.Items[0]; salaryItem.Net != 7250 || len(salaryItem.Children) != 1 {
+		t.Errorf("unexpected salary drill-down %+v", salaryItem)
+	}
+	if rent := jan.Items[1]; rent.Net != -3000 {
+		t.Errorf("unexpected rent net %v", rent.Net)
+	}
+
+	if feb := months[1]; feb.Net != 10000 || len(feb.Items) != 1 {
+		t.Errorf("unexpected February %+v", feb)
+	}
+}
@@ -41,6 +41,15 @@ CREATE TABLE TaxType (
 	CONSTRAINT FK_TaxType_Entity FOREIGN KEY (EntityID) REFERENCES Entity(EntityID) ON DELETE CASCADE
 );
 
+-- Tax Type Rule (JSON definition used to compute the tax amount, see internal/tax)
+CREATE TABLE TaxTypeRule (
+    TaxTypeRuleID SERIAL PRIMARY KEY,
+    TaxTypeID INT NOT NULL UNIQUE, -- FK TaxType
+    RuleDefinition JSONB NOT NULL, -- {"kind": "flat" | "fixed" | "progressive", ...}
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_TaxTypeRule_TaxType FOREIGN KEY (TaxTypeID) REFERENCES TaxType(TaxTypeID) ON DELETE CASCADE
+);
+
 -- Expense Type
 CREATE TABLE ExpenseType (
     ExpenseTypeID SERIAL PRIMARY KEY,
@@ -75,9 +84,11 @@ CREATE TABLE Currency (
 CREATE TABLE CurrencyExchangeRate (
     CurrencyExchangeRateID SERIAL PRIMARY KEY,
 	CurrencyID INT NOT NULL, --FK Currency
-	ExchangeRateValue DECIMAL(15,2) NOT NULL, 
+	ExchangeRateValue DECIMAL(18,8) NOT NULL, -- Units of the currency for one USD
+	ExchangeRateDate DATE NOT NULL DEFAULT CURRENT_DATE, -- The rate is effective from this date until the next one
 	CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-	CONSTRAINT FK_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID) ON DELETE CASCADE
+	CONSTRAINT FK_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID) ON DELETE CASCADE,
+	CONSTRAINT UQ_CurrencyExchangeRate_CurrencyDate UNIQUE (CurrencyID, ExchangeRateDate)
 
 );
 CREATE TABLE Recurrency (
@@ -106,6 +117,53 @@ CREATE TABLE UserProfile (
     CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- User Tax Profile (personal data used by the Brazilian IRPF withholding)
+CREATE TABLE UserTaxProfile (
+    UserTaxProfileID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL UNIQUE, -- FK UserProfile
+    IRPFDependents INT NOT NULL DEFAULT 0 CHECK (IRPFDependents >= 0),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserTaxProfile_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE
+);
+
+
+-- Household Groups: users sharing Group Income / Group Expense items (FinancialUserItem with EntityIDs 1-4 and UserEntityID = UserGroupID)
+CREATE TABLE UserGroup (
+    UserGroupID SERIAL PRIMARY KEY,
+    UserGroupName VARCHAR(100) NOT NULL,
+    CreatedByUserProfileID INT NOT NULL, -- FK UserProfile
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserGroup_UserProfile FOREIGN KEY (CreatedByUserProfileID) REFERENCES UserProfile(UserProfileID)
+);
+
+-- Group members and their role: owner (manages the group and its members), member (manages the items), viewer (read only)
+CREATE TABLE UserGroupMember (
+    UserGroupMemberID SERIAL PRIMARY KEY,
+    UserGroupID INT NOT NULL, -- FK UserGroup
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    GroupRole VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (GroupRole IN ('owner', 'member', 'viewer')),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserGroupMember_UserGroup FOREIGN KEY (UserGroupID) REFERENCES UserGroup(UserGroupID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserGroupMember_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserGroupMember_GroupUser UNIQUE (UserGroupID, UserProfileID)
+);
+
+-- Group invites, accepted by the user with the invited email address
+CREATE TABLE UserGroupInvite (
+    UserGroupInviteID SERIAL PRIMARY KEY,
+    UserGroupID INT NOT NULL, -- FK UserGroup
+    InvitedEmailAddress VARCHAR(255) NOT NULL,
+    InvitedByUserProfileID INT NOT NULL, -- FK UserProfile
+    GroupRole VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (GroupRole IN ('owner', 'member', 'viewer')),
+    InviteToken VARCHAR(64) NOT NULL UNIQUE,
+    InviteStatus VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (InviteStatus IN ('pending', 'accepted', 'declined', 'revoked')),
+    ExpiresAt TIMESTAMP NOT NULL,
+    RespondedAt TIMESTAMP,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserGroupInvite_UserGroup FOREIGN KEY (UserGroupID) REFERENCES UserGroup(UserGroupID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserGroupInvite_UserProfile FOREIGN KEY (InvitedByUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE
+);
+
 
 -- Asset Management. as Asset is a complex entity on it's own, will be manage separately. On MVP, assets will either be represented on one or other. If it's owned buy the user
 
@@ -123,6 +181,75 @@ CREATE TABLE UserAsset (
     CONSTRAINT FK_UserAsset_AssetType FOREIGN KEY (AssetTypeID) REFERENCES AssetType(AssetTypeID)
 );
 
+-- Asset valuation history. UserAsset.UserAssetValueAmount keeps the latest valuation, this table keeps all of them
+CREATE TABLE UserAssetValuation (
+    UserAssetValuationID SERIAL PRIMARY KEY,
+    UserAssetID INT NOT NULL, -- FK UserAsset
+    UserAssetValuationDate DATE NOT NULL,
+    UserAssetValuationAmount DECIMAL(15,2) NOT NULL CHECK (UserAssetValuationAmount >= 0),
+    Note VARCHAR(255),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserAssetValuation_UserAsset FOREIGN KEY (UserAssetID) REFERENCES UserAsset(UserAssetID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserAssetValuation_AssetDate UNIQUE (UserAssetID, UserAssetValuationDate)
+);
+
+-- How the value of an asset changes between valuations. Assets without a row are manual
+CREATE TABLE UserAssetValuationModel (
+    UserAssetValuationModelID SERIAL PRIMARY KEY,
+    UserAssetID INT NOT NULL UNIQUE, -- FK UserAsset
+    ValuationMethod VARCHAR(30) NOT NULL DEFAULT 'manual', -- manual, straight_line, declining_balance, fixed_appreciation
+    ValuationRate DECIMAL(7,4), -- Yearly rate for declining_balance and fixed_appreciation (0.15 = 15%)
+    UsefulLifeYears INT, -- straight_line only
+    SalvageValue DECIMAL(15,2) NOT NULL DEFAULT 0,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserAssetValuationModel_UserAsset FOREIGN KEY (UserAssetID) REFERENCES UserAsset(UserAssetID) ON DELETE CASCADE
+);
+
+-- Securities traded by the user, identified by ticker, and their market prices (imported from CSV files)
+CREATE TABLE UserSecurity (
+    UserSecurityID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    Ticker VARCHAR(20) NOT NULL,
+    SecurityName VARCHAR(100),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserSecurity_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserSecurity_UserTicker UNIQUE (UserProfileID, Ticker)
+);
+
+CREATE TABLE UserSecurityPrice (
+    UserSecurityPriceID SERIAL PRIMARY KEY,
+    UserSecurityID INT NOT NULL, -- FK UserSecurity
+    PriceDate DATE NOT NULL,
+    ClosePrice DECIMAL(15,6) NOT NULL CHECK (ClosePrice >= 0),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserSecurityPrice_UserSecurity FOREIGN KEY (UserSecurityID) REFERENCES UserSecurity(UserSecurityID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserSecurityPrice_SecurityDate UNIQUE (UserSecurityID, PriceDate)
+);
+
+-- Holdings of an investment asset (AssetType 2). The asset value is derived from them instead of typed in
+CREATE TABLE UserAssetHolding (
+    UserAssetHoldingID SERIAL PRIMARY KEY,
+    UserAssetID INT NOT NULL, -- FK UserAsset
+    UserSecurityID INT NOT NULL, -- FK UserSecurity
+    CostMethod VARCHAR(10) NOT NULL DEFAULT 'average' CHECK (CostMethod IN ('average', 'fifo')), -- average cost is the Brazilian default
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserAssetHolding_UserAsset FOREIGN KEY (UserAssetID) REFERENCES UserAsset(UserAssetID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserAssetHolding_UserSecurity FOREIGN KEY (UserSecurityID) REFERENCES UserSecurity(UserSecurityID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserAssetHolding_AssetSecurity UNIQUE (UserAssetID, UserSecurityID)
+);
+
+CREATE TABLE UserAssetHoldingTransaction (
+    UserAssetHoldingTransactionID SERIAL PRIMARY KEY,
+    UserAssetHoldingID INT NOT NULL, -- FK UserAssetHolding
+    TransactionDate DATE NOT NULL,
+    TransactionSide VARCHAR(4) NOT NULL CHECK (TransactionSide IN ('buy', 'sell')),
+    Quantity DECIMAL(20,8) NOT NULL CHECK (Quantity > 0),
+    UnitPrice DECIMAL(15,6) NOT NULL CHECK (UnitPrice >= 0),
+    Fees DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (Fees >= 0),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserAssetHoldingTransaction_UserAssetHolding FOREIGN KEY (UserAssetHoldingID) REFERENCES UserAssetHolding(UserAssetHoldingID) ON DELETE CASCADE
+);
+
 
 
 -- User Category
@@ -140,6 +267,32 @@ CREATE TABLE UserCategory (
 	CONSTRAINT FK_UserCategory_Entity FOREIGN KEY (EntityID) REFERENCES Entity(EntityID) ON DELETE CASCADE
 );
 
+-- User Category Budget: spending limit of a category per month or year, consumed by the actuals in the category
+CREATE TABLE UserCategoryBudget (
+    UserCategoryBudgetID SERIAL PRIMARY KEY,
+    UserCategoryID INT NOT NULL UNIQUE, -- FK UserCategory
+    BudgetPeriod VARCHAR(10) NOT NULL DEFAULT 'monthly', -- monthly, yearly
+    BudgetAmount DECIMAL(15,2) NOT NULL CHECK (BudgetAmount > 0),
+    CurrencyID INT NOT NULL, -- FK Currency
+    BudgetRollover BOOLEAN NOT NULL DEFAULT FALSE, -- Unspent amount is added to the next period
+    AlertThresholds INT[] NOT NULL DEFAULT '{80,100}', -- Percentages of the available amount that raise an alert
+    BudgetStartDate DATE NOT NULL DEFAULT CURRENT_DATE, -- Rollover starts on the period of this date
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserCategoryBudget_UserCategory FOREIGN KEY (UserCategoryID) REFERENCES UserCategory(UserCategoryID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserCategoryBudget_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID)
+);
+
+-- Budget alerts already published, so each threshold is only alerted once per period
+CREATE TABLE UserCategoryBudgetAlert (
+    UserCategoryBudgetAlertID SERIAL PRIMARY KEY,
+    UserCategoryBudgetID INT NOT NULL, -- FK UserCategoryBudget
+    BudgetPeriodStart DATE NOT NULL,
+    AlertThreshold INT NOT NULL,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserCategoryBudgetAlert_UserCategoryBudget FOREIGN KEY (UserCategoryBudgetID) REFERENCES UserCategoryBudget(UserCategoryBudgetID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserCategoryBudgetAlert UNIQUE (UserCategoryBudgetID, BudgetPeriodStart, AlertThreshold)
+);
+
 
 -- Financial User Item -- This is the custom name users can give to a Income, Expense, Asset, Asset Income, or Asset Expense
 CREATE TABLE FinancialUserItem (
@@ -152,7 +305,11 @@ CREATE TABLE FinancialUserItem (
 							 --If EntityItemTypeName="Income" then IncomeTypeID from IncomeType Table
 							 --If EntityItemTypeName="Expense" then ExpenseTypeID from ExpenseType Table
 	ParentFinancialUserItemID INT, -- This will reference cases where the item is a child. (e.g.: A "User Income" has a "Tax" as child, the Tax User Item would refer here to which "Income" it's tied to)
+	RecurrencyRule TEXT, -- Optional RFC 5545 RRULE (e.g.: FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=5) for schedules the Recurrency table can't express
+	RecurrencyEndDate DATE, -- Last date the item repeats on, NULL means it keeps repeating
 	IsActive BOOLEAN NOT NULL DEFAULT TRUE,
+	DeletedAt TIMESTAMP, -- Set while the item is in the trash, with IsActive FALSE. Purged after the retention days
+	DeletedWasActive BOOLEAN, -- IsActive before the item went to the trash, set back on restore
     CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 	CONSTRAINT FK_FinancialUserItem_Entity FOREIGN KEY (EntityID) REFERENCES Entity(EntityID) ON DELETE CASCADE,
 	CONSTRAINT FK_FinancialUserItem_Recurrency FOREIGN KEY (RecurrencyID) REFERENCES Recurrency(RecurrencyID) ON DELETE CASCADE,
@@ -202,4 +359,308 @@ CREATE TABLE UserForecastActualRelation(
 	CONSTRAINT FK_UserForecastActualRelation_UserFinancialActual FOREIGN KEY (UserFinancialActualID) REFERENCES UserFinancialActual(UserFinancialActualID),
 	CONSTRAINT FK_UserForecastActualRelation_UserFinancialForecast FOREIGN KEY (UserFinancialForecastID) REFERENCES UserFinancialForecast(UserFinancialForecastID)
 
-);
\ No newline at end of file
+);
+
+-- Split Expenses: an expense paid by one user and divided between several users (equal parts, percentages, shares or exact amounts)
+CREATE TABLE SplitExpense (
+    SplitExpenseID SERIAL PRIMARY KEY,
+    SplitExpenseName VARCHAR(255) NOT NULL,
+    PaidByUserProfileID INT NOT NULL, -- FK UserProfile
+    CreatedByUserProfileID INT NOT NULL, -- FK UserProfile
+    UserGroupID INT, -- Optional FK UserGroup, balances can be settled inside a household
+    FinancialUserItemID INT, -- Optional FK to the payer's expense item
+    SplitExpenseAmount DECIMAL(15,2) NOT NULL CHECK (SplitExpenseAmount > 0),
+    CurrencyID INT NOT NULL, -- FK Currency
+    SplitExpenseDate DATE NOT NULL,
+    SplitMode VARCHAR(10) NOT NULL CHECK (SplitMode IN ('equal', 'percentage', 'shares', 'exact')),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_SplitExpense_PaidBy FOREIGN KEY (PaidByUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitExpense_CreatedBy FOREIGN KEY (CreatedByUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitExpense_UserGroup FOREIGN KEY (UserGroupID) REFERENCES UserGroup(UserGroupID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitExpense_FinancialUserItem FOREIGN KEY (FinancialUserItemID) REFERENCES FinancialUserItem(FinancialUserItemID) ON DELETE SET NULL,
+    CONSTRAINT FK_SplitExpense_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID)
+);
+
+-- The portion of a split expense each participant is responsible for
+CREATE TABLE SplitExpenseShare (
+    SplitExpenseShareID SERIAL PRIMARY KEY,
+    SplitExpenseID INT NOT NULL, -- FK SplitExpense
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    ShareValue DECIMAL(15,4), -- Percentage, number of shares or exact amount given on creation, NULL for equal parts
+    ShareAmount DECIMAL(15,2) NOT NULL,
+    CONSTRAINT FK_SplitExpenseShare_SplitExpense FOREIGN KEY (SplitExpenseID) REFERENCES SplitExpense(SplitExpenseID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitExpenseShare_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT UQ_SplitExpenseShare_ExpenseUser UNIQUE (SplitExpenseID, UserProfileID)
+);
+
+-- Settle-up payments between two users, each side gets a matching actual
+CREATE TABLE SplitSettlement (
+    SplitSettlementID SERIAL PRIMARY KEY,
+    FromUserProfileID INT NOT NULL, -- FK UserProfile, who pays
+    ToUserProfileID INT NOT NULL, -- FK UserProfile, who receives
+    UserGroupID INT, -- Optional FK UserGroup
+    SettlementAmount DECIMAL(15,2) NOT NULL CHECK (SettlementAmount > 0),
+    CurrencyID INT NOT NULL, -- FK Currency
+    SettlementDate DATE NOT NULL,
+    FromUserFinancialActualID INT, -- FK UserFinancialActual booked as an expense of the payer
+    ToUserFinancialActualID INT, -- FK UserFinancialActual booked as an income of the receiver
+    CreatedByUserProfileID INT NOT NULL, -- FK UserProfile
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_SplitSettlement_From FOREIGN KEY (FromUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitSettlement_To FOREIGN KEY (ToUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitSettlement_UserGroup FOREIGN KEY (UserGroupID) REFERENCES UserGroup(UserGroupID) ON DELETE CASCADE,
+    CONSTRAINT FK_SplitSettlement_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID),
+    CONSTRAINT FK_SplitSettlement_FromActual FOREIGN KEY (FromUserFinancialActualID) REFERENCES UserFinancialActual(UserFinancialActualID) ON DELETE SET NULL,
+    CONSTRAINT FK_SplitSettlement_ToActual FOREIGN KEY (ToUserFinancialActualID) REFERENCES UserFinancialActual(UserFinancialActualID) ON DELETE SET NULL,
+    CONSTRAINT CK_SplitSettlement_Users CHECK (FromUserProfileID <> ToUserProfileID)
+);
+
+-- Consent to split expenses outside a group. A user asks by email, which never tells if the email has an account,
+-- and the invited user accepts or declines. Only accepted contacts can be named in each other's split expenses and
+-- settlements without a group.
+CREATE TABLE UserSplitContact (
+    UserSplitContactID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile, who asked
+    InvitedEmailAddress VARCHAR(255) NOT NULL,
+    ContactUserProfileID INT, -- FK UserProfile, the invited user once they respond
+    ContactStatus VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (ContactStatus IN ('pending', 'accepted', 'declined')),
+    RespondedAt TIMESTAMP,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserSplitContact_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserSplitContact_Contact FOREIGN KEY (ContactUserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE
+);
+
+
+-- Loans and Mortgages: the amortization table of a loan is generated as the forecasts of its FinancialUserItem
+CREATE TABLE UserLoan (
+    UserLoanID SERIAL PRIMARY KEY,
+    FinancialUserItemID INT NOT NULL UNIQUE, -- FK Financial User Item (User Expense or Asset Expense)
+    LoanPrincipal DECIMAL(15,2) NOT NULL CHECK (LoanPrincipal > 0),
+    LoanAnnualRate DECIMAL(9,4) NOT NULL CHECK (LoanAnnualRate >= 0), -- Effective annual rate in percent
+    LoanTermMonths INT NOT NULL CHECK (LoanTermMonths > 0),
+    LoanStartDate DATE NOT NULL, -- The first installment is due one month later
+    AmortizationSystem VARCHAR(15) NOT NULL CHECK (AmortizationSystem IN ('price', 'sac', 'interest_only')),
+    CurrencyID INT NOT NULL, -- FK Currency
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserLoan_FinancialUserItem FOREIGN KEY (FinancialUserItemID) REFERENCES FinancialUserItem(FinancialUserItemID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserLoan_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID)
+);
+
+-- Extra principal payments, each either shortening the term or reducing the installments
+CREATE TABLE UserLoanPrepayment (
+    UserLoanPrepaymentID SERIAL PRIMARY KEY,
+    UserLoanID INT NOT NULL, -- FK UserLoan
+    PrepaymentDate DATE NOT NULL,
+    PrepaymentAmount DECIMAL(15,2) NOT NULL CHECK (PrepaymentAmount > 0),
+    PrepaymentMode VARCHAR(20) NOT NULL CHECK (PrepaymentMode IN ('shorten_term', 'reduce_installment')),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserLoanPrepayment_UserLoan FOREIGN KEY (UserLoanID) REFERENCES UserLoan(UserLoanID) ON DELETE CASCADE
+);
+
+-- Savings goals: a target amount to reach by a date. The contributions are the actuals of the goal item and, when
+-- a category is linked, the actuals of that category. When an asset is linked, its value is the amount saved.
+CREATE TABLE UserSavingsGoal (
+    UserSavingsGoalID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    GoalName VARCHAR(255) NOT NULL,
+    TargetAmount DECIMAL(15,2) NOT NULL CHECK (TargetAmount > 0),
+    TargetDate DATE NOT NULL,
+    CurrencyID INT NOT NULL, -- FK Currency
+    FinancialUserItemID INT, -- FK Financial User Item (User Expense) the contributions are booked on, created on the first one
+    UserAssetID INT, -- FK UserAsset
+    UserCategoryID INT, -- FK UserCategory
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserSavingsGoal_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserSavingsGoal_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID),
+    CONSTRAINT FK_UserSavingsGoal_FinancialUserItem FOREIGN KEY (FinancialUserItemID) REFERENCES FinancialUserItem(FinancialUserItemID) ON DELETE SET NULL,
+    CONSTRAINT FK_UserSavingsGoal_UserAsset FOREIGN KEY (UserAssetID) REFERENCES UserAsset(UserAssetID) ON DELETE SET NULL,
+    CONSTRAINT FK_UserSavingsGoal_UserCategory FOREIGN KEY (UserCategoryID) REFERENCES UserCategory(UserCategoryID) ON DELETE SET NULL
+);
+
+-- Statement imports (OFX, CSV): the lines of a file wait in ImportedTransaction as pending actuals until the user commits them
+CREATE TABLE ImportBatch (
+    ImportBatchID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    ImportSource VARCHAR(10) NOT NULL, -- ofx, csv
+    FileName VARCHAR(255),
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CommittedAt TIMESTAMP, -- Set once no line is pending
+    CONSTRAINT FK_ImportBatch_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE
+);
+
+CREATE TABLE ImportedTransaction (
+    ImportedTransactionID SERIAL PRIMARY KEY,
+    ImportBatchID INT NOT NULL, -- FK ImportBatch
+    UserProfileID INT NOT NULL, -- FK UserProfile, external IDs are unique per user and account
+    AccountKey VARCHAR(100) NOT NULL DEFAULT '', -- Bank and account of the statement ('BANKID/ACCTID' for OFX, the profile account for CSV)
+    ExternalID VARCHAR(255) NOT NULL, -- OFX FITID, or a hash of the line when the file has none
+    TransactionDate DATE NOT NULL,
+    TransactionAmount DECIMAL(15,2) NOT NULL, -- Negative for debits
+    TransactionDescription VARCHAR(255) NOT NULL,
+    TransactionType VARCHAR(20), -- OFX TRNTYPE
+    CurrencyID INT NOT NULL, -- FK Currency
+    FinancialUserItemID INT, -- FK Financial User Item, suggested on import and changeable until committed
+    UserCategoryID INT, -- FK UserCategory
+    MatchSource VARCHAR(50), -- What suggested the item: history, item_name or rule:<UserCategorizationRuleID>
+    TransactionNote VARCHAR(255), -- Set by a rule, booked as the note of the actual instead of the description
+    ImportStatus VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (ImportStatus IN ('pending', 'committed', 'skipped')),
+    UserFinancialActualID INT, -- FK UserFinancialActual created on commit
+    DuplicateActualID INT, -- FK UserFinancialActual that looks like the same transaction, flagged on import
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_ImportedTransaction_ImportBatch FOREIGN KEY (ImportBatchID) REFERENCES ImportBatch(ImportBatchID) ON DELETE CASCADE,
+    CONSTRAINT FK_ImportedTransaction_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_ImportedTransaction_Currency FOREIGN KEY (CurrencyID) REFERENCES Currency(CurrencyID),
+    CONSTRAINT FK_ImportedTransaction_FinancialUserItem FOREIGN KEY (FinancialUserItemID) REFERENCES FinancialUserItem(FinancialUserItemID) ON DELETE SET NULL,
+    CONSTRAINT FK_ImportedTransaction_UserCategory FOREIGN KEY (UserCategoryID) REFERENCES UserCategory(UserCategoryID) ON DELETE SET NULL,
+    CONSTRAINT FK_ImportedTransaction_UserFinancialActual FOREIGN KEY (UserFinancialActualID) REFERENCES UserFinancialActual(UserFinancialActualID) ON DELETE SET NULL,
+    CONSTRAINT FK_ImportedTransaction_DuplicateActual FOREIGN KEY (DuplicateActualID) REFERENCES UserFinancialActual(UserFinancialActualID) ON DELETE SET NULL,
+    CONSTRAINT UQ_ImportedTransaction_External UNIQUE (UserProfileID, AccountKey, ExternalID)
+);
+
+-- Saved CSV column mappings, one per bank export layout
+CREATE TABLE UserImportProfile (
+    UserImportProfileID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    ProfileName VARCHAR(100) NOT NULL,
+    ProfileMapping JSONB NOT NULL, -- Delimiter, encoding, columns and formats (statement.CSVMapping)
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserImportProfile_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserImportProfile_Name UNIQUE (UserProfileID, ProfileName)
+);
+
+-- Categorization rules, run by priority on imported lines, on new actuals and on demand. Each action is taken from
+-- the first matching rule that sets it.
+CREATE TABLE UserCategorizationRule (
+    UserCategorizationRuleID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    RuleName VARCHAR(100) NOT NULL,
+    RulePriority INT NOT NULL DEFAULT 100, -- Lower runs first
+    DescriptionContains VARCHAR(255), -- Compared without case, accents, digits or punctuation
+    DescriptionRegex VARCHAR(255), -- Case insensitive
+    MinAmount DECIMAL(15,2), -- Amounts are compared without sign
+    MaxAmount DECIMAL(15,2),
+    AmountSign VARCHAR(6) CHECK (AmountSign IN ('debit', 'credit')),
+    AccountKey VARCHAR(100), -- ImportedTransaction.AccountKey, imported lines only
+    UserCategoryID INT, -- FK UserCategory
+    FinancialUserItemID INT, -- FK Financial User Item, imported lines only
+    RuleNote VARCHAR(255),
+    SkipTransaction BOOLEAN NOT NULL DEFAULT FALSE, -- Imported lines only
+    IsActive BOOLEAN NOT NULL DEFAULT TRUE,
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserCategorizationRule_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserCategorizationRule_UserCategory FOREIGN KEY (UserCategoryID) REFERENCES UserCategory(UserCategoryID) ON DELETE CASCADE,
+    CONSTRAINT FK_UserCategorizationRule_FinancialUserItem FOREIGN KEY (FinancialUserItemID) REFERENCES FinancialUserItem(FinancialUserItemID) ON DELETE CASCADE
+);
+
+-- Pairs of actuals that look like the same transaction booked twice, and what the user decided about them. The
+-- actual IDs have no foreign key so a decision outlives the merge that deleted one of them and the pair is never
+-- flagged again.
+CREATE TABLE UserDuplicatePair (
+    UserDuplicatePairID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    FirstActualID INT NOT NULL, -- UserFinancialActual with the lower ID
+    SecondActualID INT NOT NULL, -- UserFinancialActual with the higher ID
+    MatchScore DECIMAL(4,3) NOT NULL, -- From 0 to 1
+    MatchReasons TEXT[] NOT NULL DEFAULT '{}',
+    PairStatus VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (PairStatus IN ('pending', 'merged', 'kept', 'dismissed')),
+    KeptActualID INT, -- Actual left after a merge
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    DecidedAt TIMESTAMP,
+    CONSTRAINT FK_UserDuplicatePair_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE,
+    CONSTRAINT UQ_UserDuplicatePair_Actuals UNIQUE (FirstActualID, SecondActualID),
+    CONSTRAINT CK_UserDuplicatePair_Order CHECK (FirstActualID < SecondActualID)
+);
+
+-- Secret links of the calendar feed of forecast occurrences. Calendar clients can't send the session cookie, so the
+-- token in the URL is the only credential: revoking it stops the feed, and a new token gives a new URL.
+CREATE TABLE UserCalendarFeed (
+    UserCalendarFeedID SERIAL PRIMARY KEY,
+    UserProfileID INT NOT NULL, -- FK UserProfile
+    FeedToken VARCHAR(64) NOT NULL UNIQUE,
+    LastAccessedAt TIMESTAMP,
+    RevokedAt TIMESTAMP, -- NULL while the feed is active
+    CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT FK_UserCalendarFeed_UserProfile FOREIGN KEY (UserProfileID) REFERENCES UserProfile(UserProfileID) ON DELETE CASCADE
+);
+
+-- Append-only history of the changes to the financial data, written by the AuditChange triggers below so the changes
+-- made inside the stored procedures are logged too. The handlers set finanapp.actor_id, finanapp.request_id and
+-- finanapp.source_ip in the transaction of the change; without them (jobs, manual SQL) the actor is the UserProfileID
+-- of the row when it has one. There are no foreign keys so the history outlives the records and the users.
+CREATE TABLE AuditLog (
+    AuditLogID BIGSERIAL PRIMARY KEY,
+    EntityName VARCHAR(50) NOT NULL, -- Table of the record, e.g. FinancialUserItem
+    EntityRecordID INT NOT NULL, -- Primary key of the record
+    AuditAction VARCHAR(6) NOT NULL CHECK (AuditAction IN ('INSERT', 'UPDATE', 'DELETE')),
+    ActorUserProfileID INT, -- UserProfile who made the change, NULL for system changes
+    BeforeData JSONB, -- Row before the change, NULL on INSERT
+    AfterData JSONB, -- Row after the change, NULL on DELETE
+    RequestID VARCHAR(64),
+    SourceIP VARCHAR(45),
+    ChangedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IX_AuditLog_Entity ON AuditLog (EntityName, EntityRecordID, AuditLogID);
+
+-- Logs a row change. TG_ARGV[0] is the primary key column, in lower case as it appears in the JSON of the row, and
+-- TG_ARGV[1] the name of the table saved in EntityName
+CREATE OR REPLACE FUNCTION AuditChange() RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    v_Before JSONB;
+    v_After JSONB;
+    v_Row JSONB;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        v_Before := to_jsonb(OLD);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        v_After := to_jsonb(NEW);
+    END IF;
+
+    -- Updates that didn't change anything aren't history
+    IF v_Before = v_After THEN
+        RETURN NULL;
+    END IF;
+
+    v_Row := COALESCE(v_After, v_Before);
+    INSERT INTO AuditLog (EntityName, EntityRecordID, AuditAction, ActorUserProfileID, BeforeData, AfterData, RequestID, SourceIP)
+    VALUES (
+        TG_ARGV[1],
+        (v_Row ->> TG_ARGV[0])::INT,
+        TG_OP,
+        COALESCE(NULLIF(current_setting('finanapp.actor_id', TRUE), '')::INT, (v_Row ->> 'userprofileid')::INT),
+        v_Before,
+        v_After,
+        NULLIF(current_setting('finanapp.request_id', TRUE), ''),
+        NULLIF(current_setting('finanapp.source_ip', TRUE), '')
+    );
+    RETURN NULL;
+END;
+$$;
+
+-- Keeps the audit log append-only
+CREATE OR REPLACE FUNCTION AuditLogReadOnly() RETURNS TRIGGER
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RAISE EXCEPTION 'AuditLog is append-only, % is not allowed', TG_OP;
+END;
+$$;
+
+CREATE TRIGGER TR_AuditLog_ReadOnly BEFORE UPDATE OR DELETE ON AuditLog
+    FOR EACH ROW EXECUTE FUNCTION AuditLogReadOnly();
+CREATE TRIGGER TR_AuditLog_NoTruncate BEFORE TRUNCATE ON AuditLog
+    FOR EACH STATEMENT EXECUTE FUNCTION AuditLogReadOnly();
+
+CREATE TRIGGER TR_FinancialUserItem_Audit AFTER INSERT OR UPDATE OR DELETE ON FinancialUserItem
+    FOR EACH ROW EXECUTE FUNCTION AuditChange('financialuseritemid', 'FinancialUserItem');
+CREATE TRIGGER TR_UserFinancialForecast_Audit AFTER INSERT OR UPDATE OR DELETE ON UserFinancialForecast
+    FOR EACH ROW EXECUTE FUNCTION AuditChange('userfinancialforecastid', 'UserFinancialForecast');
+CREATE TRIGGER TR_UserFinancialActual_Audit AFTER INSERT OR UPDATE OR DELETE ON UserFinancialActual
+    FOR EACH ROW EXECUTE FUNCTION AuditChange('userfinancialactualid', 'UserFinancialActual');
+CREATE TRIGGER TR_UserAsset_Audit AFTER INSERT OR UPDATE OR DELETE ON UserAsset
+    FOR EACH ROW EXECUTE FUNCTION AuditChange('userassetid', 'UserAsset');
+CREATE TRIGGER TR_UserCategory_Audit AFTER INSERT OR UPDATE OR DELETE ON UserCategory
+    FOR EACH ROW EXECUTE FUNCTION AuditChange('usercategoryid', 'UserCategory');
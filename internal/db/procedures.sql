@@ -23,7 +23,7 @@ STORED PROCEDURE DESCRIPTION:
 
 STORED PROCEDURE NAME: DeleteUserParentIncomecls
 STORED PROCEDURE DESCRIPTION: 
-   Deleta um User Parent Income, e todos seus childs associados.
+   Move um User Parent Income, e todos seus childs associados, para a lixeira (soft delete). Forecasts e actuals são mantidos para o restore.
 
 ---------------------------------------------------------------------------------------------
 ------------------------------------------INCOME CHILD---------------------------------------
@@ -52,8 +52,7 @@ STORED PROCEDURE DESCRIPTION:
 
 STORED PROCEDURE NAME: DeleteUserAssetParentIncome
 STORED PROCEDURE DESCRIPTION: 
-   Deletar o UserAsset. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals (soft deletes são executados pelo UpdateUserAssetParentIncome, pela flag IsActive). Aqui todos os dados são deletados sem possibilidade de recuperação.
-   Todos os items relacionado a user Parent serão deletados.
+   Move o User Asset Parent Income, e todos seus childs associados, para a lixeira (soft delete). Forecasts e actuals são mantidos, o item pode ser restaurado pelo POST /api/trash/{id}/restore até ser purgado pelo job de retenção.
 ---------------------------------------------------------------------------------------------
 ------------------------------------------ASSET CHILD MANAGEMENT-----------------------------
 ---------------------------------------------------------------------------------------------
@@ -68,11 +67,11 @@ STORED PROCEDURE DESCRIPTION:
 
 STORED PROCEDURE NAME: DeleteUserAssetChildIncomeExpense
 STORED PROCEDURE DESCRIPTION: 
-     Deletar um expense child User Asset Income. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals. Não existe opção de soft delete em childs Aqui todos os dados são deletados sem possibilidade de recuperação.
+     Move um expense child User Asset Income para a lixeira (soft delete), mantendo forecasts e actuals. Pode ser restaurado pelo POST /api/trash/{id}/restore.
 
 STORED PROCEDURE NAME: DeleteUserAssetChildIncomeTax
 STORED PROCEDURE DESCRIPTION: 
-     Deletar um tax child User Asset Income. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals. Não existe opção de soft delete em childs Aqui todos os dados são deletados sem possibilidade de recuperação.
+     Move um tax child User Asset Income para a lixeira (soft delete), mantendo forecasts e actuals. Pode ser restaurado pelo POST /api/trash/{id}/restore.
 ---------------------------------------------------------------------------------------------
 ------------------------------------------USER PARENT EXPENSE MANAGEMENT----------------------
 ---------------------------------------------------------------------------------------------
@@ -81,6 +80,26 @@ STORED PROCEDURE DESCRIPTION:
 STORED PROCEDURE NAME: CreateUserParentExpense
 STORED PROCEDURE DESCRIPTION: 
     Procedure para criar um novo parent expense para o usuario. Associado na tabela financialuseritem o novo record e criando (baseado na recurency escolhida) todos os records the forecast associados.
+
+---------------------------------------------------------------------------------------------
+------------------------------------------GROUP MANAGEMENT-----------------------------------
+---------------------------------------------------------------------------------------------
+
+STORED PROCEDURE NAME: CreateGroupParentItem
+STORED PROCEDURE DESCRIPTION: 
+    Procedure para criar um novo Group Income ou Group Expense (EntityID 1 ou 2) para um grupo. Somente owners e members do grupo podem criar. Cria os records the forecast associados (baseado na recurency escolhida), igual ao CreateUserParentIncome.
+
+STORED PROCEDURE NAME: CreateGroupChildItem
+STORED PROCEDURE DESCRIPTION: 
+    Cria um Group Income Tax ou Group Income Expense (EntityID 3 ou 4) associado a um Group Income existente, replicando os forecasts do parent com o valor informado.
+
+STORED PROCEDURE NAME: UpdateGroupParentItem
+STORED PROCEDURE DESCRIPTION: 
+    Altera o nome e o valor dos forecasts a partir de uma data de um Group Income ou Group Expense. Somente owners e members do grupo podem alterar.
+
+STORED PROCEDURE NAME: DeleteGroupItem
+STORED PROCEDURE DESCRIPTION: 
+    Move um item do grupo (parent ou child), e todos seus childs, para a lixeira (soft delete). Forecasts e actuals são mantidos, owners e members do grupo podem restaurar pelo POST /api/trash/{id}/restore.
 */
   
 CREATE OR REPLACE PROCEDURE CreateUser(
@@ -235,10 +254,13 @@ BEGIN
             v_Increment := '1 month'::INTERVAL;
         ELSIF p_RecurrencyID = 3 THEN -- Quarterly
             v_Iterations := 4;
-            v_Increment := '4 months'::INTERVAL;
+            v_Increment := '3 months'::INTERVAL;
         ELSIF p_RecurrencyID = 4 THEN -- Yearly
             v_Iterations := 1;
             v_Increment := '1 year'::INTERVAL;
+        ELSIF p_RecurrencyID IN (5, 6, 7, 8) THEN -- Variable, Daily, Weekly, Biweekly: apenas o primeiro record, o restante é expandido pelo recurrence engine
+            v_Iterations := 1;
+            v_Increment := '1 day'::INTERVAL;
         ELSE
             RAISE EXCEPTION 'Invalid RecurrencyID';
         END IF;
@@ -259,7 +281,7 @@ BEGIN
                     WHEN p_RecurrencyID = 2 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Monthly
                     WHEN p_RecurrencyID = 2 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '1 month') - INTERVAL '1 day' -- Último mês
                     WHEN p_RecurrencyID = 3 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Quarterly
-                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '4 months') - INTERVAL '1 day' -- Último trimestre
+                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '3 months') - INTERVAL '1 day' -- Último trimestre
                     WHEN p_RecurrencyID = 4 THEN v_CurrentDate + INTERVAL '1 year' - INTERVAL '1 day' -- Yearly
                 END,
                 p_ParentIncomeAmount, 1
@@ -331,11 +353,14 @@ BEGIN
         RETURN;
     END IF;
 
-    -- Confere se o usuário é dono do financial item
-    IF p_UserID <> (
-        SELECT userentityid 
-        FROM FinancialUserItem 
+    -- Confere se o usuário é dono do financial item (somente itens pessoais fora da lixeira)
+    IF NOT EXISTS (
+        SELECT 1
+        FROM FinancialUserItem
         WHERE FinancialUserItemID = p_FinancialUserItemID
+          AND UserEntityID = p_UserID
+          AND EntityID IN (5, 6, 7, 8)
+          AND DeletedAt IS NULL
     ) THEN
         p_Message := '{"status": "fail", "message": "User does not match FinancialUserItem"}';
         RETURN;
@@ -401,41 +426,49 @@ CREATE OR REPLACE PROCEDURE DeleteUserParentIncome(
 )
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: DeleteUserParentIncome
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 30-Mar-2025
+STORED PROCEDURE VERSION: 2.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
-   Deleta um User Parent Income, e todos seus childs associados.   -- NOTA: PRECISA DE IMPROVEMENTS PARA REFLETIR MESMA LOGICA DO DELETE USER ASSET
+   Move um User Parent Income, e todos seus childs associados, para a lixeira (soft delete): IsActive FALSE e DeletedAt preenchido.
+   Forecasts, actuals e relations são mantidos, o item pode ser restaurado pelo POST /api/trash/{id}/restore até ser purgado pelo job de retenção.
 STORED PROCEDURE TEST CASE(S):
 
+call DeleteUserParentIncome (66,13,'')-- Informando FinancialUserItemID e UserID
 
 BACKEND VISUALIZATION:
-  
+
+select FinancialUserItemID, IsActive, DeletedAt from financialuseritem where FinancialUserItemID=66 or ParentFinancialUserItemID=66
+Deve retornar IsActive FALSE e DeletedAt preenchido no parent e nos childs
+
 USER INTERFACE:
 
+Não devem estar visiveis na UI após o delete, somente na lixeira.
 ----------------------------------------------------*/
 LANGUAGE plpgsql
 AS $$
 BEGIN
-    -- Verifica se o FinancialUserItem existe
-    IF NOT EXISTS (SELECT 1 FROM FinancialUserItem WHERE FinancialUserItemID = p_FinancialUserItemID AND UserEntityID = p_UserID) THEN
+    -- Verifica se o FinancialUserItem existe, é um item do usuário (EntityID 5-8, UserEntityID é o UserProfileID)
+    -- e não está na lixeira. Nos itens de asset e de grupo o UserEntityID é outro ID e não pode ser comparado com o usuário.
+    IF NOT EXISTS (
+        SELECT 1 FROM FinancialUserItem
+        WHERE FinancialUserItemID = p_FinancialUserItemID
+        AND EntityID IN (5, 6, 7, 8)
+        AND UserEntityID = p_UserID
+        AND DeletedAt IS NULL
+    ) THEN
         p_Message := '{"status": "fail", "message": "UserParentIncome not found"}';
         RETURN;
     END IF;
 
-    -- Remove forecasts associadas
-    DELETE FROM UserFinancialForecast WHERE FinancialUserItemID = p_FinancialUserItemID;
-
-    --Remove actuals associados
-
-    DELETE FROM UserFinancialActual WHERE FinancialUserItemID = p_FinancialUserItemID;
-
-    -- Remove o FinancialUserItem Child
-    DELETE FROM FinancialUserItem WHERE parentfinancialuseritemid = p_FinancialUserItemID;
-
-    -- Remove o FinancialUserItem
-    DELETE FROM FinancialUserItem WHERE FinancialUserItemID = p_FinancialUserItemID;
+    -- Move o FinancialUserItem e seus childs para a lixeira, guardando o IsActive para o restore
+    UPDATE FinancialUserItem
+    SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
+    WHERE (FinancialUserItemID = p_FinancialUserItemID OR ParentFinancialUserItemID = p_FinancialUserItemID)
+        AND EntityID IN (5, 6, 7, 8)
+        AND UserEntityID = p_UserID
+        AND DeletedAt IS NULL;
 
-    p_Message := '{"status": "success", "message": "UserParentIncome deleted successfully."}';
+    p_Message := '{"status": "success", "message": "UserParentIncome moved to the trash."}';
 END;
 $$;
 
@@ -560,10 +593,11 @@ CREATE OR REPLACE PROCEDURE CreateUserAsset(
 
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: CreateUserAsset
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 30-Mar-2025
+STORED PROCEDURE VERSION: 1.1
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
     Procedure para criar uma novo asset associado a um usuario. É obrigatório a seleção de um "AssetType" valido para criação.
+    O valor informado também é registrado como a primeira avaliação do asset em UserAssetValuation, na data de aquisição.
 STORED PROCEDURE TEST CASE(S):
 
 CALL CreateUserAsset (1,1,'Novo Apartamento',500000,'2025-01-01',null,'')
@@ -572,6 +606,7 @@ BACKEND VISUALIZATION:
   
 
 select * from userasset where UserProfileID=1 -- Mostra os assets criados para o usuario, onde o novo deve estar sendo listado.
+select * from userassetvaluation where UserAssetID=1 -- Mostra a avaliação inicial do asset
 
 USER INTERFACE:
 
@@ -579,6 +614,8 @@ TBD
 ----------------------------------------------------*/
 LANGUAGE plpgsql
 AS $$
+DECLARE
+    v_UserAssetID INT;
 BEGIN
     -- Validação dos campos obrigatórios
     IF p_AssetTypeID IS NULL THEN
@@ -617,7 +654,12 @@ BEGIN
         p_UserAssetValueAmount, 
         p_UserAssetAcquisitionBeginDate, 
         p_UserAssetAcquisitionEndDate
-    );
+    )
+    RETURNING UserAssetID INTO v_UserAssetID;
+
+    -- Registrar o valor inicial no histórico de avaliações
+    INSERT INTO UserAssetValuation (UserAssetID, UserAssetValuationDate, UserAssetValuationAmount)
+    VALUES (v_UserAssetID, p_UserAssetAcquisitionBeginDate, p_UserAssetValueAmount);
 
     p_Message := '{"status": "success", "message": "UserAsset created successfully."}';
 
@@ -729,10 +771,13 @@ BEGIN
             v_Increment := '1 month'::INTERVAL;
         ELSIF p_RecurrencyID = 3 THEN -- Quarterly
             v_Iterations := 4;
-            v_Increment := '4 months'::INTERVAL;
+            v_Increment := '3 months'::INTERVAL;
         ELSIF p_RecurrencyID = 4 THEN -- Yearly
             v_Iterations := 1;
             v_Increment := '1 year'::INTERVAL;
+        ELSIF p_RecurrencyID IN (5, 6, 7, 8) THEN -- Variable, Daily, Weekly, Biweekly: apenas o primeiro record, o restante é expandido pelo recurrence engine
+            v_Iterations := 1;
+            v_Increment := '1 day'::INTERVAL;
         ELSE
             RAISE EXCEPTION 'Invalid RecurrencyID';
         END IF;
@@ -753,7 +798,7 @@ BEGIN
                     WHEN p_RecurrencyID = 2 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Monthly
                     WHEN p_RecurrencyID = 2 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '1 month') - INTERVAL '1 day' -- Último mês
                     WHEN p_RecurrencyID = 3 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Quarterly
-                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '4 months') - INTERVAL '1 day' -- Último trimestre
+                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '3 months') - INTERVAL '1 day' -- Último trimestre
                     WHEN p_RecurrencyID = 4 THEN v_CurrentDate + INTERVAL '1 year' - INTERVAL '1 day' -- Yearly
                 END,
                 p_ParentIncomeAmount, 1
@@ -777,12 +822,12 @@ $$;
 
 
 -- STORED PROCEDURE NAME: DeleteUserAssetParentIncome
--- STORED PROCEDURE VERSION: 1.0
--- LAST UPDATED: 31-Mar-2025
+-- STORED PROCEDURE VERSION: 2.0
+-- LAST UPDATED: 18-Oct-2026
 -- DESCRIPTION: 
--- Deleta permanentemente o UserAssetParentIncome (hard delete).
--- Remove todo histórico relacionado (forecasts, actuals, relations).
--- Esse procedimento remove todos os itens relacionados ao Parent Asset.
+-- Move o UserAssetParentIncome, e todos seus childs, para a lixeira (soft delete): IsActive FALSE e DeletedAt preenchido.
+-- Forecasts, actuals e relations são mantidos, o item pode ser restaurado pelo POST /api/trash/{id}/restore
+-- até ser purgado pelo job de retenção.
 
 -- TEST CASE:
 -- CALL DeleteUserAssetParentIncome(59, 1, 2, '');
@@ -797,13 +842,14 @@ LANGUAGE plpgsql
 AS $$
 DECLARE 
     v_UserValidation INT;
-    v_ChildCheck INT;
 BEGIN
-    -- Validação: FinancialUserItem existe e pertence ao UserAssetID
+    -- Validação: FinancialUserItem é um parent do UserAssetID e não está na lixeira
     IF NOT EXISTS (
         SELECT 1 FROM FinancialUserItem 
         WHERE FinancialUserItemID = p_FinancialUserItemID 
+        AND EntityID IN (9, 10, 11)
         AND UserEntityID = p_UserAssetID
+        AND DeletedAt IS NULL
     ) THEN
         p_Message := '{"status": "fail", "message": "UserAssetParentIncome not found"}';
         RETURN;
@@ -815,85 +861,19 @@ BEGIN
     FROM UserAsset
     WHERE UserAssetID = p_UserAssetID;
 
-    IF p_UserID <> v_UserValidation THEN
+    IF v_UserValidation IS NULL OR p_UserID <> v_UserValidation THEN
         p_Message := '{"status": "fail", "message": "UserID provided is different from the asset owner"}';
         RETURN;
     END IF;
 
-    BEGIN 
-        -- Verifica se há Childs relacionados ao Parent
-        SELECT parentfinancialuseritemid
-        INTO v_ChildCheck
-        FROM financialuseritem
-        WHERE parentfinancialuseritemid = p_FinancialUserItemID        
-        LIMIT 1;
-    
-        IF v_ChildCheck IS NOT NULL THEN
-            -- Tabelas temporárias para armazenar dados relacionados
-            CREATE TEMP TABLE TempForecasts AS 
-            SELECT f.FinancialUserItemID, uf.UserFinancialForecastID 
-            FROM FinancialUserItem f
-            JOIN UserFinancialForecast uf ON f.FinancialUserItemID = uf.FinancialUserItemID
-            WHERE f.ParentFinancialUserItemID = p_FinancialUserItemID;
-
-            CREATE TEMP TABLE TempActuals AS 
-            SELECT f.FinancialUserItemID, ua.UserFinancialActualID 
-            FROM FinancialUserItem f
-            JOIN UserFinancialActual ua ON f.FinancialUserItemID = ua.FinancialUserItemID
-            WHERE f.ParentFinancialUserItemID = p_FinancialUserItemID;
-
-            -- Deletar relações Forecast-Actual
-            DELETE FROM UserForecastActualRelation
-            WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM TempForecasts)
-               OR UserFinancialActualID IN (SELECT UserFinancialActualID FROM TempActuals);
-
-            -- Deletar Forecasts e Actuals filhos
-            DELETE FROM UserFinancialForecast 
-            WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM TempForecasts);
-
-            DELETE FROM UserFinancialActual 
-            WHERE UserFinancialActualID IN (SELECT UserFinancialActualID FROM TempActuals);
-
-            -- Deletar FinancialUserItem filhos
-            DELETE FROM FinancialUserItem 
-            WHERE FinancialUserItemID IN (SELECT FinancialUserItemID FROM TempForecasts)
-               OR FinancialUserItemID IN (SELECT FinancialUserItemID FROM TempActuals);
-        END IF;
-
-        -- Deletar relações do Parent
-        DELETE FROM UserForecastActualRelation
-        WHERE UserFinancialForecastID IN (
-            SELECT UserFinancialForecastID FROM UserFinancialForecast WHERE FinancialUserItemID = p_FinancialUserItemID
-        )
-        OR UserFinancialActualID IN (
-            SELECT UserFinancialActualID FROM UserFinancialActual WHERE FinancialUserItemID = p_FinancialUserItemID
-        );
-
-        -- Deletar Forecasts e Actuals do Parent
-        DELETE FROM UserFinancialForecast
-        WHERE FinancialUserItemID = p_FinancialUserItemID;
-
-        DELETE FROM UserFinancialActual
-        WHERE FinancialUserItemID = p_FinancialUserItemID;
-
-        -- Deletar FinancialUserItem do Parent
-        DELETE FROM FinancialUserItem 
-        WHERE FinancialUserItemID = p_FinancialUserItemID;
-
-        -- Limpeza
-        DROP TABLE IF EXISTS TempForecasts;
-        DROP TABLE IF EXISTS TempActuals;
-
-        -- Mensagem de sucesso
-        p_Message := '{"status": "success", "message": "User Asset Parent Income and all references deleted successfully."}';
-
-    EXCEPTION 
-        WHEN OTHERS THEN 
-            p_Message := format('{"status": "fail", "message": "An error occurred: %s"}', SQLERRM);
-            DROP TABLE IF EXISTS TempForecasts;
-            DROP TABLE IF EXISTS TempActuals;
-    END;
+    -- Move o Parent e seus childs para a lixeira, guardando o IsActive para o restore
+    UPDATE FinancialUserItem
+    SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
+    WHERE (FinancialUserItemID = p_FinancialUserItemID OR ParentFinancialUserItemID = p_FinancialUserItemID)
+        AND UserEntityID = p_UserAssetID
+        AND DeletedAt IS NULL;
 
+    p_Message := '{"status": "success", "message": "User Asset Parent Income moved to the trash."}';
 END;
 $$;
 
@@ -1113,19 +1093,18 @@ CREATE OR REPLACE PROCEDURE DeleteUserAssetChildIncomeExpense(
 )
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: DeleteUserAssetChildIncomeExpense
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 30-Mar-2025
+STORED PROCEDURE VERSION: 2.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
-     Deletar um expense child User Asset Income. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals. Não existe opção de soft delete em childs Aqui todos os dados são deletados sem possibilidade de recuperação.
+     Move um expense child User Asset Income para a lixeira (soft delete), mantendo forecasts e actuals. Pode ser restaurado pelo POST /api/trash/{id}/restore.
 STORED PROCEDURE TEST CASE(S):
 
 CALL CreateUserAssetChildIncomeTax(60,1,2,'')
 
 BACKEND VISUALIZATION:
   
-select * from financialuseritem where FinancialUserItemID=60
-
---Deve retorna "0" results em todos selects acima
+select FinancialUserItemID, IsActive, DeletedAt from financialuseritem where FinancialUserItemID=60
+Deve retornar IsActive FALSE e DeletedAt preenchido
 
 USER INTERFACE:
 
@@ -1133,54 +1112,28 @@ TBD
 ----------------------------------------------------*/
 LANGUAGE plpgsql
 AS $$
-DECLARE 
-    v_UserValidation INT;
-    v_EntityID INT;
 BEGIN
-    -- Verifica se o FinancialUserItem existe e obtém seu EntityID
-    SELECT EntityID, UserEntityID INTO v_EntityID, v_UserValidation
-    FROM FinancialUserItem
-    WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
-    -- Se o item não existir, retorna erro
-    IF v_EntityID IS NULL THEN
+    -- Verifica se o FinancialUserItem é um expense child do UserAsset informado, do UserID, e não está na lixeira
+    IF NOT EXISTS (
+        SELECT 1 FROM FinancialUserItem fui
+        JOIN UserAsset ua ON ua.UserAssetID = fui.UserEntityID
+        WHERE fui.FinancialUserItemID = p_FinancialUserItemID
+        AND fui.EntityID = 13
+        AND fui.UserEntityID = p_UserAssetID
+        AND ua.UserProfileID = p_UserID
+        AND fui.DeletedAt IS NULL
+    ) THEN
         p_Message := '{"status": "fail", "message": "Child Income Expense not found"}';
         RETURN;
     END IF;
-    
-    -- Verifica se o EntityID é 13 (Expense)
-    IF v_EntityID <> 13 THEN
-        p_Message := '{"status": "fail", "message": "Not an expense type child"}';
-        RETURN;
-    END IF;
-    
-    -- Verifica se o UserAsset pertence ao UserID informado
-    IF p_UserID <> v_UserValidation THEN
-        p_Message := '{"status": "fail", "message": "UserID provided is different from the asset owner"}';
-        RETURN;
-    END IF;
-	-- Deletar Relation records
-    DELETE FROM UserForecastActualRelation
-    WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM userfinancialforecast where financialuseritemid=p_FinancialUserItemID)
-    OR UserFinancialActualID IN (SELECT UserFinancialActualID FROM userfinancialactual where financialuseritemid=p_FinancialUserItemID);
-        
-    -- Deletar registros relacionados na tabela UserFinancialForecast
-    DELETE FROM UserFinancialForecast
-    WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
-    -- Deletar o FinancialUserItem
-    DELETE FROM FinancialUserItem 
+
+    -- Move o child para a lixeira, guardando o IsActive para o restore
+    UPDATE FinancialUserItem
+    SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
     WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
+
     -- Retorna mensagem de sucesso
-    p_Message := '{"status": "success", "message": "Child Asset Income Expense deleted successfully."}';
-    
-EXCEPTION 
-    WHEN OTHERS THEN 
-        p_Message := format(
-            '{"status": "fail", "message": "An error occurred: %s"}', 
-            SQLERRM
-        );
+    p_Message := '{"status": "success", "message": "Child Asset Income Expense moved to the trash."}';
 END;
 $$;
 
@@ -1198,19 +1151,18 @@ CREATE OR REPLACE PROCEDURE DeleteUserAssetChildIncomeTax(
 )
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: DeleteUserAssetChildIncomeTax
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 30-Mar-2025
+STORED PROCEDURE VERSION: 2.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
-     Deletar um tax child User Asset Income. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals. Não existe opção de soft delete em childs Aqui todos os dados são deletados sem possibilidade de recuperação.
+     Move um tax child User Asset Income para a lixeira (soft delete), mantendo forecasts e actuals. Pode ser restaurado pelo POST /api/trash/{id}/restore.
 STORED PROCEDURE TEST CASE(S):
 
 CALL CreateUserAssetChildIncomeTax(60,1,2,'')
 
 BACKEND VISUALIZATION:
   
-select * from financialuseritem where FinancialUserItemID=60
-
---Deve retorna "0" results em todos selects acima
+select FinancialUserItemID, IsActive, DeletedAt from financialuseritem where FinancialUserItemID=60
+Deve retornar IsActive FALSE e DeletedAt preenchido
 
 USER INTERFACE:
 
@@ -1218,54 +1170,28 @@ TBD
 ----------------------------------------------------*/
 LANGUAGE plpgsql
 AS $$
-DECLARE 
-    v_UserValidation INT;
-    v_EntityID INT;
 BEGIN
-    -- Verifica se o FinancialUserItem existe e obtém seu EntityID
-    SELECT EntityID, UserEntityID INTO v_EntityID, v_UserValidation
-    FROM FinancialUserItem
-    WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
-    -- Se o item não existir, retorna erro
-    IF v_EntityID IS NULL THEN
+    -- Verifica se o FinancialUserItem é um tax child do UserAsset informado, do UserID, e não está na lixeira
+    IF NOT EXISTS (
+        SELECT 1 FROM FinancialUserItem fui
+        JOIN UserAsset ua ON ua.UserAssetID = fui.UserEntityID
+        WHERE fui.FinancialUserItemID = p_FinancialUserItemID
+        AND fui.EntityID = 12
+        AND fui.UserEntityID = p_UserAssetID
+        AND ua.UserProfileID = p_UserID
+        AND fui.DeletedAt IS NULL
+    ) THEN
         p_Message := '{"status": "fail", "message": "Child Income Tax not found"}';
         RETURN;
     END IF;
-    
-    -- Verifica se o EntityID é 12 (Expense)
-    IF v_EntityID <> 12 THEN
-        p_Message := '{"status": "fail", "message": "Not an Tax type child"}';
-        RETURN;
-    END IF;
-    
-    -- Verifica se o UserAsset pertence ao UserID informado
-    IF p_UserID <> v_UserValidation THEN
-        p_Message := '{"status": "fail", "message": "UserID provided is different from the asset owner"}';
-        RETURN;
-    END IF;
-	-- Deletar Relation records
-    DELETE FROM UserForecastActualRelation
-    WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM userfinancialforecast where financialuseritemid=p_FinancialUserItemID)
-    OR UserFinancialActualID IN (SELECT UserFinancialActualID FROM userfinancialactual where financialuseritemid=p_FinancialUserItemID);
-        
-    -- Deletar registros relacionados na tabela UserFinancialForecast
-    DELETE FROM UserFinancialForecast
-    WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
-    -- Deletar o FinancialUserItem
-    DELETE FROM FinancialUserItem 
+
+    -- Move o child para a lixeira, guardando o IsActive para o restore
+    UPDATE FinancialUserItem
+    SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
     WHERE FinancialUserItemID = p_FinancialUserItemID;
-    
+
     -- Retorna mensagem de sucesso
-    p_Message := '{"status": "success", "message": "Child Asset Income Tax deleted successfully."}';
-    
-EXCEPTION 
-    WHEN OTHERS THEN 
-        p_Message := format(
-            '{"status": "fail", "message": "An error occurred: %s"}', 
-            SQLERRM
-        );
+    p_Message := '{"status": "success", "message": "Child Asset Income Tax moved to the trash."}';
 END;
 $$;
 
@@ -1363,10 +1289,13 @@ BEGIN
             v_Increment := '1 month'::INTERVAL;
         ELSIF p_RecurrencyID = 3 THEN -- Quarterly
             v_Iterations := 4;
-            v_Increment := '4 months'::INTERVAL;
+            v_Increment := '3 months'::INTERVAL;
         ELSIF p_RecurrencyID = 4 THEN -- Yearly
             v_Iterations := 1;
             v_Increment := '1 year'::INTERVAL;
+        ELSIF p_RecurrencyID IN (5, 6, 7, 8) THEN -- Variable, Daily, Weekly, Biweekly: apenas o primeiro record, o restante é expandido pelo recurrence engine
+            v_Iterations := 1;
+            v_Increment := '1 day'::INTERVAL;
         ELSE
             RAISE EXCEPTION 'Invalid RecurrencyID';
         END IF;
@@ -1387,7 +1316,7 @@ BEGIN
                     WHEN p_RecurrencyID = 2 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Monthly
                     WHEN p_RecurrencyID = 2 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '1 month') - INTERVAL '1 day' -- Último mês
                     WHEN p_RecurrencyID = 3 AND i < v_Iterations THEN v_NextDate - INTERVAL '1 day' -- Quarterly
-                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '4 months') - INTERVAL '1 day' -- Último trimestre
+                    WHEN p_RecurrencyID = 3 AND i = v_Iterations THEN (v_CurrentDate + INTERVAL '3 months') - INTERVAL '1 day' -- Último trimestre
                     WHEN p_RecurrencyID = 4 THEN v_CurrentDate + INTERVAL '1 year' - INTERVAL '1 day' -- Yearly
                 END,
                 p_ParentExpenseAmount, 1
@@ -1418,8 +1347,8 @@ CREATE OR REPLACE PROCEDURE UpdateUserParentExpense(
 )
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: UpdateUserParentExpense
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 31-Mar-2025
+STORED PROCEDURE VERSION: 1.1
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
    Procedure altera valores de Expense baseados na data (utilizados para modificar um valor de um Expense a partir de uma data para frente no forecast)
    Tambe utilizada para inativar (Expense não é mais valido, porém mantem o historico) pela Flag IsActive
@@ -1479,8 +1408,15 @@ BEGIN
         p_Message := '{"status": "fail", "message": "UserParentExpense not found"}';
         RETURN;
     END IF;
-    -- Confere se o user realmente é o dono do financial user item
-    IF p_userID <> (SELECT userentityid FROM FinancialUserItem WHERE FinancialUserItemID = p_FinancialUserItemID) THEN
+    -- Confere se o user realmente é o dono do financial user item (somente itens pessoais fora da lixeira)
+    IF NOT EXISTS (
+        SELECT 1
+        FROM FinancialUserItem
+        WHERE FinancialUserItemID = p_FinancialUserItemID
+          AND UserEntityID = p_UserID
+          AND EntityID IN (5, 6, 7, 8)
+          AND DeletedAt IS NULL
+    ) THEN
        p_Message := '{"status": "fail", "message": "User do not match FinancialuserItem"}';
        RETURN;
     END IF;
@@ -1540,58 +1476,50 @@ CREATE OR REPLACE PROCEDURE DeleteUserParentExpense(
 )
 /* ----------------------------------------------------------------------
 STORED PROCEDURE NAME: DeleteUserParentExpense
-STORED PROCEDURE VERSION: 1.0
-STORED PROCEDURE LAST UPDATED DATE: 31-Mar-2025
+STORED PROCEDURE VERSION: 2.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
 STORED PROCEDURE DESCRIPTION: 
-   Deletar o Parent Expense. Essa procedure é para um hard delete, excluindo todo historico tanto de forecasts e de actuals (soft deletes são executados pelo UpdateUserParentExpense, pela flag IsActive). Aqui todos os dados são deletados sem possibilidade de recuperação.
-   Todos os items relacionado a user Parent serão deletados.
+   Move o Parent Expense, e todos seus childs associados, para a lixeira (soft delete): IsActive FALSE e DeletedAt preenchido.
+   Forecasts, actuals e relations são mantidos, o item pode ser restaurado pelo POST /api/trash/{id}/restore até ser purgado pelo job de retenção.
 STORED PROCEDURE TEST CASE(S):
 
 call DeleteUserParentExpense (66,13,'')-- Informando FinancialUserItemID e UserID
 
 BACKEND VISUALIZATION:
   
-select * from userfinancialactual where FinancialUserItemID=60 -- Selectione o FinancialUserItemID no select
-select * from u userfinancialforecast where FinancialUserItemID=60 -- Selectione o FinancialUserItemID no select
-select * from financialuseritem where FinancialUserItemID=60 -- Selectione o FinancialUserItemID no select
-Deve retorna "0" results em todos selects acima-- Selectione o FinancialUserItemID no select
+select FinancialUserItemID, IsActive, DeletedAt from financialuseritem where FinancialUserItemID=66 or ParentFinancialUserItemID=66
+Deve retornar IsActive FALSE e DeletedAt preenchido no parent e nos childs
 
 USER INTERFACE:
 
-Não devem estar visiveis na UI após o delete.
+Não devem estar visiveis na UI após o delete, somente na lixeira.
 ----------------------------------------------------*/
 LANGUAGE plpgsql
 AS $$
-DECLARE 
-    v_UserValidation INT;
-    v_ChildCheck INT;
 BEGIN
-    -- Verifica se o FinancialUserItem existe
+    -- Verifica se o FinancialUserItem existe, é um item do usuário (EntityID 5-8, UserEntityID é o UserProfileID)
+    -- e não está na lixeira. Nos itens de asset e de grupo o UserEntityID é outro ID e não pode ser comparado com o usuário.
     IF NOT EXISTS (
         SELECT 1 FROM FinancialUserItem 
         WHERE FinancialUserItemID = p_FinancialUserItemID 
+        AND EntityID IN (5, 6, 7, 8)
         AND UserEntityID = p_UserID
+        AND DeletedAt IS NULL
     ) THEN
         p_Message := '{"status": "fail", "message": "UserParentExpense not found"}';
         RETURN;
     END IF;
-     
-        -- Deletar o User Parent Expense da relations
-        DELETE FROM UserForecastActualRelation
-        WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM userfinancialforecast where financialuseritemid=p_FinancialUserItemID)
-        OR UserFinancialActualID IN (SELECT UserFinancialActualID FROM userfinancialactual where financialuseritemid=p_FinancialUserItemID);
-        
-        -- Deletar o User Parent Expense da Forecast
-        DELETE FROM UserFinancialForecast
-        WHERE financialuseritemid =p_FinancialUserItemID;
 
-        
-        -- Deletar o User Parent Expense da Actual
-        DELETE FROM FinancialUserItem 
-        WHERE FinancialUserItemID = p_FinancialUserItemID;
+        -- Move o User Parent Expense e seus childs para a lixeira, guardando o IsActive para o restore
+        UPDATE FinancialUserItem
+        SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
+        WHERE (FinancialUserItemID = p_FinancialUserItemID OR ParentFinancialUserItemID = p_FinancialUserItemID)
+            AND EntityID IN (5, 6, 7, 8)
+            AND UserEntityID = p_UserID
+            AND DeletedAt IS NULL;
 
         -- Retorna mensagem de sucesso
-        p_Message := '{"status": "success", "message": "User Parent Expense and all references deleted successfully."}';
+        p_Message := '{"status": "success", "message": "User Parent Expense moved to the trash."}';
 
         EXCEPTION 
         WHEN OTHERS THEN 
@@ -1601,4 +1529,378 @@ BEGIN
                 SQLERRM
             );
 END;
-$$;
\ No newline at end of file
+$$;
+
+
+
+-- STORED PROCEDURES TO MANAGE GROUP ITEMS
+
+CREATE OR REPLACE PROCEDURE CreateGroupParentItem(
+    IN p_UserID INT,
+    IN p_UserGroupID INT,
+    IN p_EntityID INT,
+    IN p_FinancialUserItemName VARCHAR(255),
+    IN p_RecurrencyID INT,
+    IN p_FinancialUserEntityItemID INT,
+    IN p_Amount NUMERIC(15,2),
+    IN p_BeginDate DATE,
+    IN p_CurrencyID INT,
+    OUT p_Message TEXT
+)
+/* ----------------------------------------------------------------------
+STORED PROCEDURE NAME: CreateGroupParentItem
+STORED PROCEDURE VERSION: 1.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
+STORED PROCEDURE DESCRIPTION: 
+    Procedure para criar um novo Group Income (EntityID 1) ou Group Expense (EntityID 2). O UserEntityID do FinancialUserItem é o UserGroupID.
+    Somente owners e members do grupo podem criar items (viewers apenas visualizam). Cria os records the forecast associados, baseado na recurency escolhida, igual ao CreateUserParentIncome.
+STORED PROCEDURE TEST CASE(S):
+
+CALL CreateGroupParentItem(1, 1, 2, 'Aluguel', 2, 1, 3000, '2026-01-05', 1, '');
+
+BACKEND VISUALIZATION:
+
+select * from financialuseritem where entityid in (1, 2) and userentityid = 1 -- Items do grupo 1
+select * from userfinancialforecast where financialuseritemid = (select max(financialuseritemid) from financialuseritem where financialuseritemname = 'Aluguel')
+
+USER INTERFACE:
+
+Navegue até o grupo e veja os records
+----------------------------------------------------*/
+LANGUAGE plpgsql
+AS $$
+DECLARE 
+    v_GroupRole VARCHAR(10);
+    v_NewFinancialUserItemID INT;
+    v_CurrentDate DATE;
+    v_NextDate DATE;
+    v_Iterations INT;
+    v_Increment INTERVAL;
+    i INT;
+BEGIN
+    -- Validações de campos obrigatórios
+    IF p_UserID IS NULL OR p_UserGroupID IS NULL THEN 
+        p_Message := '{"status": "fail", "message": "Missing UserID or UserGroupID"}';
+        RETURN;
+    END IF;
+    IF p_EntityID NOT IN (1, 2) THEN 
+        p_Message := '{"status": "fail", "message": "EntityID must be Group Income (1) or Group Expense (2)"}';
+        RETURN;
+    END IF;
+    IF p_FinancialUserItemName IS NULL OR p_FinancialUserItemName = '' THEN 
+        p_Message := '{"status": "fail", "message": "Missing FinancialUserItemName"}';
+        RETURN;
+    END IF;
+    IF p_RecurrencyID IS NULL THEN 
+        p_Message := '{"status": "fail", "message": "Missing RecurrencyID"}';
+        RETURN;
+    END IF;
+    IF p_Amount IS NULL OR p_Amount <= 0 THEN 
+        p_Message := '{"status": "fail", "message": "Invalid Amount"}';
+        RETURN;
+    END IF;
+    IF p_BeginDate IS NULL THEN 
+        p_Message := '{"status": "fail", "message": "Missing BeginDate"}';
+        RETURN;
+    END IF;
+
+    -- Confere se o usuário pode alterar o grupo
+    SELECT GroupRole INTO v_GroupRole
+    FROM UserGroupMember
+    WHERE UserGroupID = p_UserGroupID AND UserProfileID = p_UserID;
+
+    IF v_GroupRole IS NULL OR v_GroupRole = 'viewer' THEN
+        p_Message := '{"status": "fail", "message": "User cannot manage the items of this group"}';
+        RETURN;
+    END IF;
+
+    BEGIN
+        INSERT INTO FinancialUserItem (
+            FinancialUserItemName, EntityID, UserEntityID, RecurrencyID, 
+            FinancialUserEntityItemID, ParentFinancialUserItemID
+        ) VALUES (
+            p_FinancialUserItemName, p_EntityID, p_UserGroupID, p_RecurrencyID, 
+            p_FinancialUserEntityItemID, NULL
+        ) RETURNING FinancialUserItemID INTO v_NewFinancialUserItemID;
+
+        -- Configura número de inserções e intervalo conforme a recorrência
+        IF p_RecurrencyID = 1 THEN -- One time
+            v_Iterations := 1;
+            v_Increment := '1 day'::INTERVAL;
+        ELSIF p_RecurrencyID = 2 THEN -- Monthly
+            v_Iterations := 12;
+            v_Increment := '1 month'::INTERVAL;
+        ELSIF p_RecurrencyID = 3 THEN -- Quarterly
+            v_Iterations := 4;
+            v_Increment := '3 months'::INTERVAL;
+        ELSIF p_RecurrencyID = 4 THEN -- Yearly
+            v_Iterations := 1;
+            v_Increment := '1 year'::INTERVAL;
+        ELSIF p_RecurrencyID IN (5, 6, 7, 8) THEN -- Variable, Daily, Weekly, Biweekly: apenas o primeiro record, o restante é expandido pelo recurrence engine
+            v_Iterations := 1;
+            v_Increment := '1 day'::INTERVAL;
+        ELSE
+            RAISE EXCEPTION 'Invalid RecurrencyID';
+        END IF;
+
+        -- Insere múltiplos registros conforme a recorrência, cada um termina no dia anterior ao próximo
+        v_CurrentDate := p_BeginDate;
+        FOR i IN 1..v_Iterations LOOP
+            v_NextDate := v_CurrentDate + v_Increment;
+
+            INSERT INTO UserFinancialForecast (
+                usercategoryid, financialuseritemid, userfinancialforecastbegindate, 
+                userfinancialforecastenddate, userfinancialforecastamount, currencyid
+            ) VALUES (
+                NULL, v_NewFinancialUserItemID, v_CurrentDate,
+                CASE 
+                    WHEN p_RecurrencyID IN (1, 5, 6, 7, 8) THEN v_CurrentDate
+                    ELSE v_NextDate - INTERVAL '1 day'
+                END,
+                p_Amount, COALESCE(p_CurrencyID, 1)
+            );
+
+            v_CurrentDate := v_NextDate;
+        END LOOP;
+
+        p_Message := format('{"status": "success", "message": "New group item and forecast created successfully.", "financial_user_item_id": %s}', v_NewFinancialUserItemID);
+    EXCEPTION 
+        WHEN OTHERS THEN
+            p_Message := '{"status": "fail", "message": "Error creating forecast values: ' || SQLERRM || '"}';
+    END;
+END;
+$$;
+
+
+CREATE OR REPLACE PROCEDURE CreateGroupChildItem(
+    IN p_UserID INT,
+    IN p_EntityID INT,
+    IN p_FinancialUserItemName VARCHAR(255),
+    IN p_FinancialUserEntityItemID INT,
+    IN p_ParentFinancialUserItemID INT,
+    IN p_Amount NUMERIC(15,2),
+    OUT p_Message TEXT
+)
+/* ----------------------------------------------------------------------
+STORED PROCEDURE NAME: CreateGroupChildItem
+STORED PROCEDURE VERSION: 1.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
+STORED PROCEDURE DESCRIPTION: 
+    Cria um Group Income Tax (EntityID 3) ou Group Income Expense (EntityID 4) associado a um Group Income existente (EntityID 1).
+    Replica todos os forecasts do parent, com as mesmas datas e o valor informado, igual ao CreateUserAssetChildIncomeTax.
+STORED PROCEDURE TEST CASE(S):
+
+CALL CreateGroupChildItem(1, 3, 'IRPF Aluguel', 3, 80, 450, '');
+
+BACKEND VISUALIZATION:
+
+select * from financialuseritem where parentfinancialuseritemid = 80
+select * from userfinancialforecast where financialuseritemid in (select financialuseritemid from financialuseritem where parentfinancialuseritemid = 80)
+
+USER INTERFACE:
+
+TBD
+----------------------------------------------------*/
+LANGUAGE plpgsql
+AS $$
+DECLARE 
+    v_UserGroupID INT;
+    v_GroupRole VARCHAR(10);
+    v_FinancialUserItemID INT;
+BEGIN
+    IF p_EntityID NOT IN (3, 4) THEN 
+        p_Message := '{"status": "fail", "message": "EntityID must be Group Income Tax (3) or Group Income Expense (4)"}';
+        RETURN;
+    END IF;
+    IF p_FinancialUserItemName IS NULL OR p_FinancialUserItemName = '' THEN 
+        p_Message := '{"status": "fail", "message": "Missing FinancialUserItemName"}';
+        RETURN;
+    END IF;
+    IF p_Amount IS NULL OR p_Amount < 0 THEN 
+        p_Message := '{"status": "fail", "message": "Invalid Amount"}';
+        RETURN;
+    END IF;
+
+    -- O parent precisa ser um Group Income
+    SELECT UserEntityID INTO v_UserGroupID
+    FROM FinancialUserItem
+    WHERE FinancialUserItemID = p_ParentFinancialUserItemID AND EntityID = 1 AND DeletedAt IS NULL;
+
+    IF v_UserGroupID IS NULL THEN
+        p_Message := '{"status": "fail", "message": "Parent Group Income not found"}';
+        RETURN;
+    END IF;
+
+    SELECT GroupRole INTO v_GroupRole
+    FROM UserGroupMember
+    WHERE UserGroupID = v_UserGroupID AND UserProfileID = p_UserID;
+
+    IF v_GroupRole IS NULL OR v_GroupRole = 'viewer' THEN
+        p_Message := '{"status": "fail", "message": "User cannot manage the items of this group"}';
+        RETURN;
+    END IF;
+
+    BEGIN
+        INSERT INTO FinancialUserItem (
+            FinancialUserItemName, EntityID, UserEntityID, RecurrencyID, FinancialUserEntityItemID, ParentFinancialUserItemID
+        )
+        SELECT p_FinancialUserItemName, p_EntityID, v_UserGroupID, RecurrencyID, p_FinancialUserEntityItemID, p_ParentFinancialUserItemID
+        FROM FinancialUserItem
+        WHERE FinancialUserItemID = p_ParentFinancialUserItemID
+        RETURNING FinancialUserItemID INTO v_FinancialUserItemID;
+
+        INSERT INTO userfinancialforecast (
+            usercategoryid, financialuseritemid, userfinancialforecastbegindate, 
+            userfinancialforecastenddate, userfinancialforecastamount, currencyid
+        )
+        SELECT usercategoryid, v_FinancialUserItemID, userfinancialforecastbegindate, 
+            userfinancialforecastenddate, p_Amount, currencyid
+        FROM userfinancialforecast
+        WHERE financialuseritemid = p_ParentFinancialUserItemID;
+
+        p_Message := format('{"status": "success", "message": "New group child item created successfully.", "financial_user_item_id": %s}', v_FinancialUserItemID);
+    EXCEPTION
+        WHEN OTHERS THEN
+            p_Message := '{"status": "fail", "message": "Could not create the Forecast: ' || SQLERRM || '"}';
+    END;
+END;
+$$;
+
+
+CREATE OR REPLACE PROCEDURE UpdateGroupParentItem(
+    IN p_FinancialUserItemID INT,
+    IN p_UserID INT,
+    IN p_NewFinancialUserItemName VARCHAR(255),
+    IN p_NewAmount NUMERIC(15,2),
+    IN p_NewBeginDate DATE,
+    OUT p_Message TEXT
+)
+/* ----------------------------------------------------------------------
+STORED PROCEDURE NAME: UpdateGroupParentItem
+STORED PROCEDURE VERSION: 1.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
+STORED PROCEDURE DESCRIPTION: 
+    Altera o nome e o valor dos forecasts a partir de p_NewBeginDate de um Group Income ou Group Expense, igual ao UpdateUserParentIncome.
+    Somente owners e members do grupo podem alterar.
+STORED PROCEDURE TEST CASE(S):
+
+CALL UpdateGroupParentItem(80, 1, 'Aluguel', 3200, '2026-06-01', '');
+
+BACKEND VISUALIZATION:
+
+select * from userfinancialforecast where financialuseritemid = 80
+
+USER INTERFACE:
+
+TBD
+----------------------------------------------------*/
+LANGUAGE plpgsql
+AS $$
+DECLARE 
+    v_UserGroupID INT;
+    v_GroupRole VARCHAR(10);
+BEGIN
+    IF p_NewFinancialUserItemName IS NULL OR p_NewFinancialUserItemName = '' THEN 
+        p_Message := '{"status": "fail", "message": "Missing the new FinancialUserItemName"}';
+        RETURN;
+    END IF;
+    IF p_NewAmount IS NULL OR p_NewAmount <= 0 THEN 
+        p_Message := '{"status": "fail", "message": "Invalid Amount"}';
+        RETURN;
+    END IF;
+    IF p_NewBeginDate IS NULL THEN 
+        p_Message := '{"status": "fail", "message": "Missing new BeginDate"}';
+        RETURN;
+    END IF;
+
+    SELECT UserEntityID INTO v_UserGroupID
+    FROM FinancialUserItem
+    WHERE FinancialUserItemID = p_FinancialUserItemID AND EntityID IN (1, 2) AND DeletedAt IS NULL;
+
+    IF v_UserGroupID IS NULL THEN
+        p_Message := '{"status": "fail", "message": "Group item not found"}';
+        RETURN;
+    END IF;
+
+    SELECT GroupRole INTO v_GroupRole
+    FROM UserGroupMember
+    WHERE UserGroupID = v_UserGroupID AND UserProfileID = p_UserID;
+
+    IF v_GroupRole IS NULL OR v_GroupRole = 'viewer' THEN
+        p_Message := '{"status": "fail", "message": "User cannot manage the items of this group"}';
+        RETURN;
+    END IF;
+
+    UPDATE FinancialUserItem
+    SET FinancialUserItemName = p_NewFinancialUserItemName
+    WHERE FinancialUserItemID = p_FinancialUserItemID;
+
+    UPDATE UserFinancialForecast
+    SET userfinancialforecastamount = p_NewAmount
+    WHERE financialuseritemid = p_FinancialUserItemID
+    AND userfinancialforecastbegindate >= p_NewBeginDate;
+
+    p_Message := '{"status": "success", "message": "Group item updated successfully."}';
+END;
+$$;
+
+
+CREATE OR REPLACE PROCEDURE DeleteGroupItem(
+    IN p_FinancialUserItemID INT,
+    IN p_UserID INT,
+    OUT p_Message TEXT
+)
+/* ----------------------------------------------------------------------
+STORED PROCEDURE NAME: DeleteGroupItem
+STORED PROCEDURE VERSION: 2.0
+STORED PROCEDURE LAST UPDATED DATE: 18-Oct-2026
+STORED PROCEDURE DESCRIPTION: 
+    Move um item do grupo (EntityIDs 1-4), e todos os seus childs, para a lixeira (soft delete). Forecasts, actuals e relations são mantidos.
+    Somente owners e members do grupo podem deletar e restaurar.
+STORED PROCEDURE TEST CASE(S):
+
+CALL DeleteGroupItem(80, 1, '');
+
+BACKEND VISUALIZATION:
+
+select FinancialUserItemID, IsActive, DeletedAt from financialuseritem where financialuseritemid = 80 or parentfinancialuseritemid = 80
+Deve retornar IsActive FALSE e DeletedAt preenchido no item e nos childs
+
+USER INTERFACE:
+
+Não devem estar visiveis na UI após o delete, somente na lixeira.
+----------------------------------------------------*/
+LANGUAGE plpgsql
+AS $$
+DECLARE 
+    v_UserGroupID INT;
+    v_GroupRole VARCHAR(10);
+BEGIN
+    SELECT UserEntityID INTO v_UserGroupID
+    FROM FinancialUserItem
+    WHERE FinancialUserItemID = p_FinancialUserItemID AND EntityID IN (1, 2, 3, 4) AND DeletedAt IS NULL;
+
+    IF v_UserGroupID IS NULL THEN
+        p_Message := '{"status": "fail", "message": "Group item not found"}';
+        RETURN;
+    END IF;
+
+    SELECT GroupRole INTO v_GroupRole
+    FROM UserGroupMember
+    WHERE UserGroupID = v_UserGroupID AND UserProfileID = p_UserID;
+
+    IF v_GroupRole IS NULL OR v_GroupRole = 'viewer' THEN
+        p_Message := '{"status": "fail", "message": "User cannot manage the items of this group"}';
+        RETURN;
+    END IF;
+
+    -- Move o item e seus childs para a lixeira, guardando o IsActive para o restore
+    UPDATE FinancialUserItem
+    SET DeletedWasActive = IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
+    WHERE (FinancialUserItemID = p_FinancialUserItemID OR ParentFinancialUserItemID = p_FinancialUserItemID)
+        AND UserEntityID = v_UserGroupID
+        AND DeletedAt IS NULL;
+
+    p_Message := '{"status": "success", "message": "Group item moved to the trash."}';
+END;
+$$;
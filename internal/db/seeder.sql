@@ -76,15 +76,20 @@ INSERT INTO AssetType (AssetTypeName, AssetTypeDescription, EntityID) VALUES
 -- Currency Seder
 
 INSERT INTO Currency ( CurrencyName,CurrencyAbreviation, CurrencySymbol) VALUES
-('Brazilian Real','BRL','R$'); 
+('Brazilian Real','BRL','R$'),
+('US Dollar','USD','US$'),
+('Euro','EUR','€'); 
 
 -- User Seeder
 INSERT INTO Recurrency (RecurrencyName, RecurrencyPeriod) VALUES 
 /*1*/('One Time', 'today'),
 /*2*/('Monthly', '1 month'),
-/*3*/('Quarterly','4 months'),
+/*3*/('Quarterly','3 months'),
 /*4*/('Yearly', '1 year'),
-/*5*/('Variable','undefined');
+/*5*/('Variable','undefined'),
+/*6*/('Daily', '1 day'),
+/*7*/('Weekly', '1 week'),
+/*8*/('Biweekly', '2 weeks');
 /*-- ATUALIZANDO A TABELA RECURRENCY PRA FUTURAMENTE UPGRADE A PROCEDURE PARA UTILIZAR O PERIDO DIRETAMENTE DA TABELA COMO EXEMPLO ABAIXO:
       -- Configura número de inserções e intervalo conforme a recorrência
         IF p_RecurrencyID = 1 THEN -- One time
@@ -95,7 +100,7 @@ INSERT INTO Recurrency (RecurrencyName, RecurrencyPeriod) VALUES
             v_Increment := '1 month'::INTERVAL;
         ELSIF p_RecurrencyID = 3 THEN -- Quarterly
             v_Iterations := 4;
-            v_Increment := '4 months'::INTERVAL;
+            v_Increment := '3 months'::INTERVAL;
         ELSIF p_RecurrencyID = 4 THEN -- Yearly
             v_Iterations := 1;
             v_Increment := '1 year'::INTERVAL;
@@ -0,0 +1,167 @@
+// Package dedupe finds actuals that are likely the same transaction booked twice, like a purchase imported from a
+// statement and also typed by hand.
+package dedupe
+
+import (
+	"finanapp/internal/statement"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options controls how close two actuals must be to be flagged
+type Options struct {
+	DateWindowDays int     `json:"dateWindowDays"` // days between the two dates
+	Threshold      float64 `json:"threshold"`      // minimum score, between 0 and 1
+}
+
+// DefaultOptions is used when flagging duplicates on import and on creation
+var DefaultOptions = Options{DateWindowDays: 3, Threshold: 0.7}
+
+// Score weights, an exact amount is required so it only counts as the base of the score
+const (
+	amountWeight      = 0.4
+	dateWeight        = 0.2
+	descriptionWeight = 0.25
+	itemWeight        = 0.1
+	sourceWeight      = 0.05
+)
+
+// Actual is the side being compared
+type Actual struct {
+	ID          int
+	Date        time.Time
+	Amount      float64
+	CurrencyID  int
+	ItemID      int
+	Description string
+	Account     string // account key of the imported line, empty for manual entries
+	ExternalID  string // FITID of the imported line, empty for manual entries
+}
+
+// Imported tells whether the actual came from a statement
+func (a Actual) Imported() bool {
+	return a.ExternalID != ""
+}
+
+// Pair is two actuals that look like the same transaction, FirstID is the lower ID
+type Pair struct {
+	FirstID  int
+	SecondID int
+	Score    float64
+	Reasons  []string
+}
+
+// Score rates how likely a and b are the same transaction, from 0 to 1, and tells why. Different amounts or
+// currencies, dates outside the window and two different lines of the same statement account never match; the same
+// FITID always does.
+func Score(a, b Actual, opts Options) (float64, []string) {
+	if a.CurrencyID != b.CurrencyID || math.Abs(a.Amount-b.Amount) > 0.005 {
+		return 0, nil
+	}
+	days := int(math.Abs(math.Round(a.Date.Sub(b.Date).Hours() / 24)))
+	if days > opts.DateWindowDays {
+		return 0, nil
+	}
+	if a.Imported() && b.Imported() {
+		if a.ExternalID == b.ExternalID {
+			return 1, []string{"same FITID"}
+		}
+		// The bank already told them apart
+		if a.Account == b.Account {
+			return 0, nil
+		}
+	}
+
+	score := amountWeight + dateWeight*(1-float64(days)/float64(opts.DateWindowDays+1))
+	reasons := []string{"same amount"}
+	if days == 0 {
+		reasons = append(reasons, "same day")
+	} else {
+		reasons = append(reasons, fmt.Sprintf("%d days apart", days))
+	}
+	if similarity := Similarity(a.Description, b.Description); similarity > 0 {
+		score += descriptionWeight * similarity
+		reasons = append(reasons, fmt.Sprintf("description %.0f%% similar", similarity*100))
+	}
+	if a.ItemID != 0 && a.ItemID == b.ItemID {
+		score += itemWeight
+		reasons = append(reasons, "same item")
+	}
+	if a.Imported() != b.Imported() {
+		score += sourceWeight
+		reasons = append(reasons, "imported and manual entry")
+	}
+	return math.Round(score*1000) / 1000, reasons
+}
+
+// Similarity is the share of words the normalized descriptions have in common (Jaccard index)
+func Similarity(a, b string) float64 {
+	wordsA := strings.Fields(statement.Normalize(a))
+	wordsB := strings.Fields(statement.Normalize(b))
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range wordsA {
+		set[w] = true
+	}
+	common, union := 0, len(set)
+	seen := map[string]bool{}
+	for _, w := range wordsB {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		} else {
+			union++
+		}
+	}
+	return float64(common) / float64(union)
+}
+
+// Find compares the actuals with each other and returns the pairs at or above the threshold, best first
+func Find(actuals []Actual, opts Options) []Pair {
+	sorted := make([]Actual, len(actuals))
+	copy(sorted, actuals)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	pairs := []Pair{}
+	window := time.Duration(opts.DateWindowDays+1) * 24 * time.Hour
+	for i := range sorted {
+		for j := i + 1; j < len(sorted) && sorted[j].Date.Sub(sorted[i].Date) < window; j++ {
+			if sorted[i].ID == sorted[j].ID {
+				continue
+			}
+			score, reasons := Score(sorted[i], sorted[j], opts)
+			if score == 0 || score < opts.Threshold {
+				continue
+			}
+			first, second := sorted[i].ID, sorted[j].ID
+			if first > second {
+				first, second = second, first
+			}
+			pairs = append(pairs, Pair{FirstID: first, SecondID: second, Score: score, Reasons: reasons})
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Score > pairs[j].Score })
+	return pairs
+}
+
+// Best returns the candidate that most looks like the actual, at or above the threshold
+func Best(actual Actual, candidates []Actual, opts Options) (Actual, float64, bool) {
+	var best Actual
+	bestScore := 0.0
+	for _, c := range candidates {
+		score, _ := Score(actual, c, opts)
+		if score > bestScore && score >= opts.Threshold {
+			best, bestScore = c, score
+		}
+	}
+	return best, bestScore, bestScore > 0
+}
@@ -0,0 +1,76 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestScore(t *testing.T) {
+	imported := Actual{ID: 1, Date: day("2025-04-02"), Amount: 89.9, CurrencyID: 1, ItemID: 3,
+		Description: "PAG*Supermercado Pao 0402", Account: "0341/1", ExternalID: "A1"}
+	manual := Actual{ID: 2, Date: day("2025-04-01"), Amount: 89.9, CurrencyID: 1, ItemID: 3, Description: "supermercado"}
+
+	score, reasons := Score(imported, manual, DefaultOptions)
+	if score < DefaultOptions.Threshold || len(reasons) != 5 {
+		t.Errorf("expected a likely duplicate, got %v %v", score, reasons)
+	}
+
+	other := manual
+	other.Amount = 89.0
+	if score, _ := Score(imported, other, DefaultOptions); score != 0 {
+		t.Errorf("different amounts scored %v", score)
+	}
+	other = manual
+	other.Date = day("2025-04-10")
+	if score, _ := Score(imported, other, DefaultOptions); score != 0 {
+		t.Errorf("dates outside the window scored %v", score)
+	}
+
+	// Two lines of the same account are different transactions, the same FITID elsewhere is the same one
+	sameAccount := imported
+	sameAccount.ID, sameAccount.ExternalID = 3, "A2"
+	if score, _ := Score(imported, sameAccount, DefaultOptions); score != 0 {
+		t.Errorf("lines of the same account scored %v", score)
+	}
+	otherAccount := sameAccount
+	otherAccount.Account, otherAccount.ExternalID = "csv", "A1"
+	if score, _ := Score(imported, otherAccount, DefaultOptions); score != 1 {
+		t.Errorf("same FITID scored %v", score)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("Padaria São João", "PADARIA SAO JOAO 123"); s != 1 {
+		t.Errorf("got %v, want 1", s)
+	}
+	if s := Similarity("uber trip", "uber eats"); s < 0.33 || s > 0.34 {
+		t.Errorf("got %v, want 1/3", s)
+	}
+	if s := Similarity("", "uber"); s != 0 {
+		t.Errorf("got %v, want 0", s)
+	}
+}
+
+func TestFind(t *testing.T) {
+	actuals := []Actual{
+		{ID: 5, Date: day("2025-04-03"), Amount: 50, CurrencyID: 1, ItemID: 1, Description: "Gym"},
+		{ID: 2, Date: day("2025-04-03"), Amount: 50, CurrencyID: 1, ItemID: 1, Description: "gym monthly"},
+		{ID: 7, Date: day("2025-04-03"), Amount: 50, CurrencyID: 2, ItemID: 1, Description: "Gym"},
+		{ID: 9, Date: day("2025-05-03"), Amount: 50, CurrencyID: 1, ItemID: 1, Description: "Gym"},
+	}
+	pairs := Find(actuals, DefaultOptions)
+	if len(pairs) != 1 || pairs[0].FirstID != 2 || pairs[0].SecondID != 5 {
+		t.Fatalf("unexpected pairs %+v", pairs)
+	}
+
+	best, _, ok := Best(Actual{Date: day("2025-04-04"), Amount: 50, CurrencyID: 1, Description: "GYM", ExternalID: "X"},
+		actuals, DefaultOptions)
+	if !ok || best.ID != 5 {
+		t.Errorf("expected actual 5, got %+v (found=%v)", best, ok)
+	}
+}
@@ -0,0 +1,122 @@
+// Package export writes tables as CSV files or XLSX workbooks row by row, so large exports are streamed instead of
+// held in memory. The XLSX writer only uses the standard library: inline strings, typed numeric and date cells and
+// a bold header row.
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell kinds
+const (
+	kindEmpty = iota
+	kindString
+	kindNumber
+	kindDate
+)
+
+// Cell is one typed value of a row
+type Cell struct {
+	kind   int
+	text   string
+	number float64
+	date   time.Time
+}
+
+// String is a text cell
+func String(s string) Cell {
+	return Cell{kind: kindString, text: s}
+}
+
+// Number is a numeric cell
+func Number(f float64) Cell {
+	return Cell{kind: kindNumber, number: f}
+}
+
+// Date is a date cell, the time of the day is dropped
+func Date(t time.Time) Cell {
+	return Cell{kind: kindDate, date: t}
+}
+
+// Empty is a blank cell
+func Empty() Cell {
+	return Cell{}
+}
+
+// OptionalString is a text cell, blank when s is nil
+func OptionalString(s *string) Cell {
+	if s == nil {
+		return Empty()
+	}
+	return String(*s)
+}
+
+// OptionalDate is a date cell, blank when t is nil
+func OptionalDate(t *time.Time) Cell {
+	if t == nil {
+		return Empty()
+	}
+	return Date(*t)
+}
+
+// Text is the cell as CSV writes it: numbers without thousand separators, dates as YYYY-MM-DD
+func (c Cell) Text() string {
+	switch c.kind {
+	case kindString:
+		return c.text
+	case kindNumber:
+		return strconv.FormatFloat(c.number, 'f', -1, 64)
+	case kindDate:
+		return c.date.Format("2006-01-02")
+	}
+	return ""
+}
+
+// RowWriter writes the rows of a table, the first row written is the header
+type RowWriter interface {
+	WriteRow(cells ...Cell) error
+}
+
+// CSV writes one table as a CSV file
+type CSV struct {
+	w    *csv.Writer
+	rows int
+}
+
+// flushEvery is how many CSV rows are buffered before they are sent
+const flushEvery = 500
+
+// NewCSV starts a CSV file on w
+func NewCSV(w io.Writer) *CSV {
+	return &CSV{w: csv.NewWriter(w)}
+}
+
+// WriteRow writes one record. Strings that a spreadsheet would read as a formula are prefixed with a quote; XLSX
+// doesn't need it since its strings are never evaluated.
+func (c *CSV) WriteRow(cells ...Cell) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = cell.Text()
+		if cell.kind == kindString && record[i] != "" && strings.ContainsRune("=+-@", rune(record[i][0])) {
+			record[i] = "'" + record[i]
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%flushEvery == 0 {
+		c.w.Flush()
+	}
+	return c.w.Error()
+}
+
+// Close flushes the buffered records
+func (c *CSV) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func readPart(t *testing.T, r *zip.Reader, name string) string {
+	t.Helper()
+	for _, f := range r.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			data, _ := io.ReadAll(rc)
+			return string(data)
+		}
+	}
+	t.Fatalf("part %s not found", name)
+	return ""
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	x, err := NewXLSX(&buf, "Summary", "Actuals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.NextSheet(); err != nil {
+		t.Fatal(err)
+	}
+	x.WriteRow(String("Sheet"), String("Rows"))
+	x.WriteRow(String("Actuals <all>"), Number(1))
+	if err := x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workbook := readPart(t, r, "xl/workbook.xml"); !strings.Contains(workbook, `<sheet name="Actuals" sheetId="2" r:id="rId2"/>`) {
+		t.Errorf("unexpected workbook %s", workbook)
+	}
+	summary := readPart(t, r, "xl/worksheets/sheet1.xml")
+	for _, want := range []string{
+		`<c r="A1" s="3" t="inlineStr"><is><t xml:space="preserve">Sheet</t></is></c>`,
+		`<t xml:space="preserve">Actuals &lt;all&gt;</t>`,
+		`<c r="B2" s="2"><v>1</v></c>`,
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary sheet misses %s", want)
+		}
+	}
+	// The sheet that was never started is still there
+	if sheet := readPart(t, r, "xl/worksheets/sheet2.xml"); !strings.HasSuffix(sheet, sheetEndXML) {
+		t.Errorf("unexpected empty sheet %s", sheet)
+	}
+}
+
+func TestXLSXDates(t *testing.T) {
+	var buf bytes.Buffer
+	x, _ := NewXLSX(&buf, "Dates")
+	x.NextSheet()
+	x.WriteRow(String("Date"))
+	x.WriteRow(Date(day("2025-01-01")), Empty(), OptionalDate(nil), Date(day("1900-03-01")))
+	x.Close()
+
+	r, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	sheet := readPart(t, r, "xl/worksheets/sheet1.xml")
+	if !strings.Contains(sheet, `<row r="2"><c r="A2" s="1"><v>45658</v></c><c r="D2" s="1"><v>61</v></c></row>`) {
+		t.Errorf("unexpected dates %s", sheet)
+	}
+}
+
+func TestSheetNames(t *testing.T) {
+	if _, err := NewXLSX(io.Discard, "a/b"); err == nil {
+		t.Error("expected an error for a name with a slash")
+	}
+	if _, err := NewXLSX(io.Discard, strings.Repeat("x", 32)); err == nil {
+		t.Error("expected an error for a long name")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCSV(&buf)
+	c.WriteRow(String("Name"), String("Amount"), String("Date"))
+	c.WriteRow(String("Rent, flat"), Number(1500.5), Date(day("2025-03-01")))
+	c.WriteRow(Empty(), OptionalString(nil), Number(-2))
+	c.WriteRow(String("=HYPERLINK(\"x\")"), String("-2"), String("@SUM(A1)"))
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name,Amount,Date\n\"Rent, flat\",1500.5,2025-03-01\n,,-2\n\"'=HYPERLINK(\"\"x\"\")\",'-2,'@SUM(A1)\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
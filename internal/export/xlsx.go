@@ -0,0 +1,195 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cell styles, indexes of cellXfs in styles.xml
+const (
+	styleDefault = 0
+	styleDate    = 1 // numFmtId 14, the short date of the reader's locale
+	styleNumber  = 2 // numFmtId 4, #,##0.00
+	styleHeader  = 3 // bold
+)
+
+// excelEpoch is day 0 of the 1900 date system, shifted to skip its fictitious 1900-02-29
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const (
+	contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`%s</Types>`
+
+	rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	stylesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="4">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`</cellXfs></styleSheet>`
+
+	// The header row stays visible while scrolling
+	sheetStartXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`
+
+	sheetEndXML = `</sheetData></worksheet>`
+)
+
+// XLSX writes a workbook sheet by sheet. The sheets are declared when the workbook is created and written in that
+// order; each one is streamed to the zip file as its rows come.
+type XLSX struct {
+	zw     *zip.Writer
+	sheets []string
+	next   int           // index of the next sheet to start
+	out    *bufio.Writer // current sheet, nil before the first one
+	row    int
+}
+
+// NewXLSX starts a workbook on w with the given sheet names (at most 31 characters, no []:*?/\)
+func NewXLSX(w io.Writer, sheets ...string) (*XLSX, error) {
+	for _, name := range sheets {
+		if name == "" || len([]rune(name)) > 31 || strings.ContainsAny(name, `[]:*?/\`) {
+			return nil, fmt.Errorf("invalid sheet name %q", name)
+		}
+	}
+	x := &XLSX{zw: zip.NewWriter(w), sheets: sheets}
+
+	var overrides, sheetList, sheetRels strings.Builder
+	for i, name := range sheets {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+		fmt.Fprintf(&sheetRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&sheetRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", fmt.Sprintf(contentTypesXML, overrides.String())},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheetList.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + sheetRels.String() + `</Relationships>`},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, part := range parts {
+		f, err := x.zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// NextSheet ends the current sheet and starts the next declared one, its first row is written as the header
+func (x *XLSX) NextSheet() error {
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+	if x.next >= len(x.sheets) {
+		return fmt.Errorf("all %d sheets were already written", len(x.sheets))
+	}
+	f, err := x.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", x.next+1))
+	if err != nil {
+		return err
+	}
+	x.next++
+	x.out = bufio.NewWriter(f)
+	x.row = 0
+	_, err = x.out.WriteString(sheetStartXML)
+	return err
+}
+
+// WriteRow adds a row to the current sheet
+func (x *XLSX) WriteRow(cells ...Cell) error {
+	if x.out == nil {
+		return fmt.Errorf("no sheet started")
+	}
+	x.row++
+	fmt.Fprintf(x.out, `<row r="%d">`, x.row)
+	for i, c := range cells {
+		ref := columnName(i) + strconv.Itoa(x.row)
+		switch c.kind {
+		case kindString:
+			style := styleDefault
+			if x.row == 1 {
+				style = styleHeader
+			}
+			fmt.Fprintf(x.out, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, escape(c.text))
+		case kindNumber:
+			fmt.Fprintf(x.out, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleNumber, strconv.FormatFloat(c.number, 'f', -1, 64))
+		case kindDate:
+			day := time.Date(c.date.Year(), c.date.Month(), c.date.Day(), 0, 0, 0, 0, time.UTC)
+			fmt.Fprintf(x.out, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleDate, int(day.Sub(excelEpoch).Hours()/24))
+		}
+	}
+	_, err := x.out.WriteString(`</row>`)
+	return err
+}
+
+// Close ends the workbook. Declared sheets that were not started are written empty, so the workbook stays valid.
+func (x *XLSX) Close() error {
+	for x.next < len(x.sheets) {
+		if err := x.NextSheet(); err != nil {
+			return err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+func (x *XLSX) endSheet() error {
+	if x.out == nil {
+		return nil
+	}
+	if _, err := x.out.WriteString(sheetEndXML); err != nil {
+		return err
+	}
+	err := x.out.Flush()
+	x.out = nil
+	return err
+}
+
+// columnName is the letter reference of a zero-based column: A, B, ..., Z, AA, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// escape makes text safe inside XML, replacing the characters XML can't hold
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
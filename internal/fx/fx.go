@@ -0,0 +1,181 @@
+// Package fx converts amounts between currencies using the rates of CurrencyExchangeRate.
+// Rates are always based on the Dollar: the value is how many units of the currency buy one USD,
+// so any pair is converted as a cross rate through USD.
+package fx
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Base is the currency every rate is quoted against
+const Base = "USD"
+
+// ErrUnknownCurrency is returned for currencies that aren't in the Currency table
+var ErrUnknownCurrency = errors.New("fx: unknown currency")
+
+// ErrNoRate is returned when a currency has no exchange rate at all
+var ErrNoRate = errors.New("fx: no exchange rate")
+
+// Rate is the value of one USD in a currency from a date on
+type Rate struct {
+	Date  time.Time
+	Value float64
+}
+
+// Table holds the currencies and their rates ordered by date
+type Table struct {
+	codes map[int]string
+	rates map[string][]Rate
+}
+
+// NewTable returns an empty table
+func NewTable() *Table {
+	return &Table{codes: map[int]string{}, rates: map[string][]Rate{}}
+}
+
+// Load reads every currency and exchange rate from the database
+func Load(database *sql.DB) (*Table, error) {
+	t := NewTable()
+
+	currencyRows, err := database.Query(`SELECT CurrencyID, CurrencyAbreviation FROM currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer currencyRows.Close()
+
+	for currencyRows.Next() {
+		var id int
+		var code string
+		if err := currencyRows.Scan(&id, &code); err != nil {
+			return nil, err
+		}
+		t.AddCurrency(id, code)
+	}
+	if err := currencyRows.Err(); err != nil {
+		return nil, err
+	}
+
+	rateRows, err := database.Query(`
+		SELECT c.CurrencyAbreviation, cer.ExchangeRateDate, cer.ExchangeRateValue
+		FROM currencyexchangerate cer
+		JOIN currency c ON cer.CurrencyID = c.CurrencyID
+		ORDER BY cer.ExchangeRateDate`)
+	if err != nil {
+		return nil, err
+	}
+	defer rateRows.Close()
+
+	for rateRows.Next() {
+		var code string
+		var date time.Time
+		var value float64
+		if err := rateRows.Scan(&code, &date, &value); err != nil {
+			return nil, err
+		}
+		t.AddRate(code, date, value)
+	}
+
+	return t, rateRows.Err()
+}
+
+// AddCurrency registers the abbreviation of a CurrencyID
+func (t *Table) AddCurrency(id int, code string) {
+	t.codes[id] = strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AddRate registers the value of one USD in the currency from the given date on
+func (t *Table) AddRate(code string, date time.Time, value float64) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	rates := t.rates[code]
+	i := sort.Search(len(rates), func(i int) bool { return !rates[i].Date.Before(date) })
+	if i < len(rates) && rates[i].Date.Equal(date) {
+		rates[i].Value = value
+		return
+	}
+	rates = append(rates, Rate{})
+	copy(rates[i+1:], rates[i:])
+	rates[i] = Rate{Date: date, Value: value}
+	t.rates[code] = rates
+}
+
+// Code returns the abbreviation of a CurrencyID
+func (t *Table) Code(id int) (string, bool) {
+	code, ok := t.codes[id]
+	return code, ok
+}
+
+// Known tells if the abbreviation belongs to a registered currency
+func (t *Table) Known(code string) bool {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == Base {
+		return true
+	}
+	for _, c := range t.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Rate returns the effective value of one USD in the currency on the date: the latest rate on or before
+// it. Dates before the first known rate have no rate.
+func (t *Table) Rate(code string, on time.Time) (float64, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == Base {
+		return 1, nil
+	}
+
+	rates := t.rates[code]
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("%w for %s", ErrNoRate, code)
+	}
+
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(on) })
+	if i == 0 {
+		return 0, fmt.Errorf("%w for %s on %s", ErrNoRate, code, on.Format("2006-01-02"))
+	}
+	return rates[i-1].Value, nil
+}
+
+// CrossRate returns how many units of "to" one unit of "from" is worth on the date
+func (t *Table) CrossRate(from, to string, on time.Time) (float64, error) {
+	if strings.EqualFold(from, to) {
+		return 1, nil
+	}
+
+	fromRate, err := t.Rate(from, on)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := t.Rate(to, on)
+	if err != nil {
+		return 0, err
+	}
+	if fromRate == 0 {
+		return 0, fmt.Errorf("%w for %s", ErrNoRate, from)
+	}
+
+	return toRate / fromRate, nil
+}
+
+// Convert converts an amount in the CurrencyID to the currency abbreviation on the date, rounded to cents
+func (t *Table) Convert(amount float64, currencyID int, to string, on time.Time) (float64, error) {
+	from, ok := t.Code(currencyID)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownCurrency, currencyID)
+	}
+
+	rate, err := t.CrossRate(from, to, on)
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Round(amount*rate*100) / 100, nil
+}
@@ -0,0 +1,69 @@
+package fx
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func testTable() *Table {
+	t := NewTable()
+	t.AddCurrency(1, "BRL")
+	t.AddCurrency(2, "USD")
+	t.AddCurrency(3, "EUR")
+	t.AddRate("BRL", day("2025-01-01"), 6.0)
+	t.AddRate("BRL", day("2025-02-01"), 5.0)
+	t.AddRate("EUR", day("2025-01-01"), 0.8)
+	return t
+}
+
+func TestRateUsesLatestOnOrBeforeDate(t *testing.T) {
+	table := testTable()
+	cases := map[string]float64{
+		"2025-01-15": 6.0,
+		"2025-02-01": 5.0,
+		"2025-06-30": 5.0,
+	}
+	for date, want := range cases {
+		got, err := table.Rate("brl", day(date))
+		if err != nil || got != want {
+			t.Errorf("Rate(BRL, %s) = %v, %v; want %v", date, got, err, want)
+		}
+	}
+
+	if _, err := table.Rate("BRL", day("2024-12-01")); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Rate before every rate: expected ErrNoRate, got %v", err)
+	}
+}
+
+func TestConvertCrossesThroughUSD(t *testing.T) {
+	table := testTable()
+
+	// 100 EUR = 125 USD = 625 BRL
+	got, err := table.Convert(100, 3, "BRL", day("2025-03-01"))
+	if err != nil || got != 625 {
+		t.Errorf("Convert EUR->BRL = %v, %v; want 625", got, err)
+	}
+
+	got, err = table.Convert(500, 1, "USD", day("2025-03-01"))
+	if err != nil || got != 100 {
+		t.Errorf("Convert BRL->USD = %v, %v; want 100", got, err)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	table := testTable()
+	table.AddCurrency(4, "GBP")
+
+	if _, err := table.Convert(10, 4, "BRL", day("2025-03-01")); !errors.Is(err, ErrNoRate) {
+		t.Errorf("expected ErrNoRate, got %v", err)
+	}
+	if _, err := table.Convert(10, 9, "BRL", day("2025-03-01")); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("expected ErrUnknownCurrency, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package fx
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Change is the effect an imported quote has on CurrencyExchangeRate
+type Change struct {
+	Date     string   `json:"date"`
+	Currency string   `json:"currency"`
+	Action   string   `json:"action"` // insert, update or unchanged
+	OldRate  *float64 `json:"oldRate,omitempty"`
+	NewRate  float64  `json:"newRate"`
+
+	currencyID int
+}
+
+// ImportResult summarizes an import, or what an import would do in a dry run
+type ImportResult struct {
+	DryRun    bool     `json:"dryRun"`
+	Inserted  int      `json:"inserted"`
+	Updated   int      `json:"updated"`
+	Unchanged int      `json:"unchanged"`
+	Skipped   int      `json:"skipped"`
+	Changes   []Change `json:"changes"`
+	Warnings  []string `json:"warnings"`
+}
+
+// Import upserts the quotes into CurrencyExchangeRate keyed on Currency.CurrencyAbreviation and date.
+// Quotes of currencies that aren't registered are skipped with a warning, USD is skipped as it's the base.
+// With dryRun nothing is written and the result holds the diff against the stored rates.
+func Import(database *sql.DB, quotes []Quote, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun, Changes: []Change{}, Warnings: []string{}}
+
+	currencyIDs := map[string]int{}
+	rows, err := database.Query(`SELECT CurrencyID, CurrencyAbreviation FROM currency`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		currencyIDs[strings.ToUpper(strings.TrimSpace(code))] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	existing := NewTable()
+	stored := map[string]float64{}
+	rateRows, err := database.Query(`
+		SELECT c.CurrencyAbreviation, cer.ExchangeRateDate, cer.ExchangeRateValue
+		FROM currencyexchangerate cer
+		JOIN currency c ON cer.CurrencyID = c.CurrencyID`)
+	if err != nil {
+		return nil, err
+	}
+	for rateRows.Next() {
+		var q Quote
+		if err := rateRows.Scan(&q.Code, &q.Date, &q.Rate); err != nil {
+			rateRows.Close()
+			return nil, err
+		}
+		existing.AddRate(q.Code, q.Date, q.Rate)
+		stored[quoteKey(q)] = q.Rate
+	}
+	rateRows.Close()
+	if err := rateRows.Err(); err != nil {
+		return nil, err
+	}
+
+	unknown := map[string]bool{}
+	seen := map[string]bool{}
+	for _, q := range quotes {
+		q.Code = strings.ToUpper(strings.TrimSpace(q.Code))
+		if q.Code == Base {
+			result.Skipped++
+			continue
+		}
+		id, ok := currencyIDs[q.Code]
+		if !ok {
+			result.Skipped++
+			if !unknown[q.Code] {
+				unknown[q.Code] = true
+				result.Warnings = append(result.Warnings, fmt.Sprintf("currency %s is not registered, its rates were skipped", q.Code))
+			}
+			continue
+		}
+		if q.Rate <= 0 || math.IsInf(q.Rate, 0) || math.IsNaN(q.Rate) {
+			result.Skipped++
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s %s: invalid rate %v", q.Date.Format("2006-01-02"), q.Code, q.Rate))
+			continue
+		}
+
+		// The column keeps 8 decimal places
+		q.Rate = math.Round(q.Rate*1e8) / 1e8
+
+		key := quoteKey(q)
+		if seen[key] {
+			result.Skipped++
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s %s: duplicated in the file, the first rate was kept", q.Date.Format("2006-01-02"), q.Code))
+			continue
+		}
+		seen[key] = true
+
+		change := Change{Date: q.Date.Format("2006-01-02"), Currency: q.Code, NewRate: q.Rate, currencyID: id}
+		if old, ok := stored[key]; ok {
+			oldRate := old
+			change.OldRate = &oldRate
+			if old == q.Rate {
+				change.Action = "unchanged"
+				result.Unchanged++
+			} else {
+				change.Action = "update"
+				result.Updated++
+			}
+		} else {
+			change.Action = "insert"
+			result.Inserted++
+			// Flag rates that move more than half away from the effective one, usually a wrong file or base
+			if previous, err := existing.Rate(q.Code, q.Date); err == nil && math.Abs(q.Rate-previous)/previous > 0.5 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("%s %s: rate %v differs more than 50%% from %v", change.Date, q.Code, q.Rate, previous))
+			}
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	sort.SliceStable(result.Changes, func(i, j int) bool {
+		if result.Changes[i].Date != result.Changes[j].Date {
+			return result.Changes[i].Date < result.Changes[j].Date
+		}
+		return result.Changes[i].Currency < result.Changes[j].Currency
+	})
+
+	if dryRun {
+		return result, nil
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	for _, change := range result.Changes {
+		if change.Action == "unchanged" {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO CurrencyExchangeRate (CurrencyID, ExchangeRateValue, ExchangeRateDate)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (CurrencyID, ExchangeRateDate) DO UPDATE SET ExchangeRateValue = EXCLUDED.ExchangeRateValue`,
+			change.currencyID, change.NewRate, change.Date)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func quoteKey(q Quote) string {
+	return q.Date.Format("2006-01-02") + "|" + strings.ToUpper(q.Code)
+}
+
+// sortQuotes orders quotes by date and currency so the parsed files have a stable output
+func sortQuotes(quotes []Quote) {
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if !quotes[i].Date.Equal(quotes[j].Date) {
+			return quotes[i].Date.Before(quotes[j].Date)
+		}
+		return quotes[i].Code < quotes[j].Code
+	})
+}
@@ -0,0 +1,270 @@
+package fx
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported rate file formats
+const (
+	FormatCSV  = "csv"  // date,currency,rate with the rate in units of the currency per USD
+	FormatECB  = "ecb"  // European Central Bank eurofxref XML, rates per EUR
+	FormatPTAX = "ptax" // Banco Central do Brasil PTAX CSV, rates in BRL
+)
+
+// Quote is one parsed rate: how many units of the currency buy one USD on the date
+type Quote struct {
+	Date time.Time `json:"-"`
+	Code string    `json:"currency"`
+	Rate float64   `json:"rate"`
+}
+
+// Parse reads a rate file in the given format, "" or "auto" detects it from the content
+func Parse(format string, data []byte) ([]Quote, error) {
+	if format == "" || format == "auto" {
+		format = DetectFormat(data)
+	}
+
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return ParseCSV(bytes.NewReader(data))
+	case FormatECB:
+		return ParseECB(bytes.NewReader(data))
+	case FormatPTAX:
+		return ParsePTAX(bytes.NewReader(data))
+	}
+	return nil, fmt.Errorf("unsupported rate file format %q", format)
+}
+
+// DetectFormat guesses the format of a rate file: XML is ECB, PTAX lines start with a DDMMYYYY date and
+// a numeric currency code, anything else is the generic CSV
+func DetectFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return FormatECB
+	}
+
+	firstLine := string(trimmed)
+	if i := strings.IndexAny(firstLine, "\r\n"); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	fields := strings.Split(firstLine, ";")
+	if len(fields) >= 6 {
+		if _, err := parsePTAXDate(fields[0]); err == nil {
+			if _, err := strconv.Atoi(strings.TrimSpace(fields[1])); err == nil {
+				return FormatPTAX
+			}
+		}
+	}
+	return FormatCSV
+}
+
+// ParseCSV reads "date,currency,rate" rows, comma or semicolon separated, with an optional header.
+// Dates are YYYY-MM-DD or DD/MM/YYYY and rates accept a decimal comma.
+func ParseCSV(r io.Reader) ([]Quote, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ','
+	if firstLine, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []Quote
+	for i, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected date, currency and rate", i+1)
+		}
+
+		date, err := parseDate(record[0])
+		if err != nil {
+			// The first line may be a header
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid date %q", i+1, record[0])
+		}
+
+		rate, err := parseRate(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", i+1, record[2])
+		}
+
+		quotes = append(quotes, Quote{Date: date, Code: strings.ToUpper(strings.TrimSpace(record[1])), Rate: rate})
+	}
+
+	return quotes, nil
+}
+
+// ecbEnvelope is the layout of the eurofxref daily, 90 days and historical XML files
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECB reads the eurofxref XML. Rates are quoted per EUR, so every day must include USD to be rebased on it.
+func ParseECB(r io.Reader) ([]Quote, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid ECB XML: %w", err)
+	}
+
+	var quotes []Quote
+	for _, day := range envelope.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ECB date %q", day.Time)
+		}
+
+		perEUR := map[string]float64{}
+		for _, rate := range day.Rates {
+			value, err := parseRate(rate.Rate)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid rate %q for %s", day.Time, rate.Rate, rate.Currency)
+			}
+			perEUR[strings.ToUpper(rate.Currency)] = value
+		}
+
+		usd, ok := perEUR[Base]
+		if !ok {
+			return nil, fmt.Errorf("%s: USD rate missing, unable to rebase the EUR rates", day.Time)
+		}
+
+		quotes = append(quotes, Quote{Date: date, Code: "EUR", Rate: 1 / usd})
+		for code, value := range perEUR {
+			if code == Base {
+				continue
+			}
+			quotes = append(quotes, Quote{Date: date, Code: code, Rate: value / usd})
+		}
+	}
+
+	sortQuotes(quotes)
+	return quotes, nil
+}
+
+// ParsePTAX reads the Banco Central PTAX closing file:
+// date (DDMMYYYY);currency code;type;currency;buy rate;sell rate;buy parity;sell parity
+// The sell rates are BRL per unit of the currency, so every day must include USD to be rebased on it.
+func ParsePTAX(r io.Reader) ([]Quote, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	type ptaxDay struct {
+		date   time.Time
+		perBRL map[string]float64
+	}
+	var days []*ptaxDay
+	byDate := map[time.Time]*ptaxDay{}
+
+	for i, record := range records {
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 6 {
+			return nil, fmt.Errorf("line %d: expected the PTAX layout with at least 6 fields", i+1)
+		}
+
+		date, err := parsePTAXDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", i+1, record[0])
+		}
+		rate, err := parseRate(record[5])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid sell rate %q", i+1, record[5])
+		}
+
+		day, ok := byDate[date]
+		if !ok {
+			day = &ptaxDay{date: date, perBRL: map[string]float64{}}
+			byDate[date] = day
+			days = append(days, day)
+		}
+		day.perBRL[strings.ToUpper(strings.TrimSpace(record[3]))] = rate
+	}
+
+	var quotes []Quote
+	for _, day := range days {
+		usd, ok := day.perBRL[Base]
+		if !ok {
+			return nil, fmt.Errorf("%s: USD rate missing, unable to rebase the BRL rates", day.date.Format("2006-01-02"))
+		}
+
+		quotes = append(quotes, Quote{Date: day.date, Code: "BRL", Rate: usd})
+		for code, value := range day.perBRL {
+			if code == Base {
+				continue
+			}
+			quotes = append(quotes, Quote{Date: day.date, Code: code, Rate: usd / value})
+		}
+	}
+
+	sortQuotes(quotes)
+	return quotes, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "02/01/2006"} {
+		if d, err := time.Parse(layout, s); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parsePTAXDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.Parse("02012006", s); err == nil {
+		return d, nil
+	}
+	return time.Parse("02/01/2006", s)
+}
+
+// parseRate accepts "5.1234" and "5,1234" and rejects anything that isn't a positive finite number
+func parseRate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ",") && !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if value <= 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("rate must be a positive number")
+	}
+	return value, nil
+}
@@ -0,0 +1,76 @@
+package fx
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseCSV(t *testing.T) {
+	data := "date;currency;rate\n2025-01-02;brl;6,18\n03/01/2025;EUR;0.97\n"
+	quotes, err := Parse("auto", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("expected 2 quotes, got %d", len(quotes))
+	}
+	if quotes[0].Code != "BRL" || quotes[0].Rate != 6.18 || quotes[1].Date.Format("2006-01-02") != "2025-01-03" {
+		t.Errorf("unexpected quotes %+v", quotes)
+	}
+
+	if _, err := ParseCSV(strings.NewReader("2025-01-02,BRL,-1\n")); err == nil {
+		t.Error("expected an error for a negative rate")
+	}
+}
+
+func TestParseECBRebasesOnUSD(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2025-01-02">
+			<Cube currency="USD" rate="1.25"/>
+			<Cube currency="BRL" rate="7.5"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+	if DetectFormat([]byte(data)) != FormatECB {
+		t.Fatal("expected the ECB format to be detected")
+	}
+
+	quotes, err := Parse("auto", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := map[string]float64{}
+	for _, q := range quotes {
+		rates[q.Code] = q.Rate
+	}
+	if len(rates) != 2 || !near(rates["EUR"], 0.8) || !near(rates["BRL"], 6) {
+		t.Errorf("unexpected rates %v", rates)
+	}
+}
+
+func TestParsePTAXRebasesOnUSD(t *testing.T) {
+	data := "02012025;220;A;USD;6,1809;6,2000;1,0000;1,0000\n02012025;978;B;EUR;6,4000;6,5100;1,0500;1,0500\n"
+	if DetectFormat([]byte(data)) != FormatPTAX {
+		t.Fatal("expected the PTAX format to be detected")
+	}
+
+	quotes, err := Parse("auto", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rates := map[string]float64{}
+	for _, q := range quotes {
+		rates[q.Code] = q.Rate
+	}
+	if len(rates) != 2 || !near(rates["BRL"], 6.2) || !near(rates["EUR"], 6.2/6.51) {
+		t.Errorf("unexpected rates %v", rates)
+	}
+}
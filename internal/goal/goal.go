@@ -0,0 +1,141 @@
+// Package goal tracks the progress of a savings goal: how much is left, the monthly contribution needed to reach
+// the target on time and when it will be reached at the recent contribution pace.
+package goal
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Goal statuses
+const (
+	Achieved = "achieved" // the target amount is saved
+	OnTrack  = "on_track" // the recent pace reaches the target by the target date
+	Behind   = "behind"   // the recent pace is too slow, or there are no recent contributions
+	Overdue  = "overdue"  // the target date passed without reaching the target
+)
+
+// DefaultPaceMonths is how many months of contributions the projection uses when none is given
+const DefaultPaceMonths = 3
+
+// daysPerMonth is the average month length, used when the pace window is shorter than asked
+const daysPerMonth = 365.25 / 12
+
+// Goal is a target amount to save by a date
+type Goal struct {
+	Target     float64
+	TargetDate time.Time
+	Start      time.Time // contributions before the goal was created still count, but not for the pace
+}
+
+// Validate checks the goal parameters
+func (g Goal) Validate() error {
+	if g.Target <= 0 {
+		return fmt.Errorf("target amount must be greater than zero")
+	}
+	if g.TargetDate.IsZero() {
+		return fmt.Errorf("target date is required")
+	}
+	return nil
+}
+
+// Contribution is an amount put towards the goal, already in the goal currency
+type Contribution struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Progress is the state of a goal on a date
+type Progress struct {
+	Saved           float64 `json:"saved"`
+	Remaining       float64 `json:"remaining"`
+	Percent         float64 `json:"percent"`
+	MonthsLeft      int     `json:"monthsLeft"`
+	RequiredMonthly float64 `json:"requiredMonthly"`        // to reach the target by the target date
+	AverageMonthly  float64 `json:"averageMonthly"`         // recent contribution pace
+	ProjectedDate   string  `json:"projectedDate"`          // empty when there is no recent contribution
+	AchievedDate    string  `json:"achievedDate,omitempty"` // when the contributions reached the target
+	Status          string  `json:"status"`
+}
+
+// MonthsLeft counts the contribution months from the month of on to the month of the target date, both included.
+// It is 0 once the target date has passed.
+func MonthsLeft(on, targetDate time.Time) int {
+	if targetDate.Before(day(on)) {
+		return 0
+	}
+	return (targetDate.Year()-on.Year())*12 + int(targetDate.Month()-on.Month()) + 1
+}
+
+// Evaluate computes the progress of the goal on a date. saved is the amount set aside, usually the sum of the
+// contributions; the contributions of the last paceMonths months give the pace of the projection.
+func Evaluate(g Goal, saved float64, contributions []Contribution, on time.Time, paceMonths int) Progress {
+	if paceMonths <= 0 {
+		paceMonths = DefaultPaceMonths
+	}
+	on = day(on)
+
+	p := Progress{Saved: round2(saved), MonthsLeft: MonthsLeft(on, g.TargetDate)}
+	p.Remaining = round2(math.Max(g.Target-saved, 0))
+	p.Percent = round2(math.Min(saved/g.Target*100, 100))
+
+	// Pace: contributions since the start of the window, which can't begin before the goal
+	windowStart := on.AddDate(0, -paceMonths, 0)
+	span := float64(paceMonths)
+	if day(g.Start).After(windowStart) {
+		windowStart = day(g.Start)
+		span = math.Max(on.Sub(windowStart).Hours()/24/daysPerMonth, 1)
+	}
+	total, cumulative := 0.0, 0.0
+	for _, c := range contributions {
+		if c.Date.After(on) {
+			continue
+		}
+		cumulative += c.Amount
+		if p.AchievedDate == "" && cumulative >= g.Target {
+			p.AchievedDate = c.Date.Format("2006-01-02")
+		}
+		if !c.Date.Before(windowStart) {
+			total += c.Amount
+		}
+	}
+	p.AverageMonthly = round2(math.Max(total/span, 0))
+
+	switch {
+	case p.Remaining == 0:
+		p.Status = Achieved
+		return p
+	case p.MonthsLeft == 0:
+		p.Status = Overdue
+		p.RequiredMonthly = p.Remaining
+	default:
+		p.RequiredMonthly = round2(p.Remaining / float64(p.MonthsLeft))
+		p.Status = Behind
+	}
+	p.AchievedDate = ""
+
+	if p.AverageMonthly > 0 {
+		projected := addMonths(on, int(math.Ceil(p.Remaining/p.AverageMonthly)))
+		p.ProjectedDate = projected.Format("2006-01-02")
+		if p.Status == Behind && !projected.After(g.TargetDate) {
+			p.Status = OnTrack
+		}
+	}
+	return p
+}
+
+// addMonths adds months to a date, clamping the day to the end of shorter months
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(t.Day(), last), 0, 0, 0, 0, time.UTC)
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
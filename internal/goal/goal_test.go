@@ -0,0 +1,77 @@
+package goal
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+var car = Goal{Target: 50000, TargetDate: date("2027-12-31"), Start: date("2026-01-01")}
+
+func TestMonthsLeft(t *testing.T) {
+	if got := MonthsLeft(date("2026-10-18"), date("2027-12-31")); got != 15 {
+		t.Errorf("got %d, want 15", got)
+	}
+	if got := MonthsLeft(date("2027-12-31"), date("2027-12-31")); got != 1 {
+		t.Errorf("got %d, want 1", got)
+	}
+	if got := MonthsLeft(date("2028-01-01"), date("2027-12-31")); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+}
+
+func TestOnTrack(t *testing.T) {
+	var contributions []Contribution
+	for m := 1; m <= 9; m++ {
+		contributions = append(contributions, Contribution{Date: time.Date(2026, time.Month(m), 5, 0, 0, 0, 0, time.UTC), Amount: 3000})
+	}
+	p := Evaluate(car, 27000, contributions, date("2026-10-01"), 3)
+
+	// 23000 left over Oct 2026 - Dec 2027
+	if p.Remaining != 23000 || p.Percent != 54 || p.MonthsLeft != 15 || p.RequiredMonthly != 1533.33 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+	// Jul, Aug and Sep contributions over 3 months
+	if p.AverageMonthly != 3000 || p.ProjectedDate != "2027-06-01" || p.Status != OnTrack {
+		t.Errorf("unexpected projection %+v", p)
+	}
+}
+
+func TestBehind(t *testing.T) {
+	contributions := []Contribution{{Date: date("2026-09-10"), Amount: 300}}
+	p := Evaluate(car, 300, contributions, date("2026-10-01"), 3)
+	if p.Status != Behind || p.ProjectedDate == "" {
+		t.Errorf("unexpected progress %+v", p)
+	}
+
+	p = Evaluate(car, 0, nil, date("2026-10-01"), 3)
+	if p.Status != Behind || p.ProjectedDate != "" || p.AverageMonthly != 0 {
+		t.Errorf("unexpected progress without contributions %+v", p)
+	}
+}
+
+func TestNewGoalPace(t *testing.T) {
+	// A goal created two weeks ago isn't diluted over the whole window
+	g := Goal{Target: 1000, TargetDate: date("2027-01-31"), Start: date("2026-10-01")}
+	p := Evaluate(g, 200, []Contribution{{Date: date("2026-10-02"), Amount: 200}}, date("2026-10-15"), 6)
+	if p.AverageMonthly != 200 {
+		t.Errorf("got pace %v, want 200", p.AverageMonthly)
+	}
+}
+
+func TestAchievedAndOverdue(t *testing.T) {
+	contributions := []Contribution{{Date: date("2026-02-01"), Amount: 30000}, {Date: date("2026-05-01"), Amount: 25000}}
+	p := Evaluate(car, 55000, contributions, date("2026-06-01"), 3)
+	if p.Status != Achieved || p.Remaining != 0 || p.Percent != 100 || p.AchievedDate != "2026-05-01" {
+		t.Errorf("unexpected progress %+v", p)
+	}
+
+	p = Evaluate(car, 40000, contributions[:1], date("2028-02-01"), 3)
+	if p.Status != Overdue || p.RequiredMonthly != 10000 || p.MonthsLeft != 0 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+}
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/reconcile"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// actualColumns is the base query of the actual listings, the condition on the items follows the WHERE
+const actualColumns = `
+	SELECT
+		ufa.UserFinancialActualID,
+		ufa.UserCategoryID,
+		ufa.FinancialUserItemID,
+		ufa.UserFinancialActualAmount,
+		ufa.UserFinancialActualtBeginDate,
+		ufa.UserFinancialActualEndDate,
+		ufa.CurrencyID,
+		COALESCE(uc.UserCategoryName, ''),
+		fui.FinancialUserItemName,
+		c.CurrencyName,
+		ufa.Note,
+		ufa.CreatedAt
+	FROM
+		userfinancialactual ufa
+	LEFT JOIN
+		usercategory uc ON ufa.UserCategoryID = uc.UserCategoryID
+	JOIN
+		financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+	JOIN
+		currency c ON ufa.CurrencyID = c.CurrencyID
+	WHERE
+		`
+
+// queryActuals lists the actuals of the items owned by the user in $1, with the extra filter
+func queryActuals(database *sql.DB, filter string, args ...interface{}) ([]models.UserFinancialActual, error) {
+	return queryActualsWhere(database, ownedItemCondition+filter, args...)
+}
+
+// queryActualsWhere runs actualColumns with the given condition and returns the scanned rows
+func queryActualsWhere(database *sql.DB, condition string, args ...interface{}) ([]models.UserFinancialActual, error) {
+	rows, err := database.Query(actualColumns+condition+` ORDER BY ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actuals := []models.UserFinancialActual{}
+	for rows.Next() {
+		var ufa models.UserFinancialActual
+		if err := rows.Scan(
+			&ufa.UserFinancialActualID,
+			&ufa.UserCategoryID,
+			&ufa.FinancialUserItemID,
+			&ufa.UserFinancialActualAmount,
+			&ufa.UserFinancialActualtBeginDate,
+			&ufa.UserFinancialActualEndDate,
+			&ufa.CurrencyID,
+			&ufa.UserCategoryName,
+			&ufa.FinancialUserItemName,
+			&ufa.CurrencyName,
+			&ufa.Note,
+			&ufa.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		actuals = append(actuals, ufa)
+	}
+	return actuals, rows.Err()
+}
+
+// validateActualPayload checks the payload fields and the ownership of the item and category
+func validateActualPayload(database queryer, userID int, payload *models.UserFinancialActualPayload) (time.Time, sql.NullTime, int, error) {
+	var endDate sql.NullTime
+
+	if payload.FinancialUserItemID == 0 {
+		return time.Time{}, endDate, http.StatusBadRequest, fmt.Errorf("FinancialUserItemID is required")
+	}
+	if payload.Amount <= 0 {
+		return time.Time{}, endDate, http.StatusBadRequest, fmt.Errorf("Amount must be greater than zero")
+	}
+
+	beginDate, err := time.Parse("2006-01-02", payload.BeginDate)
+	if err != nil {
+		return time.Time{}, endDate, http.StatusBadRequest, fmt.Errorf("Invalid BeginDate format. Expected YYYY-MM-DD")
+	}
+
+	if payload.EndDate != nil && *payload.EndDate != "" {
+		parsed, err := time.Parse("2006-01-02", *payload.EndDate)
+		if err != nil {
+			return beginDate, endDate, http.StatusBadRequest, fmt.Errorf("Invalid EndDate format. Expected YYYY-MM-DD")
+		}
+		if parsed.Before(beginDate) {
+			return beginDate, endDate, http.StatusBadRequest, fmt.Errorf("EndDate must be on or after BeginDate")
+		}
+		endDate = sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	// Actuals default to the same currency the procedures use for forecasts
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+	var currencyExists bool
+	if err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM currency WHERE CurrencyID = $1)`, payload.CurrencyID).Scan(&currencyExists); err != nil {
+		return beginDate, endDate, http.StatusInternalServerError, err
+	}
+	if !currencyExists {
+		return beginDate, endDate, http.StatusBadRequest, fmt.Errorf("Invalid CurrencyID")
+	}
+
+	owns, err := userOwnsItem(database, userID, payload.FinancialUserItemID)
+	if err != nil {
+		return beginDate, endDate, http.StatusInternalServerError, err
+	}
+	if !owns {
+		return beginDate, endDate, http.StatusNotFound, fmt.Errorf("Item not found or unauthorized")
+	}
+
+	if payload.UserCategoryID != nil && *payload.UserCategoryID != 0 {
+		owns, err := userOwnsCategory(database, userID, *payload.UserCategoryID)
+		if err != nil {
+			return beginDate, endDate, http.StatusInternalServerError, err
+		}
+		if !owns {
+			return beginDate, endDate, http.StatusNotFound, fmt.Errorf("Category not found or unauthorized")
+		}
+	} else {
+		payload.UserCategoryID = nil
+	}
+
+	if payload.Note != nil && strings.TrimSpace(*payload.Note) == "" {
+		payload.Note = nil
+	}
+
+	return beginDate, endDate, http.StatusOK, nil
+}
+
+// ActualsByItem lists the actuals of one item (?itemId=)
+func ActualsByItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ActualsByItem: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(r.URL.Query().Get("itemId"))
+	if err != nil || itemID == 0 {
+		http.Error(w, "Invalid itemId", http.StatusBadRequest)
+		return
+	}
+
+	actuals, err := queryActuals(db.GetDB(), ` AND ufa.FinancialUserItemID = $2`, user.UserProfileID, itemID)
+	if err != nil {
+		log.Println("ActualsByItem: Error fetching actuals:", err)
+		http.Error(w, "Error fetching actuals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_financial_actuals": actuals})
+}
+
+// ActualsByDateRange lists the actuals of the user that begin between "from" and "to"
+func ActualsByDateRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ActualsByDateRange: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Default window is the current month
+	now := time.Now()
+	defaultFrom := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, defaultFrom, defaultFrom.AddDate(0, 1, -1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actuals, err := queryActuals(db.GetDB(),
+		` AND ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3`,
+		user.UserProfileID, from, to)
+	if err != nil {
+		log.Println("ActualsByDateRange: Error fetching actuals:", err)
+		http.Error(w, "Error fetching actuals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":                   from.Format("2006-01-02"),
+		"to":                     to.Format("2006-01-02"),
+		"user_financial_actuals": actuals,
+	})
+}
+
+func CreateActual(w http.ResponseWriter, r *http.Request) {
+	// Ensure the request method is POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateActual: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserFinancialActualPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("CreateActual: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	beginDate, endDate, status, err := validateActualPayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("CreateActual: Error validating payload:", err)
+			http.Error(w, "Failed to create actual", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Uncategorized actuals get their category from the rules, a failure here doesn't stop the creation
+	if payload.UserCategoryID == nil {
+		if err := categorizeActual(database, user.UserProfileID, &payload); err != nil {
+			log.Println("CreateActual: Error applying rules:", err)
+		}
+	}
+
+	var actualID int
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			INSERT INTO UserFinancialActual (UserCategoryID, FinancialUserItemID, UserFinancialActualtBeginDate,
+				UserFinancialActualEndDate, UserFinancialActualAmount, CurrencyID, Note)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING UserFinancialActualID`,
+			payload.UserCategoryID, payload.FinancialUserItemID, beginDate, endDate,
+			payload.Amount, payload.CurrencyID, payload.Note).Scan(&actualID)
+	})
+	if err != nil {
+		log.Println("CreateActual: Error inserting actual:", err)
+		http.Error(w, "Failed to create actual", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":                   "success",
+		"message":                  "Actual created successfully",
+		"user_financial_actual_id": actualID,
+	}
+
+	// Match the new actual with its forecast and queue its likely duplicates, a failure here doesn't undo the creation
+	matches, err := autoReconcile(database, r, user.UserProfileID, reconcile.DefaultOptions, ` AND ufa.UserFinancialActualID = $2`, actualID)
+	if err != nil {
+		log.Println("CreateActual: Error reconciling actual:", err)
+	} else if len(matches) > 0 {
+		response["user_financial_forecast_id"] = matches[0].UserFinancialForecastID
+	}
+	if flagged, err := flagDuplicates(database, user.UserProfileID, []int64{int64(actualID)}); err != nil {
+		log.Println("CreateActual: Error flagging duplicates:", err)
+	} else if flagged > 0 {
+		response["possible_duplicates"] = flagged
+	}
+	checkBudgetAlerts(database, user.UserProfileID, payload.UserCategoryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func UpdateActual(w http.ResponseWriter, r *http.Request) {
+	// Ensure the request method is PUT
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateActual: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserFinancialActualPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("UpdateActual: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserFinancialActualID == 0 {
+		http.Error(w, "UserFinancialActualID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	beginDate, endDate, status, err := validateActualPayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("UpdateActual: Error validating payload:", err)
+			http.Error(w, "Failed to update actual", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// The current item of the actual must belong to the user as well, so an actual can't be moved out of someone else's item
+	var result sql.Result
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		result, err = tx.Exec(`
+			UPDATE userfinancialactual ufa
+			SET UserCategoryID = $3, FinancialUserItemID = $4, UserFinancialActualtBeginDate = $5,
+				UserFinancialActualEndDate = $6, UserFinancialActualAmount = $7, CurrencyID = $8, Note = $9
+			FROM financialuseritem fui
+			WHERE ufa.UserFinancialActualID = $2 AND ufa.FinancialUserItemID = fui.FinancialUserItemID AND `+ownedItemCondition,
+			user.UserProfileID, payload.UserFinancialActualID, payload.UserCategoryID, payload.FinancialUserItemID,
+			beginDate, endDate, payload.Amount, payload.CurrencyID, payload.Note)
+		return err
+	})
+	if err != nil {
+		log.Println("UpdateActual: Error updating actual:", err)
+		http.Error(w, "Failed to update actual", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Actual not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	checkBudgetAlerts(database, user.UserProfileID, payload.UserCategoryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Actual updated successfully"})
+}
+
+func DeleteActual(w http.ResponseWriter, r *http.Request) {
+	// Ensure the request method is DELETE
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteActual: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserFinancialActualID int `json:"userFinancialActualId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("DeleteActual: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserFinancialActualID == 0 {
+		http.Error(w, "UserFinancialActualID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	// Begin a transaction
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteActual: Error starting transaction:", err)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var owns bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM userfinancialactual ufa
+			JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+			WHERE ufa.UserFinancialActualID = $2 AND `+ownedItemCondition+`
+		)`, user.UserProfileID, payload.UserFinancialActualID).Scan(&owns)
+	if err != nil {
+		log.Println("DeleteActual: Error checking ownership:", err)
+		http.Error(w, "Failed to delete actual", http.StatusInternalServerError)
+		return
+	}
+	if !owns {
+		http.Error(w, "Actual not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	// Delete the links with forecasts first
+	_, err = tx.Exec("DELETE FROM userforecastactualrelation WHERE UserFinancialActualID = $1", payload.UserFinancialActualID)
+	if err != nil {
+		log.Println("DeleteActual: Error deleting from userforecastactualrelation:", err)
+		http.Error(w, "Failed to delete related records from userforecastactualrelation", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM userfinancialactual WHERE UserFinancialActualID = $1", payload.UserFinancialActualID)
+	if err != nil {
+		log.Println("DeleteActual: Error deleting from userfinancialactual:", err)
+		http.Error(w, "Failed to delete actual", http.StatusInternalServerError)
+		return
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		log.Println("DeleteActual: Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Actual deleted successfully"})
+}
@@ -3,9 +3,12 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"finanapp/internal/db"
+	"finanapp/internal/fx"
 	"finanapp/internal/models"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -30,8 +33,8 @@ func AssetItem(w http.ResponseWriter, r *http.Request) {
 		ItemID int `json:"itemId"`
 	}
 
-	// Decode the incoming JSON request body
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	// Decode the incoming JSON request body, which is optional when only the asset valuation is needed
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
 		log.Println("Error decoding request body:", err)
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
@@ -40,6 +43,18 @@ func AssetItem(w http.ResponseWriter, r *http.Request) {
 	// Get Database
 	database := db.GetDB()
 
+	// Optional currency to convert the amounts to
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
 	// Consulta UserFinancialForecasts
 	userFinancialForecastQuery := `
 	SELECT 
@@ -62,7 +77,7 @@ func AssetItem(w http.ResponseWriter, r *http.Request) {
 	JOIN 
 		currency c ON uff.CurrencyID = c.CurrencyID
 	WHERE
-		fui.userentityid = $1 and fui.EntityID = 5 and fui.FinancialUserItemID = $2
+		fui.userentityid = $1 and fui.EntityID = 5 and fui.FinancialUserItemID = $2 and fui.DeletedAt IS NULL
 	ORDER BY 
 		uff.UserFinancialForecastBeginDate;
 	`
@@ -107,19 +122,19 @@ func AssetItem(w http.ResponseWriter, r *http.Request) {
 		ufa.UserFinancialActualtBeginDate, 
 		ufa.UserFinancialActualEndDate, 
 		ufa.CurrencyID,
-		uc.UserCategoryName, -- Relacionamento com tabela de categorias
+		COALESCE(uc.UserCategoryName, ''), -- Relacionamento com tabela de categorias (opcional)
 		fui.FinancialUserItemName, -- Relacionamento com tabela de itens financeiros
 		c.CurrencyName -- Relacionamento com tabela de moedas
 	FROM 
 		userfinancialactual ufa
-	JOIN 
+	LEFT JOIN 
 		usercategory uc ON ufa.UserCategoryID = uc.UserCategoryID
 	JOIN 
 		financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
 	JOIN 
 		currency c ON ufa.CurrencyID = c.CurrencyID
 	WHERE
-		fui.userentityid = $1  and fui.EntityID = 5 and fui.FinancialUserItemID = $2   
+		fui.userentityid = $1  and fui.EntityID = 5 and fui.FinancialUserItemID = $2 and fui.DeletedAt IS NULL   
 	ORDER BY 
 		ufa.UserFinancialActualtBeginDate;`
 
@@ -152,14 +167,36 @@ func AssetItem(w http.ResponseWriter, r *http.Request) {
 		userFinancialActuals = append(userFinancialActuals, ufa)
 	}
 
+	if rates != nil {
+		convertForecasts(rates, currencyCode, userFinancialForecasts)
+		convertActuals(rates, currencyCode, userFinancialActuals)
+	}
+
+	// Valuation model and projected values of the asset in the path, for the next months (?months=12)
+	var valuation *assetValuation
+	if assetID, err := strconv.Atoi(r.PathValue("id")); err == nil && assetID > 0 {
+		months := 12
+		if v, err := strconv.Atoi(r.URL.Query().Get("months")); err == nil && v > 0 && v <= 120 {
+			months = v
+		}
+		valuation, err = loadAssetValuation(database, user.UserProfileID, assetID, months)
+		if err != nil {
+			log.Println("Erro ao buscar avaliação do asset:", err)
+			http.Error(w, "Erro ao buscar avaliação do asset", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Criar resposta final
 	response := struct {
 		UserFinancialForecasts []models.UserFinancialForecast `json:"user_financial_forecasts"`
 		UserFinancialActuals   []models.UserFinancialActual   `json:"user_financial_actuals"`
+		AssetValuation         *assetValuation                `json:"asset_valuation,omitempty"`
 	}{
 
 		UserFinancialForecasts: userFinancialForecasts,
 		UserFinancialActuals:   userFinancialActuals,
+		AssetValuation:         valuation,
 	}
 
 	// Configurar cabeçalhos da resposta
@@ -231,15 +268,17 @@ func CreateAsset(w http.ResponseWriter, r *http.Request) {
 
 	// Call stored procedure
 	var message string
-	err = database.QueryRow(
-		"CALL CreateUserAsset($1, $2, $3, $4, $5, $6, $7)",
-		payload.AssetTypeID,
-		user.UserProfileID,
-		payload.UserAssetName,
-		amount,
-		beginDate,
-		endDate,
-		message).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL CreateUserAsset($1, $2, $3, $4, $5, $6, $7)",
+			payload.AssetTypeID,
+			user.UserProfileID,
+			payload.UserAssetName,
+			amount,
+			beginDate,
+			endDate,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("Database error:", err)
@@ -265,7 +304,6 @@ func CreateAsset(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// TODO
 func UpdateAsset(w http.ResponseWriter, r *http.Request) {
 	// Ensure the request method is PUT
 	if r.Method != http.MethodPut {
@@ -273,6 +311,14 @@ func UpdateAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Retrieve user from context, the change is audited as theirs
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateAsset: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Decode request payload into struct
 	var payload struct {
 		ItemID                int    `json:"FinancialUserItemId"`   // ID of the item to be updated
@@ -295,20 +341,33 @@ func UpdateAsset(w http.ResponseWriter, r *http.Request) {
 	// Get database connection
 	database := db.GetDB()
 
-	// Prepare the SQL update statement
-	query := `UPDATE FinancialUserItem SET FinancialUserItemName = $1 WHERE FinancialUserItemId = $2`
+	// Only the live items of the user's assets can be renamed
+	query := `
+		UPDATE FinancialUserItem fui SET FinancialUserItemName = $1
+		WHERE fui.FinancialUserItemId = $2 AND fui.EntityID BETWEEN 9 AND 13 AND fui.DeletedAt IS NULL
+			AND fui.UserEntityID IN (SELECT UserAssetID FROM UserAsset WHERE UserProfileID = $3)`
 
 	// Execute the update statement
-	_, err = database.Exec(query, payload.FinancialUserItemName, payload.ItemID)
+	var result sql.Result
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		result, err = tx.Exec(query, payload.FinancialUserItemName, payload.ItemID, user.UserProfileID)
+		return err
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating income: %s", err.Error()), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating asset: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Asset item not found or unauthorized", http.StatusNotFound)
 		return
 	}
 
 	// Respond with success message
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Income updated successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Asset updated successfully"})
 }
 
 func DeleteAsset(w http.ResponseWriter, r *http.Request) {
@@ -318,6 +377,14 @@ func DeleteAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteAsset: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	// Decode request payload into struct
 	var payload struct {
 		ItemID int `json:"itemId"`
@@ -333,50 +400,26 @@ func DeleteAsset(w http.ResponseWriter, r *http.Request) {
 	// Get database connection
 	database := db.GetDB()
 
-	// Begin a transaction
-	tx, err := database.Begin()
-	if err != nil {
-		log.Println("Error starting transaction:", err)
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback() // Ensure rollback on error
-
-	// Delete related records from 'userfinancialactual' table
-	_, err = tx.Exec("DELETE FROM userfinancialactual WHERE FinancialUserItemID = $1", payload.ItemID)
-	if err != nil {
-		log.Println("Error deleting from userfinancialactual:", err)
-		http.Error(w, "Failed to delete related records from userfinancialactual", http.StatusInternalServerError)
-		return
-	}
-
-	// Delete related records from 'userfinancialforecast' table
-	_, err = tx.Exec("DELETE FROM userfinancialforecast WHERE FinancialUserItemID = $1", payload.ItemID)
+	// Move the item and its children to the trash, their forecasts and actuals are kept until the trash is purged
+	var trashed bool
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		trashed, err = moveItemToTrash(tx, user.UserProfileID, payload.ItemID)
+		return err
+	})
 	if err != nil {
-		log.Println("Error deleting from userfinancialforecast:", err)
-		http.Error(w, "Failed to delete related records from userfinancialforecast", http.StatusInternalServerError)
-		return
-	}
-
-	// Finally, delete the record from 'financialuseritem' table
-	_, err = tx.Exec("DELETE FROM financialuseritem WHERE FinancialUserItemID = $1", payload.ItemID)
-	if err != nil {
-		log.Println("Error deleting from financialuseritem:", err)
+		log.Println("Error moving financialuseritem to the trash:", err)
 		http.Error(w, "Failed to delete record from financialuseritem", http.StatusInternalServerError)
 		return
 	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		log.Println("Error committing transaction:", err)
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+	if !trashed {
+		http.Error(w, "Item not found or unauthorized", http.StatusNotFound)
 		return
 	}
 
 	// Respond with success message
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Income deleted successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Income moved to the trash"})
 }
 
 func CreateAssetParentIncome(w http.ResponseWriter, r *http.Request) {
@@ -415,17 +458,19 @@ func CreateAssetParentIncome(w http.ResponseWriter, r *http.Request) {
 
 	// Executa a procedure
 	var message string
-	err := database.QueryRow(`
-		CALL CreateUserAssetParentIncome($1, $2, $3, $4, $5, $6, $7, $8)
-	`,
-		user.UserProfileID,
-		payload.UserAssetID,
-		payload.FinancialUserItemName,
-		payload.RecurrencyID,
-		payload.FinancialUserEntityItemID,
-		payload.ParentIncomeAmount,
-		payload.BeginDate,
-		message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			CALL CreateUserAssetParentIncome($1, $2, $3, $4, $5, $6, $7, $8)
+		`,
+			user.UserProfileID,
+			payload.UserAssetID,
+			payload.FinancialUserItemName,
+			payload.RecurrencyID,
+			payload.FinancialUserEntityItemID,
+			payload.ParentIncomeAmount,
+			payload.BeginDate,
+			message).Scan(&message)
+	})
 
 	// Trata erro de execução
 	if err != nil {
@@ -489,13 +534,15 @@ func DeleteAssetParentIncome(w http.ResponseWriter, r *http.Request) {
 
 	// Executa a procedure
 	var message string
-	err := database.QueryRow(`
-		CALL DeleteUserAssetParentIncome($1, $2, $3, $4)
-	`,
-		payload.FinancialUserItemID,
-		user.UserProfileID,
-		payload.UserAssetID,
-		message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			CALL DeleteUserAssetParentIncome($1, $2, $3, $4)
+		`,
+			payload.FinancialUserItemID,
+			user.UserProfileID,
+			payload.UserAssetID,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Printf("Error executing stored procedure: %v", err)
@@ -549,37 +596,84 @@ func CreateAssetChildIncomeTax(w http.ResponseWriter, r *http.Request) {
 
 	// Basic validations
 	if payload.UserAssetID == 0 || payload.FinancialUserItemName == "" ||
-		payload.FinancialUserEntityItemID == 0 || payload.ParentFinancialUserItemID == 0 || payload.TaxIncomeAmount <= 0 {
+		payload.FinancialUserEntityItemID == 0 || payload.ParentFinancialUserItemID == 0 || payload.TaxIncomeAmount < 0 {
 		http.Error(w, "Missing or invalid required fields", http.StatusBadRequest)
 		return
 	}
 
-	// Execute stored procedure
-	var message string
-	err := database.QueryRow(`
-		CALL CreateUserAssetChildIncomeTax($1, $2, $3, $4, $5, $6, $7)
-	`,
-		user.UserProfileID,
-		payload.UserAssetID,
-		payload.FinancialUserItemName,
-		payload.FinancialUserEntityItemID,
-		payload.ParentFinancialUserItemID,
-		payload.TaxIncomeAmount,
-		message).Scan(&message)
-
+	// The amount is only required when the TaxType has no rule to compute it
+	_, computed, err := taxRuleFor(database, payload.FinancialUserEntityItemID)
 	if err != nil {
-		log.Printf("Error calling procedure: %v", err)
-		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
+		log.Println("CreateAssetChildIncomeTax: Error resolving tax rule:", err)
+		http.Error(w, "Invalid tax rule for this tax type", http.StatusInternalServerError)
+		return
+	}
+	if !computed && payload.TaxIncomeAmount == 0 {
+		http.Error(w, "tax_income_amount is required for tax types without a rule", http.StatusBadRequest)
 		return
 	}
 
-	// Parse stored procedure response
+	// Execute stored procedure
+	var message string
 	var resp models.Response
-	if err := json.Unmarshal([]byte(message), &resp); err != nil {
-		log.Println("Error parsing stored procedure response:", err)
+	var parseErr error
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		// The tax forecasts are computed from the parent, which must be an income of the asset
+		owns, err := userOwnsParentIncome(tx, user.UserProfileID, payload.ParentFinancialUserItemID, payload.UserAssetID)
+		if err == nil && !owns {
+			err = errParentIncomeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow(`
+			CALL CreateUserAssetChildIncomeTax($1, $2, $3, $4, $5, $6, $7)
+		`,
+			user.UserProfileID,
+			payload.UserAssetID,
+			payload.FinancialUserItemName,
+			payload.FinancialUserEntityItemID,
+			payload.ParentFinancialUserItemID,
+			payload.TaxIncomeAmount,
+			message).Scan(&message)
+		if err != nil {
+			return err
+		}
+
+		// Parse stored procedure response
+		if parseErr = json.Unmarshal([]byte(message), &resp); parseErr != nil {
+			return parseErr
+		}
+
+		// Replace the copied amount with the one computed from each parent forecast, in the same transaction
+		if computed && resp.Status == "success" {
+			var childItemID int
+			err = tx.QueryRow(`
+				SELECT FinancialUserItemID FROM FinancialUserItem
+				WHERE ParentFinancialUserItemID = $1 AND EntityID = 12 AND UserEntityID = $2 AND FinancialUserEntityItemID = $3
+				ORDER BY FinancialUserItemID DESC LIMIT 1`,
+				payload.ParentFinancialUserItemID, payload.UserAssetID, payload.FinancialUserEntityItemID).Scan(&childItemID)
+			if err == nil {
+				_, err = recomputeChildTaxes(tx, payload.ParentFinancialUserItemID, childItemID, time.Time{})
+			}
+		}
+		return err
+	})
+
+	if parseErr != nil {
+		log.Println("Error parsing stored procedure response:", parseErr)
 		http.Error(w, "Invalid response from stored procedure", http.StatusInternalServerError)
 		return
 	}
+	if errors.Is(err, errParentIncomeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("CreateAssetChildIncomeTax: Error creating tax: %v", err)
+		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
+		return
+	}
 
 	// Send response
 	w.Header().Set("Content-Type", "application/json")
@@ -627,17 +721,31 @@ func CreateAssetChildIncomeExpense(w http.ResponseWriter, r *http.Request) {
 
 	// Execute stored procedure
 	var message string
-	err := database.QueryRow(`
-		CALL CreateUserAssetChildIncomeExpense($1, $2, $3, $4, $5, $6, $7)
-	`,
-		user.UserProfileID,
-		payload.UserAssetID,
-		payload.FinancialUserItemName,
-		payload.FinancialUserEntityItemID,
-		payload.ParentFinancialUserItemID,
-		payload.ExpenseAmount,
-		message).Scan(&message)
-
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		// The expense forecasts follow the parent, which must be an income of the asset
+		owns, err := userOwnsParentIncome(tx, user.UserProfileID, payload.ParentFinancialUserItemID, payload.UserAssetID)
+		if err == nil && !owns {
+			err = errParentIncomeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(`
+			CALL CreateUserAssetChildIncomeExpense($1, $2, $3, $4, $5, $6, $7)
+		`,
+			user.UserProfileID,
+			payload.UserAssetID,
+			payload.FinancialUserItemName,
+			payload.FinancialUserEntityItemID,
+			payload.ParentFinancialUserItemID,
+			payload.ExpenseAmount,
+			message).Scan(&message)
+	})
+
+	if errors.Is(err, errParentIncomeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("Error calling procedure: %v", err)
 		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
@@ -704,9 +812,11 @@ func DeleteUserAssetChildIncomeExpense(w http.ResponseWriter, r *http.Request) {
 	// Chama a stored procedure
 	var message string
 
-	err := database.QueryRow(`
-		CALL DeleteUserAssetChildIncomeExpense($1, $2, $3, $4)
-	`, input.FinancialUserItemID, user.UserProfileID, input.UserAssetID, message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			CALL DeleteUserAssetChildIncomeExpense($1, $2, $3, $4)
+		`, input.FinancialUserItemID, user.UserProfileID, input.UserAssetID, message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Printf("DeleteUserAssetChildIncomeExpense: Error calling procedure - %v", err)
@@ -774,9 +884,11 @@ func DeleteUserAssetChildIncomeTax(w http.ResponseWriter, r *http.Request) {
 	// Chama a stored procedure
 	var message string
 
-	err := database.QueryRow(`
-		CALL DeleteUserAssetChildIncomeTax($1, $2, $3, $4)
-	`, input.FinancialUserItemID, user.UserProfileID, input.UserAssetID, message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`
+			CALL DeleteUserAssetChildIncomeTax($1, $2, $3, $4)
+		`, input.FinancialUserItemID, user.UserProfileID, input.UserAssetID, message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Printf("DeleteUserAssetChildIncomeTax: Error calling procedure - %v", err)
@@ -840,7 +952,9 @@ func CreateAssetCategory(w http.ResponseWriter, r *http.Request) {
 	query := `INSERT INTO UserCategory (UserCategoryName, UserProfileID, EntityID, FinancialGroupEntityItemID, IsActive)
 			  VALUES ($1, $2, $3, $4, $5) RETURNING UserCategoryID`
 	var userCategoryID int
-	err := database.QueryRow(query, payload.UserCategoryName, user.UserProfileID, entityID, financialGroupEntityItemID, isActive).Scan(&userCategoryID)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(query, payload.UserCategoryName, user.UserProfileID, entityID, financialGroupEntityItemID, isActive).Scan(&userCategoryID)
+	})
 	if err != nil {
 		log.Println("Error inserting new category:", err)
 		http.Error(w, "Failed to create category", http.StatusInternalServerError)
@@ -893,10 +1007,14 @@ func DeleteAssetCategory(w http.ResponseWriter, r *http.Request) {
 	database := db.GetDB()
 
 	// Executes the DELETE in the UserCategory table, ensuring it belongs to the authenticated user
-	result, err := database.Exec(`
+	var result sql.Result
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		result, err = tx.Exec(`
         DELETE FROM usercategory 
         WHERE UserCategoryID = $1 AND UserProfileID = $2`,
-		payload.UserCategoryID, user.UserProfileID)
+			payload.UserCategoryID, user.UserProfileID)
+		return err
+	})
 
 	if err != nil {
 		log.Println("Error deleting category:", err)
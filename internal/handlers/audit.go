@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/audit"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditEntity is a table whose changes are in the audit log, with the query telling if the user in $1 can see the
+// record in $2
+type auditEntity struct {
+	table  string
+	access string
+}
+
+// auditEntities are the values of the "entity" query parameter of the audit history
+var auditEntities = map[string]auditEntity{
+	"item": {"FinancialUserItem", `SELECT 1 FROM FinancialUserItem fui
+		WHERE fui.FinancialUserItemID = $2 AND (` + ownedOrTrashedItemCondition + ` OR ` + groupOrTrashedItemCondition + `)`},
+	"forecast": {"UserFinancialForecast", `SELECT 1 FROM UserFinancialForecast uff
+		JOIN FinancialUserItem fui ON uff.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE uff.UserFinancialForecastID = $2 AND (` + ownedOrTrashedItemCondition + ` OR ` + groupOrTrashedItemCondition + `)`},
+	"actual": {"UserFinancialActual", `SELECT 1 FROM UserFinancialActual ufa
+		JOIN FinancialUserItem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE ufa.UserFinancialActualID = $2 AND (` + ownedOrTrashedItemCondition + ` OR ` + groupOrTrashedItemCondition + `)`},
+	"asset":    {"UserAsset", `SELECT 1 FROM UserAsset WHERE UserAssetID = $2 AND UserProfileID = $1`},
+	"category": {"UserCategory", `SELECT 1 FROM UserCategory WHERE UserCategoryID = $2 AND UserProfileID = $1`},
+}
+
+// beginAudit starts a transaction whose changes to the audited tables are logged as made by the user, with the
+// request ID and source IP of r. The settings are local to the transaction, so they never leak to other requests
+// through the connection pool.
+func beginAudit(database *sql.DB, r *http.Request, userID int) (*sql.Tx, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	requestID, _ := r.Context().Value("request_id").(string)
+	_, err = tx.Exec(`SELECT set_config('finanapp.actor_id', $1, TRUE), set_config('finanapp.request_id', $2, TRUE),
+		set_config('finanapp.source_ip', $3, TRUE)`, strconv.Itoa(userID), requestID, sourceIP(r))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// audited runs fn in a transaction started by beginAudit, committed when fn succeeds
+func audited(database *sql.DB, r *http.Request, userID int, fn func(tx *sql.Tx) error) error {
+	tx, err := beginAudit(database, r, userID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sourceIP is the address of the client that sent r, without the port
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Audit lists the history of a record, oldest change first: GET /api/audit?entity=item|forecast|actual|asset|category&id=
+// Records that no longer exist can be reviewed by the users who changed them.
+func Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Audit: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	entity, ok := auditEntities[strings.ToLower(r.URL.Query().Get("entity"))]
+	if !ok {
+		http.Error(w, "Invalid entity, use item, forecast, actual, asset or category", http.StatusBadRequest)
+		return
+	}
+	recordID, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || recordID <= 0 {
+		http.Error(w, "Invalid record ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var allowed bool
+	err = database.QueryRow(`SELECT EXISTS (`+entity.access+`)
+		OR EXISTS (SELECT 1 FROM AuditLog WHERE EntityName = $3 AND EntityRecordID = $2 AND ActorUserProfileID = $1)`,
+		user.UserProfileID, recordID, entity.table).Scan(&allowed)
+	if err != nil {
+		log.Println("Audit: Error checking access:", err)
+		http.Error(w, "Error fetching audit history", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "Record not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	rows, err := database.Query(`
+		SELECT al.AuditLogID, al.EntityName, al.EntityRecordID, al.AuditAction, al.ActorUserProfileID,
+			up.FirstName || ' ' || up.LastName, al.BeforeData, al.AfterData, al.RequestID, al.SourceIP, al.ChangedAt
+		FROM AuditLog al
+		LEFT JOIN UserProfile up ON up.UserProfileID = al.ActorUserProfileID
+		WHERE al.EntityName = $1 AND al.EntityRecordID = $2
+		ORDER BY al.AuditLogID`, entity.table, recordID)
+	if err != nil {
+		log.Println("Audit: Error fetching audit history:", err)
+		http.Error(w, "Error fetching audit history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLog{}
+	for rows.Next() {
+		var entry models.AuditLog
+		var before, after []byte
+		var changedAt time.Time
+		err := rows.Scan(&entry.AuditLogID, &entry.EntityName, &entry.EntityRecordID, &entry.AuditAction,
+			&entry.ActorUserProfileID, &entry.ActorName, &before, &after, &entry.RequestID, &entry.SourceIP, &changedAt)
+		if err == nil {
+			entry.ChangedFields, err = audit.ChangedFields(before, after)
+		}
+		if err != nil {
+			log.Println("Audit: Error scanning audit history:", err)
+			http.Error(w, "Error fetching audit history", http.StatusInternalServerError)
+			return
+		}
+		entry.BeforeData, entry.AfterData = before, after // NULL columns are encoded as null
+		entry.ChangedAt = changedAt.Format(time.RFC3339)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("Audit: Error fetching audit history:", err)
+		http.Error(w, "Error fetching audit history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entries)
+}
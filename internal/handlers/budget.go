@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"finanapp/internal/budget"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/messaging"
+	"finanapp/internal/models"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// budgetStatus is the status of a budget with its category and currency
+type budgetStatus struct {
+	budget.Status
+	CategoryName string `json:"categoryName"`
+	Currency     string `json:"currency"`
+	Rollover     bool   `json:"rollover"`
+	Thresholds   []int  `json:"thresholds"`
+}
+
+// budgetAlert is the message published on the budget_alerts topic
+type budgetAlert struct {
+	UserProfileID int     `json:"userProfileId"`
+	BudgetID      int     `json:"budgetId"`
+	CategoryID    int     `json:"categoryId"`
+	CategoryName  string  `json:"categoryName"`
+	PeriodStart   string  `json:"periodStart"`
+	Threshold     int     `json:"threshold"`
+	Spent         float64 `json:"spent"`
+	Available     float64 `json:"available"`
+	PercentUsed   float64 `json:"percentUsed"`
+	Currency      string  `json:"currency"`
+}
+
+var (
+	budgetProducerOnce sync.Once
+	budgetProducer     *messaging.Producer
+	errNoProducer      = errors.New("NSQ producer not available")
+)
+
+// publishBudgetAlert publishes the alert on NSQ, creating the producer on first use
+func publishBudgetAlert(alert budgetAlert) error {
+	budgetProducerOnce.Do(func() {
+		producer, err := messaging.NewProducer()
+		if err != nil {
+			log.Println("Budget: Error creating NSQ producer:", err)
+			return
+		}
+		budgetProducer = producer
+	})
+	if budgetProducer == nil {
+		return errNoProducer
+	}
+
+	message, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	return budgetProducer.BudgetAlert(message)
+}
+
+// evaluateBudgets computes the status of the user's budgets on a date. categoryID restricts it to the budget
+// of one category, 0 means all of them.
+func evaluateBudgets(database *sql.DB, userID, categoryID int, on time.Time) ([]budgetStatus, error) {
+	rows, err := database.Query(`
+		SELECT b.UserCategoryBudgetID, b.UserCategoryID, uc.UserCategoryName, b.BudgetPeriod, b.BudgetAmount,
+			b.CurrencyID, c.CurrencyAbreviation, b.BudgetRollover, b.AlertThresholds, b.BudgetStartDate
+		FROM UserCategoryBudget b
+		JOIN UserCategory uc ON uc.UserCategoryID = b.UserCategoryID
+		JOIN Currency c ON c.CurrencyID = b.CurrencyID
+		WHERE uc.UserProfileID = $1 AND ($2 = 0 OR b.UserCategoryID = $2)
+		ORDER BY uc.UserCategoryName`, userID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	type loadedBudget struct {
+		budget.Budget
+		name       string
+		currencyID int
+		currency   string
+	}
+	var budgets []loadedBudget
+	for rows.Next() {
+		var b loadedBudget
+		var thresholds pq.Int64Array
+		if err := rows.Scan(&b.BudgetID, &b.CategoryID, &b.name, &b.Period, &b.Amount,
+			&b.currencyID, &b.currency, &b.Rollover, &thresholds, &b.Start); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, t := range thresholds {
+			b.Thresholds = append(b.Thresholds, int(t))
+		}
+		budgets = append(budgets, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Rates are only needed when an actual isn't in the budget currency
+	var rates *fx.Table
+	statuses := make([]budgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		rows, err := database.Query(`
+			SELECT UserFinancialActualtBeginDate, UserFinancialActualAmount, CurrencyID
+			FROM UserFinancialActual
+			WHERE UserCategoryID = $1 AND UserFinancialActualtBeginDate >= $2 AND UserFinancialActualtBeginDate <= $3`,
+			b.CategoryID, budget.PeriodStart(b.Period, b.Start), on)
+		if err != nil {
+			return nil, err
+		}
+
+		var spends []budget.Spend
+		for rows.Next() {
+			var s budget.Spend
+			var currencyID int
+			if err := rows.Scan(&s.Date, &s.Amount, &currencyID); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if currencyID != b.currencyID {
+				if rates == nil {
+					if rates, err = fx.Load(database); err != nil {
+						rows.Close()
+						return nil, err
+					}
+				}
+				if s.Amount, err = rates.Convert(s.Amount, currencyID, b.currency, s.Date); err != nil {
+					rows.Close()
+					return nil, err
+				}
+			}
+			spends = append(spends, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, budgetStatus{
+			Status:       budget.Evaluate(b.Budget, spends, on),
+			CategoryName: b.name,
+			Currency:     b.currency,
+			Rollover:     b.Rollover,
+			Thresholds:   b.Thresholds,
+		})
+	}
+	return statuses, nil
+}
+
+// raiseBudgetAlerts publishes the thresholds crossed since the last check. A threshold is recorded once it is
+// published, so it is alerted once per period and retried on the next check when NSQ is down.
+func raiseBudgetAlerts(database *sql.DB, userID int, statuses []budgetStatus) ([]budgetAlert, error) {
+	var raised []budgetAlert
+	for _, st := range statuses {
+		if len(st.Crossed) == 0 {
+			continue
+		}
+
+		var alerted pq.Int64Array
+		err := database.QueryRow(`
+			SELECT COALESCE(array_agg(AlertThreshold), '{}')
+			FROM UserCategoryBudgetAlert
+			WHERE UserCategoryBudgetID = $1 AND BudgetPeriodStart = $2`,
+			st.BudgetID, st.PeriodStart).Scan(&alerted)
+		if err != nil {
+			return raised, err
+		}
+		sent := make([]int, 0, len(alerted))
+		for _, t := range alerted {
+			sent = append(sent, int(t))
+		}
+
+		for _, threshold := range budget.NewAlerts(st.Status, sent) {
+			alert := budgetAlert{
+				UserProfileID: userID,
+				BudgetID:      st.BudgetID,
+				CategoryID:    st.CategoryID,
+				CategoryName:  st.CategoryName,
+				PeriodStart:   st.PeriodStart,
+				Threshold:     threshold,
+				Spent:         st.Spent,
+				Available:     st.Available,
+				PercentUsed:   st.PercentUsed,
+				Currency:      st.Currency,
+			}
+			if err := publishBudgetAlert(alert); err != nil {
+				log.Println("Budget: Error publishing alert:", err)
+				continue
+			}
+
+			_, err = database.Exec(`
+				INSERT INTO UserCategoryBudgetAlert (UserCategoryBudgetID, BudgetPeriodStart, AlertThreshold)
+				VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+				st.BudgetID, st.PeriodStart, threshold)
+			if err != nil {
+				return raised, err
+			}
+			raised = append(raised, alert)
+		}
+	}
+	return raised, nil
+}
+
+// checkBudgetAlerts raises the alerts of the budget of a category after its actuals changed.
+// Errors are logged, they don't undo the change.
+func checkBudgetAlerts(database *sql.DB, userID int, categoryID *int) {
+	if categoryID == nil {
+		return
+	}
+	statuses, err := evaluateBudgets(database, userID, *categoryID, time.Now())
+	if err == nil {
+		_, err = raiseBudgetAlerts(database, userID, statuses)
+	}
+	if err != nil {
+		log.Println("Budget: Error checking budget alerts:", err)
+	}
+}
+
+// SaveBudget creates or replaces the budget of a category
+func SaveBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SaveBudget: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserCategoryID int     `json:"userCategoryId"`
+		Period         string  `json:"period"`
+		Amount         float64 `json:"amount"`
+		CurrencyID     int     `json:"currencyId"`
+		Rollover       bool    `json:"rollover"`
+		Thresholds     []int   `json:"thresholds"`
+		StartDate      string  `json:"startDate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Period == "" {
+		payload.Period = budget.Monthly
+	}
+	if len(payload.Thresholds) == 0 {
+		payload.Thresholds = budget.DefaultThresholds
+	}
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+	start := time.Now()
+	if payload.StartDate != "" {
+		parsed, err := time.Parse("2006-01-02", payload.StartDate)
+		if err != nil {
+			http.Error(w, "Invalid startDate format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	}
+
+	b := budget.Budget{Period: payload.Period, Amount: payload.Amount, Thresholds: payload.Thresholds}
+	if payload.UserCategoryID == 0 {
+		http.Error(w, "userCategoryId is required", http.StatusBadRequest)
+		return
+	}
+	if err := b.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var currencyExists bool
+	if err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM currency WHERE CurrencyID = $1)`, payload.CurrencyID).Scan(&currencyExists); err != nil {
+		log.Println("SaveBudget: Error checking currency:", err)
+		http.Error(w, "Failed to save budget", http.StatusInternalServerError)
+		return
+	}
+	if !currencyExists {
+		http.Error(w, "Invalid currencyId", http.StatusBadRequest)
+		return
+	}
+
+	owns, err := userOwnsCategory(database, user.UserProfileID, payload.UserCategoryID)
+	if err != nil {
+		log.Println("SaveBudget: Error checking category:", err)
+		http.Error(w, "Failed to save budget", http.StatusInternalServerError)
+		return
+	}
+	if !owns {
+		http.Error(w, "Category not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	thresholds := make(pq.Int64Array, 0, len(payload.Thresholds))
+	for _, t := range payload.Thresholds {
+		thresholds = append(thresholds, int64(t))
+	}
+
+	var budgetID int
+	err = database.QueryRow(`
+		INSERT INTO UserCategoryBudget (UserCategoryID, BudgetPeriod, BudgetAmount, CurrencyID, BudgetRollover,
+			AlertThresholds, BudgetStartDate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (UserCategoryID) DO UPDATE SET BudgetPeriod = EXCLUDED.BudgetPeriod,
+			BudgetAmount = EXCLUDED.BudgetAmount, CurrencyID = EXCLUDED.CurrencyID,
+			BudgetRollover = EXCLUDED.BudgetRollover, AlertThresholds = EXCLUDED.AlertThresholds,
+			BudgetStartDate = EXCLUDED.BudgetStartDate
+		RETURNING UserCategoryBudgetID`,
+		payload.UserCategoryID, payload.Period, payload.Amount, payload.CurrencyID, payload.Rollover,
+		thresholds, start.Format("2006-01-02")).Scan(&budgetID)
+	if err != nil {
+		log.Println("SaveBudget: Error saving budget:", err)
+		http.Error(w, "Failed to save budget", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                  "success",
+		"message":                 "Budget saved successfully",
+		"user_category_budget_id": budgetID,
+	})
+}
+
+// DeleteBudget removes the budget of a category and its alert history
+func DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteBudget: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserCategoryBudgetID int `json:"userCategoryBudgetId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserCategoryBudgetID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`
+		DELETE FROM UserCategoryBudget b
+		USING UserCategory uc
+		WHERE b.UserCategoryBudgetID = $2 AND uc.UserCategoryID = b.UserCategoryID AND uc.UserProfileID = $1`,
+		user.UserProfileID, payload.UserCategoryBudgetID)
+	if err != nil {
+		log.Println("DeleteBudget: Error deleting budget:", err)
+		http.Error(w, "Failed to delete budget", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Budget not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Budget deleted successfully"})
+}
+
+// BudgetStatus returns the consumption of every budget of the user in the period containing ?date=
+// (today by default), raising the alerts of the thresholds crossed since the last check
+func BudgetStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("BudgetStatus: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	on := time.Now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		on = parsed
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	statuses, err := evaluateBudgets(database, user.UserProfileID, 0, on)
+	if err != nil {
+		log.Println("BudgetStatus: Error evaluating budgets:", err)
+		http.Error(w, "Error fetching budget status", http.StatusInternalServerError)
+		return
+	}
+
+	// Alerts only make sense for the current periods, each budget with its own period
+	alerts := []budgetAlert{}
+	now := time.Now()
+	current := make([]budgetStatus, 0, len(statuses))
+	for _, st := range statuses {
+		if budget.PeriodStart(st.Period, on).Equal(budget.PeriodStart(st.Period, now)) {
+			current = append(current, st)
+		}
+	}
+	if len(current) > 0 {
+		raised, err := raiseBudgetAlerts(database, user.UserProfileID, current)
+		if err != nil {
+			log.Println("BudgetStatus: Error raising alerts:", err)
+		}
+		if raised != nil {
+			alerts = raised
+		}
+	}
+
+	overBudget := 0
+	for _, st := range statuses {
+		if st.Remaining < 0 {
+			overBudget++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"date":        on.Format("2006-01-02"),
+		"budgets":     statuses,
+		"over_budget": overBudget,
+		"alerts":      alerts,
+	})
+}
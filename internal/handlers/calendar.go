@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/cashflow"
+	"finanapp/internal/db"
+	"finanapp/internal/ical"
+	"finanapp/internal/models"
+	"finanapp/internal/recurrence"
+	"finanapp/internal/report"
+	"finanapp/internal/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The feed covers the occurrences of this rolling window around the day it's requested
+const (
+	calendarDaysBack  = 30
+	calendarDaysAhead = 365
+)
+
+// calendarRefresh is how often calendar clients are asked to fetch the feed again
+const calendarRefresh = 6 * time.Hour
+
+// calendarFeedPath is the URL of the feed of a token
+func calendarFeedPath(token string) string {
+	return "/api/calendar/" + token + ".ics"
+}
+
+// calendarKindLabels prefix the summary of the events
+var calendarKindLabels = map[string]string{
+	cashflow.KindInflow:  "Income",
+	cashflow.KindTax:     "Tax",
+	cashflow.KindExpense: "Bill",
+}
+
+// calendarRule is the RRULE of the projected dates of the item, or "" when the recurrence doesn't map cleanly on
+// RFC 5545 and the dates must be listed one by one. The rule is anchored on the first date and ends with the window.
+func calendarRule(s *forecastSchedule, dates []time.Time, to time.Time) string {
+	if len(dates) < 2 {
+		return ""
+	}
+	rule, err := s.rule()
+	if err != nil || rule.Freq == recurrence.Once || rule.Count > 0 {
+		return ""
+	}
+	// Our monthly and yearly rules clamp the anchor day to the end of shorter months, RFC 5545 skips those months
+	if (rule.Freq == recurrence.Monthly || rule.Freq == recurrence.Yearly) && len(rule.ByMonthDay) == 0 &&
+		len(rule.ByDay) == 0 && s.Forecasts[0].Date.Day() > 28 {
+		return ""
+	}
+	// Weeks of RFC 5545 start on WKST, ours on the anchor date
+	if rule.Freq == recurrence.Weekly && rule.Interval > 1 && len(rule.ByDay) > 1 {
+		return ""
+	}
+
+	clean := *rule
+	clean.Until = to
+	if !rule.Until.IsZero() && rule.Until.Before(clean.Until) {
+		clean.Until = rule.Until
+	}
+	if s.RecurrencyEndDate.Valid && s.RecurrencyEndDate.Time.Before(clean.Until) {
+		clean.Until = s.RecurrencyEndDate.Time
+	}
+
+	expanded := clean.Between(dates[0], dates[0], to)
+	if len(expanded) != len(dates) {
+		return ""
+	}
+	for i := range dates {
+		if !expanded[i].Equal(dates[i]) {
+			return ""
+		}
+	}
+	return clean.String()
+}
+
+// calendarEvents turns the occurrences of the item inside [from, to] into events. Stored forecasts are one event
+// each, since their amounts can differ; the projected ones share the last amount and become a single recurring
+// event when the rule allows it.
+func calendarEvents(s *forecastSchedule, from, to time.Time, currencies map[int]string) []ical.Event {
+	kind := cashflow.KindOf(s.EntityID)
+	label, ok := calendarKindLabels[kind]
+	if !ok {
+		return nil
+	}
+
+	event := func(uid string, o models.ForecastOccurrence) ical.Event {
+		date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+		amount := strings.TrimSpace(report.FormatAmount(o.Amount) + " " + currencies[o.CurrencyID])
+		source := "Forecast"
+		if o.Projected {
+			source = "Projected from the " + strings.ToLower(s.RecurrencyName) + " recurrence"
+		}
+		return ical.Event{
+			UID:         uid,
+			Date:        date,
+			Summary:     fmt.Sprintf("%s: %s %s", label, s.ItemName, amount),
+			Description: fmt.Sprintf("%s\nAmount: %s\nType: %s\n%s", s.ItemName, amount, s.EntityType, source),
+			Categories:  []string{label},
+			Transparent: true,
+		}
+	}
+
+	var events []ical.Event
+	var projected []models.ForecastOccurrence
+	var projectedDates []time.Time
+	for _, o := range s.occurrences(from, to) {
+		if !o.Projected {
+			events = append(events, event(fmt.Sprintf("forecast-%d@finanapp", *o.UserFinancialForecastID), o))
+			continue
+		}
+		date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+		projected = append(projected, o)
+		projectedDates = append(projectedDates, date)
+	}
+
+	if rule := calendarRule(s, projectedDates, to); rule != "" {
+		e := event(fmt.Sprintf("item-%d-projected@finanapp", s.ItemID), projected[0])
+		e.RRule = rule
+		return append(events, e)
+	}
+	for _, o := range projected {
+		events = append(events, event(fmt.Sprintf("item-%d-%s@finanapp", s.ItemID, o.OccurrenceDate), o))
+	}
+	return events
+}
+
+// currencyCodes maps every CurrencyID to its abbreviation
+func currencyCodes(database *sql.DB) (map[int]string, error) {
+	rows, err := database.Query(`SELECT CurrencyID, CurrencyAbreviation FROM currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := map[int]string{}
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			return nil, err
+		}
+		codes[id] = strings.TrimSpace(code)
+	}
+	return codes, rows.Err()
+}
+
+// CalendarICS serves the calendar feed of the token: GET /api/calendar/{token}.ics. It isn't behind the auth
+// middleware, the token identifies the user.
+func CalendarICS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.PathValue("file")
+	token := strings.TrimSuffix(file, ".ics")
+	if token == file || token == "" {
+		http.Error(w, "Calendar not found", http.StatusNotFound)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var userID int
+	err := database.QueryRow(`
+		UPDATE UserCalendarFeed SET LastAccessedAt = CURRENT_TIMESTAMP
+		WHERE FeedToken = $1 AND RevokedAt IS NULL
+		RETURNING UserProfileID`, token).Scan(&userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Calendar not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("CalendarICS: Error checking token:", err)
+		http.Error(w, "Error fetching calendar", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := loadForecastSchedules(database, userID)
+	if err != nil {
+		log.Println("CalendarICS: Error loading forecast schedules:", err)
+		http.Error(w, "Error fetching calendar", http.StatusInternalServerError)
+		return
+	}
+	currencies, err := currencyCodes(database)
+	if err != nil {
+		log.Println("CalendarICS: Error loading currencies:", err)
+		http.Error(w, "Error fetching calendar", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to := today.AddDate(0, 0, -calendarDaysBack), today.AddDate(0, 0, calendarDaysAhead)
+
+	calendar := ical.Calendar{
+		ProductID: "-//FinanApp//Forecast calendar//EN",
+		Name:      "FinanApp bills and income",
+		Refresh:   calendarRefresh,
+	}
+	for _, s := range schedules {
+		calendar.Events = append(calendar.Events, calendarEvents(s, from, to, currencies)...)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="finanapp.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err := calendar.Write(w, now); err != nil {
+		log.Println("CalendarICS: Error writing calendar:", err)
+	}
+}
+
+// CalendarFeed returns the active calendar feed of the logged-in user
+func CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CalendarFeed: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var feedID int
+	var token, createdAt string
+	var lastAccessedAt sql.NullString
+	err := database.QueryRow(`
+		SELECT UserCalendarFeedID, FeedToken, CreatedAt, LastAccessedAt
+		FROM UserCalendarFeed
+		WHERE UserProfileID = $1 AND RevokedAt IS NULL
+		ORDER BY CreatedAt DESC LIMIT 1`, user.UserProfileID).Scan(&feedID, &token, &createdAt, &lastAccessedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No active calendar feed", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("CalendarFeed: Error fetching feed:", err)
+		http.Error(w, "Error fetching calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_calendar_feed_id": feedID,
+		"feed_token":            token,
+		"url":                   calendarFeedPath(token),
+		"created_at":            createdAt,
+		"last_accessed_at":      nil,
+	}
+	if lastAccessedAt.Valid {
+		response["last_accessed_at"] = lastAccessedAt.String
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateCalendarFeed issues a new calendar feed token. The previous one is revoked, so a leaked URL is fixed by
+// creating a new feed.
+func CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateCalendarFeed: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("CreateCalendarFeed: Error starting transaction:", err)
+		http.Error(w, "Failed to create calendar feed", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	token := utils.GenerateToken(32)
+	var feedID int
+	_, err = tx.Exec(`
+		UPDATE UserCalendarFeed SET RevokedAt = CURRENT_TIMESTAMP
+		WHERE UserProfileID = $1 AND RevokedAt IS NULL`, user.UserProfileID)
+	if err == nil {
+		err = tx.QueryRow(`
+			INSERT INTO UserCalendarFeed (UserProfileID, FeedToken) VALUES ($1, $2)
+			RETURNING UserCalendarFeedID`, user.UserProfileID, token).Scan(&feedID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CreateCalendarFeed: Error creating feed:", err)
+		http.Error(w, "Failed to create calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                "success",
+		"message":               "Calendar feed created successfully",
+		"user_calendar_feed_id": feedID,
+		"feed_token":            token,
+		"url":                   calendarFeedPath(token),
+	})
+}
+
+// RevokeCalendarFeed stops the active calendar feed of the logged-in user
+func RevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RevokeCalendarFeed: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`
+		UPDATE UserCalendarFeed SET RevokedAt = CURRENT_TIMESTAMP
+		WHERE UserProfileID = $1 AND RevokedAt IS NULL`, user.UserProfileID)
+	if err != nil {
+		log.Println("RevokeCalendarFeed: Error revoking feed:", err)
+		http.Error(w, "Failed to revoke calendar feed", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "No active calendar feed", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Calendar feed revoked successfully",
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"finanapp/internal/cashflow"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CashFlow projects the income, taxes, expenses and net of the logged-in user for the next months (?months=12),
+// optionally converted to another currency (?currency=BRL)
+func CashFlow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CashFlow: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	months := 12
+	if v := r.URL.Query().Get("months"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 60 {
+			http.Error(w, "Invalid months (expected 1 to 60)", http.StatusBadRequest)
+			return
+		}
+		months = parsed
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("CashFlow: Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := loadForecastSchedules(database, user.UserProfileID)
+	if err != nil {
+		log.Println("CashFlow: Error loading forecast schedules:", err)
+		http.Error(w, "Error fetching forecasts", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, months, -1)
+
+	var entries []cashflow.Entry
+	for _, s := range schedules {
+		for _, o := range s.occurrences(from, to) {
+			date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+			amount := o.Amount
+			if rates != nil {
+				amount, err = rates.Convert(o.Amount, o.CurrencyID, currencyCode, date)
+				if err != nil {
+					http.Error(w, "Unable to convert "+s.ItemName+": "+err.Error(), http.StatusUnprocessableEntity)
+					return
+				}
+			}
+			entries = append(entries, cashflow.Entry{
+				ItemID:       s.ItemID,
+				ItemName:     s.ItemName,
+				EntityID:     s.EntityID,
+				EntityType:   s.EntityType,
+				ParentItemID: s.ParentItemID,
+				Date:         date,
+				Amount:       amount,
+			})
+		}
+	}
+
+	response := struct {
+		From     string           `json:"from"`
+		To       string           `json:"to"`
+		Currency string           `json:"currency,omitempty"`
+		Months   []cashflow.Month `json:"months"`
+	}{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Currency: currencyCode,
+		Months:   cashflow.Project(entries, from, months),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,11 +1,43 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"finanapp/internal/models"
+	"fmt"
 	"html/template"
 	"net/http"
+	"time"
 )
 
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ownedItemCondition restricts the FinancialUserItem alias "fui" to the items owned by the user in $1, outside the trash:
+// user items (EntityIDs 5-8) point to the UserProfileID, asset items (EntityIDs 9-13) point to one of the user's assets
+const ownedItemCondition = `(fui.DeletedAt IS NULL AND ` + ownedOrTrashedItemCondition + `)`
+
+// ownedOrTrashedItemCondition is ownedItemCondition including the items in the trash
+const ownedOrTrashedItemCondition = `((fui.EntityID IN (5, 6, 7, 8) AND fui.UserEntityID = $1)
+		OR (fui.EntityID IN (9, 10, 11, 12, 13) AND fui.UserEntityID IN (SELECT UserAssetID FROM UserAsset WHERE UserProfileID = $1)))`
+
+// groupItemCondition restricts the FinancialUserItem alias "fui" to the group items (EntityIDs 1-4) of the groups
+// the user in $1 belongs to, outside the trash, whatever their role: every member sees the household finances
+const groupItemCondition = `(fui.DeletedAt IS NULL AND ` + groupOrTrashedItemCondition + `)`
+
+// groupOrTrashedItemCondition is groupItemCondition including the items in the trash
+const groupOrTrashedItemCondition = `(fui.EntityID IN (1, 2, 3, 4)
+		AND fui.UserEntityID IN (SELECT UserGroupID FROM UserGroupMember WHERE UserProfileID = $1))`
+
+// managedGroupItemCondition is groupOrTrashedItemCondition restricted to the groups where the user in $1 can manage
+// the items: owners and members, not viewers
+const managedGroupItemCondition = `(fui.EntityID IN (1, 2, 3, 4)
+		AND fui.UserEntityID IN (SELECT UserGroupID FROM UserGroupMember WHERE UserProfileID = $1 AND GroupRole <> 'viewer'))`
+
 // RenderTemplate loads and renders templates with the base layout
 func RenderTemplate(w http.ResponseWriter, r *http.Request, templateName string, data interface{}) {
 	// Retrieve authentication and user data from the context
@@ -50,3 +82,65 @@ func RenderTemplate(w http.ResponseWriter, r *http.Request, templateName string,
 		http.Error(w, "Error rendering template: "+err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// parseDateRange reads the "from" and "to" query parameters (YYYY-MM-DD), falling back to the given defaults
+func parseDateRange(r *http.Request, defaultFrom, defaultTo time.Time) (time.Time, time.Time, error) {
+	from, to := defaultFrom, defaultTo
+
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date format (expected YYYY-MM-DD)")
+		}
+		from = parsed
+	}
+
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date format (expected YYYY-MM-DD)")
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to date must be on or after from date")
+	}
+	return from, to, nil
+}
+
+// userOwnsItem checks if the FinancialUserItem belongs to the user, directly or through one of the user's assets
+func userOwnsItem(database queryer, userID, itemID int) (bool, error) {
+	var exists bool
+	err := database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM financialuseritem fui
+			WHERE fui.FinancialUserItemID = $2 AND `+ownedItemCondition+`
+		)`, userID, itemID).Scan(&exists)
+	return exists, err
+}
+
+// errParentIncomeNotFound is returned when a child is attached to an income that isn't the user's
+var errParentIncomeNotFound = errors.New("Parent income not found or unauthorized")
+
+// userOwnsParentIncome checks if the item is a live income of the user that children can be attached to: a User
+// Income (EntityID 5) when assetID is 0, or else an Asset Income (EntityID 11) of that asset
+func userOwnsParentIncome(database queryer, userID, itemID, assetID int) (bool, error) {
+	var exists bool
+	err := database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM financialuseritem fui
+			WHERE fui.FinancialUserItemID = $2 AND `+ownedItemCondition+`
+				AND (($3 = 0 AND fui.EntityID = 5) OR (fui.EntityID = 11 AND fui.UserEntityID = $3))
+		)`, userID, itemID, assetID).Scan(&exists)
+	return exists, err
+}
+
+// userOwnsCategory checks if the UserCategory belongs to the user
+func userOwnsCategory(database queryer, userID, categoryID int) (bool, error) {
+	var exists bool
+	err := database.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM usercategory WHERE UserCategoryID = $2 AND UserProfileID = $1)`,
+		userID, categoryID).Scan(&exists)
+	return exists, err
+}
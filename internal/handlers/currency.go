@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"database/sql"
+	"finanapp/internal/fx"
+	"finanapp/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestedCurrency loads the exchange rates when the request asks for the amounts in another currency (?currency=BRL).
+// The table is nil when no currency was requested.
+func requestedCurrency(r *http.Request, database *sql.DB) (*fx.Table, string, error) {
+	code := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("currency")))
+	if code == "" {
+		return nil, "", nil
+	}
+
+	table, err := fx.Load(database)
+	if err != nil {
+		return nil, "", err
+	}
+	if !table.Known(code) {
+		return nil, "", fmt.Errorf("%w: %s", fx.ErrUnknownCurrency, code)
+	}
+
+	return table, code, nil
+}
+
+// convertAmount converts the amount on the date, returning nil when there's no rate for it
+func convertAmount(table *fx.Table, code string, amount float64, currencyID int, on time.Time) *float64 {
+	converted, err := table.Convert(amount, currencyID, code, on)
+	if err != nil {
+		log.Printf("Currency: Unable to convert currency %d to %s: %v", currencyID, code, err)
+		return nil
+	}
+	return &converted
+}
+
+// convertForecasts fills the converted amounts of the forecasts using the rate of their begin date
+func convertForecasts(table *fx.Table, code string, forecasts []models.UserFinancialForecast) {
+	for i := range forecasts {
+		f := &forecasts[i]
+		f.ConvertedAmount = convertAmount(table, code, f.UserFinancialForecastAmount, f.CurrencyID, dbDate(f.UserFinancialForecastBeginDate))
+		f.ConvertedCurrency = code
+	}
+}
+
+// convertActuals fills the converted amounts of the actuals using the rate of their begin date
+func convertActuals(table *fx.Table, code string, actuals []models.UserFinancialActual) {
+	for i := range actuals {
+		a := &actuals[i]
+		a.ConvertedAmount = convertAmount(table, code, a.UserFinancialActualAmount, a.CurrencyID, dbDate(a.UserFinancialActualtBeginDate))
+		a.ConvertedCurrency = code
+	}
+}
+
+// dbDate parses a DATE column scanned into a string ("2006-01-02" or RFC 3339)
+func dbDate(s string) time.Time {
+	if len(s) >= 10 {
+		if d, err := time.Parse("2006-01-02", s[:10]); err == nil {
+			return d
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/dedupe"
+	"finanapp/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Review decisions on a duplicate pair
+const (
+	duplicateMerge   = "merge"
+	duplicateKeep    = "keep"
+	duplicateDismiss = "dismiss"
+)
+
+// duplicateCandidateColumns is the base query of the actuals compared by the duplicate detector, $1 is the user.
+// Actuals are described by their imported line, or by their note and item name.
+const duplicateCandidateColumns = `
+	SELECT ufa.UserFinancialActualID, ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualAmount, ufa.CurrencyID,
+		ufa.FinancialUserItemID, COALESCE(it.TransactionDescription, COALESCE(ufa.Note || ' ', '') || fui.FinancialUserItemName),
+		COALESCE(it.AccountKey, ''), COALESCE(it.ExternalID, '')
+	FROM userfinancialactual ufa
+	JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+	LEFT JOIN ImportedTransaction it ON it.UserFinancialActualID = ufa.UserFinancialActualID
+	WHERE `
+
+// queryDuplicateCandidates lists the actuals of the items owned by the user in $1, with the extra filter
+func queryDuplicateCandidates(q queryer, filter string, args ...interface{}) ([]dedupe.Actual, error) {
+	rows, err := q.Query(duplicateCandidateColumns+ownedItemCondition+filter+` ORDER BY ufa.UserFinancialActualID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actuals := []dedupe.Actual{}
+	for rows.Next() {
+		var a dedupe.Actual
+		if err := rows.Scan(&a.ID, &a.Date, &a.Amount, &a.CurrencyID, &a.ItemID, &a.Description, &a.Account,
+			&a.ExternalID); err != nil {
+			return nil, err
+		}
+		actuals = append(actuals, a)
+	}
+	return actuals, rows.Err()
+}
+
+// duplicateCandidatesBetween lists the actuals dated from the window before from to the window after to
+func duplicateCandidatesBetween(q queryer, userID int, from, to time.Time) ([]dedupe.Actual, error) {
+	window := dedupe.DefaultOptions.DateWindowDays
+	return queryDuplicateCandidates(q, ` AND ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3`,
+		userID, from.AddDate(0, 0, -window), to.AddDate(0, 0, window))
+}
+
+// recordDuplicatePairs adds the pairs to the review queue of the user. Pairs already in the queue, decided or not,
+// are left as they are; it returns how many were added.
+func recordDuplicatePairs(q queryer, userID int, pairs []dedupe.Pair) (int, error) {
+	added := 0
+	for _, p := range pairs {
+		result, err := q.Exec(`
+			INSERT INTO UserDuplicatePair (UserProfileID, FirstActualID, SecondActualID, MatchScore, MatchReasons)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (FirstActualID, SecondActualID) DO NOTHING`,
+			userID, p.FirstID, p.SecondID, p.Score, pq.StringArray(p.Reasons))
+		if err != nil {
+			return added, err
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// flagDuplicates compares the given actuals with the other actuals of the user and queues the likely duplicates
+func flagDuplicates(q queryer, userID int, actualIDs []int64) (int, error) {
+	var from, to sql.NullTime
+	err := q.QueryRow(`
+		SELECT MIN(UserFinancialActualtBeginDate), MAX(UserFinancialActualtBeginDate)
+		FROM userfinancialactual WHERE UserFinancialActualID = ANY($1)`, pq.Int64Array(actualIDs)).Scan(&from, &to)
+	if err != nil || !from.Valid {
+		return 0, err
+	}
+
+	candidates, err := duplicateCandidatesBetween(q, userID, from.Time, to.Time)
+	if err != nil {
+		return 0, err
+	}
+
+	flagged := map[int]bool{}
+	for _, id := range actualIDs {
+		flagged[int(id)] = true
+	}
+	var pairs []dedupe.Pair
+	for _, p := range dedupe.Find(candidates, dedupe.DefaultOptions) {
+		if flagged[p.FirstID] || flagged[p.SecondID] {
+			pairs = append(pairs, p)
+		}
+	}
+	return recordDuplicatePairs(q, userID, pairs)
+}
+
+// Duplicates lists the review queue of likely duplicate actuals (?status=pending by default, or merged, kept,
+// dismissed), with both actuals of each pair. Pending pairs whose actuals were deleted are left out.
+func Duplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Duplicates: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "pending"
+	case "pending", "merged", "kept", "dismissed":
+	default:
+		http.Error(w, "status must be pending, merged, kept or dismissed", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rows, err := database.Query(`
+		SELECT p.UserDuplicatePairID, p.FirstActualID, p.SecondActualID, p.MatchScore, p.MatchReasons, p.PairStatus,
+			p.KeptActualID, p.CreatedAt, p.DecidedAt
+		FROM UserDuplicatePair p
+		WHERE p.UserProfileID = $1 AND p.PairStatus = $2
+			AND (p.PairStatus <> 'pending' OR (
+				EXISTS (SELECT 1 FROM userfinancialactual WHERE UserFinancialActualID = p.FirstActualID)
+				AND EXISTS (SELECT 1 FROM userfinancialactual WHERE UserFinancialActualID = p.SecondActualID)))
+		ORDER BY p.MatchScore DESC, p.UserDuplicatePairID`, user.UserProfileID, status)
+	if err != nil {
+		log.Println("Duplicates: Error fetching pairs:", err)
+		http.Error(w, "Error fetching duplicates", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	pairs := []models.UserDuplicatePair{}
+	var ids pq.Int64Array
+	for rows.Next() {
+		var p models.UserDuplicatePair
+		var reasons pq.StringArray
+		if err := rows.Scan(&p.UserDuplicatePairID, &p.FirstActualID, &p.SecondActualID, &p.MatchScore, &reasons,
+			&p.PairStatus, &p.KeptActualID, &p.CreatedAt, &p.DecidedAt); err != nil {
+			log.Println("Duplicates: Error reading pair:", err)
+			http.Error(w, "Error fetching duplicates", http.StatusInternalServerError)
+			return
+		}
+		p.MatchReasons = reasons
+		pairs = append(pairs, p)
+		ids = append(ids, int64(p.FirstActualID), int64(p.SecondActualID))
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("Duplicates: Error fetching pairs:", err)
+		http.Error(w, "Error fetching duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	if len(ids) > 0 {
+		actuals, err := queryActuals(database, ` AND ufa.UserFinancialActualID = ANY($2)`, user.UserProfileID, ids)
+		if err != nil {
+			log.Println("Duplicates: Error fetching actuals:", err)
+			http.Error(w, "Error fetching duplicates", http.StatusInternalServerError)
+			return
+		}
+		byID := map[int]models.UserFinancialActual{}
+		for _, a := range actuals {
+			byID[a.UserFinancialActualID] = a
+		}
+		for i := range pairs {
+			for _, id := range []int{pairs[i].FirstActualID, pairs[i].SecondActualID} {
+				if a, ok := byID[id]; ok {
+					pairs[i].Actuals = append(pairs[i].Actuals, a)
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pairs)
+}
+
+// ScanDuplicates looks for likely duplicates among the actuals dated in the ?from= to ?to= range (default: the last
+// three months) and adds the new ones to the review queue
+func ScanDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ScanDuplicates: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, today.AddDate(0, -3, 0), today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	candidates, err := queryDuplicateCandidates(database, ` AND ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3`,
+		user.UserProfileID, from, to)
+	var added int
+	if err == nil {
+		added, err = recordDuplicatePairs(database, user.UserProfileID, dedupe.Find(candidates, dedupe.DefaultOptions))
+	}
+	if err != nil {
+		log.Println("ScanDuplicates: Error scanning actuals:", err)
+		http.Error(w, "Failed to scan duplicates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"scanned": len(candidates),
+		"flagged": added,
+	})
+}
+
+// ResolveDuplicate records the decision on a pending pair: merge deletes one actual and keeps the other (keepActualId,
+// by default the imported one or else the older one), keep confirms both are real transactions and dismiss just
+// takes the pair out of the queue. The pair is not flagged again whatever the decision.
+func ResolveDuplicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ResolveDuplicate: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pairID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || pairID <= 0 {
+		http.Error(w, "Invalid pair ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Action       string `json:"action"`
+		KeepActualID int    `json:"keepActualId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	statuses := map[string]string{duplicateMerge: "merged", duplicateKeep: "kept", duplicateDismiss: "dismissed"}
+	if _, ok := statuses[payload.Action]; !ok {
+		http.Error(w, "action must be merge, keep or dismiss", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("ResolveDuplicate: Error starting transaction:", err)
+		http.Error(w, "Failed to resolve duplicate", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var firstID, secondID int
+	err = tx.QueryRow(`
+		SELECT FirstActualID, SecondActualID FROM UserDuplicatePair
+		WHERE UserDuplicatePairID = $2 AND UserProfileID = $1 AND PairStatus = 'pending'
+		FOR UPDATE`, user.UserProfileID, pairID).Scan(&firstID, &secondID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Pair not found, unauthorized or already resolved", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("ResolveDuplicate: Error fetching pair:", err)
+		http.Error(w, "Failed to resolve duplicate", http.StatusInternalServerError)
+		return
+	}
+
+	var keptID *int
+	if payload.Action == duplicateMerge {
+		actuals, err := queryDuplicateCandidates(tx, ` AND ufa.UserFinancialActualID IN ($2, $3)`, user.UserProfileID,
+			firstID, secondID)
+		if err != nil {
+			log.Println("ResolveDuplicate: Error fetching actuals:", err)
+			http.Error(w, "Failed to resolve duplicate", http.StatusInternalServerError)
+			return
+		}
+		if len(actuals) != 2 {
+			http.Error(w, "One of the actuals no longer exists", http.StatusConflict)
+			return
+		}
+
+		keep, remove := actuals[0], actuals[1]
+		switch payload.KeepActualID {
+		case 0:
+			if remove.Imported() && !keep.Imported() {
+				keep, remove = remove, keep
+			}
+		case keep.ID:
+		case remove.ID:
+			keep, remove = remove, keep
+		default:
+			http.Error(w, "keepActualId must be one of the pair", http.StatusBadRequest)
+			return
+		}
+
+		// The kept actual takes over the links of the removed one
+		_, err = tx.Exec(`
+			UPDATE userforecastactualrelation SET UserFinancialActualID = $1
+			WHERE UserFinancialActualID = $2
+				AND NOT EXISTS (SELECT 1 FROM userforecastactualrelation WHERE UserFinancialActualID = $1)`, keep.ID, remove.ID)
+		if err == nil {
+			_, err = tx.Exec(`DELETE FROM userforecastactualrelation WHERE UserFinancialActualID = $1`, remove.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE ImportedTransaction SET UserFinancialActualID = $1 WHERE UserFinancialActualID = $2`, keep.ID, remove.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE SplitSettlement SET FromUserFinancialActualID = $1 WHERE FromUserFinancialActualID = $2`, keep.ID, remove.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE SplitSettlement SET ToUserFinancialActualID = $1 WHERE ToUserFinancialActualID = $2`, keep.ID, remove.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(`
+				UPDATE userfinancialactual k SET UserCategoryID = COALESCE(k.UserCategoryID, r.UserCategoryID),
+					Note = COALESCE(k.Note, r.Note)
+				FROM userfinancialactual r
+				WHERE k.UserFinancialActualID = $1 AND r.UserFinancialActualID = $2`, keep.ID, remove.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(`DELETE FROM userfinancialactual WHERE UserFinancialActualID = $1`, remove.ID)
+		}
+		if err != nil {
+			log.Println("ResolveDuplicate: Error merging actuals:", err)
+			http.Error(w, "Failed to resolve duplicate", http.StatusInternalServerError)
+			return
+		}
+		keptID = &keep.ID
+	}
+
+	_, err = tx.Exec(`
+		UPDATE UserDuplicatePair SET PairStatus = $2, KeptActualID = $3, DecidedAt = CURRENT_TIMESTAMP
+		WHERE UserDuplicatePairID = $1`, pairID, statuses[payload.Action], keptID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("ResolveDuplicate: Error saving decision:", err)
+		http.Error(w, "Failed to resolve duplicate", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":  "success",
+		"message": "Duplicate resolved successfully",
+	}
+	if keptID != nil {
+		response["user_financial_actual_id"] = *keptID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
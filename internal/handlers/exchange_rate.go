@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRateFileSize limits the uploaded rate files, the ECB historical file is around 6 MB
+const maxRateFileSize = 32 << 20
+
+// ImportExchangeRates upserts the rates of an uploaded file into CurrencyExchangeRate.
+// The file is sent as the "file" field of a multipart form or as the raw body.
+// Query parameters: format=auto|csv|ecb|ptax (default auto) and dryRun=true to only get the diff.
+func ImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateFileSize)
+
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Rate file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Error reading file", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if len(data) == 0 {
+		http.Error(w, "Rate file is required", http.StatusBadRequest)
+		return
+	}
+
+	quotes, err := fx.Parse(r.URL.Query().Get("format"), data)
+	if err != nil {
+		http.Error(w, "Invalid rate file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := fx.Import(db.GetDB(), quotes, dryRun)
+	if err != nil {
+		log.Println("ImportExchangeRates: Error importing rates:", err)
+		http.Error(w, "Failed to import exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"finanapp/internal/db"
 	"finanapp/internal/models"
@@ -68,15 +69,17 @@ func CreateExpense(w http.ResponseWriter, r *http.Request) {
 
 	// Call the stored procedure
 	var message string
-	err = database.QueryRow(
-		"CALL CreateUserParentExpense($1, $2, $3, $4, $5, $6, $7)",
-		user.UserProfileID,
-		payload.FinancialUserItemName,
-		payload.RecurrencyID,
-		payload.FinancialUserEntityItemID,
-		amount,
-		beginDate,
-		message).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL CreateUserParentExpense($1, $2, $3, $4, $5, $6, $7)",
+			user.UserProfileID,
+			payload.FinancialUserItemName,
+			payload.RecurrencyID,
+			payload.FinancialUserEntityItemID,
+			amount,
+			beginDate,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("Database error:", err)
@@ -162,15 +165,17 @@ func UpdateExpense(w http.ResponseWriter, r *http.Request) {
 
 	// Call the stored procedure
 	var message string
-	err = database.QueryRow(
-		"CALL UpdateUserParentExpense($1, $2, $3, $4, $5, $6, $7)",
-		payload.FinancialUserItemID,
-		user.UserProfileID,
-		payload.NewFinancialUserItemName,
-		amount,
-		beginDate,
-		payload.IsActive,
-		message).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL UpdateUserParentExpense($1, $2, $3, $4, $5, $6, $7)",
+			payload.FinancialUserItemID,
+			user.UserProfileID,
+			payload.NewFinancialUserItemName,
+			amount,
+			beginDate,
+			payload.IsActive,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("Database error:", err)
@@ -231,11 +236,13 @@ func DeleteExpense(w http.ResponseWriter, r *http.Request) {
 	var message string
 
 	// Executa a stored procedure
-	err := database.QueryRow(
-		"CALL DeleteUserParentExpense($1, $2, $3)",
-		payload.ItemID,
-		user.UserProfileID,
-		message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL DeleteUserParentExpense($1, $2, $3)",
+			payload.ItemID,
+			user.UserProfileID,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("DeleteExpense: Database error:", err)
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"database/sql"
+	"finanapp/internal/db"
+	"finanapp/internal/export"
+	"finanapp/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportTable is one table of the export: a CSV file or an XLSX sheet. kinds has one letter per column of the query:
+// i integer, n number, s text, d date, b boolean; all of them can be NULL.
+type exportTable struct {
+	entity string
+	sheet  string
+	header []string
+	kinds  string
+	query  string // $1 is the user, $2 and $3 the date range of the dated tables
+	dated  bool
+	amount string // amount column totaled by currency on the summary, empty for none
+}
+
+// exportTables are the tables of the export, in the order of the sheets
+var exportTables = []exportTable{
+	{
+		entity: "items",
+		sheet:  "Items",
+		header: []string{"Item ID", "Item", "Entity", "Entity Type", "Parent Item", "Asset", "Recurrence",
+			"Recurrence Rule", "Recurrence End", "Active", "Created"},
+		kinds: "isssssssdbd",
+		query: `
+			SELECT fui.FinancialUserItemID, fui.FinancialUserItemName, e.EntityName, e.EntityType,
+				parent.FinancialUserItemName, ua.UserAssetName, r.RecurrencyName, fui.RecurrencyRule,
+				fui.RecurrencyEndDate, fui.IsActive, fui.CreatedAt
+			FROM financialuseritem fui
+			JOIN entity e ON e.EntityID = fui.EntityID
+			JOIN recurrency r ON r.RecurrencyID = fui.RecurrencyID
+			LEFT JOIN financialuseritem parent ON parent.FinancialUserItemID = fui.ParentFinancialUserItemID
+			LEFT JOIN userasset ua ON fui.EntityID IN (9, 10, 11, 12, 13) AND ua.UserAssetID = fui.UserEntityID
+			WHERE ` + ownedItemCondition + `
+			ORDER BY fui.FinancialUserItemID`,
+	},
+	{
+		entity: "forecasts",
+		sheet:  "Forecasts",
+		header: []string{"Forecast ID", "Item ID", "Item", "Parent Item", "Entity", "Category", "Begin Date",
+			"End Date", "Amount", "Currency", "Recurrence", "Created"},
+		kinds: "iissssddnssd",
+		query: `
+			SELECT uff.UserFinancialForecastID, fui.FinancialUserItemID, fui.FinancialUserItemName,
+				parent.FinancialUserItemName, e.EntityName, uc.UserCategoryName, uff.UserFinancialForecastBeginDate,
+				uff.UserFinancialForecastEndDate, uff.UserFinancialForecastAmount, c.CurrencyAbreviation,
+				r.RecurrencyName, uff.CreatedAt
+			FROM userfinancialforecast uff
+			JOIN financialuseritem fui ON fui.FinancialUserItemID = uff.FinancialUserItemID
+			JOIN entity e ON e.EntityID = fui.EntityID
+			JOIN recurrency r ON r.RecurrencyID = fui.RecurrencyID
+			JOIN currency c ON c.CurrencyID = uff.CurrencyID
+			LEFT JOIN usercategory uc ON uc.UserCategoryID = uff.UserCategoryID
+			LEFT JOIN financialuseritem parent ON parent.FinancialUserItemID = fui.ParentFinancialUserItemID
+			WHERE uff.UserFinancialForecastBeginDate BETWEEN $2 AND $3 AND ` + ownedItemCondition + `
+			ORDER BY uff.UserFinancialForecastBeginDate, uff.UserFinancialForecastID`,
+		dated:  true,
+		amount: "UserFinancialForecastAmount",
+	},
+	{
+		entity: "actuals",
+		sheet:  "Actuals",
+		header: []string{"Actual ID", "Item ID", "Item", "Parent Item", "Entity", "Category", "Begin Date",
+			"End Date", "Amount", "Currency", "Recurrence", "Note", "Created"},
+		kinds: "iissssddnsssd",
+		query: `
+			SELECT ufa.UserFinancialActualID, fui.FinancialUserItemID, fui.FinancialUserItemName,
+				parent.FinancialUserItemName, e.EntityName, uc.UserCategoryName, ufa.UserFinancialActualtBeginDate,
+				ufa.UserFinancialActualEndDate, ufa.UserFinancialActualAmount, c.CurrencyAbreviation,
+				r.RecurrencyName, ufa.Note, ufa.CreatedAt
+			FROM userfinancialactual ufa
+			JOIN financialuseritem fui ON fui.FinancialUserItemID = ufa.FinancialUserItemID
+			JOIN entity e ON e.EntityID = fui.EntityID
+			JOIN recurrency r ON r.RecurrencyID = fui.RecurrencyID
+			JOIN currency c ON c.CurrencyID = ufa.CurrencyID
+			LEFT JOIN usercategory uc ON uc.UserCategoryID = ufa.UserCategoryID
+			LEFT JOIN financialuseritem parent ON parent.FinancialUserItemID = fui.ParentFinancialUserItemID
+			WHERE ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3 AND ` + ownedItemCondition + `
+			ORDER BY ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualID`,
+		dated:  true,
+		amount: "UserFinancialActualAmount",
+	},
+}
+
+// scanExportRow reads the current row as cells of the column kinds
+func scanExportRow(rows *sql.Rows, kinds string) ([]export.Cell, error) {
+	values := make([]interface{}, len(kinds))
+	for i, kind := range kinds {
+		switch kind {
+		case 'i':
+			values[i] = new(sql.NullInt64)
+		case 'n':
+			values[i] = new(sql.NullFloat64)
+		case 's':
+			values[i] = new(sql.NullString)
+		case 'd':
+			values[i] = new(sql.NullTime)
+		case 'b':
+			values[i] = new(sql.NullBool)
+		}
+	}
+	if err := rows.Scan(values...); err != nil {
+		return nil, err
+	}
+
+	cells := make([]export.Cell, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case *sql.NullInt64:
+			if v.Valid {
+				cells[i] = export.Number(float64(v.Int64))
+			}
+		case *sql.NullFloat64:
+			if v.Valid {
+				cells[i] = export.Number(v.Float64)
+			}
+		case *sql.NullString:
+			if v.Valid {
+				cells[i] = export.String(v.String)
+			}
+		case *sql.NullTime:
+			if v.Valid {
+				cells[i] = export.Date(v.Time)
+			}
+		case *sql.NullBool:
+			if v.Valid && v.Bool {
+				cells[i] = export.String("Yes")
+			} else if v.Valid {
+				cells[i] = export.String("No")
+			}
+		}
+	}
+	return cells, nil
+}
+
+// args are the query arguments of the table
+func (t exportTable) args(userID int, from, to time.Time) []interface{} {
+	if t.dated {
+		return []interface{}{userID, from, to}
+	}
+	return []interface{}{userID}
+}
+
+// writeExportTable streams the rows of a table, header first
+func writeExportTable(database *sql.DB, out export.RowWriter, table exportTable, userID int, from, to time.Time) error {
+	header := make([]export.Cell, len(table.header))
+	for i, name := range table.header {
+		header[i] = export.String(name)
+	}
+	if err := out.WriteRow(header...); err != nil {
+		return err
+	}
+
+	rows, err := database.Query(table.query, table.args(userID, from, to)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		cells, err := scanExportRow(rows, table.kinds)
+		if err != nil {
+			return err
+		}
+		if err := out.WriteRow(cells...); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// writeExportSummary writes the row count of each table and, for the forecasts and the actuals, the count and the
+// total of each currency
+func writeExportSummary(database *sql.DB, out export.RowWriter, tables []exportTable, userID int, from, to time.Time) error {
+	if err := out.WriteRow(export.String("Sheet"), export.String("Currency"), export.String("Rows"), export.String("Total")); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		query := `SELECT NULL::TEXT, COUNT(*), NULL::DECIMAL FROM (` + table.query + `) t`
+		if table.amount != "" {
+			query = `SELECT t.CurrencyAbreviation, COUNT(*), SUM(t.` + table.amount + `) FROM (` + table.query + `) t
+				GROUP BY t.CurrencyAbreviation ORDER BY t.CurrencyAbreviation`
+		}
+		rows, err := database.Query(query, table.args(userID, from, to)...)
+		if err != nil {
+			return err
+		}
+		written := 0
+		for rows.Next() {
+			cells, err := scanExportRow(rows, "sin")
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			if err := out.WriteRow(append([]export.Cell{export.String(table.sheet)}, cells...)...); err != nil {
+				rows.Close()
+				return err
+			}
+			written++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Grouped totals have no row when the table is empty
+		if written == 0 {
+			if err := out.WriteRow(export.String(table.sheet), export.Empty(), export.Number(0)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := out.WriteRow(); err != nil {
+		return err
+	}
+	for _, row := range [][]export.Cell{
+		{export.String("From"), export.Date(from)},
+		{export.String("To"), export.Date(to)},
+		{export.String("Generated"), export.Date(time.Now())},
+	} {
+		if err := out.WriteRow(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export streams the items, forecasts and actuals of the user with their category, currency, recurrence and parent
+// item names. ?format= is csv or xlsx (default); ?from= and ?to= filter the forecasts and actuals by begin date
+// (default: the current year); ?entity= picks the tables, comma separated among items, forecasts and actuals. A CSV
+// holds one table, so it needs a single entity. An XLSX has one sheet per table after a summary sheet.
+func Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Export: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	year := time.Now().Year()
+	from, to, err := parseDateRange(r, time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "xlsx"
+	}
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	tables := exportTables
+	if value := r.URL.Query().Get("entity"); value != "" {
+		wanted := map[string]bool{}
+		for _, entity := range strings.Split(value, ",") {
+			wanted[strings.TrimSpace(entity)] = true
+		}
+		tables = nil
+		for _, table := range exportTables {
+			if wanted[table.entity] {
+				tables = append(tables, table)
+				delete(wanted, table.entity)
+			}
+		}
+		if len(wanted) > 0 || len(tables) == 0 {
+			http.Error(w, "entity must be items, forecasts or actuals", http.StatusBadRequest)
+			return
+		}
+	}
+	if format == "csv" && len(tables) != 1 {
+		http.Error(w, "csv exports one entity: items, forecasts or actuals", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	fileName := fmt.Sprintf("finanapp-%s-%s", from.Format("20060102"), to.Format("20060102"))
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, fileName, tables[0].entity))
+		w.WriteHeader(http.StatusOK)
+
+		out := export.NewCSV(w)
+		err = writeExportTable(database, out, tables[0], user.UserProfileID, from, to)
+		if err == nil {
+			err = out.Close()
+		}
+		// The response already started, the client gets a truncated file
+		if err != nil {
+			log.Println("Export: Error writing csv:", err)
+		}
+		return
+	}
+
+	sheets := []string{"Summary"}
+	for _, table := range tables {
+		sheets = append(sheets, table.sheet)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, fileName))
+	w.WriteHeader(http.StatusOK)
+
+	out, err := export.NewXLSX(w, sheets...)
+	if err == nil {
+		err = out.NextSheet()
+	}
+	if err == nil {
+		err = writeExportSummary(database, out, tables, user.UserProfileID, from, to)
+	}
+	for _, table := range tables {
+		if err == nil {
+			err = out.NextSheet()
+		}
+		if err == nil {
+			err = writeExportTable(database, out, table, user.UserProfileID, from, to)
+		}
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	// The response already started, the client gets a truncated file
+	if err != nil {
+		log.Println("Export: Error writing xlsx:", err)
+	}
+}
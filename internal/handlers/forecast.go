@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/recurrence"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// storedForecast is a UserFinancialForecast row used to anchor and price the occurrences of an item
+type storedForecast struct {
+	ID         int
+	Date       time.Time
+	Amount     float64
+	CurrencyID int
+}
+
+// forecastSchedule holds an item with its recurrence settings and its stored forecasts
+type forecastSchedule struct {
+	ItemID            int
+	ItemName          string
+	EntityID          int
+	EntityType        string
+	ParentItemID      *int
+	RecurrencyName    string
+	RecurrencyPeriod  string
+	RecurrencyRule    sql.NullString
+	RecurrencyEndDate sql.NullTime
+	Forecasts         []storedForecast
+}
+
+// loadForecastSchedules loads every active item of the user with its forecasts ordered by date
+func loadForecastSchedules(database *sql.DB, userID int) ([]*forecastSchedule, error) {
+	itemQuery := `
+	SELECT
+		fui.FinancialUserItemID,
+		fui.FinancialUserItemName,
+		fui.EntityID,
+		e.EntityType,
+		fui.ParentFinancialUserItemID,
+		r.RecurrencyName,
+		r.RecurrencyPeriod,
+		fui.RecurrencyRule,
+		fui.RecurrencyEndDate
+	FROM
+		financialuseritem fui
+	JOIN
+		entity e ON fui.EntityID = e.EntityID
+	JOIN
+		recurrency r ON fui.RecurrencyID = r.RecurrencyID
+	WHERE
+		fui.IsActive = TRUE AND ` + ownedItemCondition + `
+	ORDER BY
+		fui.FinancialUserItemID`
+
+	itemRows, err := database.Query(itemQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	var schedules []*forecastSchedule
+	byID := map[int]*forecastSchedule{}
+	for itemRows.Next() {
+		var s forecastSchedule
+		var parentID sql.NullInt64
+		if err := itemRows.Scan(
+			&s.ItemID,
+			&s.ItemName,
+			&s.EntityID,
+			&s.EntityType,
+			&parentID,
+			&s.RecurrencyName,
+			&s.RecurrencyPeriod,
+			&s.RecurrencyRule,
+			&s.RecurrencyEndDate,
+		); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			s.ParentItemID = &id
+		}
+		schedules = append(schedules, &s)
+		byID[s.ItemID] = &s
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	forecastQuery := `
+	SELECT
+		uff.UserFinancialForecastID,
+		uff.FinancialUserItemID,
+		uff.UserFinancialForecastBeginDate,
+		uff.UserFinancialForecastAmount,
+		uff.CurrencyID
+	FROM
+		userfinancialforecast uff
+	JOIN
+		financialuseritem fui ON uff.FinancialUserItemID = fui.FinancialUserItemID
+	WHERE
+		fui.IsActive = TRUE AND ` + ownedItemCondition + `
+	ORDER BY
+		uff.FinancialUserItemID, uff.UserFinancialForecastBeginDate`
+
+	forecastRows, err := database.Query(forecastQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer forecastRows.Close()
+
+	for forecastRows.Next() {
+		var f storedForecast
+		var itemID int
+		if err := forecastRows.Scan(&f.ID, &itemID, &f.Date, &f.Amount, &f.CurrencyID); err != nil {
+			return nil, err
+		}
+		if s, ok := byID[itemID]; ok {
+			s.Forecasts = append(s.Forecasts, f)
+		}
+	}
+
+	return schedules, forecastRows.Err()
+}
+
+// rule resolves the recurrence of the item, giving priority to the item's own RRULE
+func (s *forecastSchedule) rule() (*recurrence.Rule, error) {
+	if s.RecurrencyRule.Valid && strings.TrimSpace(s.RecurrencyRule.String) != "" {
+		return recurrence.Parse(s.RecurrencyRule.String)
+	}
+	return recurrence.FromPeriod(s.RecurrencyPeriod)
+}
+
+// occurrences returns the stored forecasts inside [from, to] and, after the last stored forecast,
+// the dates projected by the recurrence engine priced with the last stored amount
+func (s *forecastSchedule) occurrences(from, to time.Time) []models.ForecastOccurrence {
+	var result []models.ForecastOccurrence
+	if len(s.Forecasts) == 0 {
+		return result
+	}
+
+	for i := range s.Forecasts {
+		f := s.Forecasts[i]
+		if f.Date.Before(from) || f.Date.After(to) {
+			continue
+		}
+		occurrence := s.occurrence(f.Date, f)
+		occurrence.UserFinancialForecastID = &s.Forecasts[i].ID
+		result = append(result, occurrence)
+	}
+
+	rule, err := s.rule()
+	if err != nil {
+		if err != recurrence.ErrNoSchedule {
+			log.Printf("Forecast: Ignoring recurrence of item %d: %v", s.ItemID, err)
+		}
+		return result
+	}
+
+	first, last := s.Forecasts[0], s.Forecasts[len(s.Forecasts)-1]
+	for _, d := range rule.Between(first.Date, from, to) {
+		if !d.After(last.Date) {
+			continue
+		}
+		if s.RecurrencyEndDate.Valid && d.After(s.RecurrencyEndDate.Time) {
+			break
+		}
+		occurrence := s.occurrence(d, last)
+		occurrence.Projected = true
+		result = append(result, occurrence)
+	}
+
+	return result
+}
+
+func (s *forecastSchedule) occurrence(d time.Time, f storedForecast) models.ForecastOccurrence {
+	return models.ForecastOccurrence{
+		FinancialUserItemID:       s.ItemID,
+		FinancialUserItemName:     s.ItemName,
+		EntityID:                  s.EntityID,
+		EntityType:                s.EntityType,
+		ParentFinancialUserItemID: s.ParentItemID,
+		RecurrencyName:            s.RecurrencyName,
+		OccurrenceDate:            d.Format("2006-01-02"),
+		Amount:                    f.Amount,
+		CurrencyID:                f.CurrencyID,
+	}
+}
+
+// materializeUntil stores the projected occurrences of the item up to the given date as UserFinancialForecast
+// rows, the same way the create procedures do, and returns the new forecast IDs by date (YYYY-MM-DD)
+func (s *forecastSchedule) materializeUntil(q queryer, until time.Time) (map[string]int, error) {
+	created := map[string]int{}
+	if len(s.Forecasts) == 0 {
+		return created, nil
+	}
+
+	rule, err := s.rule()
+	if err != nil {
+		if err == recurrence.ErrNoSchedule {
+			return created, nil
+		}
+		return nil, err
+	}
+
+	first, last := s.Forecasts[0], s.Forecasts[len(s.Forecasts)-1]
+	for _, d := range rule.Between(first.Date, last.Date.AddDate(0, 0, 1), until) {
+		if s.RecurrencyEndDate.Valid && d.After(s.RecurrencyEndDate.Time) {
+			break
+		}
+
+		// The forecast ends the day before the next occurrence
+		var endDate sql.NullTime
+		if next, ok := rule.Next(first.Date, d.AddDate(0, 0, 1)); ok {
+			endDate = sql.NullTime{Time: next.AddDate(0, 0, -1), Valid: true}
+		}
+
+		var id int
+		err := q.QueryRow(`
+			INSERT INTO UserFinancialForecast (UserCategoryID, FinancialUserItemID, UserFinancialForecastBeginDate,
+				UserFinancialForecastEndDate, UserFinancialForecastAmount, CurrencyID)
+			VALUES (NULL, $1, $2, $3, $4, $5) RETURNING UserFinancialForecastID`,
+			s.ItemID, d, endDate, last.Amount, last.CurrencyID).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Forecasts = append(s.Forecasts, storedForecast{ID: id, Date: d, Amount: last.Amount, CurrencyID: last.CurrencyID})
+		created[d.Format("2006-01-02")] = id
+	}
+
+	return created, nil
+}
+
+// ForecastOccurrences lists every expected payment of the logged-in user between "from" and "to"
+func ForecastOccurrences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ForecastOccurrences: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Default window is the current month plus the next eleven
+	now := time.Now()
+	defaultFrom := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, defaultFrom, defaultFrom.AddDate(1, 0, -1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	schedules, err := loadForecastSchedules(db.GetDB(), user.UserProfileID)
+	if err != nil {
+		log.Println("ForecastOccurrences: Error loading forecast schedules:", err)
+		http.Error(w, "Error fetching forecasts", http.StatusInternalServerError)
+		return
+	}
+
+	occurrences := []models.ForecastOccurrence{}
+	for _, s := range schedules {
+		occurrences = append(occurrences, s.occurrences(from, to)...)
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].OccurrenceDate != occurrences[j].OccurrenceDate {
+			return occurrences[i].OccurrenceDate < occurrences[j].OccurrenceDate
+		}
+		return occurrences[i].FinancialUserItemID < occurrences[j].FinancialUserItemID
+	})
+
+	response := struct {
+		From        string                      `json:"from"`
+		To          string                      `json:"to"`
+		Occurrences []models.ForecastOccurrence `json:"occurrences"`
+	}{
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Occurrences: occurrences,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateItemRecurrence sets the custom RRULE and the end date of an item's recurrence
+func UpdateItemRecurrence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateItemRecurrence: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		FinancialUserItemID int    `json:"financialUserItemId"`
+		RecurrencyRule      string `json:"recurrencyRule"`
+		RecurrencyEndDate   string `json:"recurrencyEndDate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("UpdateItemRecurrence: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.FinancialUserItemID == 0 {
+		http.Error(w, "FinancialUserItemID is required", http.StatusBadRequest)
+		return
+	}
+
+	// An empty rule falls back to the item's Recurrency
+	var rule sql.NullString
+	if strings.TrimSpace(payload.RecurrencyRule) != "" {
+		if _, err := recurrence.Parse(payload.RecurrencyRule); err != nil {
+			http.Error(w, "Invalid recurrency rule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule = sql.NullString{String: strings.TrimSpace(payload.RecurrencyRule), Valid: true}
+	}
+
+	var endDate sql.NullTime
+	if payload.RecurrencyEndDate != "" {
+		parsed, err := time.Parse("2006-01-02", payload.RecurrencyEndDate)
+		if err != nil {
+			http.Error(w, "Invalid recurrency end date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		endDate = sql.NullTime{Time: parsed, Valid: true}
+	}
+
+	var result sql.Result
+	err := audited(db.GetDB(), r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		result, err = tx.Exec(`
+			UPDATE financialuseritem fui
+			SET RecurrencyRule = $2, RecurrencyEndDate = $3
+			WHERE fui.FinancialUserItemID = $4 AND `+ownedItemCondition,
+			user.UserProfileID, rule, endDate, payload.FinancialUserItemID)
+		return err
+	})
+	if err != nil {
+		log.Println("UpdateItemRecurrence: Error updating item:", err)
+		http.Error(w, "Failed to update recurrence", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Item not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Recurrence updated successfully"})
+}
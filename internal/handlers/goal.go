@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/goal"
+	"finanapp/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// goalColumns is the base query of the goal listings, $1 is the user
+const goalColumns = `
+	SELECT g.UserSavingsGoalID, g.GoalName, g.TargetAmount, g.TargetDate, g.CurrencyID, c.CurrencyAbreviation,
+		g.FinancialUserItemID, g.UserAssetID, g.UserCategoryID, g.CreatedAt
+	FROM UserSavingsGoal g
+	JOIN Currency c ON c.CurrencyID = g.CurrencyID
+	WHERE g.UserProfileID = $1`
+
+// queryGoals lists the goals of the user in $1, with the extra filter
+func queryGoals(q queryer, filter string, args ...interface{}) ([]models.UserSavingsGoal, error) {
+	rows, err := q.Query(goalColumns+filter+` ORDER BY g.TargetDate, g.UserSavingsGoalID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []models.UserSavingsGoal{}
+	for rows.Next() {
+		var g models.UserSavingsGoal
+		var targetDate time.Time
+		if err := rows.Scan(&g.UserSavingsGoalID, &g.GoalName, &g.TargetAmount, &targetDate, &g.CurrencyID, &g.Currency,
+			&g.FinancialUserItemID, &g.UserAssetID, &g.UserCategoryID, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		g.TargetDate = targetDate.Format("2006-01-02")
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+// validateGoalPayload checks the payload fields and the ownership of the linked asset and category
+func validateGoalPayload(database *sql.DB, userID int, payload *models.UserSavingsGoalPayload) (time.Time, int, error) {
+	payload.GoalName = strings.TrimSpace(payload.GoalName)
+	if payload.GoalName == "" {
+		return time.Time{}, http.StatusBadRequest, fmt.Errorf("goalName is required")
+	}
+	targetDate, err := time.Parse("2006-01-02", payload.TargetDate)
+	if err != nil {
+		return time.Time{}, http.StatusBadRequest, fmt.Errorf("Invalid targetDate format. Expected YYYY-MM-DD")
+	}
+	if err := (goal.Goal{Target: payload.TargetAmount, TargetDate: targetDate}).Validate(); err != nil {
+		return targetDate, http.StatusBadRequest, err
+	}
+
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+	var currencyExists bool
+	if err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM currency WHERE CurrencyID = $1)`, payload.CurrencyID).Scan(&currencyExists); err != nil {
+		return targetDate, http.StatusInternalServerError, err
+	}
+	if !currencyExists {
+		return targetDate, http.StatusBadRequest, fmt.Errorf("Invalid currencyId")
+	}
+
+	if payload.UserAssetID != nil && *payload.UserAssetID != 0 {
+		var owns bool
+		err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM UserAsset WHERE UserAssetID = $2 AND UserProfileID = $1)`,
+			userID, *payload.UserAssetID).Scan(&owns)
+		if err != nil {
+			return targetDate, http.StatusInternalServerError, err
+		}
+		if !owns {
+			return targetDate, http.StatusNotFound, fmt.Errorf("Asset not found or unauthorized")
+		}
+	} else {
+		payload.UserAssetID = nil
+	}
+
+	if payload.UserCategoryID != nil && *payload.UserCategoryID != 0 {
+		owns, err := userOwnsCategory(database, userID, *payload.UserCategoryID)
+		if err != nil {
+			return targetDate, http.StatusInternalServerError, err
+		}
+		if !owns {
+			return targetDate, http.StatusNotFound, fmt.Errorf("Category not found or unauthorized")
+		}
+	} else {
+		payload.UserCategoryID = nil
+	}
+	return targetDate, http.StatusOK, nil
+}
+
+// goalView is a goal with its progress
+type goalView struct {
+	models.UserSavingsGoal
+	Progress      goal.Progress                `json:"progress"`
+	Contributions []models.UserFinancialActual `json:"contributions,omitempty"`
+}
+
+// evaluateGoal computes the progress of a goal on a date from its contributions, converted to the goal currency.
+// rates is loaded on first use and shared between the goals of a listing.
+func evaluateGoal(database *sql.DB, userID int, g models.UserSavingsGoal, on time.Time, paceMonths int, rates **fx.Table) (goalView, error) {
+	view := goalView{UserSavingsGoal: g}
+
+	actuals, err := queryActuals(database, ` AND (ufa.FinancialUserItemID = $2 OR ufa.UserCategoryID = $3)`,
+		userID, g.FinancialUserItemID, g.UserCategoryID)
+	if err != nil {
+		return view, err
+	}
+
+	saved := 0.0
+	contributions := make([]goal.Contribution, 0, len(actuals))
+	for _, a := range actuals {
+		c := goal.Contribution{Date: dbDate(a.UserFinancialActualtBeginDate), Amount: a.UserFinancialActualAmount}
+		if a.CurrencyID != g.CurrencyID {
+			if *rates == nil {
+				if *rates, err = fx.Load(database); err != nil {
+					return view, err
+				}
+			}
+			if c.Amount, err = (*rates).Convert(c.Amount, a.CurrencyID, g.Currency, c.Date); err != nil {
+				return view, err
+			}
+		}
+		if !c.Date.After(on) {
+			saved += c.Amount
+		}
+		contributions = append(contributions, c)
+	}
+
+	// A linked asset holds the savings, its value is what is saved so far
+	if g.UserAssetID != nil {
+		err := database.QueryRow(`SELECT UserAssetValueAmount FROM UserAsset WHERE UserAssetID = $1`, *g.UserAssetID).Scan(&saved)
+		if err != nil {
+			return view, err
+		}
+	}
+
+	target := goal.Goal{Target: g.TargetAmount, TargetDate: dbDate(g.TargetDate), Start: dbDate(g.CreatedAt)}
+	view.Progress = goal.Evaluate(target, saved, contributions, on, paceMonths)
+	view.Contributions = actuals
+	return view, nil
+}
+
+// paceMonths reads the ?months= parameter, the number of months of contributions the projection is based on
+func paceMonths(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("months")
+	if value == "" {
+		return goal.DefaultPaceMonths, nil
+	}
+	months, err := strconv.Atoi(value)
+	if err != nil || months <= 0 || months > 120 {
+		return 0, fmt.Errorf("months must be between 1 and 120")
+	}
+	return months, nil
+}
+
+// Goals lists the savings goals of the user with their progress (?months= sets the pace window, default 3)
+func Goals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Goals: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	months, err := paceMonths(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	goals, err := queryGoals(database, "", user.UserProfileID)
+	if err != nil {
+		log.Println("Goals: Error fetching goals:", err)
+		http.Error(w, "Error fetching goals", http.StatusInternalServerError)
+		return
+	}
+
+	var rates *fx.Table
+	views := make([]goalView, 0, len(goals))
+	for _, g := range goals {
+		view, err := evaluateGoal(database, user.UserProfileID, g, time.Now(), months, &rates)
+		if err != nil {
+			log.Println("Goals: Error evaluating goal:", err)
+			http.Error(w, "Error fetching goals", http.StatusInternalServerError)
+			return
+		}
+		view.Contributions = nil
+		views = append(views, view)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(views)
+}
+
+// GoalDetail returns one goal with its progress and contributions
+func GoalDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("GoalDetail: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	goalID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || goalID <= 0 {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	months, err := paceMonths(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	goals, err := queryGoals(database, ` AND g.UserSavingsGoalID = $2`, user.UserProfileID, goalID)
+	if err != nil {
+		log.Println("GoalDetail: Error fetching goal:", err)
+		http.Error(w, "Error fetching goal", http.StatusInternalServerError)
+		return
+	}
+	if len(goals) == 0 {
+		http.Error(w, "Goal not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	var rates *fx.Table
+	view, err := evaluateGoal(database, user.UserProfileID, goals[0], time.Now(), months, &rates)
+	if err != nil {
+		log.Println("GoalDetail: Error evaluating goal:", err)
+		http.Error(w, "Error fetching goal", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(view)
+}
+
+// CreateGoal creates a savings goal
+func CreateGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateGoal: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserSavingsGoalPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	targetDate, status, err := validateGoalPayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("CreateGoal: Error validating payload:", err)
+			http.Error(w, "Failed to create goal", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var goalID int
+	err = database.QueryRow(`
+		INSERT INTO UserSavingsGoal (UserProfileID, GoalName, TargetAmount, TargetDate, CurrencyID, UserAssetID, UserCategoryID)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING UserSavingsGoalID`,
+		user.UserProfileID, payload.GoalName, payload.TargetAmount, targetDate, payload.CurrencyID,
+		payload.UserAssetID, payload.UserCategoryID).Scan(&goalID)
+	if err != nil {
+		log.Println("CreateGoal: Error inserting goal:", err)
+		http.Error(w, "Failed to create goal", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":               "success",
+		"message":              "Goal created successfully",
+		"user_savings_goal_id": goalID,
+	})
+}
+
+// UpdateGoal changes the target, the name or the links of a goal
+func UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateGoal: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserSavingsGoalPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserSavingsGoalID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	targetDate, status, err := validateGoalPayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("UpdateGoal: Error validating payload:", err)
+			http.Error(w, "Failed to update goal", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("UpdateGoal: Error starting transaction:", err)
+		http.Error(w, "Failed to update goal", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var itemID sql.NullInt64
+	err = tx.QueryRow(`
+		UPDATE UserSavingsGoal SET GoalName = $3, TargetAmount = $4, TargetDate = $5, CurrencyID = $6,
+			UserAssetID = $7, UserCategoryID = $8
+		WHERE UserSavingsGoalID = $2 AND UserProfileID = $1
+		RETURNING FinancialUserItemID`,
+		user.UserProfileID, payload.UserSavingsGoalID, payload.GoalName, payload.TargetAmount, targetDate,
+		payload.CurrencyID, payload.UserAssetID, payload.UserCategoryID).Scan(&itemID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Goal not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	// The contribution item follows the goal name
+	if err == nil && itemID.Valid {
+		_, err = tx.Exec(`UPDATE FinancialUserItem SET FinancialUserItemName = $2 WHERE FinancialUserItemID = $1`,
+			itemID.Int64, payload.GoalName)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("UpdateGoal: Error updating goal:", err)
+		http.Error(w, "Failed to update goal", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Goal updated successfully"})
+}
+
+// DeleteGoal removes a goal. Its contributions are kept as the actuals of the goal item.
+func DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteGoal: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserSavingsGoalID int `json:"userSavingsGoalId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserSavingsGoalID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`DELETE FROM UserSavingsGoal WHERE UserSavingsGoalID = $2 AND UserProfileID = $1`,
+		user.UserProfileID, payload.UserSavingsGoalID)
+	if err != nil {
+		log.Println("DeleteGoal: Error deleting goal:", err)
+		http.Error(w, "Failed to delete goal", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Goal not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Goal deleted successfully"})
+}
+
+// goalItem returns the item the contributions of a goal are booked on, creating a User Expense named after the
+// goal the first time
+func goalItem(q queryer, userID, goalID int) (int, error) {
+	var name string
+	var itemID sql.NullInt64
+	err := q.QueryRow(`SELECT GoalName, FinancialUserItemID FROM UserSavingsGoal WHERE UserSavingsGoalID = $2 AND UserProfileID = $1 FOR UPDATE`,
+		userID, goalID).Scan(&name, &itemID)
+	if err != nil || itemID.Valid {
+		return int(itemID.Int64), err
+	}
+
+	// EntityID 6 is User Expense and RecurrencyID 1 is One Time
+	var id int
+	err = q.QueryRow(`
+		INSERT INTO FinancialUserItem (FinancialUserItemName, EntityID, UserEntityID, RecurrencyID)
+		VALUES ($1, 6, $2, 1) RETURNING FinancialUserItemID`, name, userID).Scan(&id)
+	if err == nil {
+		_, err = q.Exec(`UPDATE UserSavingsGoal SET FinancialUserItemID = $2 WHERE UserSavingsGoalID = $1`, goalID, id)
+	}
+	return id, err
+}
+
+// AddGoalContribution records a contribution to a goal as an actual of the goal item, in the linked category.
+// Contributions are changed and removed with the actual endpoints.
+func AddGoalContribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("AddGoalContribution: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserSavingsGoalID int     `json:"userSavingsGoalId"`
+		Amount            float64 `json:"amount"`
+		Date              string  `json:"date"` // "YYYY-MM-DD", defaults to today
+		CurrencyID        int     `json:"currencyId"`
+		Note              *string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserSavingsGoalID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Date == "" {
+		payload.Date = time.Now().Format("2006-01-02")
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	goals, err := queryGoals(database, ` AND g.UserSavingsGoalID = $2`, user.UserProfileID, payload.UserSavingsGoalID)
+	if err != nil {
+		log.Println("AddGoalContribution: Error fetching goal:", err)
+		http.Error(w, "Failed to add contribution", http.StatusInternalServerError)
+		return
+	}
+	if len(goals) == 0 {
+		http.Error(w, "Goal not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = goals[0].CurrencyID
+	}
+
+	// The goal item is created, the contribution validated against it and the actual inserted in one transaction,
+	// so an invalid contribution doesn't leave a goal item behind
+	actual := models.UserFinancialActualPayload{
+		UserCategoryID: goals[0].UserCategoryID,
+		CurrencyID:     payload.CurrencyID,
+		Amount:         payload.Amount,
+		BeginDate:      payload.Date,
+		Note:           payload.Note,
+	}
+	var actualID int
+	status := http.StatusInternalServerError
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		var err error
+		actual.FinancialUserItemID, err = goalItem(tx, user.UserProfileID, payload.UserSavingsGoalID)
+		if err != nil {
+			return err
+		}
+		var beginDate time.Time
+		var endDate sql.NullTime
+		beginDate, endDate, status, err = validateActualPayload(tx, user.UserProfileID, &actual)
+		if err != nil {
+			return err
+		}
+		status = http.StatusInternalServerError
+		return tx.QueryRow(`
+			INSERT INTO UserFinancialActual (UserCategoryID, FinancialUserItemID, UserFinancialActualtBeginDate,
+				UserFinancialActualEndDate, UserFinancialActualAmount, CurrencyID, Note)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING UserFinancialActualID`,
+			actual.UserCategoryID, actual.FinancialUserItemID, beginDate, endDate,
+			actual.Amount, actual.CurrencyID, actual.Note).Scan(&actualID)
+	})
+	if err != nil {
+		if status != http.StatusInternalServerError {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		log.Println("AddGoalContribution: Error adding contribution:", err)
+		http.Error(w, "Failed to add contribution", http.StatusInternalServerError)
+		return
+	}
+	checkBudgetAlerts(database, user.UserProfileID, actual.UserCategoryID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                   "success",
+		"message":                  "Contribution added successfully",
+		"user_financial_actual_id": actualID,
+	})
+}
@@ -0,0 +1,1060 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Group roles: owners manage the group and its members, members manage the group items and viewers only read
+const (
+	groupRoleOwner  = "owner"
+	groupRoleMember = "member"
+	groupRoleViewer = "viewer"
+)
+
+// groupInviteValidity is how long an invite can be accepted
+const groupInviteValidity = 7 * 24 * time.Hour
+
+func validGroupRole(role string) bool {
+	return role == groupRoleOwner || role == groupRoleMember || role == groupRoleViewer
+}
+
+// groupRole returns the role of the user in the group, "" when they aren't a member
+func groupRole(q queryer, userID, groupID int) (string, error) {
+	var role string
+	err := q.QueryRow(`SELECT GroupRole FROM UserGroupMember WHERE UserGroupID = $1 AND UserProfileID = $2`,
+		groupID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// groupOwnerCount counts the owners of the group, which must never drop to zero
+func groupOwnerCount(q queryer, groupID int) (int, error) {
+	var owners int
+	err := q.QueryRow(`SELECT COUNT(*) FROM UserGroupMember WHERE UserGroupID = $1 AND GroupRole = 'owner'`,
+		groupID).Scan(&owners)
+	return owners, err
+}
+
+// loadGroupMembers lists the members of a group
+func loadGroupMembers(database *sql.DB, groupID int) ([]models.UserGroupMember, error) {
+	rows, err := database.Query(`
+		SELECT up.UserProfileID, up.FirstName, up.LastName, up.EmailAddress, ugm.GroupRole, ugm.CreatedAt
+		FROM UserGroupMember ugm
+		JOIN UserProfile up ON up.UserProfileID = ugm.UserProfileID
+		WHERE ugm.UserGroupID = $1
+		ORDER BY ugm.CreatedAt, up.UserProfileID`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.UserGroupMember{}
+	for rows.Next() {
+		var m models.UserGroupMember
+		if err := rows.Scan(&m.UserProfileID, &m.FirstName, &m.LastName, &m.EmailAddress, &m.GroupRole, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// loadGroupItems lists the items of a group with their forecasts and actuals. The user in $1 must be a member.
+func loadGroupItems(database *sql.DB, userID, groupID int) ([]models.UserGroupItem, error) {
+	rows, err := database.Query(`
+		SELECT fui.FinancialUserItemID, fui.FinancialUserItemName, fui.EntityID, e.EntityType, fui.RecurrencyID,
+			fui.FinancialUserEntityItemID, fui.ParentFinancialUserItemID, fui.IsActive
+		FROM FinancialUserItem fui
+		JOIN Entity e ON e.EntityID = fui.EntityID
+		WHERE `+groupItemCondition+` AND fui.UserEntityID = $2
+		ORDER BY fui.EntityID, fui.FinancialUserItemID`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []models.UserGroupItem{}
+	index := map[int]int{}
+	for rows.Next() {
+		var item models.UserGroupItem
+		if err := rows.Scan(&item.FinancialUserItemID, &item.FinancialUserItemName, &item.EntityID, &item.EntityType,
+			&item.RecurrencyID, &item.FinancialUserEntityItemID, &item.ParentFinancialUserItemID, &item.IsActive); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.Forecasts = []models.UserFinancialForecast{}
+		item.Actuals = []models.UserFinancialActual{}
+		index[item.FinancialUserItemID] = len(items)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.Query(`
+		SELECT uff.UserFinancialForecastID, uff.UserCategoryID, uff.FinancialUserItemID, uff.UserFinancialForecastAmount,
+			uff.UserFinancialForecastBeginDate, uff.UserFinancialForecastEndDate, uff.CurrencyID,
+			uc.UserCategoryName, fui.FinancialUserItemName, c.CurrencyName, uff.CreatedAt
+		FROM userfinancialforecast uff
+		LEFT JOIN usercategory uc ON uff.UserCategoryID = uc.UserCategoryID
+		JOIN financialuseritem fui ON uff.FinancialUserItemID = fui.FinancialUserItemID
+		JOIN currency c ON uff.CurrencyID = c.CurrencyID
+		WHERE `+groupItemCondition+` AND fui.UserEntityID = $2
+		ORDER BY uff.UserFinancialForecastBeginDate`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var f models.UserFinancialForecast
+		if err := rows.Scan(&f.UserFinancialForecastID, &f.UserCategoryID, &f.FinancialUserItemID, &f.UserFinancialForecastAmount,
+			&f.UserFinancialForecastBeginDate, &f.UserFinancialForecastEndDate, &f.CurrencyID,
+			&f.UserCategoryName, &f.FinancialUserItemName, &f.CurrencyName, &f.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i, ok := index[f.FinancialUserItemID]; ok {
+			items[i].Forecasts = append(items[i].Forecasts, f)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	actuals, err := queryActualsWhere(database, groupItemCondition+` AND fui.UserEntityID = $2`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range actuals {
+		if i, ok := index[a.FinancialUserItemID]; ok {
+			items[i].Actuals = append(items[i].Actuals, a)
+		}
+	}
+
+	return items, nil
+}
+
+// CreateGroup creates a household group with the logged-in user as its owner
+func CreateGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateGroup: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupName string `json:"userGroupName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.UserGroupName = strings.TrimSpace(payload.UserGroupName)
+	if payload.UserGroupName == "" {
+		http.Error(w, "userGroupName is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("CreateGroup: Error starting transaction:", err)
+		http.Error(w, "Failed to create group", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var groupID int
+	err = tx.QueryRow(`INSERT INTO UserGroup (UserGroupName, CreatedByUserProfileID) VALUES ($1, $2) RETURNING UserGroupID`,
+		payload.UserGroupName, user.UserProfileID).Scan(&groupID)
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO UserGroupMember (UserGroupID, UserProfileID, GroupRole) VALUES ($1, $2, 'owner')`,
+			groupID, user.UserProfileID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CreateGroup: Error creating group:", err)
+		http.Error(w, "Failed to create group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "success",
+		"message":       "Group created successfully",
+		"user_group_id": groupID,
+	})
+}
+
+// Groups lists the groups of the logged-in user with their members
+func Groups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Groups: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rows, err := database.Query(`
+		SELECT ug.UserGroupID, ug.UserGroupName, ug.CreatedByUserProfileID, ugm.GroupRole, ug.CreatedAt
+		FROM UserGroup ug
+		JOIN UserGroupMember ugm ON ugm.UserGroupID = ug.UserGroupID
+		WHERE ugm.UserProfileID = $1
+		ORDER BY ug.UserGroupName`, user.UserProfileID)
+	if err != nil {
+		log.Println("Groups: Error fetching groups:", err)
+		http.Error(w, "Error fetching groups", http.StatusInternalServerError)
+		return
+	}
+
+	groups := []models.UserGroup{}
+	for rows.Next() {
+		var g models.UserGroup
+		if err := rows.Scan(&g.UserGroupID, &g.UserGroupName, &g.CreatedByUserProfileID, &g.GroupRole, &g.CreatedAt); err != nil {
+			rows.Close()
+			log.Println("Groups: Error scanning group:", err)
+			http.Error(w, "Error fetching groups", http.StatusInternalServerError)
+			return
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+
+	for i := range groups {
+		members, err := loadGroupMembers(database, groups[i].UserGroupID)
+		if err != nil {
+			log.Println("Groups: Error fetching members:", err)
+			http.Error(w, "Error fetching groups", http.StatusInternalServerError)
+			return
+		}
+		groups[i].Members = members
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_groups": groups})
+}
+
+// GroupDetail returns a group with its members and the shared items, visible to every member.
+// Owners also see the pending invites.
+func GroupDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("GroupDetail: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || groupID <= 0 {
+		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var group models.UserGroup
+	err = database.QueryRow(`
+		SELECT ug.UserGroupID, ug.UserGroupName, ug.CreatedByUserProfileID, ugm.GroupRole, ug.CreatedAt
+		FROM UserGroup ug
+		JOIN UserGroupMember ugm ON ugm.UserGroupID = ug.UserGroupID
+		WHERE ug.UserGroupID = $2 AND ugm.UserProfileID = $1`, user.UserProfileID, groupID).Scan(
+		&group.UserGroupID, &group.UserGroupName, &group.CreatedByUserProfileID, &group.GroupRole, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Group not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("GroupDetail: Error fetching group:", err)
+		http.Error(w, "Error fetching group", http.StatusInternalServerError)
+		return
+	}
+
+	if group.Members, err = loadGroupMembers(database, groupID); err != nil {
+		log.Println("GroupDetail: Error fetching members:", err)
+		http.Error(w, "Error fetching group", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := loadGroupItems(database, user.UserProfileID, groupID)
+	if err != nil {
+		log.Println("GroupDetail: Error fetching items:", err)
+		http.Error(w, "Error fetching group", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_group":  group,
+		"group_items": items,
+	}
+
+	if group.GroupRole == groupRoleOwner {
+		invites, err := queryGroupInvites(database, `ugi.UserGroupID = $1 AND ugi.InviteStatus = 'pending'`, groupID)
+		if err != nil {
+			log.Println("GroupDetail: Error fetching invites:", err)
+			http.Error(w, "Error fetching group", http.StatusInternalServerError)
+			return
+		}
+		response["pending_invites"] = invites
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteGroup deletes a group. Only owners can delete it. Its items, with their forecasts and actuals, go to the trash
+// of the owner who deletes it as their own items (Group EntityIDs 1-4 become the User EntityIDs 5-8), so they can
+// still be restored until the retention purges them.
+func DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteGroup: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupID int `json:"userGroupId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserGroupID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteGroup: Error starting transaction:", err)
+		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	role, err := groupRole(tx, user.UserProfileID, payload.UserGroupID)
+	if err != nil {
+		log.Println("DeleteGroup: Error checking role:", err)
+		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+	if role != groupRoleOwner {
+		http.Error(w, "Only the group owners can delete it", http.StatusForbidden)
+		return
+	}
+
+	// Items already in the trash keep their DeletedAt, the others are trashed together and share it with their children
+	_, err = tx.Exec(`
+		UPDATE FinancialUserItem
+		SET EntityID = EntityID + 4, UserEntityID = $2,
+			DeletedWasActive = CASE WHEN DeletedAt IS NULL THEN IsActive ELSE DeletedWasActive END,
+			IsActive = FALSE, DeletedAt = COALESCE(DeletedAt, CURRENT_TIMESTAMP)
+		WHERE EntityID IN (1, 2, 3, 4) AND UserEntityID = $1`, payload.UserGroupID, user.UserProfileID)
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM UserGroup WHERE UserGroupID = $1`, payload.UserGroupID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DeleteGroup: Error deleting group:", err)
+		http.Error(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Group deleted successfully, its items were moved to your trash"})
+}
+
+// queryGroupInvites lists the invites matching the condition on the alias "ugi"
+func queryGroupInvites(database *sql.DB, condition string, args ...interface{}) ([]models.UserGroupInvite, error) {
+	rows, err := database.Query(`
+		SELECT ugi.UserGroupInviteID, ugi.UserGroupID, ug.UserGroupName, ugi.InvitedEmailAddress,
+			up.FirstName || ' ' || up.LastName, ugi.GroupRole, ugi.InviteToken, ugi.InviteStatus, ugi.ExpiresAt
+		FROM UserGroupInvite ugi
+		JOIN UserGroup ug ON ug.UserGroupID = ugi.UserGroupID
+		JOIN UserProfile up ON up.UserProfileID = ugi.InvitedByUserProfileID
+		WHERE `+condition+`
+		ORDER BY ugi.CreatedAt`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.UserGroupInvite{}
+	for rows.Next() {
+		var invite models.UserGroupInvite
+		if err := rows.Scan(&invite.UserGroupInviteID, &invite.UserGroupID, &invite.UserGroupName, &invite.InvitedEmailAddress,
+			&invite.InvitedBy, &invite.GroupRole, &invite.InviteToken, &invite.InviteStatus, &invite.ExpiresAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// InviteGroupMember invites a user by email to join the group with a role. Only owners can invite.
+func InviteGroupMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("InviteGroupMember: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupID  int    `json:"userGroupId"`
+		EmailAddress string `json:"emailAddress"`
+		GroupRole    string `json:"groupRole"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.EmailAddress = strings.ToLower(strings.TrimSpace(payload.EmailAddress))
+	if payload.GroupRole == "" {
+		payload.GroupRole = groupRoleMember
+	}
+	if payload.UserGroupID == 0 || payload.EmailAddress == "" {
+		http.Error(w, "userGroupId and emailAddress are required", http.StatusBadRequest)
+		return
+	}
+	if !validGroupRole(payload.GroupRole) {
+		http.Error(w, "groupRole must be owner, member or viewer", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	role, err := groupRole(database, user.UserProfileID, payload.UserGroupID)
+	if err != nil {
+		log.Println("InviteGroupMember: Error checking role:", err)
+		http.Error(w, "Failed to invite member", http.StatusInternalServerError)
+		return
+	}
+	if role != groupRoleOwner {
+		http.Error(w, "Only the group owners can invite members", http.StatusForbidden)
+		return
+	}
+
+	var alreadyMember bool
+	err = database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM UserGroupMember ugm
+			JOIN UserProfile up ON up.UserProfileID = ugm.UserProfileID
+			WHERE ugm.UserGroupID = $1 AND LOWER(up.EmailAddress) = $2
+		)`, payload.UserGroupID, payload.EmailAddress).Scan(&alreadyMember)
+	if err != nil {
+		log.Println("InviteGroupMember: Error checking members:", err)
+		http.Error(w, "Failed to invite member", http.StatusInternalServerError)
+		return
+	}
+	if alreadyMember {
+		http.Error(w, "User is already a member of the group", http.StatusConflict)
+		return
+	}
+
+	// A new invite replaces the pending one of the same email
+	token := utils.GenerateToken(32)
+	expiresAt := time.Now().Add(groupInviteValidity)
+	var inviteID int
+	_, err = database.Exec(`
+		UPDATE UserGroupInvite SET InviteStatus = 'revoked', RespondedAt = CURRENT_TIMESTAMP
+		WHERE UserGroupID = $1 AND LOWER(InvitedEmailAddress) = $2 AND InviteStatus = 'pending'`,
+		payload.UserGroupID, payload.EmailAddress)
+	if err == nil {
+		err = database.QueryRow(`
+			INSERT INTO UserGroupInvite (UserGroupID, InvitedEmailAddress, InvitedByUserProfileID, GroupRole, InviteToken, ExpiresAt)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING UserGroupInviteID`,
+			payload.UserGroupID, payload.EmailAddress, user.UserProfileID, payload.GroupRole, token, expiresAt).Scan(&inviteID)
+	}
+	if err != nil {
+		log.Println("InviteGroupMember: Error creating invite:", err)
+		http.Error(w, "Failed to invite member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":               "success",
+		"message":              "Invite created successfully",
+		"user_group_invite_id": inviteID,
+		"invite_token":         token,
+		"expires_at":           expiresAt.Format(time.RFC3339),
+	})
+}
+
+// GroupInvites lists the pending invites sent to the email of the logged-in user
+func GroupInvites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("GroupInvites: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	invites, err := queryGroupInvites(database, `LOWER(ugi.InvitedEmailAddress) = LOWER($1)
+		AND ugi.InviteStatus = 'pending' AND ugi.ExpiresAt > CURRENT_TIMESTAMP`, user.EmailAddress)
+	if err != nil {
+		log.Println("GroupInvites: Error fetching invites:", err)
+		http.Error(w, "Error fetching invites", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"group_invites": invites})
+}
+
+// RespondGroupInvite accepts or declines an invite sent to the email of the logged-in user
+func RespondGroupInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RespondGroupInvite: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		InviteToken string `json:"inviteToken"`
+		Accept      bool   `json:"accept"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.InviteToken == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("RespondGroupInvite: Error starting transaction:", err)
+		http.Error(w, "Failed to respond to invite", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var inviteID, groupID int
+	var role string
+	err = tx.QueryRow(`
+		SELECT UserGroupInviteID, UserGroupID, GroupRole
+		FROM UserGroupInvite
+		WHERE InviteToken = $1 AND LOWER(InvitedEmailAddress) = LOWER($2)
+			AND InviteStatus = 'pending' AND ExpiresAt > CURRENT_TIMESTAMP
+		FOR UPDATE`, payload.InviteToken, user.EmailAddress).Scan(&inviteID, &groupID, &role)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Invite not found or expired", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("RespondGroupInvite: Error fetching invite:", err)
+		http.Error(w, "Failed to respond to invite", http.StatusInternalServerError)
+		return
+	}
+
+	status, message := "declined", "Invite declined"
+	if payload.Accept {
+		status, message = "accepted", "Invite accepted, you are now a member of the group"
+		_, err = tx.Exec(`
+			INSERT INTO UserGroupMember (UserGroupID, UserProfileID, GroupRole) VALUES ($1, $2, $3)
+			ON CONFLICT (UserGroupID, UserProfileID) DO NOTHING`,
+			groupID, user.UserProfileID, role)
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE UserGroupInvite SET InviteStatus = $2, RespondedAt = CURRENT_TIMESTAMP WHERE UserGroupInviteID = $1`,
+			inviteID, status)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("RespondGroupInvite: Error responding to invite:", err)
+		http.Error(w, "Failed to respond to invite", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "success",
+		"message":       message,
+		"user_group_id": groupID,
+	})
+}
+
+// RevokeGroupInvite cancels a pending invite. Only owners can revoke.
+func RevokeGroupInvite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RevokeGroupInvite: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupInviteID int `json:"userGroupInviteId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserGroupInviteID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`
+		UPDATE UserGroupInvite ugi SET InviteStatus = 'revoked', RespondedAt = CURRENT_TIMESTAMP
+		FROM UserGroupMember ugm
+		WHERE ugi.UserGroupInviteID = $2 AND ugi.InviteStatus = 'pending'
+			AND ugm.UserGroupID = ugi.UserGroupID AND ugm.UserProfileID = $1 AND ugm.GroupRole = 'owner'`,
+		user.UserProfileID, payload.UserGroupInviteID)
+	if err != nil {
+		log.Println("RevokeGroupInvite: Error revoking invite:", err)
+		http.Error(w, "Failed to revoke invite", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Invite not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Invite revoked successfully"})
+}
+
+// UpdateGroupMemberRole changes the role of a member. Only owners can change roles and the group always keeps an owner.
+func UpdateGroupMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateGroupMemberRole: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupID   int    `json:"userGroupId"`
+		UserProfileID int    `json:"userProfileId"`
+		GroupRole     string `json:"groupRole"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserGroupID == 0 || payload.UserProfileID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !validGroupRole(payload.GroupRole) {
+		http.Error(w, "groupRole must be owner, member or viewer", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("UpdateGroupMemberRole: Error starting transaction:", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	role, err := groupRole(tx, user.UserProfileID, payload.UserGroupID)
+	if err != nil {
+		log.Println("UpdateGroupMemberRole: Error checking role:", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+	if role != groupRoleOwner {
+		http.Error(w, "Only the group owners can change roles", http.StatusForbidden)
+		return
+	}
+
+	current, err := groupRole(tx, payload.UserProfileID, payload.UserGroupID)
+	if err != nil {
+		log.Println("UpdateGroupMemberRole: Error checking member:", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+	if current == "" {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if current == groupRoleOwner && payload.GroupRole != groupRoleOwner {
+		owners, err := groupOwnerCount(tx, payload.UserGroupID)
+		if err != nil {
+			log.Println("UpdateGroupMemberRole: Error counting owners:", err)
+			http.Error(w, "Failed to update role", http.StatusInternalServerError)
+			return
+		}
+		if owners <= 1 {
+			http.Error(w, "The group must keep at least one owner", http.StatusConflict)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE UserGroupMember SET GroupRole = $3 WHERE UserGroupID = $1 AND UserProfileID = $2`,
+		payload.UserGroupID, payload.UserProfileID, payload.GroupRole)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("UpdateGroupMemberRole: Error updating role:", err)
+		http.Error(w, "Failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Role updated successfully"})
+}
+
+// RemoveGroupMember removes a member from the group. Owners can remove anyone, members can leave,
+// and the last owner can't leave.
+func RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RemoveGroupMember: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupID   int `json:"userGroupId"`
+		UserProfileID int `json:"userProfileId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserGroupID == 0 || payload.UserProfileID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("RemoveGroupMember: Error starting transaction:", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	role, err := groupRole(tx, user.UserProfileID, payload.UserGroupID)
+	if err == nil && role != groupRoleOwner && payload.UserProfileID != user.UserProfileID {
+		http.Error(w, "Only the group owners can remove other members", http.StatusForbidden)
+		return
+	}
+	var removed string
+	if err == nil {
+		removed, err = groupRole(tx, payload.UserProfileID, payload.UserGroupID)
+	}
+	if err != nil {
+		log.Println("RemoveGroupMember: Error checking roles:", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	if removed == "" {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if removed == groupRoleOwner {
+		owners, err := groupOwnerCount(tx, payload.UserGroupID)
+		if err != nil {
+			log.Println("RemoveGroupMember: Error counting owners:", err)
+			http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+			return
+		}
+		if owners <= 1 {
+			http.Error(w, "The last owner can't leave the group, delete it or promote another member first", http.StatusConflict)
+			return
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM UserGroupMember WHERE UserGroupID = $1 AND UserProfileID = $2`,
+		payload.UserGroupID, payload.UserProfileID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("RemoveGroupMember: Error removing member:", err)
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Member removed successfully"})
+}
+
+// groupItemResponse is the p_Message of the group item procedures
+type groupItemResponse struct {
+	models.Response
+	FinancialUserItemID int `json:"financial_user_item_id,omitempty"`
+}
+
+// writeGroupItemResponse parses the procedure message and sends it, failures are bad requests
+func writeGroupItemResponse(w http.ResponseWriter, message string, successStatus int) {
+	var resp groupItemResponse
+	if err := json.Unmarshal([]byte(message), &resp); err != nil {
+		log.Println("Error parsing stored procedure response:", err)
+		http.Error(w, "Invalid response from stored procedure", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status == "fail" {
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		w.WriteHeader(successStatus)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateGroupItem creates a Group Income or Group Expense (EntityIDs 1 and 2) with its forecasts, or a Group Income
+// Tax or Group Income Expense (EntityIDs 3 and 4) under a Group Income. Viewers can't create items.
+func CreateGroupItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateGroupItem: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserGroupID               int     `json:"userGroupId"`
+		EntityID                  int     `json:"entityId"`
+		FinancialUserItemName     string  `json:"financialUserItemName"`
+		RecurrencyID              int     `json:"recurrencyId"`
+		FinancialUserEntityItemID *int    `json:"financialUserEntityItemId"`
+		ParentFinancialUserItemID int     `json:"parentFinancialUserItemId"`
+		Amount                    float64 `json:"amount"`
+		BeginDate                 string  `json:"beginDate"`
+		CurrencyID                int     `json:"currencyId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var message string
+	var err error
+	switch payload.EntityID {
+	case 1, 2:
+		if payload.UserGroupID == 0 || payload.RecurrencyID == 0 {
+			http.Error(w, "userGroupId and recurrencyId are required", http.StatusBadRequest)
+			return
+		}
+		beginDate := time.Now().Format("2006-01-02")
+		if payload.BeginDate != "" {
+			if _, err := time.Parse("2006-01-02", payload.BeginDate); err != nil {
+				http.Error(w, "Invalid beginDate format (expected YYYY-MM-DD)", http.StatusBadRequest)
+				return
+			}
+			beginDate = payload.BeginDate
+		}
+		if payload.CurrencyID == 0 {
+			payload.CurrencyID = 1
+		}
+		err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+			return tx.QueryRow(`CALL CreateGroupParentItem($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+				user.UserProfileID, payload.UserGroupID, payload.EntityID, payload.FinancialUserItemName, payload.RecurrencyID,
+				payload.FinancialUserEntityItemID, payload.Amount, beginDate, payload.CurrencyID, message).Scan(&message)
+		})
+	case 3, 4:
+		if payload.ParentFinancialUserItemID == 0 {
+			http.Error(w, "parentFinancialUserItemId is required", http.StatusBadRequest)
+			return
+		}
+		err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+			return tx.QueryRow(`CALL CreateGroupChildItem($1, $2, $3, $4, $5, $6, $7)`,
+				user.UserProfileID, payload.EntityID, payload.FinancialUserItemName, payload.FinancialUserEntityItemID,
+				payload.ParentFinancialUserItemID, payload.Amount, message).Scan(&message)
+		})
+	default:
+		http.Error(w, "entityId must be a group entity (1 to 4)", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("CreateGroupItem: Error calling procedure:", err)
+		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
+		return
+	}
+
+	writeGroupItemResponse(w, message, http.StatusCreated)
+}
+
+// UpdateGroupItem renames a Group Income or Group Expense and changes its forecast amount from a date (today by default)
+func UpdateGroupItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateGroupItem: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		FinancialUserItemID   int     `json:"financialUserItemId"`
+		FinancialUserItemName string  `json:"financialUserItemName"`
+		Amount                float64 `json:"amount"`
+		BeginDate             string  `json:"beginDate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.FinancialUserItemID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	beginDate := time.Now().Format("2006-01-02")
+	if payload.BeginDate != "" {
+		if _, err := time.Parse("2006-01-02", payload.BeginDate); err != nil {
+			http.Error(w, "Invalid beginDate format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		beginDate = payload.BeginDate
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var message string
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`CALL UpdateGroupParentItem($1, $2, $3, $4, $5, $6)`,
+			payload.FinancialUserItemID, user.UserProfileID, payload.FinancialUserItemName, payload.Amount, beginDate,
+			message).Scan(&message)
+	})
+	if err != nil {
+		log.Println("UpdateGroupItem: Error calling procedure:", err)
+		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
+		return
+	}
+
+	writeGroupItemResponse(w, message, http.StatusOK)
+}
+
+// DeleteGroupItem moves a group item with its children to the trash, where the owners and members of the group can
+// restore it
+func DeleteGroupItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteGroupItem: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		FinancialUserItemID int `json:"financialUserItemId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.FinancialUserItemID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var message string
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(`CALL DeleteGroupItem($1, $2, $3)`,
+			payload.FinancialUserItemID, user.UserProfileID, message).Scan(&message)
+	})
+	if err != nil {
+		log.Println("DeleteGroupItem: Error calling procedure:", err)
+		http.Error(w, "Error executing procedure", http.StatusInternalServerError)
+		return
+	}
+
+	writeGroupItemResponse(w, message, http.StatusOK)
+}
@@ -0,0 +1,798 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/portfolio"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// investmentAssetTypeID is the Investment AssetType from the seed
+const investmentAssetTypeID = 2
+
+// derivedValuationNote marks the valuations computed from the holdings, so they can be recomputed
+const derivedValuationNote = "Derived from holdings"
+
+// maxPriceFileSize limits the uploaded price files
+const maxPriceFileSize = 16 << 20
+
+// holdingData is a holding with its transactions and the prices of its security
+type holdingData struct {
+	models.UserAssetHolding
+	rows         []models.UserAssetHoldingTransaction
+	transactions []portfolio.Transaction
+	prices       []portfolio.Price
+}
+
+// loadHoldings loads the holdings of an asset of the user in $1 with their transactions and prices
+func loadHoldings(q queryer, userID, assetID int) ([]*holdingData, error) {
+	rows, err := q.Query(`
+		SELECT uah.UserAssetHoldingID, uah.UserAssetID, uah.UserSecurityID, us.Ticker, COALESCE(us.SecurityName, ''),
+			uah.CostMethod, uah.CreatedAt
+		FROM UserAssetHolding uah
+		JOIN UserAsset ua ON ua.UserAssetID = uah.UserAssetID
+		JOIN UserSecurity us ON us.UserSecurityID = uah.UserSecurityID
+		WHERE ua.UserProfileID = $1 AND uah.UserAssetID = $2
+		ORDER BY us.Ticker`, userID, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var holdings []*holdingData
+	byID := map[int]*holdingData{}
+	bySecurity := map[int]*holdingData{}
+	for rows.Next() {
+		h := &holdingData{rows: []models.UserAssetHoldingTransaction{}}
+		if err := rows.Scan(&h.UserAssetHoldingID, &h.UserAssetID, &h.UserSecurityID, &h.Ticker, &h.SecurityName,
+			&h.CostMethod, &h.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		holdings = append(holdings, h)
+		byID[h.UserAssetHoldingID] = h
+		bySecurity[h.UserSecurityID] = h
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT t.UserAssetHoldingTransactionID, t.UserAssetHoldingID, t.TransactionDate, t.TransactionSide,
+			t.Quantity, t.UnitPrice, t.Fees
+		FROM UserAssetHoldingTransaction t
+		JOIN UserAssetHolding uah ON uah.UserAssetHoldingID = t.UserAssetHoldingID
+		WHERE uah.UserAssetID = $1
+		ORDER BY t.TransactionDate, t.UserAssetHoldingTransactionID`, assetID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t models.UserAssetHoldingTransaction
+		var date time.Time
+		if err := rows.Scan(&t.UserAssetHoldingTransactionID, &t.UserAssetHoldingID, &date, &t.TransactionSide,
+			&t.Quantity, &t.UnitPrice, &t.Fees); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		t.TransactionDate = date.Format("2006-01-02")
+		if h, ok := byID[t.UserAssetHoldingID]; ok {
+			h.rows = append(h.rows, t)
+			h.transactions = append(h.transactions, portfolio.Transaction{
+				Date: date, Side: t.TransactionSide, Quantity: t.Quantity, Price: t.UnitPrice, Fees: t.Fees,
+			})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT usp.UserSecurityID, usp.PriceDate, usp.ClosePrice
+		FROM UserSecurityPrice usp
+		JOIN UserAssetHolding uah ON uah.UserSecurityID = usp.UserSecurityID
+		WHERE uah.UserAssetID = $1
+		ORDER BY usp.PriceDate`, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var securityID int
+		var p portfolio.Price
+		if err := rows.Scan(&securityID, &p.Date, &p.Price); err != nil {
+			return nil, err
+		}
+		if h, ok := bySecurity[securityID]; ok {
+			h.prices = append(h.prices, p)
+		}
+	}
+	return holdings, rows.Err()
+}
+
+// valueOn prices the position of the holding on a date, with the latest market price or, when there is
+// none yet, the price of the latest transaction
+func (h *holdingData) valueOn(on time.Time) (portfolio.Position, portfolio.Valuation, error) {
+	position, err := portfolio.Build(h.CostMethod, portfolio.Until(h.transactions, on))
+	if err != nil {
+		return position, portfolio.Valuation{}, err
+	}
+	if price, ok := portfolio.PriceOn(h.prices, on); ok {
+		return position, portfolio.Value(position, price.Price, price.Date.Format("2006-01-02")), nil
+	}
+	return position, portfolio.Value(position, position.LastPrice, ""), nil
+}
+
+// syncHoldingsValue recomputes the valuations of an investment asset from its holdings on every price and
+// transaction date since from, then refreshes UserAsset.UserAssetValueAmount
+func syncHoldingsValue(q queryer, userID, assetID int, from time.Time) error {
+	holdings, err := loadHoldings(q, userID, assetID)
+	if err != nil {
+		return err
+	}
+
+	var first time.Time
+	dates := map[time.Time]bool{}
+	for _, h := range holdings {
+		if len(h.transactions) > 0 && (first.IsZero() || h.transactions[0].Date.Before(first)) {
+			first = h.transactions[0].Date
+		}
+		for _, t := range h.transactions {
+			dates[t.Date] = true
+		}
+		for _, p := range h.prices {
+			dates[p.Date] = true
+		}
+	}
+
+	var keep []time.Time
+	for d := range dates {
+		if d.Before(from) || d.Before(first) {
+			continue
+		}
+		total := 0.0
+		for _, h := range holdings {
+			_, value, err := h.valueOn(d)
+			if err != nil {
+				return err
+			}
+			total += value.MarketValue
+		}
+		_, err := q.Exec(`
+			INSERT INTO UserAssetValuation (UserAssetID, UserAssetValuationDate, UserAssetValuationAmount, Note)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (UserAssetID, UserAssetValuationDate)
+			DO UPDATE SET UserAssetValuationAmount = EXCLUDED.UserAssetValuationAmount, Note = EXCLUDED.Note`,
+			assetID, d, total, derivedValuationNote)
+		if err != nil {
+			return err
+		}
+		keep = append(keep, d)
+	}
+
+	// Derived valuations of dates that no longer have a price or a transaction
+	kept := make(pq.StringArray, 0, len(keep))
+	for _, d := range keep {
+		kept = append(kept, d.Format("2006-01-02"))
+	}
+	_, err = q.Exec(`
+		DELETE FROM UserAssetValuation
+		WHERE UserAssetID = $1 AND Note = $2 AND UserAssetValuationDate >= $3
+			AND NOT (UserAssetValuationDate = ANY($4::DATE[]))`,
+		assetID, derivedValuationNote, from, kept)
+	if err != nil {
+		return err
+	}
+
+	return syncAssetValue(q, assetID)
+}
+
+// assetHasHoldings tells whether the value of an asset is derived from holdings
+func assetHasHoldings(q queryer, assetID int) (bool, error) {
+	var exists bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM UserAssetHolding WHERE UserAssetID = $1)`, assetID).Scan(&exists)
+	return exists, err
+}
+
+// holdingView is a holding with its position priced at the latest market price
+type holdingView struct {
+	models.UserAssetHolding
+	portfolio.Position
+	portfolio.Valuation
+	Transactions []models.UserAssetHoldingTransaction `json:"transactions"`
+}
+
+// Holdings lists the holdings of an investment asset (?assetId=) with their cost basis, market value and
+// realized and unrealized gains
+func Holdings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Holdings: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	assetID, err := strconv.Atoi(r.URL.Query().Get("assetId"))
+	if err != nil || assetID <= 0 {
+		http.Error(w, "assetId is required", http.StatusBadRequest)
+		return
+	}
+
+	holdings, err := loadHoldings(db.GetDB(), user.UserProfileID, assetID)
+	if err != nil {
+		log.Println("Holdings: Error fetching holdings:", err)
+		http.Error(w, "Error fetching holdings", http.StatusInternalServerError)
+		return
+	}
+
+	today := time.Now()
+	views := []holdingView{}
+	var costBasis, marketValue, realized, unrealized float64
+	for _, h := range holdings {
+		position, value, err := h.valueOn(today)
+		if err != nil {
+			log.Printf("Holdings: Ignoring holding %d: %v", h.UserAssetHoldingID, err)
+			continue
+		}
+		views = append(views, holdingView{UserAssetHolding: h.UserAssetHolding, Position: position, Valuation: value, Transactions: h.rows})
+		costBasis += position.CostBasis
+		marketValue += value.MarketValue
+		realized += position.RealizedGain
+		unrealized += value.UnrealizedGain
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_asset_id": assetID,
+		"holdings":      views,
+		"totals": map[string]float64{
+			"costBasis":      math.Round(costBasis*100) / 100,
+			"marketValue":    math.Round(marketValue*100) / 100,
+			"realizedGain":   math.Round(realized*100) / 100,
+			"unrealizedGain": math.Round(unrealized*100) / 100,
+		},
+	})
+}
+
+// CreateHolding adds a security by ticker to an investment asset
+func CreateHolding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateHolding: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetID  int    `json:"userAssetId"`
+		Ticker       string `json:"ticker"`
+		SecurityName string `json:"securityName"`
+		CostMethod   string `json:"costMethod"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.Ticker = strings.ToUpper(strings.TrimSpace(payload.Ticker))
+	if payload.UserAssetID == 0 || payload.Ticker == "" {
+		http.Error(w, "userAssetId and ticker are required", http.StatusBadRequest)
+		return
+	}
+	if payload.CostMethod == "" {
+		payload.CostMethod = portfolio.AverageCost
+	}
+	if payload.CostMethod != portfolio.AverageCost && payload.CostMethod != portfolio.FIFO {
+		http.Error(w, "costMethod must be average or fifo", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var assetTypeID int
+	err := database.QueryRow(`SELECT AssetTypeID FROM UserAsset WHERE UserAssetID = $2 AND UserProfileID = $1`,
+		user.UserProfileID, payload.UserAssetID).Scan(&assetTypeID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Asset not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("CreateHolding: Error fetching asset:", err)
+		http.Error(w, "Failed to create holding", http.StatusInternalServerError)
+		return
+	}
+	if assetTypeID != investmentAssetTypeID {
+		http.Error(w, "Holdings can only be added to investment assets", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("CreateHolding: Error starting transaction:", err)
+		http.Error(w, "Failed to create holding", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var securityID, holdingID int
+	err = tx.QueryRow(`
+		INSERT INTO UserSecurity (UserProfileID, Ticker, SecurityName) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (UserProfileID, Ticker) DO UPDATE SET SecurityName = COALESCE(EXCLUDED.SecurityName, UserSecurity.SecurityName)
+		RETURNING UserSecurityID`, user.UserProfileID, payload.Ticker, strings.TrimSpace(payload.SecurityName)).Scan(&securityID)
+	if err == nil {
+		err = tx.QueryRow(`
+			INSERT INTO UserAssetHolding (UserAssetID, UserSecurityID, CostMethod) VALUES ($1, $2, $3)
+			ON CONFLICT (UserAssetID, UserSecurityID) DO NOTHING
+			RETURNING UserAssetHoldingID`, payload.UserAssetID, securityID, payload.CostMethod).Scan(&holdingID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "The asset already holds this ticker", http.StatusConflict)
+			return
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CreateHolding: Error creating holding:", err)
+		http.Error(w, "Failed to create holding", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                "success",
+		"message":               "Holding created successfully",
+		"user_asset_holding_id": holdingID,
+		"user_security_id":      securityID,
+	})
+}
+
+// holdingAsset returns the asset of a holding owned by the user, 0 when not found
+func holdingAsset(q queryer, userID, holdingID int) (int, error) {
+	var assetID int
+	err := q.QueryRow(`
+		SELECT uah.UserAssetID FROM UserAssetHolding uah
+		JOIN UserAsset ua ON ua.UserAssetID = uah.UserAssetID
+		WHERE uah.UserAssetHoldingID = $2 AND ua.UserProfileID = $1`, userID, holdingID).Scan(&assetID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return assetID, err
+}
+
+// validateHolding rebuilds every holding of the asset, so a change that sells more than is held is refused
+func validateHolding(q queryer, userID, assetID int) error {
+	holdings, err := loadHoldings(q, userID, assetID)
+	if err != nil {
+		return err
+	}
+	for _, h := range holdings {
+		if _, err := portfolio.Build(h.CostMethod, h.transactions); err != nil {
+			return holdingError{h.Ticker + ": " + err.Error()}
+		}
+	}
+	return nil
+}
+
+// holdingError is a change refused by the holding rules, reported as a bad request
+type holdingError struct{ message string }
+
+func (e holdingError) Error() string { return e.message }
+
+// writeHoldingChange validates the holdings, recomputes the asset value from from and commits
+func writeHoldingChange(w http.ResponseWriter, tx *sql.Tx, userID, assetID int, from time.Time, status int, response map[string]interface{}) {
+	err := validateHolding(tx, userID, assetID)
+	if herr, ok := err.(holdingError); ok {
+		http.Error(w, herr.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		err = syncHoldingsValue(tx, userID, assetID, from)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("Holding: Error updating holdings:", err)
+		http.Error(w, "Failed to update holdings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateHoldingCostMethod switches a holding between average cost and FIFO
+func UpdateHoldingCostMethod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateHoldingCostMethod: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetHoldingID int    `json:"userAssetHoldingId"`
+		CostMethod         string `json:"costMethod"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserAssetHoldingID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.CostMethod != portfolio.AverageCost && payload.CostMethod != portfolio.FIFO {
+		http.Error(w, "costMethod must be average or fifo", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("UpdateHoldingCostMethod: Error starting transaction:", err)
+		http.Error(w, "Failed to update holding", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	assetID, err := holdingAsset(tx, user.UserProfileID, payload.UserAssetHoldingID)
+	if err == nil && assetID != 0 {
+		_, err = tx.Exec(`UPDATE UserAssetHolding SET CostMethod = $2 WHERE UserAssetHoldingID = $1`,
+			payload.UserAssetHoldingID, payload.CostMethod)
+	}
+	if err != nil {
+		log.Println("UpdateHoldingCostMethod: Error updating holding:", err)
+		http.Error(w, "Failed to update holding", http.StatusInternalServerError)
+		return
+	}
+	if assetID == 0 {
+		http.Error(w, "Holding not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	// The market value doesn't depend on the cost method, only the gains do
+	writeHoldingChange(w, tx, user.UserProfileID, assetID, time.Now(), http.StatusOK, map[string]interface{}{
+		"status": "success", "message": "Cost method updated successfully",
+	})
+}
+
+// DeleteHolding removes a holding with its transactions and recomputes the asset value
+func DeleteHolding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteHolding: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetHoldingID int `json:"userAssetHoldingId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserAssetHoldingID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteHolding: Error starting transaction:", err)
+		http.Error(w, "Failed to delete holding", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	assetID, err := holdingAsset(tx, user.UserProfileID, payload.UserAssetHoldingID)
+	if err == nil && assetID != 0 {
+		_, err = tx.Exec(`DELETE FROM UserAssetHolding WHERE UserAssetHoldingID = $1`, payload.UserAssetHoldingID)
+	}
+	if err != nil {
+		log.Println("DeleteHolding: Error deleting holding:", err)
+		http.Error(w, "Failed to delete holding", http.StatusInternalServerError)
+		return
+	}
+	if assetID == 0 {
+		http.Error(w, "Holding not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	writeHoldingChange(w, tx, user.UserProfileID, assetID, time.Time{}, http.StatusOK, map[string]interface{}{
+		"status": "success", "message": "Holding deleted successfully",
+	})
+}
+
+// AddHoldingTransaction records a buy or a sell of a holding and recomputes the asset value from its date
+func AddHoldingTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("AddHoldingTransaction: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserAssetHoldingTransaction
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserAssetHoldingID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse("2006-01-02", payload.TransactionDate)
+	if err != nil {
+		http.Error(w, "Invalid date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	payload.TransactionSide = strings.ToLower(payload.TransactionSide)
+	t := portfolio.Transaction{Date: date, Side: payload.TransactionSide, Quantity: payload.Quantity, Price: payload.UnitPrice, Fees: payload.Fees}
+	if err := t.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("AddHoldingTransaction: Error starting transaction:", err)
+		http.Error(w, "Failed to add transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var transactionID int
+	assetID, err := holdingAsset(tx, user.UserProfileID, payload.UserAssetHoldingID)
+	if err == nil && assetID != 0 {
+		err = tx.QueryRow(`
+			INSERT INTO UserAssetHoldingTransaction (UserAssetHoldingID, TransactionDate, TransactionSide, Quantity, UnitPrice, Fees)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING UserAssetHoldingTransactionID`,
+			payload.UserAssetHoldingID, date, payload.TransactionSide, payload.Quantity, payload.UnitPrice, payload.Fees).Scan(&transactionID)
+	}
+	if err != nil {
+		log.Println("AddHoldingTransaction: Error inserting transaction:", err)
+		http.Error(w, "Failed to add transaction", http.StatusInternalServerError)
+		return
+	}
+	if assetID == 0 {
+		http.Error(w, "Holding not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	writeHoldingChange(w, tx, user.UserProfileID, assetID, date, http.StatusCreated, map[string]interface{}{
+		"status":                            "success",
+		"message":                           "Transaction added successfully",
+		"user_asset_holding_transaction_id": transactionID,
+	})
+}
+
+// DeleteHoldingTransaction removes a buy or a sell, refused when a later sell would exceed the position
+func DeleteHoldingTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteHoldingTransaction: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetHoldingTransactionID int `json:"userAssetHoldingTransactionId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserAssetHoldingTransactionID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteHoldingTransaction: Error starting transaction:", err)
+		http.Error(w, "Failed to delete transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var assetID int
+	var date time.Time
+	err = tx.QueryRow(`
+		DELETE FROM UserAssetHoldingTransaction t
+		USING UserAssetHolding uah, UserAsset ua
+		WHERE uah.UserAssetHoldingID = t.UserAssetHoldingID AND ua.UserAssetID = uah.UserAssetID
+			AND t.UserAssetHoldingTransactionID = $2 AND ua.UserProfileID = $1
+		RETURNING ua.UserAssetID, t.TransactionDate`, user.UserProfileID, payload.UserAssetHoldingTransactionID).Scan(&assetID, &date)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Transaction not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("DeleteHoldingTransaction: Error deleting transaction:", err)
+		http.Error(w, "Failed to delete transaction", http.StatusInternalServerError)
+		return
+	}
+
+	writeHoldingChange(w, tx, user.UserProfileID, assetID, date, http.StatusOK, map[string]interface{}{
+		"status": "success", "message": "Transaction deleted successfully",
+	})
+}
+
+// ImportSecurityPrices stores the close prices of an uploaded ticker,date,price CSV for the securities of the
+// user and recomputes the value of the investment assets holding them. Tickers the user doesn't hold are
+// skipped. The file is sent as the "file" field of a multipart form or as the raw body.
+func ImportSecurityPrices(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportSecurityPrices: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPriceFileSize)
+
+	var data []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Price file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			http.Error(w, "Error reading file", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	quotes, err := portfolio.ParsePrices(data)
+	if err != nil {
+		http.Error(w, "Invalid price file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("ImportSecurityPrices: Error starting transaction:", err)
+		http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	securities := map[string]int{}
+	rows, err := tx.Query(`SELECT UserSecurityID, Ticker FROM UserSecurity WHERE UserProfileID = $1`, user.UserProfileID)
+	if err != nil {
+		log.Println("ImportSecurityPrices: Error fetching securities:", err)
+		http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var id int
+		var ticker string
+		if err := rows.Scan(&id, &ticker); err != nil {
+			rows.Close()
+			log.Println("ImportSecurityPrices: Error scanning security:", err)
+			http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+			return
+		}
+		securities[ticker] = id
+	}
+	rows.Close()
+
+	imported := 0
+	skipped := map[string]bool{}
+	earliest := map[int]time.Time{}
+	for _, q := range quotes {
+		securityID, ok := securities[q.Ticker]
+		if !ok {
+			skipped[q.Ticker] = true
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO UserSecurityPrice (UserSecurityID, PriceDate, ClosePrice) VALUES ($1, $2, $3)
+			ON CONFLICT (UserSecurityID, PriceDate) DO UPDATE SET ClosePrice = EXCLUDED.ClosePrice`,
+			securityID, q.Date, q.Price)
+		if err != nil {
+			log.Println("ImportSecurityPrices: Error inserting price:", err)
+			http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+			return
+		}
+		if first, ok := earliest[securityID]; !ok || q.Date.Before(first) {
+			earliest[securityID] = q.Date
+		}
+		imported++
+	}
+
+	// Recompute the assets holding the updated securities from the earliest imported date
+	assets := map[int]time.Time{}
+	for securityID, from := range earliest {
+		rows, err := tx.Query(`SELECT UserAssetID FROM UserAssetHolding WHERE UserSecurityID = $1`, securityID)
+		if err != nil {
+			log.Println("ImportSecurityPrices: Error fetching holdings:", err)
+			http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+			return
+		}
+		for rows.Next() {
+			var assetID int
+			if err := rows.Scan(&assetID); err == nil {
+				if current, ok := assets[assetID]; !ok || from.Before(current) {
+					assets[assetID] = from
+				}
+			}
+		}
+		rows.Close()
+	}
+	for assetID, from := range assets {
+		if err := syncHoldingsValue(tx, user.UserProfileID, assetID, from); err != nil {
+			log.Println("ImportSecurityPrices: Error updating asset value:", err)
+			http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("ImportSecurityPrices: Error committing transaction:", err)
+		http.Error(w, "Failed to import prices", http.StatusInternalServerError)
+		return
+	}
+
+	skippedTickers := []string{}
+	for ticker := range skipped {
+		skippedTickers = append(skippedTickers, ticker)
+	}
+	sort.Strings(skippedTickers)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "success",
+		"imported":        imported,
+		"skipped_tickers": skippedTickers,
+		"updated_assets":  len(assets),
+	})
+}
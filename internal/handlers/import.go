@@ -0,0 +1,1031 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/dedupe"
+	"finanapp/internal/models"
+	"finanapp/internal/reconcile"
+	"finanapp/internal/rules"
+	"finanapp/internal/statement"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxStatementFileSize limits the uploaded statement files
+const maxStatementFileSize = 16 << 20
+
+// Statuses a line can be set to before it is committed
+const (
+	importPending = "pending"
+	importSkipped = "skipped"
+)
+
+// readUploadedFile reads the "file" field of a multipart form, or the raw body, and returns it with the file name
+func readUploadedFile(w http.ResponseWriter, r *http.Request, maxSize int64) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		return data, header.Filename, err
+	}
+	data, err := io.ReadAll(r.Body)
+	return data, r.URL.Query().Get("fileName"), err
+}
+
+// importSuggestion is the item and category proposed for a statement line, with the note and skip of the rules
+type importSuggestion struct {
+	ItemID     *int
+	CategoryID *int
+	Source     string
+	Note       string
+	Skip       bool
+}
+
+// importSuggester proposes items for statement lines: first the user's rules, then the item of the last committed
+// line with the same description, then the item whose name appears in the description
+type importSuggester struct {
+	rules        *rules.Engine
+	history      map[string]importSuggestion // by sign and normalized description
+	items        []suggestedItem
+	itemCategory map[int]int // category of the latest actual of each item
+}
+
+type suggestedItem struct {
+	id     int
+	name   string // normalized
+	credit bool   // income items take the credits, expense items the debits
+}
+
+// historyKey groups the lines by sign and normalized description
+func historyKey(description string, amount float64) string {
+	if amount >= 0 {
+		return "+" + statement.Normalize(description)
+	}
+	return "-" + statement.Normalize(description)
+}
+
+// loadImportSuggester loads the rules, the committed lines and the items of the user in $1
+func loadImportSuggester(q queryer, userID int) (*importSuggester, error) {
+	s := &importSuggester{history: map[string]importSuggestion{}, itemCategory: map[int]int{}}
+
+	engine, err := loadRuleEngine(q, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.rules = engine
+
+	rows, err := q.Query(`
+		SELECT TransactionDescription, TransactionAmount, FinancialUserItemID, UserCategoryID
+		FROM ImportedTransaction
+		WHERE UserProfileID = $1 AND ImportStatus = 'committed' AND FinancialUserItemID IS NOT NULL
+		ORDER BY TransactionDate, ImportedTransactionID`, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var description string
+		var amount float64
+		suggestion := importSuggestion{Source: "history"}
+		if err := rows.Scan(&description, &amount, &suggestion.ItemID, &suggestion.CategoryID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.history[historyKey(description, amount)] = suggestion
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Parent items only: User Income (5), User Expense (6), Asset Expense (10) and Asset Income (11)
+	rows, err = q.Query(`
+		SELECT fui.FinancialUserItemID, fui.FinancialUserItemName, fui.EntityID
+		FROM financialuseritem fui
+		WHERE fui.EntityID IN (5, 6, 10, 11) AND fui.IsActive AND `+ownedItemCondition, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var item suggestedItem
+		var entityID int
+		if err := rows.Scan(&item.id, &item.name, &entityID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.name = statement.Normalize(item.name)
+		item.credit = entityID == 5 || entityID == 11
+		if item.name != "" {
+			s.items = append(s.items, item)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT DISTINCT ON (ufa.FinancialUserItemID) ufa.FinancialUserItemID, ufa.UserCategoryID
+		FROM userfinancialactual ufa
+		JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE ufa.UserCategoryID IS NOT NULL AND `+ownedItemCondition+`
+		ORDER BY ufa.FinancialUserItemID, ufa.UserFinancialActualtBeginDate DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var itemID, categoryID int
+		if err := rows.Scan(&itemID, &categoryID); err != nil {
+			return nil, err
+		}
+		s.itemCategory[itemID] = categoryID
+	}
+	return s, rows.Err()
+}
+
+// suggest proposes the item and category of a statement line, an empty Source means no match. The actions of the
+// rules win, what they leave empty is suggested from the history and the item names.
+func (s *importSuggester) suggest(description string, amount float64, account string) importSuggestion {
+	result := s.rules.Apply(rules.Transaction{Description: description, Amount: amount, Account: account})
+	if !result.Matched() {
+		return s.match(description, amount)
+	}
+
+	suggestion := importSuggestion{
+		ItemID:     result.ItemID,
+		CategoryID: result.CategoryID,
+		Source:     "rule:" + strconv.Itoa(result.RuleIDs[0]),
+		Note:       result.Note,
+		Skip:       result.Skip,
+	}
+	if suggestion.ItemID == nil && !suggestion.Skip {
+		match := s.match(description, amount)
+		suggestion.ItemID = match.ItemID
+		if suggestion.CategoryID == nil {
+			suggestion.CategoryID = match.CategoryID
+		}
+	} else if suggestion.ItemID != nil && suggestion.CategoryID == nil {
+		if categoryID, ok := s.itemCategory[*suggestion.ItemID]; ok {
+			suggestion.CategoryID = &categoryID
+		}
+	}
+	return suggestion
+}
+
+// match proposes the item of a statement line from the history and the item names
+func (s *importSuggester) match(description string, amount float64) importSuggestion {
+	if suggestion, ok := s.history[historyKey(description, amount)]; ok {
+		return suggestion
+	}
+
+	// The longest item name found as whole words in the description
+	words := " " + statement.Normalize(description) + " "
+	var best *suggestedItem
+	for i, item := range s.items {
+		if item.credit != (amount >= 0) || !strings.Contains(words, " "+item.name+" ") {
+			continue
+		}
+		if best == nil || len(item.name) > len(best.name) {
+			best = &s.items[i]
+		}
+	}
+	if best == nil {
+		return importSuggestion{}
+	}
+	suggestion := importSuggestion{ItemID: &best.id, Source: "item_name"}
+	if categoryID, ok := s.itemCategory[best.id]; ok {
+		suggestion.CategoryID = &categoryID
+	}
+	return suggestion
+}
+
+// importResult is what was stored from a statement file
+type importResult struct {
+	BatchID    int      `json:"importBatchId"`
+	Imported   int      `json:"imported"`
+	Duplicates []string `json:"duplicates"`         // external IDs already imported
+	Possible   int      `json:"possibleDuplicates"` // lines that look like an actual already booked
+}
+
+// createImportBatch stores the statement lines as pending lines of a new batch, with their suggestions. Lines whose
+// external ID was already imported for the account are left out, lines that look like a booked actual are flagged.
+func createImportBatch(q queryer, userID int, source, fileName string, accounts []statement.Account) (importResult, error) {
+	result := importResult{Duplicates: []string{}}
+
+	suggester, err := loadImportSuggester(q, userID)
+	if err != nil {
+		return result, err
+	}
+
+	var from, to time.Time
+	for _, account := range accounts {
+		for _, line := range account.Lines {
+			if from.IsZero() || line.Date.Before(from) {
+				from = line.Date
+			}
+			if line.Date.After(to) {
+				to = line.Date
+			}
+		}
+	}
+	var booked []dedupe.Actual
+	if !from.IsZero() {
+		if booked, err = duplicateCandidatesBetween(q, userID, from, to); err != nil {
+			return result, err
+		}
+	}
+
+	err = q.QueryRow(`INSERT INTO ImportBatch (UserProfileID, ImportSource, FileName) VALUES ($1, $2, NULLIF($3, '')) RETURNING ImportBatchID`,
+		userID, source, fileName).Scan(&result.BatchID)
+	if err != nil {
+		return result, err
+	}
+
+	currencies := map[string]int{}
+	for _, account := range accounts {
+		// Lines default to the same currency as the actuals
+		currencyID, ok := currencies[account.Currency]
+		if !ok {
+			err := q.QueryRow(`SELECT CurrencyID FROM currency WHERE CurrencyAbreviation = $1`, account.Currency).Scan(&currencyID)
+			if err == sql.ErrNoRows {
+				currencyID = 1
+			} else if err != nil {
+				return result, err
+			}
+			currencies[account.Currency] = currencyID
+		}
+
+		for _, line := range account.Lines {
+			description := strings.TrimSpace(line.Description)
+			if runes := []rune(description); len(runes) > 255 {
+				description = string(runes[:255])
+			}
+			suggestion := suggester.suggest(description, line.Amount, account.Key())
+			status := importPending
+			if suggestion.Skip {
+				status = importSkipped
+			}
+
+			var duplicateID *int
+			candidate := dedupe.Actual{Date: line.Date, Amount: math.Abs(line.Amount), CurrencyID: currencyID,
+				Description: description, Account: account.Key(), ExternalID: line.ExternalID}
+			if suggestion.ItemID != nil {
+				candidate.ItemID = *suggestion.ItemID
+			}
+			if match, _, ok := dedupe.Best(candidate, booked, dedupe.DefaultOptions); ok {
+				duplicateID = &match.ID
+			}
+
+			var id int
+			err := q.QueryRow(`
+				INSERT INTO ImportedTransaction (ImportBatchID, UserProfileID, AccountKey, ExternalID, TransactionDate,
+					TransactionAmount, TransactionDescription, TransactionType, CurrencyID, FinancialUserItemID,
+					UserCategoryID, MatchSource, TransactionNote, ImportStatus, DuplicateActualID)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14, $15)
+				ON CONFLICT (UserProfileID, AccountKey, ExternalID) DO NOTHING
+				RETURNING ImportedTransactionID`,
+				result.BatchID, userID, account.Key(), line.ExternalID, line.Date, line.Amount, description, line.Type,
+				currencyID, suggestion.ItemID, suggestion.CategoryID, suggestion.Source, suggestion.Note, status,
+				duplicateID).Scan(&id)
+			if err == sql.ErrNoRows {
+				result.Duplicates = append(result.Duplicates, line.ExternalID)
+				continue
+			}
+			if err != nil {
+				return result, err
+			}
+			result.Imported++
+			if duplicateID != nil {
+				result.Possible++
+			}
+		}
+	}
+	return result, nil
+}
+
+// importedTransactionColumns is the base query of the statement lines, $1 is the user
+const importedTransactionColumns = `
+	SELECT it.ImportedTransactionID, it.ImportBatchID, it.AccountKey, it.ExternalID, it.TransactionDate,
+		it.TransactionAmount, it.TransactionDescription, it.TransactionType, it.CurrencyID, it.FinancialUserItemID,
+		fui.FinancialUserItemName, it.UserCategoryID, uc.UserCategoryName, it.MatchSource, it.TransactionNote,
+		it.ImportStatus, it.UserFinancialActualID, it.DuplicateActualID
+	FROM ImportedTransaction it
+	LEFT JOIN financialuseritem fui ON fui.FinancialUserItemID = it.FinancialUserItemID
+	LEFT JOIN usercategory uc ON uc.UserCategoryID = it.UserCategoryID
+	WHERE it.UserProfileID = $1`
+
+// queryImportedTransactions lists the statement lines of the user in $1, with the extra filter
+func queryImportedTransactions(q queryer, filter string, args ...interface{}) ([]models.ImportedTransaction, error) {
+	rows, err := q.Query(importedTransactionColumns+filter+` ORDER BY it.TransactionDate, it.ImportedTransactionID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.ImportedTransaction{}
+	for rows.Next() {
+		var it models.ImportedTransaction
+		var date time.Time
+		if err := rows.Scan(&it.ImportedTransactionID, &it.ImportBatchID, &it.AccountKey, &it.ExternalID, &date,
+			&it.TransactionAmount, &it.TransactionDescription, &it.TransactionType, &it.CurrencyID, &it.FinancialUserItemID,
+			&it.FinancialUserItemName, &it.UserCategoryID, &it.UserCategoryName, &it.MatchSource, &it.TransactionNote,
+			&it.ImportStatus, &it.UserFinancialActualID, &it.DuplicateActualID); err != nil {
+			return nil, err
+		}
+		it.TransactionDate = date.Format("2006-01-02")
+		lines = append(lines, it)
+	}
+	return lines, rows.Err()
+}
+
+// queryImportBatches lists the imports of the user in $1 with their line counts, with the extra filter
+func queryImportBatches(q queryer, filter string, args ...interface{}) ([]models.ImportBatch, error) {
+	rows, err := q.Query(`
+		SELECT ib.ImportBatchID, ib.ImportSource, ib.FileName, ib.CreatedAt, ib.CommittedAt,
+			COUNT(it.ImportedTransactionID) FILTER (WHERE it.ImportStatus = 'pending'),
+			COUNT(it.ImportedTransactionID) FILTER (WHERE it.ImportStatus = 'committed'),
+			COUNT(it.ImportedTransactionID) FILTER (WHERE it.ImportStatus = 'skipped')
+		FROM ImportBatch ib
+		LEFT JOIN ImportedTransaction it ON it.ImportBatchID = ib.ImportBatchID
+		WHERE ib.UserProfileID = $1`+filter+`
+		GROUP BY ib.ImportBatchID
+		ORDER BY ib.CreatedAt DESC, ib.ImportBatchID DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []models.ImportBatch{}
+	for rows.Next() {
+		var b models.ImportBatch
+		if err := rows.Scan(&b.ImportBatchID, &b.ImportSource, &b.FileName, &b.CreatedAt, &b.CommittedAt,
+			&b.Pending, &b.Committed, &b.Skipped); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+// writeImportPreview answers with the batch and its lines
+func writeImportPreview(w http.ResponseWriter, q queryer, userID, batchID int, status int, extra map[string]interface{}) {
+	batches, err := queryImportBatches(q, ` AND ib.ImportBatchID = $2`, userID, batchID)
+	if err != nil {
+		log.Println("Import: Error fetching import:", err)
+		http.Error(w, "Error fetching import", http.StatusInternalServerError)
+		return
+	}
+	if len(batches) == 0 {
+		http.Error(w, "Import not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	lines, err := queryImportedTransactions(q, ` AND it.ImportBatchID = $2`, userID, batchID)
+	if err != nil {
+		log.Println("Import: Error fetching import lines:", err)
+		http.Error(w, "Error fetching import", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"import": batches[0], "transactions": lines}
+	for key, value := range extra {
+		response[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ImportOFX uploads an OFX bank or credit card statement. Its lines are stored as pending with a suggested item
+// and category, nothing is booked until the import is committed.
+func ImportOFX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportOFX: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, fileName, err := readUploadedFile(w, r, maxStatementFileSize)
+	if err != nil || len(data) == 0 {
+		http.Error(w, "OFX file is required", http.StatusBadRequest)
+		return
+	}
+
+	accounts, err := statement.ParseOFX(data)
+	if err != nil {
+		http.Error(w, "Invalid OFX file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("ImportOFX: Error starting transaction:", err)
+		http.Error(w, "Failed to import statement", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	result, err := createImportBatch(tx, user.UserProfileID, "ofx", fileName, accounts)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("ImportOFX: Error storing statement:", err)
+		http.Error(w, "Failed to import statement", http.StatusInternalServerError)
+		return
+	}
+
+	writeImportPreview(w, database, user.UserProfileID, result.BatchID, http.StatusCreated, map[string]interface{}{
+		"status":              "success",
+		"imported":            result.Imported,
+		"duplicates":          result.Duplicates,
+		"possible_duplicates": result.Possible,
+	})
+}
+
+// ImportBatches lists the imports of the user
+func ImportBatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportBatches: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batches, err := queryImportBatches(db.GetDB(), "", user.UserProfileID)
+	if err != nil {
+		log.Println("ImportBatches: Error fetching imports:", err)
+		http.Error(w, "Error fetching imports", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(batches)
+}
+
+// ImportPreview returns an import with its lines and their suggested items
+func ImportPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportPreview: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batchID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || batchID <= 0 {
+		http.Error(w, "Invalid import ID", http.StatusBadRequest)
+		return
+	}
+
+	writeImportPreview(w, db.GetDB(), user.UserProfileID, batchID, http.StatusOK, nil)
+}
+
+// UpdateImportedTransaction sets the item and category of a pending line, or skips it
+func UpdateImportedTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateImportedTransaction: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		ImportedTransactionID int  `json:"importedTransactionId"`
+		FinancialUserItemID   *int `json:"financialUserItemId"`
+		UserCategoryID        *int `json:"userCategoryId"`
+		Skip                  bool `json:"skip"` // skipped lines are never booked, false brings them back to pending
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ImportedTransactionID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	if payload.FinancialUserItemID != nil && *payload.FinancialUserItemID != 0 {
+		owns, err := userOwnsItem(database, user.UserProfileID, *payload.FinancialUserItemID)
+		if err != nil {
+			log.Println("UpdateImportedTransaction: Error checking item:", err)
+			http.Error(w, "Failed to update line", http.StatusInternalServerError)
+			return
+		}
+		if !owns {
+			http.Error(w, "Item not found or unauthorized", http.StatusNotFound)
+			return
+		}
+	} else {
+		payload.FinancialUserItemID = nil
+	}
+	if payload.UserCategoryID != nil && *payload.UserCategoryID != 0 {
+		owns, err := userOwnsCategory(database, user.UserProfileID, *payload.UserCategoryID)
+		if err != nil {
+			log.Println("UpdateImportedTransaction: Error checking category:", err)
+			http.Error(w, "Failed to update line", http.StatusInternalServerError)
+			return
+		}
+		if !owns {
+			http.Error(w, "Category not found or unauthorized", http.StatusNotFound)
+			return
+		}
+	} else {
+		payload.UserCategoryID = nil
+	}
+
+	status := importPending
+	if payload.Skip {
+		status = importSkipped
+	}
+	result, err := database.Exec(`
+		UPDATE ImportedTransaction SET FinancialUserItemID = $3, UserCategoryID = $4, ImportStatus = $5, MatchSource = NULL
+		WHERE ImportedTransactionID = $2 AND UserProfileID = $1 AND ImportStatus <> 'committed'`,
+		user.UserProfileID, payload.ImportedTransactionID, payload.FinancialUserItemID, payload.UserCategoryID, status)
+	if err != nil {
+		log.Println("UpdateImportedTransaction: Error updating line:", err)
+		http.Error(w, "Failed to update line", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Line not found, unauthorized or already committed", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Line updated successfully"})
+}
+
+// CommitImport books the pending lines that have an item as actuals. The body can restrict the commit to some
+// lines with importedTransactionIds; lines without an item stay pending, as do the lines whose item was deleted or
+// is no longer the user's since it was chosen.
+func CommitImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CommitImport: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	batchID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || batchID <= 0 {
+		http.Error(w, "Invalid import ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		ImportedTransactionIDs pq.Int64Array `json:"importedTransactionIds"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("CommitImport: Error starting transaction:", err)
+		http.Error(w, "Failed to commit import", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	// The item is checked again here, it may have gone to the trash or been purged after the line was reviewed
+	const pendingLines = ` AND it.ImportBatchID = $2 AND it.ImportStatus = 'pending'
+		AND it.FinancialUserItemID IS NOT NULL AND ($3::INT[] IS NULL OR it.ImportedTransactionID = ANY($3))`
+	lines, err := queryImportedTransactions(tx, pendingLines+` AND `+ownedItemCondition,
+		user.UserProfileID, batchID, payload.ImportedTransactionIDs)
+	var skipped int
+	if err == nil {
+		err = tx.QueryRow(`SELECT COUNT(*) FROM (`+importedTransactionColumns+pendingLines+` AND NOT COALESCE(`+ownedItemCondition+`, FALSE)) stale`,
+			user.UserProfileID, batchID, payload.ImportedTransactionIDs).Scan(&skipped)
+	}
+	if err != nil {
+		log.Println("CommitImport: Error fetching lines:", err)
+		http.Error(w, "Failed to commit import", http.StatusInternalServerError)
+		return
+	}
+
+	actualIDs := []int64{}
+	categories := map[int]bool{}
+	for _, line := range lines {
+		note := line.TransactionDescription
+		if line.TransactionNote != nil {
+			note = *line.TransactionNote
+		}
+
+		var actualID int
+		err := tx.QueryRow(`
+			INSERT INTO UserFinancialActual (UserCategoryID, FinancialUserItemID, UserFinancialActualtBeginDate,
+				UserFinancialActualAmount, CurrencyID, Note)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING UserFinancialActualID`,
+			line.UserCategoryID, *line.FinancialUserItemID, line.TransactionDate, math.Abs(line.TransactionAmount),
+			line.CurrencyID, note).Scan(&actualID)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE ImportedTransaction SET ImportStatus = 'committed', UserFinancialActualID = $2 WHERE ImportedTransactionID = $1`,
+				line.ImportedTransactionID, actualID)
+		}
+		if err != nil {
+			log.Println("CommitImport: Error booking line:", err)
+			http.Error(w, "Failed to commit import", http.StatusInternalServerError)
+			return
+		}
+		actualIDs = append(actualIDs, int64(actualID))
+		if line.UserCategoryID != nil {
+			categories[*line.UserCategoryID] = true
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE ImportBatch SET CommittedAt = CURRENT_TIMESTAMP
+		WHERE ImportBatchID = $1 AND UserProfileID = $2 AND CommittedAt IS NULL
+			AND NOT EXISTS (SELECT 1 FROM ImportedTransaction WHERE ImportBatchID = $1 AND ImportStatus = 'pending')`,
+		batchID, user.UserProfileID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CommitImport: Error committing import:", err)
+		http.Error(w, "Failed to commit import", http.StatusInternalServerError)
+		return
+	}
+
+	// Match the new actuals with their forecasts and queue their likely duplicates, a failure here doesn't undo
+	// the commit
+	flagged := 0
+	if len(actualIDs) > 0 {
+		if _, err := autoReconcile(database, r, user.UserProfileID, reconcile.DefaultOptions, ` AND ufa.UserFinancialActualID = ANY($2)`, pq.Int64Array(actualIDs)); err != nil {
+			log.Println("CommitImport: Error reconciling actuals:", err)
+		}
+		if flagged, err = flagDuplicates(database, user.UserProfileID, actualIDs); err != nil {
+			log.Println("CommitImport: Error flagging duplicates:", err)
+		}
+	}
+	for categoryID := range categories {
+		checkBudgetAlerts(database, user.UserProfileID, &categoryID)
+	}
+
+	writeImportPreview(w, database, user.UserProfileID, batchID, http.StatusOK, map[string]interface{}{
+		"status":                    "success",
+		"committed":                 len(actualIDs),
+		"skipped_unavailable_items": skipped,
+		"user_financial_actual_ids": actualIDs,
+		"possible_duplicates":       flagged,
+	})
+}
+
+// DiscardImport deletes the lines of an import that were not committed, and the import when none was
+func DiscardImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DiscardImport: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		ImportBatchID int `json:"importBatchId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.ImportBatchID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.GetDB().Begin()
+	if err != nil {
+		log.Println("DiscardImport: Error starting transaction:", err)
+		http.Error(w, "Failed to discard import", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ImportBatch WHERE ImportBatchID = $2 AND UserProfileID = $1)`,
+		user.UserProfileID, payload.ImportBatchID).Scan(&exists)
+	if err == nil && exists {
+		_, err = tx.Exec(`DELETE FROM ImportedTransaction WHERE ImportBatchID = $1 AND ImportStatus <> 'committed'`, payload.ImportBatchID)
+	}
+	if err == nil && exists {
+		_, err = tx.Exec(`
+			DELETE FROM ImportBatch ib WHERE ImportBatchID = $1
+				AND NOT EXISTS (SELECT 1 FROM ImportedTransaction it WHERE it.ImportBatchID = ib.ImportBatchID)`, payload.ImportBatchID)
+	}
+	// What remains was committed
+	if err == nil && exists {
+		_, err = tx.Exec(`UPDATE ImportBatch SET CommittedAt = CURRENT_TIMESTAMP WHERE ImportBatchID = $1 AND CommittedAt IS NULL`,
+			payload.ImportBatchID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DiscardImport: Error discarding import:", err)
+		http.Error(w, "Failed to discard import", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Import not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Import discarded successfully"})
+}
+
+// loadImportProfile returns the mapping of a saved profile of the user, ok is false when not found
+func loadImportProfile(q queryer, userID, profileID int) (statement.CSVMapping, bool, error) {
+	var mapping statement.CSVMapping
+	var data []byte
+	err := q.QueryRow(`SELECT ProfileMapping FROM UserImportProfile WHERE UserImportProfileID = $2 AND UserProfileID = $1`,
+		userID, profileID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return mapping, false, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &mapping)
+	}
+	return mapping, err == nil, err
+}
+
+// ImportCSV uploads a bank CSV export read with a saved profile (?profileId=) or with the "mapping" form field.
+// Like the OFX import, the lines are stored as pending with a suggested item and category until committed.
+func ImportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportCSV: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, fileName, err := readUploadedFile(w, r, maxStatementFileSize)
+	if err != nil || len(data) == 0 {
+		http.Error(w, "CSV file is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var mapping statement.CSVMapping
+	if value := r.FormValue("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &mapping); err != nil {
+			http.Error(w, "Invalid mapping", http.StatusBadRequest)
+			return
+		}
+	} else {
+		profileID, err := strconv.Atoi(r.FormValue("profileId"))
+		if err != nil || profileID <= 0 {
+			http.Error(w, "profileId or mapping is required", http.StatusBadRequest)
+			return
+		}
+		var found bool
+		mapping, found, err = loadImportProfile(database, user.UserProfileID, profileID)
+		if err != nil {
+			log.Println("ImportCSV: Error fetching profile:", err)
+			http.Error(w, "Failed to import statement", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Profile not found or unauthorized", http.StatusNotFound)
+			return
+		}
+	}
+
+	if mapping.Account == "" {
+		mapping.Account = "csv"
+	}
+	account, warnings, err := statement.ParseCSV(data, mapping)
+	if err != nil {
+		http.Error(w, "Invalid CSV file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("ImportCSV: Error starting transaction:", err)
+		http.Error(w, "Failed to import statement", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	result, err := createImportBatch(tx, user.UserProfileID, "csv", fileName, []statement.Account{account})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("ImportCSV: Error storing statement:", err)
+		http.Error(w, "Failed to import statement", http.StatusInternalServerError)
+		return
+	}
+
+	writeImportPreview(w, database, user.UserProfileID, result.BatchID, http.StatusCreated, map[string]interface{}{
+		"status":              "success",
+		"imported":            result.Imported,
+		"duplicates":          result.Duplicates,
+		"possible_duplicates": result.Possible,
+		"warnings":            warnings,
+	})
+}
+
+// ImportProfiles lists the saved CSV mappings of the user
+func ImportProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ImportProfiles: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := db.GetDB().Query(`
+		SELECT UserImportProfileID, ProfileName, ProfileMapping, CreatedAt
+		FROM UserImportProfile WHERE UserProfileID = $1 ORDER BY ProfileName`, user.UserProfileID)
+	if err != nil {
+		log.Println("ImportProfiles: Error fetching profiles:", err)
+		http.Error(w, "Error fetching profiles", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	profiles := []models.UserImportProfile{}
+	for rows.Next() {
+		var p models.UserImportProfile
+		if err := rows.Scan(&p.UserImportProfileID, &p.ProfileName, &p.ProfileMapping, &p.CreatedAt); err != nil {
+			log.Println("ImportProfiles: Error scanning profile:", err)
+			http.Error(w, "Error fetching profiles", http.StatusInternalServerError)
+			return
+		}
+		profiles = append(profiles, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// SaveImportProfile creates a CSV mapping profile, or replaces the mapping of the profile with the same name
+func SaveImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SaveImportProfile: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		ProfileName string               `json:"profileName"`
+		Mapping     statement.CSVMapping `json:"mapping"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.ProfileName = strings.TrimSpace(payload.ProfileName)
+	if payload.ProfileName == "" {
+		http.Error(w, "profileName is required", http.StatusBadRequest)
+		return
+	}
+	if err := payload.Mapping.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Lines are deduplicated per account, the profile name stands for it when none is given
+	if payload.Mapping.Account == "" {
+		payload.Mapping.Account = payload.ProfileName
+	}
+
+	mapping, err := json.Marshal(payload.Mapping)
+	if err != nil {
+		http.Error(w, "Invalid mapping", http.StatusBadRequest)
+		return
+	}
+
+	var profileID int
+	err = db.GetDB().QueryRow(`
+		INSERT INTO UserImportProfile (UserProfileID, ProfileName, ProfileMapping) VALUES ($1, $2, $3)
+		ON CONFLICT (UserProfileID, ProfileName) DO UPDATE SET ProfileMapping = EXCLUDED.ProfileMapping
+		RETURNING UserImportProfileID`, user.UserProfileID, payload.ProfileName, mapping).Scan(&profileID)
+	if err != nil {
+		log.Println("SaveImportProfile: Error saving profile:", err)
+		http.Error(w, "Failed to save profile", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                 "success",
+		"message":                "Profile saved successfully",
+		"user_import_profile_id": profileID,
+	})
+}
+
+// DeleteImportProfile removes a saved CSV mapping
+func DeleteImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteImportProfile: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserImportProfileID int `json:"userImportProfileId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserImportProfileID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`DELETE FROM UserImportProfile WHERE UserImportProfileID = $2 AND UserProfileID = $1`,
+		user.UserProfileID, payload.UserImportProfileID)
+	if err != nil {
+		log.Println("DeleteImportProfile: Error deleting profile:", err)
+		http.Error(w, "Failed to delete profile", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Profile not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Profile deleted successfully"})
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"finanapp/internal/db"
+	"finanapp/internal/fx"
 	"finanapp/internal/models"
 	"fmt"
 	"log"
@@ -37,6 +40,18 @@ func IncomeItem(w http.ResponseWriter, r *http.Request) {
 	// Get Database
 	database := db.GetDB()
 
+	// Optional currency to convert the amounts to
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
 	// Consulta UserFinancialForecasts
 	userFinancialForecastQuery := `
 	SELECT 
@@ -59,7 +74,7 @@ func IncomeItem(w http.ResponseWriter, r *http.Request) {
 	JOIN 
 		currency c ON uff.CurrencyID = c.CurrencyID
 	WHERE
-		fui.userentityid = $1 and fui.EntityID = 5 and fui.FinancialUserItemID = $2
+		fui.userentityid = $1 and fui.EntityID = 5 and fui.FinancialUserItemID = $2 and fui.DeletedAt IS NULL
 	ORDER BY 
 		uff.UserFinancialForecastBeginDate;
 	`
@@ -104,19 +119,19 @@ func IncomeItem(w http.ResponseWriter, r *http.Request) {
 		ufa.UserFinancialActualtBeginDate, 
 		ufa.UserFinancialActualEndDate, 
 		ufa.CurrencyID,
-		uc.UserCategoryName, -- Relacionamento com tabela de categorias
+		COALESCE(uc.UserCategoryName, ''), -- Relacionamento com tabela de categorias (opcional)
 		fui.FinancialUserItemName, -- Relacionamento com tabela de itens financeiros
 		c.CurrencyName -- Relacionamento com tabela de moedas
 	FROM 
 		userfinancialactual ufa
-	JOIN 
+	LEFT JOIN 
 		usercategory uc ON ufa.UserCategoryID = uc.UserCategoryID
 	JOIN 
 		financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
 	JOIN 
 		currency c ON ufa.CurrencyID = c.CurrencyID
 	WHERE
-		fui.userentityid = $1  and fui.EntityID = 5 and fui.FinancialUserItemID = $2   
+		fui.userentityid = $1  and fui.EntityID = 5 and fui.FinancialUserItemID = $2 and fui.DeletedAt IS NULL   
 	ORDER BY 
 		ufa.UserFinancialActualtBeginDate;`
 
@@ -149,6 +164,11 @@ func IncomeItem(w http.ResponseWriter, r *http.Request) {
 		userFinancialActuals = append(userFinancialActuals, ufa)
 	}
 
+	if rates != nil {
+		convertForecasts(rates, currencyCode, userFinancialForecasts)
+		convertActuals(rates, currencyCode, userFinancialActuals)
+	}
+
 	// Criar resposta final
 	response := struct {
 		UserFinancialForecasts []models.UserFinancialForecast `json:"user_financial_forecasts"`
@@ -213,15 +233,17 @@ func CreateIncome(w http.ResponseWriter, r *http.Request) {
 
 	// Call the stored procedure
 	var message string
-	err = database.QueryRow(
-		"CALL CreateUserParentIncome($1, $2, $3, $4, $5, $6, $7)",
-		user.UserProfileID,
-		payload.FinancialUserItemName,
-		recurrencyID,
-		5, // Hardcoded FinancialUserEntityItemID
-		amount,
-		beginDate,
-		message).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL CreateUserParentIncome($1, $2, $3, $4, $5, $6, $7)",
+			user.UserProfileID,
+			payload.FinancialUserItemName,
+			recurrencyID,
+			5, // Hardcoded FinancialUserEntityItemID
+			amount,
+			beginDate,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("Database error:", err)
@@ -306,20 +328,28 @@ func UpdateIncome(w http.ResponseWriter, r *http.Request) {
 	beginDate := time.Now().Format("2006-01-02")
 	isActive := true
 
-	// Call the stored procedure
+	// Call the stored procedure; the taxes computed from this income follow the new amount in the same transaction
 	var message string
-	err = database.QueryRow(
-		"CALL UpdateUserParentIncome($1, $2, $3, $4, $5, $6, $7)",
-		payload.FinancialUserItemId,
-		user.UserProfileID,
-		payload.FinancialUserItemName,
-		amountFloat,
-		beginDate,
-		isActive,
-		message).Scan(&message)
+	recomputed := 0
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		err := tx.QueryRow(
+			"CALL UpdateUserParentIncome($1, $2, $3, $4, $5, $6, $7)",
+			payload.FinancialUserItemId,
+			user.UserProfileID,
+			payload.FinancialUserItemName,
+			amountFloat,
+			beginDate,
+			isActive,
+			message).Scan(&message)
+		var resp models.Response
+		if err == nil && json.Unmarshal([]byte(message), &resp) == nil && resp.Status == "success" {
+			recomputed, err = recomputeChildTaxes(tx, payload.FinancialUserItemId, 0, dbDate(beginDate))
+		}
+		return err
+	})
 
 	if err != nil {
-		log.Println("Database error:", err)
+		log.Println("UpdateIncome: Error updating income:", err)
 		http.Error(w, "Failed to execute stored procedure", http.StatusInternalServerError)
 		return
 	}
@@ -327,8 +357,9 @@ func UpdateIncome(w http.ResponseWriter, r *http.Request) {
 	// Return success message from procedure
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": message,
+		"status":           "success",
+		"message":          message,
+		"recomputed_taxes": strconv.Itoa(recomputed),
 	})
 }
 
@@ -365,11 +396,13 @@ func DeleteIncome(w http.ResponseWriter, r *http.Request) {
 
 	// Call the stored procedure
 	var message string
-	err := database.QueryRow(
-		"CALL DeleteUserParentIncome($1, $2, $3)",
-		payload.ItemID,
-		user.UserProfileID,
-		message).Scan(&message)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(
+			"CALL DeleteUserParentIncome($1, $2, $3)",
+			payload.ItemID,
+			user.UserProfileID,
+			message).Scan(&message)
+	})
 
 	if err != nil {
 		log.Println("Database error:", err)
@@ -422,7 +455,9 @@ func CreateIncomeCategory(w http.ResponseWriter, r *http.Request) {
 	query := `INSERT INTO UserCategory (UserCategoryName, UserProfileID, EntityID, FinancialGroupEntityItemID, IsActive)
 			  VALUES ($1, $2, $3, $4, $5) RETURNING UserCategoryID`
 	var userCategoryID int
-	err := database.QueryRow(query, payload.UserCategoryName, user.UserProfileID, entityID, financialGroupEntityItemID, isActive).Scan(&userCategoryID)
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		return tx.QueryRow(query, payload.UserCategoryName, user.UserProfileID, entityID, financialGroupEntityItemID, isActive).Scan(&userCategoryID)
+	})
 	if err != nil {
 		log.Println("Error inserting new category:", err)
 		http.Error(w, "Failed to create category", http.StatusInternalServerError)
@@ -474,10 +509,14 @@ func DeleteIncomeCategory(w http.ResponseWriter, r *http.Request) {
 	database := db.GetDB()
 
 	// Executes the DELETE in the UserCategory table, ensuring it belongs to the authenticated user
-	result, err := database.Exec(`
+	var result sql.Result
+	err := audited(database, r, user.UserProfileID, func(tx *sql.Tx) (err error) {
+		result, err = tx.Exec(`
         DELETE FROM usercategory 
         WHERE UserCategoryID = $1 AND UserProfileID = $2`,
-		payload.UserCategoryID, user.UserProfileID)
+			payload.UserCategoryID, user.UserProfileID)
+		return err
+	})
 
 	if err != nil {
 		log.Println("Error deleting category:", err)
@@ -561,15 +600,45 @@ func CreateIncomeTax(w http.ResponseWriter, r *http.Request) {
 	// Chama a stored procedure
 	var message string
 	log.Println("CreateIncomeTax: Calling stored procedure CreateUserChildIncomeTax")
-	err = database.QueryRow(
-		"CALL CreateUserChildIncomeTax($1, $2, $3, $4, $5, $6)",
-		user.UserProfileID,
-		payload.FinancialUserItemName,
-		recurrencyID,
-		financialUserEntityItemID,
-		parentFinancialUserItemID,
-		message).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		// The procedure doesn't check the parent, and the tax forecasts are computed from it
+		owns, err := userOwnsParentIncome(tx, user.UserProfileID, parentFinancialUserItemID, 0)
+		if err == nil && !owns {
+			err = errParentIncomeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow(
+			"CALL CreateUserChildIncomeTax($1, $2, $3, $4, $5, $6)",
+			user.UserProfileID,
+			payload.FinancialUserItemName,
+			recurrencyID,
+			financialUserEntityItemID,
+			parentFinancialUserItemID,
+			message).Scan(&message)
+
+		// Quando o TaxType tem uma regra, os forecasts do imposto são calculados a partir dos forecasts do income,
+		// na mesma transação que cria o imposto
+		var resp models.Response
+		if err == nil && json.Unmarshal([]byte(message), &resp) == nil && resp.Status == "success" {
+			var childItemID int
+			err = tx.QueryRow(`
+				SELECT FinancialUserItemID FROM FinancialUserItem
+				WHERE ParentFinancialUserItemID = $1 AND EntityID = 7 AND UserEntityID = $2 AND FinancialUserEntityItemID = $3
+				ORDER BY FinancialUserItemID DESC LIMIT 1`,
+				parentFinancialUserItemID, user.UserProfileID, financialUserEntityItemID).Scan(&childItemID)
+			if err == nil {
+				_, err = recomputeChildTaxes(tx, parentFinancialUserItemID, childItemID, time.Time{})
+			}
+		}
+		return err
+	})
 
+	if errors.Is(err, errParentIncomeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Println("CreateIncomeTax: Database error:", err)
 		http.Error(w, "Failed to execute stored procedure", http.StatusInternalServerError)
@@ -649,16 +718,31 @@ func CreateIncomeExpense(w http.ResponseWriter, r *http.Request) {
 
 	// Chama a procedure armazenada
 	var message string
-	err = database.QueryRow(
-		"CALL CreateUserChildIncomeExpense($1, $2, $3, $4, $5, $6)",
-		user.UserProfileID,
-		payload.FinancialUserItemName,
-		recurrencyID,
-		financialUserEntityItemID,
-		parentFinancialUserItemID,
-		&message,
-	).Scan(&message)
+	err = audited(database, r, user.UserProfileID, func(tx *sql.Tx) error {
+		// The procedure doesn't check the parent, and the expense forecasts are copied from it
+		owns, err := userOwnsParentIncome(tx, user.UserProfileID, parentFinancialUserItemID, 0)
+		if err == nil && !owns {
+			err = errParentIncomeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(
+			"CALL CreateUserChildIncomeExpense($1, $2, $3, $4, $5, $6)",
+			user.UserProfileID,
+			payload.FinancialUserItemName,
+			recurrencyID,
+			financialUserEntityItemID,
+			parentFinancialUserItemID,
+			&message,
+		).Scan(&message)
+	})
 
+	if errors.Is(err, errParentIncomeNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Println("CreateIncomeExpense: Erro no banco de dados:", err)
 		w.WriteHeader(http.StatusInternalServerError)
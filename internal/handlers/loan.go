@@ -0,0 +1,666 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/loan"
+	"finanapp/internal/models"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Seed IDs used by the loans
+const (
+	mortgageExpenseTypeID = 2 // ExpenseType Mortgage
+	loanIncomeTypeID      = 5 // IncomeType Loan
+)
+
+// loanColumns selects a UserLoan joined with its item, the condition on the items follows the WHERE
+const loanColumns = `
+	SELECT ul.UserLoanID, ul.FinancialUserItemID, fui.FinancialUserItemName, ul.LoanPrincipal, ul.LoanAnnualRate,
+		ul.LoanTermMonths, ul.LoanStartDate, ul.AmortizationSystem, ul.CurrencyID, ul.CreatedAt
+	FROM UserLoan ul
+	JOIN FinancialUserItem fui ON fui.FinancialUserItemID = ul.FinancialUserItemID
+	WHERE `
+
+// queryLoans lists the loans of the items owned by the user in $1 with their prepayments, with the extra filter
+func queryLoans(q queryer, filter string, args ...interface{}) ([]models.UserLoan, error) {
+	rows, err := q.Query(loanColumns+ownedItemCondition+filter+` ORDER BY ul.LoanStartDate, ul.UserLoanID`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	loans := []models.UserLoan{}
+	index := map[int]int{}
+	for rows.Next() {
+		var l models.UserLoan
+		if err := rows.Scan(&l.UserLoanID, &l.FinancialUserItemID, &l.FinancialUserItemName, &l.LoanPrincipal, &l.LoanAnnualRate,
+			&l.LoanTermMonths, &l.LoanStartDate, &l.AmortizationSystem, &l.CurrencyID, &l.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		l.LoanStartDate = l.LoanStartDate[:10]
+		l.Prepayments = []models.UserLoanPrepayment{}
+		index[l.UserLoanID] = len(loans)
+		loans = append(loans, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`
+		SELECT ulp.UserLoanID, ulp.UserLoanPrepaymentID, ulp.PrepaymentDate, ulp.PrepaymentAmount, ulp.PrepaymentMode
+		FROM UserLoanPrepayment ulp
+		JOIN UserLoan ul ON ul.UserLoanID = ulp.UserLoanID
+		JOIN FinancialUserItem fui ON fui.FinancialUserItemID = ul.FinancialUserItemID
+		WHERE `+ownedItemCondition+filter+`
+		ORDER BY ulp.PrepaymentDate, ulp.UserLoanPrepaymentID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var loanID int
+		var p models.UserLoanPrepayment
+		if err := rows.Scan(&loanID, &p.UserLoanPrepaymentID, &p.PrepaymentDate, &p.PrepaymentAmount, &p.PrepaymentMode); err != nil {
+			return nil, err
+		}
+		p.PrepaymentDate = p.PrepaymentDate[:10]
+		if i, ok := index[loanID]; ok {
+			loans[i].Prepayments = append(loans[i].Prepayments, p)
+		}
+	}
+	return loans, rows.Err()
+}
+
+// loanTerms converts a stored loan to the terms used by the amortization engine
+func loanTerms(l models.UserLoan) loan.Loan {
+	terms := loan.Loan{
+		Principal:  l.LoanPrincipal,
+		AnnualRate: l.LoanAnnualRate,
+		Months:     l.LoanTermMonths,
+		Start:      dbDate(l.LoanStartDate),
+		System:     l.AmortizationSystem,
+	}
+	for _, p := range l.Prepayments {
+		terms.Prepayments = append(terms.Prepayments, loan.Prepayment{Date: dbDate(p.PrepaymentDate), Amount: p.PrepaymentAmount, Mode: p.PrepaymentMode})
+	}
+	return terms
+}
+
+// applyLoanSchedule stores the amortization table as the forecasts of the item. Forecasts on the same date are
+// updated so their reconciliations are kept, the others are removed, and the item recurrence ends on the last
+// installment so nothing is projected after the loan is paid off.
+func applyLoanSchedule(q queryer, itemID, currencyID int, schedule []loan.Installment) error {
+	existing := map[string]int{}
+	rows, err := q.Query(`SELECT UserFinancialForecastID, UserFinancialForecastBeginDate FROM UserFinancialForecast WHERE FinancialUserItemID = $1`, itemID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		var date time.Time
+		if err := rows.Scan(&id, &date); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[date.Format("2006-01-02")] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, row := range schedule {
+		// The forecast ends the day before the next installment
+		var endDate sql.NullTime
+		if i+1 < len(schedule) {
+			endDate = sql.NullTime{Time: dbDate(schedule[i+1].Date).AddDate(0, 0, -1), Valid: true}
+		}
+
+		if id, ok := existing[row.Date]; ok {
+			delete(existing, row.Date)
+			_, err = q.Exec(`
+				UPDATE UserFinancialForecast
+				SET UserFinancialForecastAmount = $2, UserFinancialForecastEndDate = $3, CurrencyID = $4
+				WHERE UserFinancialForecastID = $1`, id, row.Total, endDate, currencyID)
+		} else {
+			_, err = q.Exec(`
+				INSERT INTO UserFinancialForecast (UserCategoryID, FinancialUserItemID, UserFinancialForecastBeginDate,
+					UserFinancialForecastEndDate, UserFinancialForecastAmount, CurrencyID)
+				VALUES (NULL, $1, $2, $3, $4, $5)`, itemID, row.Date, endDate, row.Total, currencyID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range existing {
+		if _, err := q.Exec(`DELETE FROM UserForecastActualRelation WHERE UserFinancialForecastID = $1`, id); err != nil {
+			return err
+		}
+		if _, err := q.Exec(`DELETE FROM UserFinancialForecast WHERE UserFinancialForecastID = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	var lastDate interface{}
+	if len(schedule) > 0 {
+		lastDate = schedule[len(schedule)-1].Date
+	}
+	// RecurrencyID 2 is Monthly
+	_, err = q.Exec(`
+		UPDATE FinancialUserItem SET RecurrencyID = 2, RecurrencyRule = NULL, RecurrencyEndDate = $2
+		WHERE FinancialUserItemID = $1`, itemID, lastDate)
+	return err
+}
+
+// regenerateLoan recomputes the schedule of a stored loan and writes it to the forecasts of its item
+func regenerateLoan(q queryer, userID, loanID int) (models.UserLoan, []loan.Installment, error) {
+	loans, err := queryLoans(q, ` AND ul.UserLoanID = $2`, userID, loanID)
+	if err != nil || len(loans) == 0 {
+		return models.UserLoan{}, nil, err
+	}
+	schedule, err := loan.Schedule(loanTerms(loans[0]))
+	if err != nil {
+		return loans[0], nil, err
+	}
+	return loans[0], schedule, applyLoanSchedule(q, loans[0].FinancialUserItemID, loans[0].CurrencyID, schedule)
+}
+
+// loanResponse is a loan with its amortization table
+func loanResponse(l models.UserLoan, schedule []loan.Installment) map[string]interface{} {
+	if schedule == nil {
+		schedule = []loan.Installment{}
+	}
+	return map[string]interface{}{
+		"user_loan":           l,
+		"summary":             loan.Summarize(schedule),
+		"outstanding_balance": loan.BalanceOn(loanTerms(l), schedule, time.Now()),
+		"schedule":            schedule,
+	}
+}
+
+// SimulateLoan returns the amortization table of the terms without saving anything
+func SimulateLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload struct {
+		models.UserLoanPayload
+		Prepayments []models.UserLoanPrepayment `json:"prepayments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		http.Error(w, "Invalid startDate format (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	terms := loan.Loan{
+		Principal:  payload.Principal,
+		AnnualRate: payload.AnnualRate,
+		Months:     payload.TermMonths,
+		Start:      start,
+		System:     strings.ToLower(payload.System),
+	}
+	for _, p := range payload.Prepayments {
+		date, err := time.Parse("2006-01-02", p.PrepaymentDate)
+		if err != nil {
+			http.Error(w, "Invalid prepayment date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		terms.Prepayments = append(terms.Prepayments, loan.Prepayment{Date: date, Amount: p.PrepaymentAmount, Mode: p.PrepaymentMode})
+	}
+
+	schedule, err := loan.Schedule(terms)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"monthly_rate": loan.MonthlyRate(terms.AnnualRate),
+		"summary":      loan.Summarize(schedule),
+		"schedule":     schedule,
+	})
+}
+
+// SaveLoan creates or updates the loan of a User Expense or Asset Expense item and generates its amortization
+// table as forecasts. Without financialUserItemId a new User Expense of type Mortgage is created.
+func SaveLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SaveLoan: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserLoanPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	start, err := time.Parse("2006-01-02", payload.StartDate)
+	if err != nil {
+		http.Error(w, "Invalid startDate format (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	payload.System = strings.ToLower(payload.System)
+	terms := loan.Loan{Principal: payload.Principal, AnnualRate: payload.AnnualRate, Months: payload.TermMonths, Start: start, System: payload.System}
+	if err := terms.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+	payload.FinancialUserItemName = strings.TrimSpace(payload.FinancialUserItemName)
+	if payload.FinancialUserItemID == 0 && payload.FinancialUserItemName == "" {
+		http.Error(w, "financialUserItemId or financialUserItemName is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var currencyExists bool
+	if err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM currency WHERE CurrencyID = $1)`, payload.CurrencyID).Scan(&currencyExists); err != nil {
+		log.Println("SaveLoan: Error checking currency:", err)
+		http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+		return
+	}
+	if !currencyExists {
+		http.Error(w, "Invalid currencyId", http.StatusBadRequest)
+		return
+	}
+
+	if payload.FinancialUserItemID != 0 {
+		var entityID int
+		err := database.QueryRow(`
+			SELECT fui.EntityID FROM FinancialUserItem fui
+			WHERE fui.FinancialUserItemID = $2 AND `+ownedItemCondition,
+			user.UserProfileID, payload.FinancialUserItemID).Scan(&entityID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Item not found or unauthorized", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("SaveLoan: Error checking item:", err)
+			http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+			return
+		}
+		if entityID != 6 && entityID != 10 {
+			http.Error(w, "Loans must be attached to a User Expense or an Asset Expense", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("SaveLoan: Error starting transaction:", err)
+		http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	itemID := payload.FinancialUserItemID
+	if itemID == 0 {
+		// RecurrencyID 2 is Monthly
+		err = tx.QueryRow(`
+			INSERT INTO FinancialUserItem (FinancialUserItemName, EntityID, UserEntityID, RecurrencyID, FinancialUserEntityItemID)
+			VALUES ($1, 6, $2, 2, $3) RETURNING FinancialUserItemID`,
+			payload.FinancialUserItemName, user.UserProfileID, mortgageExpenseTypeID).Scan(&itemID)
+		if err != nil {
+			log.Println("SaveLoan: Error creating item:", err)
+			http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var loanID int
+	var created bool
+	err = tx.QueryRow(`
+		INSERT INTO UserLoan (FinancialUserItemID, LoanPrincipal, LoanAnnualRate, LoanTermMonths, LoanStartDate, AmortizationSystem, CurrencyID)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (FinancialUserItemID) DO UPDATE SET
+			LoanPrincipal = EXCLUDED.LoanPrincipal,
+			LoanAnnualRate = EXCLUDED.LoanAnnualRate,
+			LoanTermMonths = EXCLUDED.LoanTermMonths,
+			LoanStartDate = EXCLUDED.LoanStartDate,
+			AmortizationSystem = EXCLUDED.AmortizationSystem,
+			CurrencyID = EXCLUDED.CurrencyID
+		RETURNING UserLoanID, (xmax = 0)`,
+		itemID, payload.Principal, payload.AnnualRate, payload.TermMonths, payload.StartDate, payload.System,
+		payload.CurrencyID).Scan(&loanID, &created)
+	if err != nil {
+		log.Println("SaveLoan: Error saving loan:", err)
+		http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+		return
+	}
+
+	stored, schedule, err := regenerateLoan(tx, user.UserProfileID, loanID)
+	if err != nil {
+		log.Println("SaveLoan: Error generating schedule:", err)
+		http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+		return
+	}
+
+	// The money received is a one time Loan income on the start date, RecurrencyID 1 is One Time
+	if created && payload.RecordDisbursement {
+		var incomeID int
+		err = tx.QueryRow(`
+			INSERT INTO FinancialUserItem (FinancialUserItemName, EntityID, UserEntityID, RecurrencyID, FinancialUserEntityItemID)
+			VALUES ($1, 5, $2, 1, $3) RETURNING FinancialUserItemID`,
+			stored.FinancialUserItemName+" disbursement", user.UserProfileID, loanIncomeTypeID).Scan(&incomeID)
+		if err == nil {
+			_, err = tx.Exec(`
+				INSERT INTO UserFinancialForecast (UserCategoryID, FinancialUserItemID, UserFinancialForecastBeginDate,
+					UserFinancialForecastEndDate, UserFinancialForecastAmount, CurrencyID)
+				VALUES (NULL, $1, $2, $2, $3, $4)`, incomeID, payload.StartDate, payload.Principal, payload.CurrencyID)
+		}
+		if err != nil {
+			log.Println("SaveLoan: Error recording disbursement:", err)
+			http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("SaveLoan: Error committing transaction:", err)
+		http.Error(w, "Failed to save loan", http.StatusInternalServerError)
+		return
+	}
+
+	response := loanResponse(stored, schedule)
+	response["status"] = "success"
+	response["message"] = "Loan saved successfully"
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// Loans lists the loans of the logged-in user with their summary and outstanding balance
+func Loans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Loans: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	loans, err := queryLoans(db.GetDB(), "", user.UserProfileID)
+	if err != nil {
+		log.Println("Loans: Error fetching loans:", err)
+		http.Error(w, "Error fetching loans", http.StatusInternalServerError)
+		return
+	}
+
+	result := []map[string]interface{}{}
+	for _, l := range loans {
+		schedule, err := loan.Schedule(loanTerms(l))
+		if err != nil {
+			log.Printf("Loans: Ignoring schedule of loan %d: %v", l.UserLoanID, err)
+		}
+		entry := loanResponse(l, schedule)
+		delete(entry, "schedule")
+		result = append(result, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_loans": result})
+}
+
+// LoanDetail returns a loan with its full amortization table
+func LoanDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("LoanDetail: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	loanID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || loanID <= 0 {
+		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	loans, err := queryLoans(db.GetDB(), ` AND ul.UserLoanID = $2`, user.UserProfileID, loanID)
+	if err != nil {
+		log.Println("LoanDetail: Error fetching loan:", err)
+		http.Error(w, "Error fetching loan", http.StatusInternalServerError)
+		return
+	}
+	if len(loans) == 0 {
+		http.Error(w, "Loan not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	schedule, err := loan.Schedule(loanTerms(loans[0]))
+	if err != nil {
+		log.Println("LoanDetail: Error computing schedule:", err)
+		http.Error(w, "Error computing schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(loanResponse(loans[0], schedule))
+}
+
+// DeleteLoan removes the loan terms of an item. The item and its forecasts are kept as a plain expense.
+func DeleteLoan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteLoan: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserLoanID int `json:"userLoanId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserLoanID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`
+		DELETE FROM UserLoan ul
+		USING FinancialUserItem fui
+		WHERE fui.FinancialUserItemID = ul.FinancialUserItemID AND ul.UserLoanID = $2 AND `+ownedItemCondition,
+		user.UserProfileID, payload.UserLoanID)
+	if err != nil {
+		log.Println("DeleteLoan: Error deleting loan:", err)
+		http.Error(w, "Failed to delete loan", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Loan not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Loan deleted successfully"})
+}
+
+// writeLoanChange commits the transaction and answers with the regenerated loan
+func writeLoanChange(w http.ResponseWriter, tx *sql.Tx, userID, loanID int, status int, message string) {
+	stored, schedule, err := regenerateLoan(tx, userID, loanID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("Loan: Error regenerating schedule:", err)
+		http.Error(w, "Failed to update the loan schedule", http.StatusInternalServerError)
+		return
+	}
+
+	response := loanResponse(stored, schedule)
+	response["status"] = "success"
+	response["message"] = message
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// AddLoanPrepayment records an extra principal payment and regenerates the forecasts of the loan
+func AddLoanPrepayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("AddLoanPrepayment: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserLoanID int     `json:"userLoanId"`
+		Date       string  `json:"date"`
+		Amount     float64 `json:"amount"`
+		Mode       string  `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserLoanID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse("2006-01-02", payload.Date)
+	if err != nil {
+		http.Error(w, "Invalid date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if err := (loan.Prepayment{Date: date, Amount: payload.Amount, Mode: payload.Mode}).Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("AddLoanPrepayment: Error starting transaction:", err)
+		http.Error(w, "Failed to add prepayment", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	loans, err := queryLoans(tx, ` AND ul.UserLoanID = $2`, user.UserProfileID, payload.UserLoanID)
+	if err != nil {
+		log.Println("AddLoanPrepayment: Error fetching loan:", err)
+		http.Error(w, "Failed to add prepayment", http.StatusInternalServerError)
+		return
+	}
+	if len(loans) == 0 {
+		http.Error(w, "Loan not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if !date.After(dbDate(loans[0].LoanStartDate)) {
+		http.Error(w, fmt.Sprintf("Prepayments must be after the loan start date (%s)", loans[0].LoanStartDate), http.StatusBadRequest)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO UserLoanPrepayment (UserLoanID, PrepaymentDate, PrepaymentAmount, PrepaymentMode)
+		VALUES ($1, $2, $3, $4)`, payload.UserLoanID, payload.Date, payload.Amount, payload.Mode)
+	if err != nil {
+		log.Println("AddLoanPrepayment: Error inserting prepayment:", err)
+		http.Error(w, "Failed to add prepayment", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoanChange(w, tx, user.UserProfileID, payload.UserLoanID, http.StatusCreated, "Prepayment added successfully")
+}
+
+// DeleteLoanPrepayment removes an extra payment and regenerates the forecasts of the loan
+func DeleteLoanPrepayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteLoanPrepayment: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserLoanPrepaymentID int `json:"userLoanPrepaymentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserLoanPrepaymentID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := beginAudit(db.GetDB(), r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteLoanPrepayment: Error starting transaction:", err)
+		http.Error(w, "Failed to delete prepayment", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var loanID int
+	err = tx.QueryRow(`
+		DELETE FROM UserLoanPrepayment ulp
+		USING UserLoan ul, FinancialUserItem fui
+		WHERE ul.UserLoanID = ulp.UserLoanID AND fui.FinancialUserItemID = ul.FinancialUserItemID
+			AND ulp.UserLoanPrepaymentID = $2 AND `+ownedItemCondition+`
+		RETURNING ulp.UserLoanID`, user.UserProfileID, payload.UserLoanPrepaymentID).Scan(&loanID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Prepayment not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("DeleteLoanPrepayment: Error deleting prepayment:", err)
+		http.Error(w, "Failed to delete prepayment", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoanChange(w, tx, user.UserProfileID, loanID, http.StatusOK, "Prepayment deleted successfully")
+}
@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"finanapp/internal/cashflow"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/loan"
+	"finanapp/internal/models"
+	"finanapp/internal/networth"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syncAssetValue copies the latest valuation of the asset into UserAsset.UserAssetValueAmount
+func syncAssetValue(q queryer, assetID int) error {
+	_, err := q.Exec(`
+		UPDATE UserAsset ua
+		SET UserAssetValueAmount = v.UserAssetValuationAmount
+		FROM (
+			SELECT UserAssetValuationAmount FROM UserAssetValuation
+			WHERE UserAssetID = $1
+			ORDER BY UserAssetValuationDate DESC
+			LIMIT 1
+		) v
+		WHERE ua.UserAssetID = $1`, assetID)
+	return err
+}
+
+// RecordAssetValuation stores the value of an asset on a date, replacing the valuation of the same date
+func RecordAssetValuation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RecordAssetValuation: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.UserAssetValuation
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("RecordAssetValuation: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserAssetID == 0 {
+		http.Error(w, "UserAssetID is required", http.StatusBadRequest)
+		return
+	}
+	if payload.UserAssetValuationAmount < 0 {
+		http.Error(w, "Amount cannot be negative", http.StatusBadRequest)
+		return
+	}
+	valuationDate := time.Now().Truncate(24 * time.Hour)
+	if payload.UserAssetValuationDate != "" {
+		parsed, err := time.Parse("2006-01-02", payload.UserAssetValuationDate)
+		if err != nil {
+			http.Error(w, "Invalid valuation date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		valuationDate = parsed
+	}
+	if payload.Note != nil && strings.TrimSpace(*payload.Note) == "" {
+		payload.Note = nil
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("RecordAssetValuation: Error starting transaction:", err)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	// The value of an asset with holdings is derived from its transactions and market prices
+	derived, err := assetHasHoldings(tx, payload.UserAssetID)
+	if err != nil {
+		log.Println("RecordAssetValuation: Error checking holdings:", err)
+		http.Error(w, "Failed to record valuation", http.StatusInternalServerError)
+		return
+	}
+	if derived {
+		http.Error(w, "The value of this asset is derived from its holdings", http.StatusConflict)
+		return
+	}
+
+	var valuationID int
+	err = tx.QueryRow(`
+		INSERT INTO UserAssetValuation (UserAssetID, UserAssetValuationDate, UserAssetValuationAmount, Note)
+		SELECT ua.UserAssetID, $3, $4, $5 FROM UserAsset ua WHERE ua.UserAssetID = $2 AND ua.UserProfileID = $1
+		ON CONFLICT (UserAssetID, UserAssetValuationDate)
+		DO UPDATE SET UserAssetValuationAmount = EXCLUDED.UserAssetValuationAmount, Note = EXCLUDED.Note
+		RETURNING UserAssetValuationID`,
+		user.UserProfileID, payload.UserAssetID, valuationDate, payload.UserAssetValuationAmount, payload.Note).Scan(&valuationID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Asset not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("RecordAssetValuation: Error inserting valuation:", err)
+		http.Error(w, "Failed to record valuation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := syncAssetValue(tx, payload.UserAssetID); err != nil {
+		log.Println("RecordAssetValuation: Error updating asset value:", err)
+		http.Error(w, "Failed to record valuation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("RecordAssetValuation: Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                  "success",
+		"message":                 "Valuation recorded successfully",
+		"user_asset_valuation_id": valuationID,
+	})
+}
+
+// AssetValuations lists the valuation history of one asset (?assetId=)
+func AssetValuations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("AssetValuations: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	assetID, err := strconv.Atoi(r.URL.Query().Get("assetId"))
+	if err != nil || assetID == 0 {
+		http.Error(w, "Invalid assetId", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := db.GetDB().Query(`
+		SELECT v.UserAssetValuationID, v.UserAssetID, v.UserAssetValuationDate, v.UserAssetValuationAmount, v.Note, v.CreatedAt
+		FROM UserAssetValuation v
+		JOIN UserAsset ua ON v.UserAssetID = ua.UserAssetID
+		WHERE ua.UserProfileID = $1 AND v.UserAssetID = $2
+		ORDER BY v.UserAssetValuationDate`, user.UserProfileID, assetID)
+	if err != nil {
+		log.Println("AssetValuations: Error fetching valuations:", err)
+		http.Error(w, "Error fetching valuations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	valuations := []models.UserAssetValuation{}
+	for rows.Next() {
+		var v models.UserAssetValuation
+		if err := rows.Scan(&v.UserAssetValuationID, &v.UserAssetID, &v.UserAssetValuationDate, &v.UserAssetValuationAmount, &v.Note, &v.CreatedAt); err != nil {
+			log.Println("AssetValuations: Error scanning valuation:", err)
+			continue
+		}
+		valuations = append(valuations, v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"user_asset_valuations": valuations})
+}
+
+// DeleteAssetValuation removes a valuation, the asset keeps the value of the latest remaining one
+func DeleteAssetValuation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteAssetValuation: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetValuationID int `json:"userAssetValuationId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("DeleteAssetValuation: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserAssetValuationID == 0 {
+		http.Error(w, "UserAssetValuationID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteAssetValuation: Error starting transaction:", err)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var assetID int
+	err = tx.QueryRow(`
+		DELETE FROM UserAssetValuation v
+		USING UserAsset ua
+		WHERE v.UserAssetValuationID = $2 AND v.UserAssetID = ua.UserAssetID AND ua.UserProfileID = $1
+		RETURNING v.UserAssetID`, user.UserProfileID, payload.UserAssetValuationID).Scan(&assetID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Valuation not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("DeleteAssetValuation: Error deleting valuation:", err)
+		http.Error(w, "Failed to delete valuation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := syncAssetValue(tx, assetID); err != nil {
+		log.Println("DeleteAssetValuation: Error updating asset value:", err)
+		http.Error(w, "Failed to delete valuation", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("DeleteAssetValuation: Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Valuation deleted successfully"})
+}
+
+// NetWorth returns the net-worth time series of the logged-in user between "from" and "to" (?interval=month):
+// the value of the assets projected by their valuation models, the cash position from the actuals and the outstanding liabilities, which are
+// the balances of the loans by the date.
+// With ?currency= the actuals and loans are converted; asset values have no currency and are kept as stored.
+func NetWorth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("NetWorth: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Default window is the last twelve months up to today
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	defaultFrom := time.Date(now.Year()-1, now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, defaultFrom, today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "month"
+	}
+	dates, err := networth.Dates(from, to, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("NetWorth: Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+	convert := func(amount float64, currencyID int, on time.Time) (float64, error) {
+		if rates == nil {
+			return amount, nil
+		}
+		return rates.Convert(amount, currencyID, currencyCode, on)
+	}
+
+	assets, valuations, err := loadNetWorthAssets(database, user.UserProfileID)
+	if err != nil {
+		log.Println("NetWorth: Error fetching assets:", err)
+		http.Error(w, "Error fetching assets", http.StatusInternalServerError)
+		return
+	}
+
+	// Cash position: every actual up to the end of the window
+	actualRows, err := database.Query(`
+		SELECT ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualAmount, ufa.CurrencyID, fui.EntityID
+		FROM userfinancialactual ufa
+		JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE `+ownedItemCondition+` AND ufa.UserFinancialActualtBeginDate <= $2`, user.UserProfileID, to)
+	if err != nil {
+		log.Println("NetWorth: Error fetching actuals:", err)
+		http.Error(w, "Error fetching actuals", http.StatusInternalServerError)
+		return
+	}
+	defer actualRows.Close()
+
+	var movements []networth.Movement
+	for actualRows.Next() {
+		var m networth.Movement
+		var currencyID, entityID int
+		if err := actualRows.Scan(&m.Date, &m.Amount, &currencyID, &entityID); err != nil {
+			log.Println("NetWorth: Error scanning actual:", err)
+			continue
+		}
+		if m.Amount, err = convert(m.Amount, currencyID, m.Date); err != nil {
+			http.Error(w, "Unable to convert actuals: "+err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if cashflow.KindOf(entityID) != cashflow.KindInflow {
+			m.Amount = -m.Amount
+		}
+		movements = append(movements, m)
+	}
+
+	// Liabilities: the outstanding balance of the loans, each installment settles the principal it pays off on its
+	// date. Expenses and taxes aren't debts, they only reach the net worth through the actuals that pay them.
+	loans, err := queryLoans(database, "", user.UserProfileID)
+	if err != nil {
+		log.Println("NetWorth: Error fetching loans:", err)
+		http.Error(w, "Error fetching loans", http.StatusInternalServerError)
+		return
+	}
+
+	var liabilities []networth.Liability
+	for _, l := range loans {
+		terms := loanTerms(l)
+		schedule, err := loan.Schedule(terms)
+		if err != nil {
+			log.Printf("NetWorth: Ignoring schedule of loan %d: %v", l.UserLoanID, err)
+			continue
+		}
+		balance := terms.Principal
+		for i, row := range schedule {
+			amount := balance - row.Balance
+			if i == len(schedule)-1 {
+				amount = balance // nothing is left after the last installment
+			}
+			if amount, err = convert(amount, l.CurrencyID, terms.Start); err != nil {
+				http.Error(w, "Unable to convert liabilities: "+err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			settled := dbDate(row.Date)
+			liabilities = append(liabilities, networth.Liability{Due: terms.Start, Settled: &settled, Amount: amount})
+			balance = row.Balance
+		}
+	}
+
+	response := struct {
+		From     string           `json:"from"`
+		To       string           `json:"to"`
+		Interval string           `json:"interval"`
+		Currency string           `json:"currency,omitempty"`
+		Points   []networth.Point `json:"points"`
+	}{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Interval: interval,
+		Currency: currencyCode,
+		Points:   networth.Series(dates, assets, valuations, movements, liabilities),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// loadNetWorthAssets loads the active assets of the user with their valuation models and valuations. Assets
+// without any valuation are valued at UserAssetValueAmount from their acquisition date.
+func loadNetWorthAssets(database *sql.DB, userID int) ([]networth.Asset, []networth.Valuation, error) {
+	assetRows, err := database.Query(`
+		SELECT ua.UserAssetID, ua.UserAssetAcquisitionBeginDate, ua.UserAssetAcquisitionEndDate, ua.UserAssetValueAmount,
+			COALESCE(m.ValuationMethod, 'manual'), COALESCE(m.ValuationRate, 0),
+			COALESCE(m.UsefulLifeYears, 0), COALESCE(m.SalvageValue, 0)
+		FROM UserAsset ua
+		LEFT JOIN UserAssetValuationModel m ON m.UserAssetID = ua.UserAssetID
+		WHERE ua.UserProfileID = $1 AND ua.IsActive = TRUE`, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer assetRows.Close()
+
+	var assets []networth.Asset
+	current := map[int]float64{}
+	for assetRows.Next() {
+		var a networth.Asset
+		var disposed sql.NullTime
+		var value float64
+		if err := assetRows.Scan(&a.AssetID, &a.Acquired, &disposed, &value,
+			&a.Model.Method, &a.Model.Rate, &a.Model.UsefulLifeYears, &a.Model.SalvageValue); err != nil {
+			return nil, nil, err
+		}
+		if disposed.Valid {
+			a.Disposed = &disposed.Time
+		}
+		assets = append(assets, a)
+		current[a.AssetID] = value
+	}
+	if err := assetRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	valuationRows, err := database.Query(`
+		SELECT v.UserAssetID, v.UserAssetValuationDate, v.UserAssetValuationAmount
+		FROM UserAssetValuation v
+		JOIN UserAsset ua ON v.UserAssetID = ua.UserAssetID
+		WHERE ua.UserProfileID = $1
+		ORDER BY v.UserAssetValuationDate`, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer valuationRows.Close()
+
+	var valuations []networth.Valuation
+	valued := map[int]bool{}
+	for valuationRows.Next() {
+		var v networth.Valuation
+		if err := valuationRows.Scan(&v.AssetID, &v.Date, &v.Amount); err != nil {
+			return nil, nil, err
+		}
+		valuations = append(valuations, v)
+
+		// The first valuation is the acquisition cost
+		if !valued[v.AssetID] {
+			current[v.AssetID] = v.Amount
+		}
+		valued[v.AssetID] = true
+	}
+	if err := valuationRows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range assets {
+		a := &assets[i]
+		a.Cost = current[a.AssetID]
+		if !valued[a.AssetID] {
+			valuations = append(valuations, networth.Valuation{AssetID: a.AssetID, Date: a.Acquired, Amount: a.Cost})
+		}
+	}
+
+	return assets, valuations, nil
+}
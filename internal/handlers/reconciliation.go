@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/models"
+	"finanapp/internal/reconcile"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// reconcileMatch is an actual linked to a forecast by the automatic reconciliation
+type reconcileMatch struct {
+	UserFinancialActualID   int    `json:"userFinancialActualId"`
+	UserFinancialForecastID int    `json:"userFinancialForecastId"`
+	FinancialUserItemID     int    `json:"financialUserItemId"`
+	ForecastDate            string `json:"forecastDate"`
+}
+
+// monthVariance is the variance of one item in one month (YYYY-MM)
+type monthVariance struct {
+	Month string `json:"month"`
+	reconcile.Variance
+}
+
+// itemVariance is the variance of one item in the whole period with its monthly breakdown
+type itemVariance struct {
+	FinancialUserItemID   int    `json:"financialUserItemId"`
+	FinancialUserItemName string `json:"financialUserItemName"`
+	EntityType            string `json:"entityType"`
+	reconcile.Variance
+	Months []monthVariance `json:"months"`
+}
+
+// autoReconcile links the unmatched actuals of the user (narrowed by filter, which may use $2 onwards)
+// to the closest forecast of the same item. Projected occurrences are stored as forecasts when matched.
+func autoReconcile(database *sql.DB, r *http.Request, userID int, opts reconcile.Options, filter string, args ...interface{}) ([]reconcileMatch, error) {
+	matches := []reconcileMatch{}
+
+	rows, err := database.Query(`
+		SELECT ufa.UserFinancialActualID, ufa.FinancialUserItemID, ufa.UserFinancialActualtBeginDate,
+			ufa.UserFinancialActualAmount, ufa.CurrencyID
+		FROM userfinancialactual ufa
+		JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE `+ownedItemCondition+`
+			AND NOT EXISTS (SELECT 1 FROM userforecastactualrelation r WHERE r.UserFinancialActualID = ufa.UserFinancialActualID)`+
+		filter+`
+		ORDER BY ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualID`,
+		append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	type pendingActual struct {
+		ID     int
+		ItemID int
+		reconcile.Actual
+	}
+	var pending []pendingActual
+	for rows.Next() {
+		var p pendingActual
+		if err := rows.Scan(&p.ID, &p.ItemID, &p.Date, &p.Amount, &p.CurrencyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return matches, nil
+	}
+
+	schedules, err := loadForecastSchedules(database, userID)
+	if err != nil {
+		return nil, err
+	}
+	byItem := map[int]*forecastSchedule{}
+	for _, s := range schedules {
+		byItem[s.ItemID] = s
+	}
+
+	tx, err := beginAudit(database, r, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	// Read the links inside the transaction so a forecast linked meanwhile isn't linked twice
+	linked, err := linkedForecastIDs(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := time.Duration(opts.DateWindowDays) * 24 * time.Hour
+	for _, p := range pending {
+		s, ok := byItem[p.ItemID]
+		if !ok {
+			continue
+		}
+
+		var candidates []reconcile.Candidate
+		for _, o := range s.occurrences(p.Date.Add(-window), p.Date.Add(window)) {
+			if o.UserFinancialForecastID != nil && linked[*o.UserFinancialForecastID] {
+				continue
+			}
+			date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+			c := reconcile.Candidate{Date: date, Amount: o.Amount, CurrencyID: o.CurrencyID}
+			if o.UserFinancialForecastID != nil {
+				c.ForecastID = *o.UserFinancialForecastID
+			}
+			candidates = append(candidates, c)
+		}
+
+		match, found := reconcile.BestMatch(p.Actual, candidates, opts)
+		if !found {
+			continue
+		}
+
+		if match.ForecastID == 0 {
+			created, err := s.materializeUntil(tx, match.Date)
+			if err != nil {
+				return nil, err
+			}
+			match.ForecastID = created[match.Date.Format("2006-01-02")]
+			if match.ForecastID == 0 {
+				continue
+			}
+		}
+
+		_, err = tx.Exec(`INSERT INTO UserForecastActualRelation (UserFinancialActualID, UserFinancialForecastID) VALUES ($1, $2)`,
+			p.ID, match.ForecastID)
+		if err != nil {
+			return nil, err
+		}
+		linked[match.ForecastID] = true
+
+		matches = append(matches, reconcileMatch{
+			UserFinancialActualID:   p.ID,
+			UserFinancialForecastID: match.ForecastID,
+			FinancialUserItemID:     p.ItemID,
+			ForecastDate:            match.Date.Format("2006-01-02"),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// linkedForecastIDs returns the forecasts of the user that already have an actual
+func linkedForecastIDs(database queryer, userID int) (map[int]bool, error) {
+	rows, err := database.Query(`
+		SELECT DISTINCT r.UserFinancialForecastID
+		FROM userforecastactualrelation r
+		JOIN userfinancialforecast uff ON r.UserFinancialForecastID = uff.UserFinancialForecastID
+		JOIN financialuseritem fui ON uff.FinancialUserItemID = fui.FinancialUserItemID
+		WHERE `+ownedItemCondition, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	linked := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		linked[id] = true
+	}
+	return linked, rows.Err()
+}
+
+// AutoReconcile matches the unmatched actuals of the user to forecasts, optionally limited to one item
+func AutoReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("AutoReconcile: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Every field is optional, missing ones use the defaults
+	payload := struct {
+		FinancialUserItemID int `json:"financialUserItemId"`
+		reconcile.Options
+	}{Options: reconcile.DefaultOptions}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			log.Println("AutoReconcile: Error decoding request body:", err)
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if payload.AmountTolerance < 0 || payload.DateWindowDays < 0 {
+		http.Error(w, "Tolerance and date window must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	filter, args := "", []interface{}{}
+	if payload.FinancialUserItemID != 0 {
+		filter, args = ` AND ufa.FinancialUserItemID = $2`, append(args, payload.FinancialUserItemID)
+	}
+
+	matches, err := autoReconcile(db.GetDB(), r, user.UserProfileID, payload.Options, filter, args...)
+	if err != nil {
+		log.Println("AutoReconcile: Error reconciling actuals:", err)
+		http.Error(w, "Failed to reconcile actuals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "matches": matches})
+}
+
+// LinkActual manually links an actual to a forecast of the same item, replacing any previous link of the actual
+func LinkActual(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("LinkActual: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserFinancialActualID   int `json:"userFinancialActualId"`
+		UserFinancialForecastID int `json:"userFinancialForecastId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("LinkActual: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserFinancialActualID == 0 || payload.UserFinancialForecastID == 0 {
+		http.Error(w, "UserFinancialActualID and UserFinancialForecastID are required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("LinkActual: Error starting transaction:", err)
+		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	// Both sides must belong to the same item owned by the user
+	var valid bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM userfinancialactual ufa
+			JOIN userfinancialforecast uff ON uff.FinancialUserItemID = ufa.FinancialUserItemID
+			JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+			WHERE ufa.UserFinancialActualID = $2 AND uff.UserFinancialForecastID = $3 AND `+ownedItemCondition+`
+		)`, user.UserProfileID, payload.UserFinancialActualID, payload.UserFinancialForecastID).Scan(&valid)
+	if err != nil {
+		log.Println("LinkActual: Error checking ownership:", err)
+		http.Error(w, "Failed to link actual", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Actual and forecast not found, unauthorized or from different items", http.StatusNotFound)
+		return
+	}
+
+	// A forecast is matched by a single actual, the row lock keeps two links from racing past the check
+	var linkedElsewhere bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM userforecastactualrelation
+			WHERE UserFinancialForecastID = (
+				SELECT UserFinancialForecastID FROM userfinancialforecast WHERE UserFinancialForecastID = $1 FOR UPDATE
+			) AND UserFinancialActualID <> $2
+		)`, payload.UserFinancialForecastID, payload.UserFinancialActualID).Scan(&linkedElsewhere)
+	if err != nil {
+		log.Println("LinkActual: Error checking existing links:", err)
+		http.Error(w, "Failed to link actual", http.StatusInternalServerError)
+		return
+	}
+	if linkedElsewhere {
+		http.Error(w, "Forecast is already linked to another actual", http.StatusConflict)
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM userforecastactualrelation WHERE UserFinancialActualID = $1", payload.UserFinancialActualID); err != nil {
+		log.Println("LinkActual: Error removing previous link:", err)
+		http.Error(w, "Failed to link actual", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = tx.Exec(`INSERT INTO UserForecastActualRelation (UserFinancialActualID, UserFinancialForecastID) VALUES ($1, $2)`,
+		payload.UserFinancialActualID, payload.UserFinancialForecastID)
+	if err != nil {
+		log.Println("LinkActual: Error inserting link:", err)
+		http.Error(w, "Failed to link actual", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("LinkActual: Error committing transaction:", err)
+		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Actual linked successfully"})
+}
+
+// UnlinkActual removes the link between an actual and its forecast
+func UnlinkActual(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UnlinkActual: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserFinancialActualID int `json:"userFinancialActualId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("UnlinkActual: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserFinancialActualID == 0 {
+		http.Error(w, "UserFinancialActualID is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`
+		DELETE FROM userforecastactualrelation r
+		USING userfinancialactual ufa, financialuseritem fui
+		WHERE r.UserFinancialActualID = $2 AND r.UserFinancialActualID = ufa.UserFinancialActualID
+			AND ufa.FinancialUserItemID = fui.FinancialUserItemID AND `+ownedItemCondition,
+		user.UserProfileID, payload.UserFinancialActualID)
+	if err != nil {
+		log.Println("UnlinkActual: Error deleting link:", err)
+		http.Error(w, "Failed to unlink actual", http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		http.Error(w, "Link not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Actual unlinked successfully"})
+}
+
+// VarianceReport compares the expected occurrences with the actuals per item and per month between "from" and "to".
+// Amounts are converted to ?currency= or to the default currency, like the financial report, so items in different
+// currencies are never summed as they are.
+func VarianceReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("VarianceReport: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Default window is the current year up to the end of the current month
+	now := time.Now()
+	defaultFrom := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	defaultTo := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, defaultFrom, defaultTo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	itemID := 0
+	if v := r.URL.Query().Get("itemId"); v != "" {
+		if itemID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid itemId", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err == nil && rates == nil {
+		if rates, err = fx.Load(database); err == nil {
+			currencyCode, _ = rates.Code(defaultReportCurrencyID)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("VarianceReport: Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := loadForecastSchedules(database, user.UserProfileID)
+	if err != nil {
+		log.Println("VarianceReport: Error loading forecast schedules:", err)
+		http.Error(w, "Error fetching forecasts", http.StatusInternalServerError)
+		return
+	}
+
+	actuals, err := queryActuals(database, ` AND ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3`, user.UserProfileID, from, to)
+	if err != nil {
+		log.Println("VarianceReport: Error fetching actuals:", err)
+		http.Error(w, "Error fetching actuals", http.StatusInternalServerError)
+		return
+	}
+
+	items := map[int]*itemVariance{}
+	months := map[int]map[string]*monthVariance{}
+	add := func(id int, name, entityType string, date time.Time, currencyID int, expected, actual float64) error {
+		if currencyCode != "" {
+			var err error
+			if expected, err = rates.Convert(expected, currencyID, currencyCode, date); err == nil {
+				actual, err = rates.Convert(actual, currencyID, currencyCode, date)
+			}
+			if err != nil {
+				return fmt.Errorf("Unable to convert %s: %v", name, err)
+			}
+		}
+		month := date.Format("2006-01")
+		item, ok := items[id]
+		if !ok {
+			item = &itemVariance{FinancialUserItemID: id, FinancialUserItemName: name, EntityType: entityType}
+			items[id] = item
+			months[id] = map[string]*monthVariance{}
+		}
+		if item.FinancialUserItemName == "" {
+			item.FinancialUserItemName = name
+		}
+		m, ok := months[id][month]
+		if !ok {
+			m = &monthVariance{Month: month}
+			months[id][month] = m
+		}
+		item.Add(expected, actual)
+		m.Add(expected, actual)
+		return nil
+	}
+
+	for _, s := range schedules {
+		if itemID != 0 && s.ItemID != itemID {
+			continue
+		}
+		for _, o := range s.occurrences(from, to) {
+			date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+			if err := add(s.ItemID, s.ItemName, s.EntityType, date, o.CurrencyID, o.Amount, 0); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+	}
+	for _, a := range actuals {
+		if itemID != 0 && a.FinancialUserItemID != itemID {
+			continue
+		}
+		if err := add(a.FinancialUserItemID, a.FinancialUserItemName, "", dbDate(a.UserFinancialActualtBeginDate), a.CurrencyID, 0, a.UserFinancialActualAmount); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	report := []itemVariance{}
+	for id, item := range items {
+		for _, m := range months[id] {
+			item.Months = append(item.Months, *m)
+		}
+		sort.Slice(item.Months, func(i, j int) bool { return item.Months[i].Month < item.Months[j].Month })
+		report = append(report, *item)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].FinancialUserItemID < report[j].FinancialUserItemID })
+
+	response := struct {
+		From     string         `json:"from"`
+		To       string         `json:"to"`
+		Currency string         `json:"currency"`
+		Items    []itemVariance `json:"items"`
+	}{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Currency: currencyCode,
+		Items:    report,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"finanapp/internal/cashflow"
+	"finanapp/internal/db"
+	"finanapp/internal/fx"
+	"finanapp/internal/models"
+	"finanapp/internal/report"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultReportCurrencyID is the currency of the report when none is requested
+const defaultReportCurrencyID = 1
+
+// reportItem tells in which section and group the amounts of an item are shown
+type reportItem struct {
+	EntityID int
+	Group    string // income type, tax type or asset name
+	Category string // category of the latest forecast, for expenses booked without one
+}
+
+// loadReportItems loads the section data of the items owned by the user
+func loadReportItems(database *sql.DB, userID int) (map[int]reportItem, error) {
+	rows, err := database.Query(`
+	SELECT
+		fui.FinancialUserItemID,
+		fui.EntityID,
+		COALESCE(CASE
+			WHEN fui.EntityID = 5 THEN it.IncomeTypeName
+			WHEN fui.EntityID = 11 THEN ua.UserAssetName
+			WHEN fui.EntityID IN (7, 9, 12) THEN tt.TaxTypeName
+		END, ''),
+		COALESCE(fc.UserCategoryName, '')
+	FROM FinancialUserItem fui
+	LEFT JOIN IncomeType it ON fui.EntityID = 5 AND it.IncomeTypeID = fui.FinancialUserEntityItemID
+	LEFT JOIN TaxType tt ON fui.EntityID IN (7, 9, 12) AND tt.TaxTypeID = fui.FinancialUserEntityItemID
+	LEFT JOIN UserAsset ua ON fui.EntityID = 11 AND ua.UserAssetID = fui.UserEntityID
+	LEFT JOIN LATERAL (
+		SELECT uc.UserCategoryName
+		FROM UserFinancialForecast uff
+		JOIN UserCategory uc ON uc.UserCategoryID = uff.UserCategoryID
+		WHERE uff.FinancialUserItemID = fui.FinancialUserItemID
+		ORDER BY uff.UserFinancialForecastBeginDate DESC
+		LIMIT 1
+	) fc ON TRUE
+	WHERE `+ownedItemCondition, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := map[int]reportItem{}
+	for rows.Next() {
+		var id int
+		var item reportItem
+		if err := rows.Scan(&id, &item.EntityID, &item.Group, &item.Category); err != nil {
+			return nil, err
+		}
+		items[id] = item
+	}
+	return items, rows.Err()
+}
+
+// reportSection is the section of the report an entity belongs to, "" for the entities it leaves out
+func reportSection(entityID int) string {
+	switch cashflow.KindOf(entityID) {
+	case cashflow.KindInflow:
+		if entityID == 11 {
+			return report.SectionAssetIncome
+		}
+		return report.SectionIncome
+	case cashflow.KindTax:
+		return report.SectionTaxes
+	case cashflow.KindExpense:
+		return report.SectionExpenses
+	}
+	return ""
+}
+
+// reportTemplate is the standalone page of the report, it doesn't use the site layout so it prints cleanly
+func reportTemplate() (*template.Template, error) {
+	return template.New("report.html").Funcs(template.FuncMap{
+		"amount":  report.FormatAmount,
+		"percent": report.FormatPercent,
+	}).ParseFiles("views/report.html")
+}
+
+// Report builds the monthly or annual financial statement of the user:
+// GET /api/report?period=monthly|annual&year=2025&month=3&currency=BRL&format=html|pdf|json
+func Report(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Report: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	kind := query.Get("period")
+	if kind == "" {
+		kind = report.Monthly
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" && format != "json" {
+		http.Error(w, "Invalid format (expected html, pdf or json)", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	year, month := now.Year(), int(now.Month())
+	var err error
+	if v := query.Get("year"); v != "" {
+		if year, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid year", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("month"); v != "" {
+		if month, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid month", http.StatusBadRequest)
+			return
+		}
+	}
+	from, to, label, err := report.Period(kind, year, month)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	// Everything is converted, to the requested currency or to the default one
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err == nil && rates == nil {
+		if rates, err = fx.Load(database); err == nil {
+			currencyCode, _ = rates.Code(defaultReportCurrencyID)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("Report: Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	items, err := loadReportItems(database, user.UserProfileID)
+	if err != nil {
+		log.Println("Report: Error loading items:", err)
+		http.Error(w, "Error fetching items", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := loadForecastSchedules(database, user.UserProfileID)
+	if err != nil {
+		log.Println("Report: Error loading forecast schedules:", err)
+		http.Error(w, "Error fetching forecasts", http.StatusInternalServerError)
+		return
+	}
+
+	actuals, err := queryActuals(database, ` AND ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3`, user.UserProfileID, from, to)
+	if err != nil {
+		log.Println("Report: Error fetching actuals:", err)
+		http.Error(w, "Error fetching actuals", http.StatusInternalServerError)
+		return
+	}
+
+	var entries []report.Entry
+	add := func(itemID int, itemName, category string, date time.Time, amount float64, currencyID int, expected bool) error {
+		item, ok := items[itemID]
+		section := reportSection(item.EntityID)
+		if !ok || section == "" {
+			return nil
+		}
+		if currencyCode != "" {
+			converted, err := rates.Convert(amount, currencyID, currencyCode, date)
+			if err != nil {
+				return fmt.Errorf("Unable to convert %s: %v", itemName, err)
+			}
+			amount = converted
+		}
+
+		entry := report.Entry{Section: section, Group: item.Group, Date: date}
+		if section == report.SectionExpenses {
+			entry.Group = category
+			if entry.Group == "" {
+				entry.Group = item.Category
+			}
+		}
+		if expected {
+			entry.Expected = amount
+		} else {
+			entry.Actual = amount
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	for _, s := range schedules {
+		for _, o := range s.occurrences(from, to) {
+			date, _ := time.Parse("2006-01-02", o.OccurrenceDate)
+			if err := add(s.ItemID, s.ItemName, "", date, o.Amount, o.CurrencyID, true); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+	}
+	for _, a := range actuals {
+		if err := add(a.FinancialUserItemID, a.FinancialUserItemName, a.UserCategoryName, dbDate(a.UserFinancialActualtBeginDate), a.UserFinancialActualAmount, a.CurrencyID, false); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	rep := report.Build(kind, label, from, to, currencyCode, entries, now)
+
+	switch format {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(rep)
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		name := from.Format("2006-01")
+		if kind == report.Annual {
+			name = from.Format("2006")
+		}
+		w.Header().Set("Content-Disposition", `inline; filename="finanapp-report-`+name+`.pdf"`)
+		if err := report.WritePDF(w, rep); err != nil {
+			log.Println("Report: Error writing PDF:", err)
+		}
+	default:
+		tmpl, err := reportTemplate()
+		if err != nil {
+			log.Println("Report: Error loading template:", err)
+			http.Error(w, "Error loading templates", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tmpl.Execute(w, rep); err != nil {
+			log.Println("Report: Error rendering template:", err)
+		}
+	}
+}
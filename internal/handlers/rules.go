@@ -0,0 +1,652 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/cashflow"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/rules"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRulePriority is the priority of the rules created without one
+const defaultRulePriority = 100
+
+// ruleColumns is the base query of the rule listings, $1 is the user
+const ruleColumns = `
+	SELECT UserCategorizationRuleID, RuleName, RulePriority, DescriptionContains, DescriptionRegex, MinAmount,
+		MaxAmount, AmountSign, AccountKey, UserCategoryID, FinancialUserItemID, RuleNote, SkipTransaction, IsActive,
+		CreatedAt
+	FROM UserCategorizationRule
+	WHERE UserProfileID = $1`
+
+// queryRules lists the rules of the user in $1 in the order they run, with the extra filter
+func queryRules(q queryer, filter string, args ...interface{}) ([]models.UserCategorizationRule, error) {
+	rows, err := q.Query(ruleColumns+filter+` ORDER BY RulePriority, UserCategorizationRuleID`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.UserCategorizationRule{}
+	for rows.Next() {
+		var rule models.UserCategorizationRule
+		if err := rows.Scan(&rule.UserCategorizationRuleID, &rule.RuleName, &rule.RulePriority, &rule.DescriptionContains,
+			&rule.DescriptionRegex, &rule.MinAmount, &rule.MaxAmount, &rule.AmountSign, &rule.AccountKey,
+			&rule.UserCategoryID, &rule.FinancialUserItemID, &rule.RuleNote, &rule.SkipTransaction, &rule.IsActive,
+			&rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, rule)
+	}
+	return list, rows.Err()
+}
+
+// engineRule converts a stored rule to the rule the engine runs
+func engineRule(m models.UserCategorizationRule) rules.Rule {
+	text := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return rules.Rule{
+		ID:                  m.UserCategorizationRuleID,
+		Priority:            m.RulePriority,
+		DescriptionContains: text(m.DescriptionContains),
+		DescriptionRegex:    text(m.DescriptionRegex),
+		MinAmount:           m.MinAmount,
+		MaxAmount:           m.MaxAmount,
+		Sign:                text(m.AmountSign),
+		Account:             text(m.AccountKey),
+		CategoryID:          m.UserCategoryID,
+		ItemID:              m.FinancialUserItemID,
+		Note:                text(m.RuleNote),
+		Skip:                m.SkipTransaction,
+	}
+}
+
+// loadRuleEngine loads the active rules of the user
+func loadRuleEngine(q queryer, userID int) (*rules.Engine, error) {
+	stored, err := queryRules(q, ` AND IsActive`, userID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]rules.Rule, 0, len(stored))
+	for _, m := range stored {
+		list = append(list, engineRule(m))
+	}
+	return rules.NewEngine(list)
+}
+
+// validateRulePayload checks the conditions and actions of the rule and the ownership of the item and category
+func validateRulePayload(database *sql.DB, userID int, payload *models.UserCategorizationRule) (int, error) {
+	payload.RuleName = strings.TrimSpace(payload.RuleName)
+	if payload.RuleName == "" {
+		return http.StatusBadRequest, fmt.Errorf("ruleName is required")
+	}
+	if payload.RulePriority < 0 {
+		return http.StatusBadRequest, fmt.Errorf("priority can't be negative")
+	}
+	if payload.RulePriority == 0 {
+		payload.RulePriority = defaultRulePriority
+	}
+	for _, field := range []**string{&payload.DescriptionContains, &payload.DescriptionRegex, &payload.AmountSign,
+		&payload.AccountKey, &payload.RuleNote} {
+		if *field != nil && strings.TrimSpace(**field) == "" {
+			*field = nil
+		}
+	}
+
+	rule := engineRule(*payload)
+	if err := rule.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if payload.FinancialUserItemID != nil && *payload.FinancialUserItemID != 0 {
+		owns, err := userOwnsItem(database, userID, *payload.FinancialUserItemID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !owns {
+			return http.StatusNotFound, fmt.Errorf("Item not found or unauthorized")
+		}
+	} else {
+		payload.FinancialUserItemID = nil
+	}
+	if payload.UserCategoryID != nil && *payload.UserCategoryID != 0 {
+		owns, err := userOwnsCategory(database, userID, *payload.UserCategoryID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !owns {
+			return http.StatusNotFound, fmt.Errorf("Category not found or unauthorized")
+		}
+	} else {
+		payload.UserCategoryID = nil
+	}
+
+	// Validate again: clearing a zero item or category can leave the rule without action
+	rule = engineRule(*payload)
+	if err := rule.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+// categorizeActual fills the category and the note of a new actual from the rules, the item and the note are the
+// description. The payload is left as is when no rule matches.
+func categorizeActual(database *sql.DB, userID int, payload *models.UserFinancialActualPayload) error {
+	engine, err := loadRuleEngine(database, userID)
+	if err != nil {
+		return err
+	}
+
+	var itemName string
+	var entityID int
+	err = database.QueryRow(`SELECT FinancialUserItemName, EntityID FROM financialuseritem WHERE FinancialUserItemID = $1`,
+		payload.FinancialUserItemID).Scan(&itemName, &entityID)
+	if err != nil {
+		return err
+	}
+
+	description := itemName
+	if payload.Note != nil {
+		description = *payload.Note + " " + itemName
+	}
+	amount := -payload.Amount
+	if cashflow.KindOf(entityID) == cashflow.KindInflow {
+		amount = payload.Amount
+	}
+
+	result := engine.Apply(rules.Transaction{Description: description, Amount: amount})
+	if result.CategoryID != nil {
+		payload.UserCategoryID = result.CategoryID
+	}
+	if payload.Note == nil && result.Note != "" {
+		payload.Note = &result.Note
+	}
+	return nil
+}
+
+// Rules lists the categorization rules of the user in the order they run
+func Rules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Rules: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := queryRules(db.GetDB(), "", user.UserProfileID)
+	if err != nil {
+		log.Println("Rules: Error fetching rules:", err)
+		http.Error(w, "Error fetching rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(list)
+}
+
+// CreateRule creates a categorization rule, active unless isActive is false
+func CreateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateRule: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload := models.UserCategorizationRule{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	status, err := validateRulePayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("CreateRule: Error validating payload:", err)
+			http.Error(w, "Failed to create rule", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var ruleID int
+	err = database.QueryRow(`
+		INSERT INTO UserCategorizationRule (UserProfileID, RuleName, RulePriority, DescriptionContains, DescriptionRegex,
+			MinAmount, MaxAmount, AmountSign, AccountKey, UserCategoryID, FinancialUserItemID, RuleNote, SkipTransaction,
+			IsActive)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING UserCategorizationRuleID`,
+		user.UserProfileID, payload.RuleName, payload.RulePriority, payload.DescriptionContains, payload.DescriptionRegex,
+		payload.MinAmount, payload.MaxAmount, payload.AmountSign, payload.AccountKey, payload.UserCategoryID,
+		payload.FinancialUserItemID, payload.RuleNote, payload.SkipTransaction, payload.IsActive).Scan(&ruleID)
+	if err != nil {
+		log.Println("CreateRule: Error inserting rule:", err)
+		http.Error(w, "Failed to create rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                      "success",
+		"message":                     "Rule created successfully",
+		"user_categorization_rule_id": ruleID,
+	})
+}
+
+// UpdateRule replaces the conditions and actions of a rule
+func UpdateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateRule: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payload := models.UserCategorizationRule{IsActive: true}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserCategorizationRuleID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	status, err := validateRulePayload(database, user.UserProfileID, &payload)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("UpdateRule: Error validating payload:", err)
+			http.Error(w, "Failed to update rule", status)
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	result, err := database.Exec(`
+		UPDATE UserCategorizationRule SET RuleName = $3, RulePriority = $4, DescriptionContains = $5,
+			DescriptionRegex = $6, MinAmount = $7, MaxAmount = $8, AmountSign = $9, AccountKey = $10,
+			UserCategoryID = $11, FinancialUserItemID = $12, RuleNote = $13, SkipTransaction = $14, IsActive = $15
+		WHERE UserCategorizationRuleID = $2 AND UserProfileID = $1`,
+		user.UserProfileID, payload.UserCategorizationRuleID, payload.RuleName, payload.RulePriority,
+		payload.DescriptionContains, payload.DescriptionRegex, payload.MinAmount, payload.MaxAmount, payload.AmountSign,
+		payload.AccountKey, payload.UserCategoryID, payload.FinancialUserItemID, payload.RuleNote,
+		payload.SkipTransaction, payload.IsActive)
+	if err != nil {
+		log.Println("UpdateRule: Error updating rule:", err)
+		http.Error(w, "Failed to update rule", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Rule not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Rule updated successfully"})
+}
+
+// DeleteRule removes a rule, what it already categorized is kept
+func DeleteRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteRule: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserCategorizationRuleID int `json:"userCategorizationRuleId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserCategorizationRuleID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	result, err := db.GetDB().Exec(`DELETE FROM UserCategorizationRule WHERE UserCategorizationRuleID = $2 AND UserProfileID = $1`,
+		user.UserProfileID, payload.UserCategorizationRuleID)
+	if err != nil {
+		log.Println("DeleteRule: Error deleting rule:", err)
+		http.Error(w, "Failed to delete rule", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Rule not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Rule deleted successfully"})
+}
+
+// ruleChange is a line or an actual the rules changed, or would change on a dry run
+type ruleChange struct {
+	ImportedTransactionID *int    `json:"importedTransactionId,omitempty"`
+	UserFinancialActualID *int    `json:"userFinancialActualId,omitempty"`
+	Description           string  `json:"description"`
+	Amount                float64 `json:"amount"`
+	RuleIDs               []int   `json:"ruleIds"`
+	FinancialUserItemID   *int    `json:"financialUserItemId,omitempty"`
+	UserCategoryID        *int    `json:"userCategoryId,omitempty"`
+	Note                  *string `json:"note,omitempty"`
+	Skip                  bool    `json:"skip,omitempty"`
+}
+
+// ApplyRules runs the active rules on the pending imported lines and on the actuals dated in the ?from= to ?to=
+// range (default: the current month). Rules fill the empty fields only, unless the body sets overwrite; on actuals
+// they only set the category and the note, the item and the skip apply to imported lines. dryRun lists the changes
+// without saving them.
+func ApplyRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ApplyRules: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	from, to, err := parseDateRange(r, monthStart, monthStart.AddDate(0, 1, -1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Overwrite bool `json:"overwrite"`
+		DryRun    bool `json:"dryRun"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("ApplyRules: Error starting transaction:", err)
+		http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	engine, err := loadRuleEngine(tx, user.UserProfileID)
+	var lines []models.ImportedTransaction
+	if err == nil {
+		lines, err = queryImportedTransactions(tx, ` AND it.ImportStatus = 'pending' AND it.TransactionDate BETWEEN $2 AND $3`,
+			user.UserProfileID, from, to)
+	}
+	if err != nil {
+		log.Println("ApplyRules: Error loading rules:", err)
+		http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+		return
+	}
+
+	changes := []ruleChange{}
+	for _, line := range lines {
+		result := engine.Apply(rules.Transaction{Description: line.TransactionDescription, Amount: line.TransactionAmount,
+			Account: line.AccountKey})
+		if !result.Matched() {
+			continue
+		}
+
+		change := ruleChange{ImportedTransactionID: &line.ImportedTransactionID, Description: line.TransactionDescription,
+			Amount: line.TransactionAmount, RuleIDs: result.RuleIDs, Skip: result.Skip}
+		itemID, categoryID, note := line.FinancialUserItemID, line.UserCategoryID, line.TransactionNote
+		if result.ItemID != nil && (itemID == nil || payload.Overwrite) {
+			itemID, change.FinancialUserItemID = result.ItemID, result.ItemID
+		}
+		if result.CategoryID != nil && (categoryID == nil || payload.Overwrite) {
+			categoryID, change.UserCategoryID = result.CategoryID, result.CategoryID
+		}
+		if result.Note != "" && (note == nil || payload.Overwrite) {
+			note, change.Note = &result.Note, &result.Note
+		}
+		if change.FinancialUserItemID == nil && change.UserCategoryID == nil && change.Note == nil && !change.Skip {
+			continue
+		}
+		changes = append(changes, change)
+
+		if !payload.DryRun {
+			status := importPending
+			if result.Skip {
+				status = importSkipped
+			}
+			_, err := tx.Exec(`
+				UPDATE ImportedTransaction SET FinancialUserItemID = $3, UserCategoryID = $4, TransactionNote = $5,
+					ImportStatus = $6, MatchSource = $7
+				WHERE ImportedTransactionID = $2 AND UserProfileID = $1`,
+				user.UserProfileID, line.ImportedTransactionID, itemID, categoryID, note, status,
+				"rule:"+strconv.Itoa(result.RuleIDs[0]))
+			if err != nil {
+				log.Println("ApplyRules: Error updating line:", err)
+				http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	// Actuals are described by their imported line, or by their note and item name
+	rows, err := tx.Query(`
+		SELECT ufa.UserFinancialActualID, ufa.UserFinancialActualAmount, ufa.UserCategoryID, ufa.Note, fui.EntityID,
+			COALESCE(it.TransactionDescription, COALESCE(ufa.Note || ' ', '') || fui.FinancialUserItemName),
+			COALESCE(it.AccountKey, '')
+		FROM userfinancialactual ufa
+		JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		LEFT JOIN ImportedTransaction it ON it.UserFinancialActualID = ufa.UserFinancialActualID
+		WHERE ufa.UserFinancialActualtBeginDate BETWEEN $2 AND $3 AND `+ownedItemCondition+`
+		ORDER BY ufa.UserFinancialActualtBeginDate, ufa.UserFinancialActualID`, user.UserProfileID, from, to)
+	if err != nil {
+		log.Println("ApplyRules: Error fetching actuals:", err)
+		http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+		return
+	}
+	type actualUpdate struct {
+		id         int
+		categoryID *int
+		note       *string
+	}
+	var updates []actualUpdate
+	for rows.Next() {
+		var id, entityID int
+		var amount float64
+		var categoryID *int
+		var note *string
+		var description, account string
+		if err := rows.Scan(&id, &amount, &categoryID, &note, &entityID, &description, &account); err != nil {
+			rows.Close()
+			log.Println("ApplyRules: Error reading actual:", err)
+			http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+			return
+		}
+		if cashflow.KindOf(entityID) != cashflow.KindInflow {
+			amount = -amount
+		}
+
+		result := engine.Apply(rules.Transaction{Description: description, Amount: amount, Account: account})
+		if !result.Matched() {
+			continue
+		}
+		change := ruleChange{UserFinancialActualID: &id, Description: description, Amount: amount, RuleIDs: result.RuleIDs}
+		if result.CategoryID != nil && (categoryID == nil || (payload.Overwrite && *categoryID != *result.CategoryID)) {
+			categoryID, change.UserCategoryID = result.CategoryID, result.CategoryID
+		}
+		if result.Note != "" && note == nil {
+			note, change.Note = &result.Note, &result.Note
+		}
+		if change.UserCategoryID == nil && change.Note == nil {
+			continue
+		}
+		changes = append(changes, change)
+		updates = append(updates, actualUpdate{id: id, categoryID: categoryID, note: note})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Println("ApplyRules: Error fetching actuals:", err)
+		http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+		return
+	}
+
+	if !payload.DryRun {
+		for _, u := range updates {
+			if _, err = tx.Exec(`UPDATE UserFinancialActual SET UserCategoryID = $2, Note = $3 WHERE UserFinancialActualID = $1`,
+				u.id, u.categoryID, u.note); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Println("ApplyRules: Error saving changes:", err)
+			http.Error(w, "Failed to apply rules", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"dryRun":  payload.DryRun,
+		"changes": changes,
+	})
+}
+
+// SuggestRules learns rules from the categorized actuals of the user: descriptions that keep going to the same item
+// and category (?minSupport=, default 3, and ?minConfidence=, default 0.8). Suggestions the current rules already
+// cover are left out.
+func SuggestRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SuggestRules: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	minSupport, minConfidence := 3, 0.8
+	if value := r.URL.Query().Get("minSupport"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			http.Error(w, "minSupport must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		minSupport = parsed
+	}
+	if value := r.URL.Query().Get("minConfidence"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			http.Error(w, "minConfidence must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		minConfidence = parsed
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	engine, err := loadRuleEngine(database, user.UserProfileID)
+	if err != nil {
+		log.Println("SuggestRules: Error loading rules:", err)
+		http.Error(w, "Error fetching suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	// Only actuals with a description of their own: the imported line or the note
+	rows, err := database.Query(`
+		SELECT COALESCE(it.TransactionDescription, ufa.Note), ufa.UserFinancialActualAmount, fui.EntityID,
+			ufa.FinancialUserItemID, ufa.UserCategoryID, COALESCE(it.AccountKey, '')
+		FROM userfinancialactual ufa
+		JOIN financialuseritem fui ON ufa.FinancialUserItemID = fui.FinancialUserItemID
+		LEFT JOIN ImportedTransaction it ON it.UserFinancialActualID = ufa.UserFinancialActualID
+		WHERE COALESCE(it.TransactionDescription, ufa.Note) IS NOT NULL AND `+ownedItemCondition+`
+		ORDER BY ufa.UserFinancialActualtBeginDate DESC`, user.UserProfileID)
+	if err != nil {
+		log.Println("SuggestRules: Error fetching actuals:", err)
+		http.Error(w, "Error fetching suggestions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var examples []rules.Example
+	for rows.Next() {
+		var ex rules.Example
+		var entityID int
+		var account string
+		if err := rows.Scan(&ex.Description, &ex.Amount, &entityID, &ex.ItemID, &ex.CategoryID, &account); err != nil {
+			log.Println("SuggestRules: Error reading actual:", err)
+			http.Error(w, "Error fetching suggestions", http.StatusInternalServerError)
+			return
+		}
+		if cashflow.KindOf(entityID) != cashflow.KindInflow {
+			ex.Amount = -ex.Amount
+		}
+		// Descriptions the rules already handle teach nothing new
+		if engine.Apply(rules.Transaction{Description: ex.Description, Amount: ex.Amount, Account: account}).Matched() {
+			continue
+		}
+		examples = append(examples, ex)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("SuggestRules: Error fetching actuals:", err)
+		http.Error(w, "Error fetching suggestions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rules.Learn(examples, minSupport, minConfidence))
+}
@@ -0,0 +1,1111 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/split"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// splitExpenseCondition restricts the SplitExpense alias "se" to the expenses the user in $1 paid, takes part
+// in, or that belong to one of their groups
+const splitExpenseCondition = `(se.PaidByUserProfileID = $1
+		OR EXISTS (SELECT 1 FROM SplitExpenseShare s WHERE s.SplitExpenseID = se.SplitExpenseID AND s.UserProfileID = $1)
+		OR se.UserGroupID IN (SELECT UserGroupID FROM UserGroupMember WHERE UserProfileID = $1))`
+
+// splitSettlementCondition does the same for the SplitSettlement alias "ss"
+const splitSettlementCondition = `(ss.FromUserProfileID = $1 OR ss.ToUserProfileID = $1
+		OR ss.UserGroupID IN (SELECT UserGroupID FROM UserGroupMember WHERE UserProfileID = $1))`
+
+// settlementItemName is the personal item that receives the actuals of the settlements
+const settlementItemName = "Split settlements"
+
+// splitGroupFilter reads the optional ?groupId= and checks the user is a member of the group
+func splitGroupFilter(database *sql.DB, r *http.Request, userID int) (*int, int, error) {
+	raw := r.URL.Query().Get("groupId")
+	if raw == "" {
+		return nil, 0, nil
+	}
+	groupID, err := strconv.Atoi(raw)
+	if err != nil || groupID <= 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("Invalid groupId")
+	}
+	role, err := groupRole(database, userID, groupID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if role == "" {
+		return nil, http.StatusNotFound, fmt.Errorf("Group not found or unauthorized")
+	}
+	return &groupID, 0, nil
+}
+
+// splitContactCondition restricts the UserProfile alias "up" to the user in $1 and their accepted split contacts
+const splitContactCondition = `(up.UserProfileID = $1
+		OR EXISTS (SELECT 1 FROM UserSplitContact c WHERE c.ContactStatus = 'accepted'
+			AND ((c.UserProfileID = $1 AND c.ContactUserProfileID = up.UserProfileID)
+				OR (c.ContactUserProfileID = $1 AND c.UserProfileID = up.UserProfileID))))`
+
+// resolveSplitUser finds a UserProfile by ID or, when the ID is 0, by email address, among the members of the
+// group or, without a group, among the split contacts of the user. Anyone else isn't found, so the lookup never
+// tells which accounts exist.
+func resolveSplitUser(q queryer, userID int, groupID *int, otherID int, email string) (int, error) {
+	var id int
+	err := q.QueryRow(`
+		SELECT up.UserProfileID FROM UserProfile up
+		WHERE (up.UserProfileID = $2 OR ($2 = 0 AND LOWER(up.EmailAddress) = LOWER($3)))
+			AND (up.UserProfileID IN (SELECT UserProfileID FROM UserGroupMember WHERE UserGroupID = $4)
+				OR ($4::INT IS NULL AND `+splitContactCondition+`))`,
+		userID, otherID, strings.TrimSpace(email), groupID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// userNames returns the full names of the users
+func userNames(q queryer, userIDs []int64) (map[int]string, error) {
+	rows, err := q.Query(`SELECT UserProfileID, FirstName || ' ' || LastName FROM UserProfile WHERE UserProfileID = ANY($1)`,
+		pq.Int64Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+// CreateSplitExpense records an expense paid by one user and divides it between the participants.
+// Inside a group, the payer and the participants must be members and viewers can't create expenses.
+func CreateSplitExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateSplitExpense: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload models.SplitExpensePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.SplitExpenseName = strings.TrimSpace(payload.SplitExpenseName)
+	if payload.SplitExpenseName == "" {
+		http.Error(w, "splitExpenseName is required", http.StatusBadRequest)
+		return
+	}
+	date := time.Now().Format("2006-01-02")
+	if payload.Date != "" {
+		if _, err := time.Parse("2006-01-02", payload.Date); err != nil {
+			http.Error(w, "Invalid date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		date = payload.Date
+	}
+	if payload.PaidByUserProfileID == 0 {
+		payload.PaidByUserProfileID = user.UserProfileID
+	}
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	var currencyExists bool
+	if err := database.QueryRow(`SELECT EXISTS (SELECT 1 FROM currency WHERE CurrencyID = $1)`, payload.CurrencyID).Scan(&currencyExists); err != nil {
+		log.Println("CreateSplitExpense: Error checking currency:", err)
+		http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+		return
+	}
+	if !currencyExists {
+		http.Error(w, "Invalid currencyId", http.StatusBadRequest)
+		return
+	}
+
+	if payload.PaidByUserProfileID != user.UserProfileID {
+		payerID, err := resolveSplitUser(database, user.UserProfileID, payload.UserGroupID, payload.PaidByUserProfileID, "")
+		if err != nil {
+			log.Println("CreateSplitExpense: Error resolving payer:", err)
+			http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+			return
+		}
+		if payerID == 0 {
+			http.Error(w, "Payer not found in the group or your split contacts", http.StatusBadRequest)
+			return
+		}
+	}
+
+	participants := make([]split.Participant, 0, len(payload.Participants))
+	for _, p := range payload.Participants {
+		id, err := resolveSplitUser(database, user.UserProfileID, payload.UserGroupID, p.UserProfileID, p.EmailAddress)
+		if err != nil {
+			log.Println("CreateSplitExpense: Error resolving participant:", err)
+			http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+			return
+		}
+		if id == 0 {
+			participant := p.EmailAddress
+			if p.UserProfileID != 0 {
+				participant = strconv.Itoa(p.UserProfileID)
+			}
+			http.Error(w, fmt.Sprintf("Participant %s not found in the group or your split contacts", participant), http.StatusBadRequest)
+			return
+		}
+		participants = append(participants, split.Participant{UserID: id, Value: p.Value})
+	}
+
+	portions, err := split.Split(payload.Amount, payload.SplitMode, participants)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	involved := payload.PaidByUserProfileID == user.UserProfileID
+	for _, p := range portions {
+		involved = involved || p.UserID == user.UserProfileID
+	}
+
+	if payload.UserGroupID != nil {
+		role, err := groupRole(database, user.UserProfileID, *payload.UserGroupID)
+		if err != nil {
+			log.Println("CreateSplitExpense: Error checking role:", err)
+			http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+			return
+		}
+		if role == "" || role == groupRoleViewer {
+			http.Error(w, "Only group owners and members can split expenses in the group", http.StatusForbidden)
+			return
+		}
+		members := []int{payload.PaidByUserProfileID}
+		for _, p := range portions {
+			members = append(members, p.UserID)
+		}
+		for _, id := range members {
+			role, err := groupRole(database, id, *payload.UserGroupID)
+			if err != nil {
+				log.Println("CreateSplitExpense: Error checking members:", err)
+				http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+				return
+			}
+			if role == "" {
+				http.Error(w, fmt.Sprintf("User %d is not a member of the group", id), http.StatusBadRequest)
+				return
+			}
+		}
+	} else if !involved {
+		http.Error(w, "You must be the payer or one of the participants", http.StatusBadRequest)
+		return
+	}
+
+	// Only the payer can link the expense to their own item
+	if payload.FinancialUserItemID != nil {
+		if payload.PaidByUserProfileID != user.UserProfileID {
+			http.Error(w, "financialUserItemId can only be set by the payer", http.StatusBadRequest)
+			return
+		}
+		owns, err := userOwnsItem(database, user.UserProfileID, *payload.FinancialUserItemID)
+		if err != nil {
+			log.Println("CreateSplitExpense: Error checking item:", err)
+			http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+			return
+		}
+		if !owns {
+			http.Error(w, "Item not found or unauthorized", http.StatusNotFound)
+			return
+		}
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		log.Println("CreateSplitExpense: Error starting transaction:", err)
+		http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var expenseID int
+	err = tx.QueryRow(`
+		INSERT INTO SplitExpense (SplitExpenseName, PaidByUserProfileID, CreatedByUserProfileID, UserGroupID,
+			FinancialUserItemID, SplitExpenseAmount, CurrencyID, SplitExpenseDate, SplitMode)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING SplitExpenseID`,
+		payload.SplitExpenseName, payload.PaidByUserProfileID, user.UserProfileID, payload.UserGroupID,
+		payload.FinancialUserItemID, payload.Amount, payload.CurrencyID, date, payload.SplitMode).Scan(&expenseID)
+	for i := 0; err == nil && i < len(portions); i++ {
+		var value *float64
+		if payload.SplitMode != split.Equal {
+			value = &participants[i].Value
+		}
+		_, err = tx.Exec(`INSERT INTO SplitExpenseShare (SplitExpenseID, UserProfileID, ShareValue, ShareAmount) VALUES ($1, $2, $3, $4)`,
+			expenseID, portions[i].UserID, value, portions[i].Amount)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CreateSplitExpense: Error inserting split expense:", err)
+		http.Error(w, "Failed to create split expense", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":           "success",
+		"message":          "Split expense created successfully",
+		"split_expense_id": expenseID,
+		"portions":         portions,
+	})
+}
+
+// SplitExpenses lists the split expenses visible to the user with their shares, optionally of one group (?groupId=)
+func SplitExpenses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SplitExpenses: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	groupID, status, err := splitGroupFilter(database, r, user.UserProfileID)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("SplitExpenses: Error checking group:", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	rows, err := database.Query(`
+		SELECT se.SplitExpenseID, se.SplitExpenseName, se.PaidByUserProfileID, up.FirstName || ' ' || up.LastName,
+			se.UserGroupID, se.FinancialUserItemID, se.SplitExpenseAmount, se.CurrencyID, se.SplitExpenseDate, se.SplitMode, se.CreatedAt
+		FROM SplitExpense se
+		JOIN UserProfile up ON up.UserProfileID = se.PaidByUserProfileID
+		WHERE `+splitExpenseCondition+` AND ($2::INT IS NULL OR se.UserGroupID = $2)
+		ORDER BY se.SplitExpenseDate DESC, se.SplitExpenseID DESC`, user.UserProfileID, groupID)
+	if err != nil {
+		log.Println("SplitExpenses: Error fetching split expenses:", err)
+		http.Error(w, "Error fetching split expenses", http.StatusInternalServerError)
+		return
+	}
+
+	expenses := []models.SplitExpense{}
+	index := map[int]int{}
+	for rows.Next() {
+		var e models.SplitExpense
+		if err := rows.Scan(&e.SplitExpenseID, &e.SplitExpenseName, &e.PaidByUserProfileID, &e.PaidByName, &e.UserGroupID,
+			&e.FinancialUserItemID, &e.SplitExpenseAmount, &e.CurrencyID, &e.SplitExpenseDate, &e.SplitMode, &e.CreatedAt); err != nil {
+			rows.Close()
+			log.Println("SplitExpenses: Error scanning split expense:", err)
+			http.Error(w, "Error fetching split expenses", http.StatusInternalServerError)
+			return
+		}
+		e.Shares = []models.SplitExpenseShare{}
+		index[e.SplitExpenseID] = len(expenses)
+		expenses = append(expenses, e)
+	}
+	rows.Close()
+
+	rows, err = database.Query(`
+		SELECT s.SplitExpenseID, s.UserProfileID, up.FirstName || ' ' || up.LastName, s.ShareValue, s.ShareAmount
+		FROM SplitExpenseShare s
+		JOIN SplitExpense se ON se.SplitExpenseID = s.SplitExpenseID
+		JOIN UserProfile up ON up.UserProfileID = s.UserProfileID
+		WHERE `+splitExpenseCondition+` AND ($2::INT IS NULL OR se.UserGroupID = $2)
+		ORDER BY s.SplitExpenseShareID`, user.UserProfileID, groupID)
+	if err != nil {
+		log.Println("SplitExpenses: Error fetching shares:", err)
+		http.Error(w, "Error fetching split expenses", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var expenseID int
+		var share models.SplitExpenseShare
+		if err := rows.Scan(&expenseID, &share.UserProfileID, &share.UserName, &share.ShareValue, &share.ShareAmount); err != nil {
+			log.Println("SplitExpenses: Error scanning share:", err)
+			http.Error(w, "Error fetching split expenses", http.StatusInternalServerError)
+			return
+		}
+		if i, ok := index[expenseID]; ok {
+			expenses[i].Shares = append(expenses[i].Shares, share)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"split_expenses": expenses})
+}
+
+// DeleteSplitExpense deletes a split expense, allowed to the payer and to who created it
+func DeleteSplitExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteSplitExpense: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		SplitExpenseID int `json:"splitExpenseId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.SplitExpenseID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`
+		DELETE FROM SplitExpense
+		WHERE SplitExpenseID = $2 AND (PaidByUserProfileID = $1 OR CreatedByUserProfileID = $1)`,
+		user.UserProfileID, payload.SplitExpenseID)
+	if err != nil {
+		log.Println("DeleteSplitExpense: Error deleting split expense:", err)
+		http.Error(w, "Failed to delete split expense", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Split expense not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Split expense deleted successfully"})
+}
+
+// loadSplitDebts returns the debts from the split expenses and the settlements, per currency. In a group the
+// whole ledger of the group is returned, otherwise only the debts the user is part of.
+func loadSplitDebts(database *sql.DB, userID int, groupID *int) (map[int][]split.Debt, error) {
+	debts := map[int][]split.Debt{}
+	add := func(currencyID int, d split.Debt) {
+		if groupID != nil || d.DebtorID == userID || d.CreditorID == userID {
+			debts[currencyID] = append(debts[currencyID], d)
+		}
+	}
+
+	rows, err := database.Query(`
+		SELECT se.CurrencyID, se.PaidByUserProfileID, s.UserProfileID, s.ShareAmount
+		FROM SplitExpenseShare s
+		JOIN SplitExpense se ON se.SplitExpenseID = s.SplitExpenseID
+		WHERE `+splitExpenseCondition+` AND ($2::INT IS NULL OR se.UserGroupID = $2)`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var currencyID, payerID int
+		var portion split.Portion
+		if err := rows.Scan(&currencyID, &payerID, &portion.UserID, &portion.Amount); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, d := range split.ExpenseDebts(payerID, []split.Portion{portion}) {
+			add(currencyID, d)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = database.Query(`
+		SELECT ss.CurrencyID, ss.FromUserProfileID, ss.ToUserProfileID, ss.SettlementAmount
+		FROM SplitSettlement ss
+		WHERE `+splitSettlementCondition+` AND ($2::INT IS NULL OR ss.UserGroupID = $2)`, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var currencyID, fromID, toID int
+		var amount float64
+		if err := rows.Scan(&currencyID, &fromID, &toID, &amount); err != nil {
+			return nil, err
+		}
+		add(currencyID, split.SettlementDebt(fromID, toID, amount))
+	}
+	return debts, rows.Err()
+}
+
+// splitBalance is the position of one user in a currency
+type splitBalance struct {
+	UserProfileID int     `json:"userProfileId"`
+	UserName      string  `json:"userName"`
+	Amount        float64 `json:"amount"`
+}
+
+// splitTransfer is a suggested settlement with the names of both sides
+type splitTransfer struct {
+	split.Transfer
+	FromName string `json:"fromName"`
+	ToName   string `json:"toName"`
+}
+
+// splitCurrencyBalances groups the balances of one currency
+type splitCurrencyBalances struct {
+	CurrencyID int             `json:"currencyId"`
+	Balances   []splitBalance  `json:"balances"`   // net position of everyone, positive means they are owed
+	WithYou    []splitBalance  `json:"withYou"`    // per counterpart, positive means they owe you
+	Transfers  []splitTransfer `json:"transfers"`  // fewest payments that settle every balance
+	YouOwe     float64         `json:"youOwe"`     // total of your transfers to others
+	YouAreOwed float64         `json:"youAreOwed"` // total of the transfers to you
+}
+
+// SplitBalances shows who owes whom and the simplified transfers that settle the balances, per currency.
+// With ?groupId= the whole group ledger is simplified, otherwise only the debts involving the user.
+func SplitBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SplitBalances: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	groupID, status, err := splitGroupFilter(database, r, user.UserProfileID)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("SplitBalances: Error checking group:", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	debts, err := loadSplitDebts(database, user.UserProfileID, groupID)
+	if err != nil {
+		log.Println("SplitBalances: Error loading debts:", err)
+		http.Error(w, "Error computing balances", http.StatusInternalServerError)
+		return
+	}
+
+	var ids []int64
+	seen := map[int]bool{}
+	for _, currencyDebts := range debts {
+		for _, d := range currencyDebts {
+			for _, id := range []int{d.DebtorID, d.CreditorID} {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, int64(id))
+				}
+			}
+		}
+	}
+	names, err := userNames(database, ids)
+	if err != nil {
+		log.Println("SplitBalances: Error loading names:", err)
+		http.Error(w, "Error computing balances", http.StatusInternalServerError)
+		return
+	}
+
+	sorted := func(amounts map[int]float64) []splitBalance {
+		list := []splitBalance{}
+		for id, amount := range amounts {
+			list = append(list, splitBalance{UserProfileID: id, UserName: names[id], Amount: amount})
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].UserProfileID < list[j].UserProfileID })
+		return list
+	}
+
+	currencies := []splitCurrencyBalances{}
+	for currencyID, currencyDebts := range debts {
+		balances := split.Balances(currencyDebts)
+		result := splitCurrencyBalances{
+			CurrencyID: currencyID,
+			Balances:   sorted(balances),
+			WithYou:    sorted(split.Pairwise(user.UserProfileID, currencyDebts)),
+			Transfers:  []splitTransfer{},
+		}
+		for _, t := range split.Simplify(balances) {
+			result.Transfers = append(result.Transfers, splitTransfer{Transfer: t, FromName: names[t.FromUserID], ToName: names[t.ToUserID]})
+			if t.FromUserID == user.UserProfileID {
+				result.YouOwe = math.Round((result.YouOwe+t.Amount)*100) / 100
+			}
+			if t.ToUserID == user.UserProfileID {
+				result.YouAreOwed = math.Round((result.YouAreOwed+t.Amount)*100) / 100
+			}
+		}
+		currencies = append(currencies, result)
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].CurrencyID < currencies[j].CurrencyID })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"split_balances": currencies})
+}
+
+// settlementItem returns the personal item receiving the settlement actuals of the user, creating it when missing.
+// Payers book the settlement as a User Expense (EntityID 6) and receivers as a User Income (EntityID 5).
+func settlementItem(q queryer, userID, entityID int) (int, error) {
+	var itemID int
+	err := q.QueryRow(`
+		SELECT FinancialUserItemID FROM FinancialUserItem
+		WHERE EntityID = $1 AND UserEntityID = $2 AND FinancialUserItemName = $3 AND ParentFinancialUserItemID IS NULL
+		ORDER BY FinancialUserItemID LIMIT 1`, entityID, userID, settlementItemName).Scan(&itemID)
+	if err != sql.ErrNoRows {
+		return itemID, err
+	}
+	// RecurrencyID 1 is One Time
+	err = q.QueryRow(`
+		INSERT INTO FinancialUserItem (FinancialUserItemName, EntityID, UserEntityID, RecurrencyID)
+		VALUES ($1, $2, $3, 1) RETURNING FinancialUserItemID`, settlementItemName, entityID, userID).Scan(&itemID)
+	return itemID, err
+}
+
+// CreateSplitSettlement records a payment the logged-in user made to a user they share split expenses with, and
+// books the matching actuals: an expense for the payer and an income for the receiver. Only the payer can record
+// it, so nobody can book a payment into someone else's account; the receiver can delete it.
+func CreateSplitSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("CreateSplitSettlement: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		FromUserProfileID int     `json:"fromUserProfileId"` // must be the logged-in user when set
+		ToUserProfileID   int     `json:"toUserProfileId"`
+		ToEmailAddress    string  `json:"toEmailAddress"`
+		UserGroupID       *int    `json:"userGroupId"`
+		Amount            float64 `json:"amount"`
+		CurrencyID        int     `json:"currencyId"`
+		Date              string  `json:"date"` // "YYYY-MM-DD"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Amount <= 0 {
+		http.Error(w, "amount must be greater than zero", http.StatusBadRequest)
+		return
+	}
+	date := time.Now().Format("2006-01-02")
+	if payload.Date != "" {
+		if _, err := time.Parse("2006-01-02", payload.Date); err != nil {
+			http.Error(w, "Invalid date format (expected YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		date = payload.Date
+	}
+	if payload.FromUserProfileID != 0 && payload.FromUserProfileID != user.UserProfileID {
+		http.Error(w, "Only the payer can record a settlement", http.StatusForbidden)
+		return
+	}
+	if payload.CurrencyID == 0 {
+		payload.CurrencyID = 1
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	toID, err := resolveSplitUser(database, user.UserProfileID, payload.UserGroupID, payload.ToUserProfileID, payload.ToEmailAddress)
+	if err != nil {
+		log.Println("CreateSplitSettlement: Error resolving receiver:", err)
+		http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+		return
+	}
+	fromID := user.UserProfileID
+	if toID == 0 {
+		http.Error(w, "Receiver not found in the group or your split contacts", http.StatusBadRequest)
+		return
+	}
+	if fromID == toID {
+		http.Error(w, "A user can't settle with themselves", http.StatusBadRequest)
+		return
+	}
+
+	// Both sides must share a split expense, inside the group when one is given
+	var shared bool
+	err = database.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM SplitExpense se
+			WHERE ($3::INT IS NULL OR se.UserGroupID = $3)
+			AND (se.PaidByUserProfileID = $1 OR EXISTS (SELECT 1 FROM SplitExpenseShare s WHERE s.SplitExpenseID = se.SplitExpenseID AND s.UserProfileID = $1))
+			AND (se.PaidByUserProfileID = $2 OR EXISTS (SELECT 1 FROM SplitExpenseShare s WHERE s.SplitExpenseID = se.SplitExpenseID AND s.UserProfileID = $2))
+		)`, fromID, toID, payload.UserGroupID).Scan(&shared)
+	if err != nil {
+		log.Println("CreateSplitSettlement: Error checking shared expenses:", err)
+		http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+		return
+	}
+	if !shared {
+		http.Error(w, "The users don't share any split expense", http.StatusBadRequest)
+		return
+	}
+	if payload.UserGroupID != nil {
+		role, err := groupRole(database, user.UserProfileID, *payload.UserGroupID)
+		if err != nil {
+			log.Println("CreateSplitSettlement: Error checking role:", err)
+			http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+			return
+		}
+		if role == "" || role == groupRoleViewer {
+			http.Error(w, "Only group owners and members can record settlements in the group", http.StatusForbidden)
+			return
+		}
+	}
+
+	names, err := userNames(database, []int64{int64(fromID), int64(toID)})
+	if err != nil {
+		log.Println("CreateSplitSettlement: Error loading names:", err)
+		http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("CreateSplitSettlement: Error starting transaction:", err)
+		http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	insertActual := func(itemID int, note string) (int, error) {
+		var actualID int
+		err := tx.QueryRow(`
+			INSERT INTO UserFinancialActual (FinancialUserItemID, UserFinancialActualtBeginDate, UserFinancialActualAmount, CurrencyID, Note)
+			VALUES ($1, $2, $3, $4, $5) RETURNING UserFinancialActualID`,
+			itemID, date, payload.Amount, payload.CurrencyID, note).Scan(&actualID)
+		return actualID, err
+	}
+
+	var fromItem, toItem, fromActual, toActual, settlementID int
+	fromItem, err = settlementItem(tx, fromID, 6)
+	if err == nil {
+		toItem, err = settlementItem(tx, toID, 5)
+	}
+	if err == nil {
+		fromActual, err = insertActual(fromItem, "Settlement paid to "+names[toID])
+	}
+	if err == nil {
+		toActual, err = insertActual(toItem, "Settlement received from "+names[fromID])
+	}
+	if err == nil {
+		err = tx.QueryRow(`
+			INSERT INTO SplitSettlement (FromUserProfileID, ToUserProfileID, UserGroupID, SettlementAmount, CurrencyID,
+				SettlementDate, FromUserFinancialActualID, ToUserFinancialActualID, CreatedByUserProfileID)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING SplitSettlementID`,
+			fromID, toID, payload.UserGroupID, payload.Amount, payload.CurrencyID, date, fromActual, toActual,
+			user.UserProfileID).Scan(&settlementID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("CreateSplitSettlement: Error recording settlement:", err)
+		http.Error(w, "Failed to record settlement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                        "success",
+		"message":                       "Settlement recorded successfully",
+		"split_settlement_id":           settlementID,
+		"from_user_financial_actual_id": fromActual,
+		"to_user_financial_actual_id":   toActual,
+	})
+}
+
+// SplitSettlements lists the settlements visible to the user, optionally of one group (?groupId=)
+func SplitSettlements(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SplitSettlements: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	groupID, status, err := splitGroupFilter(database, r, user.UserProfileID)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			log.Println("SplitSettlements: Error checking group:", err)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	rows, err := database.Query(`
+		SELECT ss.SplitSettlementID, ss.FromUserProfileID, fp.FirstName || ' ' || fp.LastName,
+			ss.ToUserProfileID, tp.FirstName || ' ' || tp.LastName, ss.UserGroupID, ss.SettlementAmount, ss.CurrencyID,
+			ss.SettlementDate, ss.FromUserFinancialActualID, ss.ToUserFinancialActualID, ss.CreatedAt
+		FROM SplitSettlement ss
+		JOIN UserProfile fp ON fp.UserProfileID = ss.FromUserProfileID
+		JOIN UserProfile tp ON tp.UserProfileID = ss.ToUserProfileID
+		WHERE `+splitSettlementCondition+` AND ($2::INT IS NULL OR ss.UserGroupID = $2)
+		ORDER BY ss.SettlementDate DESC, ss.SplitSettlementID DESC`, user.UserProfileID, groupID)
+	if err != nil {
+		log.Println("SplitSettlements: Error fetching settlements:", err)
+		http.Error(w, "Error fetching settlements", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	settlements := []models.SplitSettlement{}
+	for rows.Next() {
+		var s models.SplitSettlement
+		if err := rows.Scan(&s.SplitSettlementID, &s.FromUserProfileID, &s.FromName, &s.ToUserProfileID, &s.ToName,
+			&s.UserGroupID, &s.SettlementAmount, &s.CurrencyID, &s.SettlementDate, &s.FromUserFinancialActualID,
+			&s.ToUserFinancialActualID, &s.CreatedAt); err != nil {
+			log.Println("SplitSettlements: Error scanning settlement:", err)
+			http.Error(w, "Error fetching settlements", http.StatusInternalServerError)
+			return
+		}
+		settlements = append(settlements, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"split_settlements": settlements})
+}
+
+// DeleteSplitSettlement deletes a settlement and the actuals it booked, allowed to both sides
+func DeleteSplitSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteSplitSettlement: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		SplitSettlementID int `json:"splitSettlementId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.SplitSettlementID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("DeleteSplitSettlement: Error starting transaction:", err)
+		http.Error(w, "Failed to delete settlement", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var fromActual, toActual sql.NullInt64
+	err = tx.QueryRow(`
+		DELETE FROM SplitSettlement
+		WHERE SplitSettlementID = $2 AND (FromUserProfileID = $1 OR ToUserProfileID = $1)
+		RETURNING FromUserFinancialActualID, ToUserFinancialActualID`,
+		user.UserProfileID, payload.SplitSettlementID).Scan(&fromActual, &toActual)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Settlement not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	for _, actual := range []sql.NullInt64{fromActual, toActual} {
+		if err == nil && actual.Valid {
+			_, err = tx.Exec(`DELETE FROM UserForecastActualRelation WHERE UserFinancialActualID = $1`, actual.Int64)
+			if err == nil {
+				_, err = tx.Exec(`DELETE FROM UserFinancialActual WHERE UserFinancialActualID = $1`, actual.Int64)
+			}
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DeleteSplitSettlement: Error deleting settlement:", err)
+		http.Error(w, "Failed to delete settlement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Settlement deleted successfully"})
+}
+
+// SplitContactRequest asks a user, by email, to split expenses outside a group. The response is the same whether the
+// email has an account or not, so the request never tells which accounts exist.
+func SplitContactRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SplitContactRequest: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		EmailAddress string `json:"emailAddress"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	payload.EmailAddress = strings.ToLower(strings.TrimSpace(payload.EmailAddress))
+	if payload.EmailAddress == "" {
+		http.Error(w, "emailAddress is required", http.StatusBadRequest)
+		return
+	}
+	if payload.EmailAddress == strings.ToLower(user.EmailAddress) {
+		http.Error(w, "A user can't be their own split contact", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	// A new request replaces the pending one of the same email
+	var contactID int
+	_, err := database.Exec(`
+		DELETE FROM UserSplitContact
+		WHERE UserProfileID = $1 AND LOWER(InvitedEmailAddress) = $2 AND ContactStatus = 'pending'`,
+		user.UserProfileID, payload.EmailAddress)
+	if err == nil {
+		err = database.QueryRow(`
+			INSERT INTO UserSplitContact (UserProfileID, InvitedEmailAddress) VALUES ($1, $2)
+			RETURNING UserSplitContactID`, user.UserProfileID, payload.EmailAddress).Scan(&contactID)
+	}
+	if err != nil {
+		log.Println("SplitContactRequest: Error creating request:", err)
+		http.Error(w, "Failed to request split contact", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                "success",
+		"message":               "Split contact requested, it can be used once accepted",
+		"user_split_contact_id": contactID,
+	})
+}
+
+// SplitContacts lists the split contact requests the logged-in user sent and the ones sent to their email
+func SplitContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("SplitContacts: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	// Declined requests are only shown to whoever declined them, so the sender can't tell a declined request from
+	// an email without account
+	rows, err := database.Query(`
+		SELECT c.UserSplitContactID, 'sent', c.ContactUserProfileID, COALESCE(up.FirstName || ' ' || up.LastName, ''),
+			c.InvitedEmailAddress, CASE WHEN c.ContactStatus = 'declined' THEN 'pending' ELSE c.ContactStatus END, c.CreatedAt
+		FROM UserSplitContact c
+		LEFT JOIN UserProfile up ON up.UserProfileID = c.ContactUserProfileID AND c.ContactStatus = 'accepted'
+		WHERE c.UserProfileID = $1
+		UNION ALL
+		SELECT c.UserSplitContactID, 'received', c.UserProfileID, up.FirstName || ' ' || up.LastName,
+			up.EmailAddress, c.ContactStatus, c.CreatedAt
+		FROM UserSplitContact c
+		JOIN UserProfile up ON up.UserProfileID = c.UserProfileID
+		WHERE c.ContactUserProfileID = $1 OR (c.ContactStatus = 'pending' AND LOWER(c.InvitedEmailAddress) = LOWER($2))
+		ORDER BY 7`, user.UserProfileID, user.EmailAddress)
+	if err != nil {
+		log.Println("SplitContacts: Error fetching split contacts:", err)
+		http.Error(w, "Error fetching split contacts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	contacts := []models.UserSplitContact{}
+	for rows.Next() {
+		var contact models.UserSplitContact
+		var contactUserID sql.NullInt64
+		var createdAt time.Time
+		if err := rows.Scan(&contact.UserSplitContactID, &contact.Direction, &contactUserID, &contact.ContactName,
+			&contact.EmailAddress, &contact.ContactStatus, &createdAt); err != nil {
+			log.Println("SplitContacts: Error scanning split contact:", err)
+			http.Error(w, "Error fetching split contacts", http.StatusInternalServerError)
+			return
+		}
+		if contactUserID.Valid && contact.ContactStatus == "accepted" {
+			id := int(contactUserID.Int64)
+			contact.ContactUserProfileID = &id
+		}
+		contact.CreatedAt = createdAt.Format(time.RFC3339)
+		contacts = append(contacts, contact)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("SplitContacts: Error fetching split contacts:", err)
+		http.Error(w, "Error fetching split contacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"split_contacts": contacts})
+}
+
+// RespondSplitContact accepts or declines a split contact request sent to the email of the logged-in user
+func RespondSplitContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RespondSplitContact: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserSplitContactID int  `json:"userSplitContactId"`
+		Accept             bool `json:"accept"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserSplitContactID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	status, message := "declined", "Split contact declined"
+	if payload.Accept {
+		status, message = "accepted", "Split contact accepted"
+	}
+	result, err := database.Exec(`
+		UPDATE UserSplitContact SET ContactStatus = $3, ContactUserProfileID = $1, RespondedAt = CURRENT_TIMESTAMP
+		WHERE UserSplitContactID = $2 AND ContactStatus = 'pending' AND LOWER(InvitedEmailAddress) = LOWER($4)
+			AND UserProfileID <> $1`,
+		user.UserProfileID, payload.UserSplitContactID, status, user.EmailAddress)
+	if err != nil {
+		log.Println("RespondSplitContact: Error responding to request:", err)
+		http.Error(w, "Failed to respond to split contact", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Split contact request not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": message})
+}
+
+// DeleteSplitContact removes a split contact, allowed to both sides. Split expenses and settlements already
+// recorded are kept.
+func DeleteSplitContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("DeleteSplitContact: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserSplitContactID int `json:"userSplitContactId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.UserSplitContactID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`
+		DELETE FROM UserSplitContact
+		WHERE UserSplitContactID = $2 AND (UserProfileID = $1 OR ContactUserProfileID = $1)`,
+		user.UserProfileID, payload.UserSplitContactID)
+	if err != nil {
+		log.Println("DeleteSplitContact: Error deleting split contact:", err)
+		http.Error(w, "Failed to delete split contact", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Split contact not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Split contact deleted successfully"})
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/tax"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// taxRuleFor resolves the rule of a TaxType. ok is false when the TaxType has no rule and its amounts are
+// typed by the user.
+func taxRuleFor(q queryer, taxTypeID int) (rule tax.Rule, ok bool, err error) {
+	t := tax.TaxType{TaxTypeID: taxTypeID}
+	var percentage sql.NullFloat64
+	var definition []byte
+	err = q.QueryRow(`
+		SELECT tt.TaxTypeName, tt.TaxCountry, tt.TaxJurisdiction, tt.TaxPercentage, ttr.RuleDefinition
+		FROM TaxType tt
+		LEFT JOIN TaxTypeRule ttr ON ttr.TaxTypeID = tt.TaxTypeID
+		WHERE tt.TaxTypeID = $1`, taxTypeID).Scan(
+		&t.TaxTypeName, &t.TaxCountry, &t.TaxJurisdiction, &percentage, &definition)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if percentage.Valid {
+		t.TaxPercentage = &percentage.Float64
+	}
+	t.Definition = definition
+	return tax.Resolve(t)
+}
+
+// taxBaseForecast is a forecast of the item a tax applies to
+type taxBaseForecast struct {
+	categoryID sql.NullInt64
+	begin      time.Time
+	end        sql.NullTime
+	amount     float64
+	currencyID int
+}
+
+// loadTaxBaseForecasts loads the forecasts of an item starting on from
+func loadTaxBaseForecasts(q queryer, itemID int, from time.Time) ([]taxBaseForecast, error) {
+	rows, err := q.Query(`
+		SELECT UserCategoryID, UserFinancialForecastBeginDate, UserFinancialForecastEndDate,
+			UserFinancialForecastAmount, CurrencyID
+		FROM UserFinancialForecast
+		WHERE FinancialUserItemID = $1 AND UserFinancialForecastBeginDate >= $2
+		ORDER BY UserFinancialForecastBeginDate`, itemID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var forecasts []taxBaseForecast
+	for rows.Next() {
+		var f taxBaseForecast
+		if err := rows.Scan(&f.categoryID, &f.begin, &f.end, &f.amount, &f.currencyID); err != nil {
+			return nil, err
+		}
+		forecasts = append(forecasts, f)
+	}
+	return forecasts, rows.Err()
+}
+
+// salaryIncomeTypeID is the Salary IncomeType from the seed
+const salaryIncomeTypeID = 1
+
+// withholdingContext tells whether an item is a user Salary income and how many IRPF dependents its owner has
+func withholdingContext(q queryer, itemID int) (salary bool, dependents int, err error) {
+	var entityID, incomeTypeID, ownerID int
+	err = q.QueryRow(`
+		SELECT fui.EntityID, COALESCE(fui.FinancialUserEntityItemID, 0), COALESCE(ua.UserProfileID, fui.UserEntityID)
+		FROM FinancialUserItem fui
+		LEFT JOIN UserAsset ua ON fui.EntityID IN (9, 10, 11, 12, 13) AND ua.UserAssetID = fui.UserEntityID
+		WHERE fui.FinancialUserItemID = $1`, itemID).Scan(&entityID, &incomeTypeID, &ownerID)
+	if err == sql.ErrNoRows {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	err = q.QueryRow(`SELECT COALESCE(MAX(IRPFDependents), 0) FROM UserTaxProfile WHERE UserProfileID = $1`,
+		ownerID).Scan(&dependents)
+	return entityID == 5 && incomeTypeID == salaryIncomeTypeID, dependents, err
+}
+
+// recomputeChildTaxes computes the forecasts of the tax children (EntityIDs 7 and 12) of an item from the
+// parent forecasts starting on from: each parent forecast gets a tax forecast with the same dates, currency
+// and category. childItemID restricts it to one child, 0 means all of them. Children whose TaxType has no
+// rule keep the amounts typed by the user. It returns the number of tax forecasts written.
+func recomputeChildTaxes(q queryer, parentItemID, childItemID int, from time.Time) (int, error) {
+	forecasts, err := loadTaxBaseForecasts(q, parentItemID, from)
+	if err != nil {
+		return 0, err
+	}
+	salary, dependents, err := withholdingContext(q, parentItemID)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := q.Query(`
+		SELECT FinancialUserItemID, FinancialUserEntityItemID
+		FROM FinancialUserItem
+		WHERE ParentFinancialUserItemID = $1 AND EntityID IN (7, 12)
+			AND ($2 = 0 OR FinancialUserItemID = $2)`, parentItemID, childItemID)
+	if err != nil {
+		return 0, err
+	}
+	children := map[int]int{}
+	for rows.Next() {
+		var itemID int
+		var taxTypeID sql.NullInt64
+		if err := rows.Scan(&itemID, &taxTypeID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if taxTypeID.Valid {
+			children[itemID] = int(taxTypeID.Int64)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for itemID, taxTypeID := range children {
+		rule, ok, err := taxRuleFor(q, taxTypeID)
+		if err != nil {
+			return written, err
+		}
+		if !ok {
+			continue
+		}
+		// The IRPF withheld from a salary deducts the INSS and the owner's dependents
+		if withholding, ok := rule.(tax.IRPFWithholding); ok {
+			withholding.Salary, withholding.Dependents = salary, dependents
+			rule = withholding
+		}
+
+		for _, f := range forecasts {
+			amount := tax.ComputeOn(rule, f.amount, f.begin)
+			result, err := q.Exec(`
+				UPDATE UserFinancialForecast
+				SET UserFinancialForecastAmount = $3, UserFinancialForecastEndDate = $4, CurrencyID = $5
+				WHERE FinancialUserItemID = $1 AND UserFinancialForecastBeginDate = $2`,
+				itemID, f.begin, amount, f.end, f.currencyID)
+			if err != nil {
+				return written, err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				_, err = q.Exec(`
+					INSERT INTO UserFinancialForecast (UserCategoryID, FinancialUserItemID, UserFinancialForecastBeginDate,
+						UserFinancialForecastEndDate, UserFinancialForecastAmount, CurrencyID)
+					VALUES ($1, $2, $3, $4, $5, $6)`,
+					f.categoryID, itemID, f.begin, f.end, amount, f.currencyID)
+				if err != nil {
+					return written, err
+				}
+			}
+			written++
+		}
+	}
+	return written, nil
+}
+
+// UpdateTaxRule stores the rule definition of a TaxType, which then computes the amounts of its taxes
+func UpdateTaxRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := r.Context().Value("user").(models.UserProfile); !ok {
+		log.Println("UpdateTaxRule: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		TaxTypeID int            `json:"taxTypeId"`
+		Rule      tax.Definition `json:"rule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.TaxTypeID == 0 {
+		http.Error(w, "taxTypeId is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := payload.Rule.Rule(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	definition, _ := json.Marshal(payload.Rule)
+	result, err := database.Exec(`
+		INSERT INTO TaxTypeRule (TaxTypeID, RuleDefinition)
+		SELECT TaxTypeID, $2 FROM TaxType WHERE TaxTypeID = $1
+		ON CONFLICT (TaxTypeID) DO UPDATE SET RuleDefinition = EXCLUDED.RuleDefinition, CreatedAt = CURRENT_TIMESTAMP`,
+		payload.TaxTypeID, definition)
+	if err != nil {
+		log.Println("UpdateTaxRule: Error saving rule:", err)
+		http.Error(w, "Failed to update tax rule", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Tax type not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Tax rule updated successfully"})
+}
+
+// DeleteTaxRule removes the stored rule of a TaxType, falling back to the rule registered in code or its TaxPercentage
+func DeleteTaxRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := r.Context().Value("user").(models.UserProfile); !ok {
+		log.Println("DeleteTaxRule: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		TaxTypeID int `json:"taxTypeId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.TaxTypeID == 0 {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	result, err := database.Exec(`DELETE FROM TaxTypeRule WHERE TaxTypeID = $1`, payload.TaxTypeID)
+	if err != nil {
+		log.Println("DeleteTaxRule: Error deleting rule:", err)
+		http.Error(w, "Failed to delete tax rule", http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Tax rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Tax rule deleted successfully"})
+}
+
+// ComputeWithholding creates or refreshes the INSS and IRPF children of a Salary income with the amounts
+// withheld in each forecast month. The optional body {"dependents": n} updates the user's IRPF dependents.
+func ComputeWithholding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("ComputeWithholding: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || itemID <= 0 {
+		http.Error(w, "Invalid income ID", http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Dependents *int `json:"dependents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Dependents != nil && *payload.Dependents < 0 {
+		http.Error(w, "dependents cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("ComputeWithholding: Error starting transaction:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var incomeTypeID, recurrencyID int
+	err = tx.QueryRow(`
+		SELECT COALESCE(fui.FinancialUserEntityItemID, 0), fui.RecurrencyID
+		FROM FinancialUserItem fui
+		WHERE fui.FinancialUserItemID = $2 AND fui.EntityID = 5 AND `+ownedItemCondition,
+		user.UserProfileID, itemID).Scan(&incomeTypeID, &recurrencyID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Income not found or unauthorized", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("ComputeWithholding: Error fetching income:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+	if incomeTypeID != salaryIncomeTypeID {
+		http.Error(w, "Withholding only applies to Salary incomes", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Dependents != nil {
+		_, err = tx.Exec(`
+			INSERT INTO UserTaxProfile (UserProfileID, IRPFDependents) VALUES ($1, $2)
+			ON CONFLICT (UserProfileID) DO UPDATE SET IRPFDependents = EXCLUDED.IRPFDependents`,
+			user.UserProfileID, *payload.Dependents)
+		if err != nil {
+			log.Println("ComputeWithholding: Error saving dependents:", err)
+			http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// The INSS and IRPF children are created the same way CreateUserChildIncomeTax does
+	taxItems := map[string]int{}
+	for _, name := range []string{"INSS", "IRPF"} {
+		var taxTypeID int
+		err = tx.QueryRow(`
+			SELECT TaxTypeID FROM TaxType
+			WHERE TaxTypeName = $1 AND TaxCountry = 'Brazil' AND TaxJurisdiction = 'Federal'
+			ORDER BY TaxTypeID LIMIT 1`, name).Scan(&taxTypeID)
+		if err != nil {
+			log.Printf("ComputeWithholding: Error fetching the %s tax type: %v\n", name, err)
+			http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+			return
+		}
+
+		var childItemID int
+		err = tx.QueryRow(`
+			SELECT fui.FinancialUserItemID FROM FinancialUserItem fui
+			WHERE fui.ParentFinancialUserItemID = $2 AND fui.EntityID = 7 AND fui.FinancialUserEntityItemID = $3 AND `+ownedItemCondition+`
+			ORDER BY fui.FinancialUserItemID LIMIT 1`, user.UserProfileID, itemID, taxTypeID).Scan(&childItemID)
+		if err == sql.ErrNoRows {
+			err = tx.QueryRow(`
+				INSERT INTO FinancialUserItem (FinancialUserItemName, EntityID, UserEntityID, RecurrencyID,
+					FinancialUserEntityItemID, ParentFinancialUserItemID)
+				VALUES ($1, 7, $2, $3, $4, $5)
+				RETURNING FinancialUserItemID`,
+				name, user.UserProfileID, recurrencyID, taxTypeID, itemID).Scan(&childItemID)
+		}
+		if err != nil {
+			log.Printf("ComputeWithholding: Error creating the %s item: %v\n", name, err)
+			http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+			return
+		}
+		taxItems[name] = childItemID
+	}
+
+	if _, err := recomputeChildTaxes(tx, itemID, 0, time.Time{}); err != nil {
+		log.Println("ComputeWithholding: Error computing tax forecasts:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+
+	// Breakdown of each forecast month
+	forecasts, err := loadTaxBaseForecasts(tx, itemID, time.Time{})
+	if err != nil {
+		log.Println("ComputeWithholding: Error fetching forecasts:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+	_, dependents, err := withholdingContext(tx, itemID)
+	if err != nil {
+		log.Println("ComputeWithholding: Error fetching dependents:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+	months := make([]tax.Withholding, 0, len(forecasts))
+	for _, f := range forecasts {
+		months = append(months, tax.Withhold(f.begin, f.amount, dependents, true))
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println("ComputeWithholding: Error committing transaction:", err)
+		http.Error(w, "Failed to compute withholding", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       "success",
+		"income_id":    itemID,
+		"dependents":   dependents,
+		"inss_item_id": taxItems["INSS"],
+		"irpf_item_id": taxItems["IRPF"],
+		"months":       months,
+	})
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTrashRetentionDays is how long deleted items stay in the trash when no retention is configured
+const DefaultTrashRetentionDays = 30
+
+// trashRetentionDays is the retention used by the purge job and shown in the trash
+var trashRetentionDays = DefaultTrashRetentionDays
+
+// trashPurgeInterval is how often the retention job runs
+const trashPurgeInterval = 24 * time.Hour
+
+// trashedWithCondition matches the FinancialUserItem alias "i" when it's the trashed item "fui" or one of the
+// children that went to the trash with it
+const trashedWithCondition = `(i.FinancialUserItemID = fui.FinancialUserItemID
+		OR (i.ParentFinancialUserItemID = fui.FinancialUserItemID AND i.DeletedAt = fui.DeletedAt))`
+
+// moveItemToTrash soft deletes an item of the user with the children that aren't in the trash yet, keeping their
+// forecasts and actuals. It returns false when the item doesn't exist, isn't the user's or is already in the trash.
+func moveItemToTrash(q queryer, userID, itemID int) (bool, error) {
+	result, err := q.Exec(`
+		UPDATE FinancialUserItem fui
+		SET DeletedWasActive = fui.IsActive, IsActive = FALSE, DeletedAt = CURRENT_TIMESTAMP
+		WHERE (fui.FinancialUserItemID = $2 OR fui.ParentFinancialUserItemID = $2)
+			AND EXISTS (SELECT 1 FROM FinancialUserItem item WHERE item.FinancialUserItemID = $2 AND item.DeletedAt IS NULL)
+			AND `+ownedItemCondition, userID, itemID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// PurgeTrash deletes for good the items that have been in the trash for more than retentionDays, with their
+// children, forecasts, actuals and relations. It returns how many items were deleted.
+func PurgeTrash(database *sql.DB, retentionDays int) (int, error) {
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var ids pq.Int64Array
+	err = tx.QueryRow(`
+		SELECT COALESCE(ARRAY_AGG(DISTINCT i.FinancialUserItemID), '{}')
+		FROM FinancialUserItem fui
+		JOIN FinancialUserItem i ON i.FinancialUserItemID = fui.FinancialUserItemID OR i.ParentFinancialUserItemID = fui.FinancialUserItemID
+		WHERE fui.DeletedAt < CURRENT_TIMESTAMP - make_interval(days => $1)`, retentionDays).Scan(&ids)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	statements := []string{
+		`DELETE FROM UserForecastActualRelation
+		WHERE UserFinancialForecastID IN (SELECT UserFinancialForecastID FROM UserFinancialForecast WHERE FinancialUserItemID = ANY($1))
+			OR UserFinancialActualID IN (SELECT UserFinancialActualID FROM UserFinancialActual WHERE FinancialUserItemID = ANY($1))`,
+		`DELETE FROM UserFinancialActual WHERE FinancialUserItemID = ANY($1)`,
+		`DELETE FROM UserFinancialForecast WHERE FinancialUserItemID = ANY($1)`,
+		`DELETE FROM FinancialUserItem WHERE FinancialUserItemID = ANY($1) AND ParentFinancialUserItemID IS NOT NULL`,
+		`DELETE FROM FinancialUserItem WHERE FinancialUserItemID = ANY($1)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, ids); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// StartTrashRetention sets the retention of the trash and purges the expired items now and then once a day
+func StartTrashRetention(retentionDays int) {
+	if retentionDays <= 0 {
+		retentionDays = DefaultTrashRetentionDays
+	}
+	trashRetentionDays = retentionDays
+
+	go func() {
+		for {
+			purged, err := PurgeTrash(db.GetDB(), retentionDays)
+			if err != nil {
+				log.Println("TrashRetention: Error purging trash:", err)
+			} else if purged > 0 {
+				log.Printf("TrashRetention: Purged %d items deleted more than %d days ago", purged, retentionDays)
+			}
+			time.Sleep(trashPurgeInterval)
+		}
+	}()
+}
+
+// Trash lists the deleted items of the logged-in user and of their groups. Children deleted with their parent are
+// listed under it.
+func Trash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("Trash: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	rows, err := database.Query(`
+		SELECT
+			fui.FinancialUserItemID,
+			fui.FinancialUserItemName,
+			fui.EntityID,
+			e.EntityType,
+			fui.DeletedAt,
+			(SELECT COUNT(*) FROM FinancialUserItem i
+				WHERE i.ParentFinancialUserItemID = fui.FinancialUserItemID AND i.DeletedAt = fui.DeletedAt),
+			(SELECT COUNT(*) FROM UserFinancialForecast uff JOIN FinancialUserItem i ON uff.FinancialUserItemID = i.FinancialUserItemID
+				WHERE `+trashedWithCondition+`),
+			(SELECT COUNT(*) FROM UserFinancialActual ufa JOIN FinancialUserItem i ON ufa.FinancialUserItemID = i.FinancialUserItemID
+				WHERE `+trashedWithCondition+`)
+		FROM FinancialUserItem fui
+		JOIN Entity e ON fui.EntityID = e.EntityID
+		LEFT JOIN FinancialUserItem parent ON parent.FinancialUserItemID = fui.ParentFinancialUserItemID
+		WHERE fui.DeletedAt IS NOT NULL AND (parent.DeletedAt IS NULL OR parent.DeletedAt <> fui.DeletedAt)
+			AND (`+ownedOrTrashedItemCondition+` OR `+groupOrTrashedItemCondition+`)
+		ORDER BY fui.DeletedAt DESC, fui.FinancialUserItemID`, user.UserProfileID)
+	if err != nil {
+		log.Println("Trash: Error fetching trash:", err)
+		http.Error(w, "Error fetching trash", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+	for rows.Next() {
+		var item models.TrashItem
+		var deletedAt time.Time
+		if err := rows.Scan(&item.FinancialUserItemID, &item.FinancialUserItemName, &item.EntityID, &item.EntityType,
+			&deletedAt, &item.Children, &item.Forecasts, &item.Actuals); err != nil {
+			log.Println("Trash: Error scanning trash:", err)
+			http.Error(w, "Error fetching trash", http.StatusInternalServerError)
+			return
+		}
+		item.DeletedAt = deletedAt.Format(time.RFC3339)
+		item.PurgeAt = deletedAt.AddDate(0, 0, trashRetentionDays).Format(time.RFC3339)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("Trash: Error fetching trash:", err)
+		http.Error(w, "Error fetching trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(items)
+}
+
+// RestoreTrashItem brings an item back from the trash with the children deleted with it, allowed to the owner of the
+// item and to the owners and members of its group: POST /api/trash/{id}/restore
+func RestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("RestoreTrashItem: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	itemID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || itemID <= 0 {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	tx, err := beginAudit(database, r, user.UserProfileID)
+	if err != nil {
+		log.Println("RestoreTrashItem: Error starting transaction:", err)
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback() // Ensure rollback on error
+
+	var deletedAt time.Time
+	var parentDeleted bool
+	err = tx.QueryRow(`
+		SELECT fui.DeletedAt, parent.DeletedAt IS NOT NULL
+		FROM FinancialUserItem fui
+		LEFT JOIN FinancialUserItem parent ON parent.FinancialUserItemID = fui.ParentFinancialUserItemID
+		WHERE fui.FinancialUserItemID = $2 AND fui.DeletedAt IS NOT NULL
+			AND (`+ownedOrTrashedItemCondition+` OR `+managedGroupItemCondition+`)
+		FOR UPDATE OF fui`, user.UserProfileID, itemID).Scan(&deletedAt, &parentDeleted)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Item not found in the trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println("RestoreTrashItem: Error fetching item:", err)
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+	if parentDeleted {
+		http.Error(w, "The parent item is in the trash, restore it first", http.StatusConflict)
+		return
+	}
+
+	result, err := tx.Exec(`
+		UPDATE FinancialUserItem
+		SET IsActive = COALESCE(DeletedWasActive, TRUE), DeletedAt = NULL, DeletedWasActive = NULL
+		WHERE FinancialUserItemID = $1 OR (ParentFinancialUserItemID = $1 AND DeletedAt = $2)`, itemID, deletedAt)
+	var restored int64
+	if err == nil {
+		restored, err = result.RowsAffected()
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("RestoreTrashItem: Error restoring item:", err)
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "success",
+		"message":        "Item restored successfully",
+		"restored_items": restored,
+	})
+}
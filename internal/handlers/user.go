@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"finanapp/internal/db"
+	"finanapp/internal/fx"
 	"finanapp/internal/models"
 	"finanapp/internal/utils"
 	"log"
@@ -173,6 +175,18 @@ func UserIncome(w http.ResponseWriter, r *http.Request) {
 	// Get Database
 	database := db.GetDB()
 
+	// Optional currency to convert the amounts to
+	rates, currencyCode, err := requestedCurrency(r, database)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnknownCurrency) {
+			http.Error(w, "Invalid currency", http.StatusBadRequest)
+			return
+		}
+		log.Println("Error loading exchange rates:", err)
+		http.Error(w, "Error loading exchange rates", http.StatusInternalServerError)
+		return
+	}
+
 	// Query currency
 	currencyQuery := `SELECT currencyid, currencyname, currencyabreviation, currencysymbol, createdat FROM currency`
 	currencyRows, err := database.Query(currencyQuery)
@@ -279,7 +293,7 @@ func UserIncome(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN 
 			incometype it ON f.UserEntityID = it.incometypeid
 		WHERE 
-			f.UserEntityID = $1 AND e.entityname = 'User' AND f.EntityID = 5
+			f.UserEntityID = $1 AND e.entityname = 'User' AND f.EntityID = 5 AND f.DeletedAt IS NULL
 	`
 
 	// Passing the logged-in user's ID to filter the SQL query
@@ -314,6 +328,60 @@ func UserIncome(w http.ResponseWriter, r *http.Request) {
 		financialUserItems = append(financialUserItems, fui)
 	}
 
+	// Query the current forecast of each item: the latest one already started, or the next one
+	currentAmountQuery := `
+		SELECT DISTINCT ON (uff.FinancialUserItemID)
+			uff.FinancialUserItemID,
+			uff.UserFinancialForecastAmount,
+			uff.CurrencyID
+		FROM
+			userfinancialforecast uff
+		JOIN
+			financialuseritem f ON uff.FinancialUserItemID = f.FinancialUserItemID
+		WHERE
+			f.UserEntityID = $1 AND f.EntityID = 5
+		ORDER BY
+			uff.FinancialUserItemID,
+			uff.UserFinancialForecastBeginDate > CURRENT_DATE,
+			ABS(uff.UserFinancialForecastBeginDate - CURRENT_DATE)
+	`
+	currentAmountRows, err := database.Query(currentAmountQuery, user.UserProfileID)
+	if err != nil {
+		log.Println("Error fetching current amounts:", err)
+		http.Error(w, "Error fetching current amounts", http.StatusInternalServerError)
+		return
+	}
+	defer currentAmountRows.Close()
+
+	type currentAmount struct {
+		Amount     float64
+		CurrencyID int
+	}
+	currentAmounts := map[int]currentAmount{}
+	for currentAmountRows.Next() {
+		var itemID int
+		var ca currentAmount
+		if err := currentAmountRows.Scan(&itemID, &ca.Amount, &ca.CurrencyID); err != nil {
+			log.Println("Error scanning current amount:", err)
+			continue
+		}
+		currentAmounts[itemID] = ca
+	}
+
+	for i := range financialUserItems {
+		fui := &financialUserItems[i]
+		ca, ok := currentAmounts[fui.FinancialUserItemID]
+		if !ok {
+			continue
+		}
+		fui.CurrentAmount = &ca.Amount
+		fui.CurrentCurrencyID = &ca.CurrencyID
+		if rates != nil {
+			fui.ConvertedAmount = convertAmount(rates, currencyCode, ca.Amount, ca.CurrencyID, time.Now())
+			fui.ConvertedCurrency = currencyCode
+		}
+	}
+
 	// Create final response
 	response := struct {
 		Currency           []models.Currency          `json:"currency"`
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/models"
+	"finanapp/internal/valuation"
+	"log"
+	"net/http"
+	"time"
+)
+
+// assetValuation is the valuation model of an asset with its projected values for the next months
+type assetValuation struct {
+	UserAssetID    int               `json:"userAssetId"`
+	AssetTypeID    int               `json:"assetTypeId"`
+	Model          valuation.Model   `json:"model"`
+	AllowedMethods []string          `json:"allowedMethods"`
+	Cost           float64           `json:"cost"`
+	CurrentValue   float64           `json:"currentValue"`
+	Projection     []valuation.Point `json:"projection"`
+}
+
+// loadAssetValuation loads the model of an asset owned by the user and projects its value from the latest
+// valuation. It returns nil when the asset doesn't exist or belongs to someone else.
+func loadAssetValuation(database *sql.DB, userID, assetID, months int) (*assetValuation, error) {
+	result := assetValuation{UserAssetID: assetID}
+	var acquired time.Time
+	var value float64
+	err := database.QueryRow(`
+		SELECT ua.AssetTypeID, ua.UserAssetAcquisitionBeginDate, ua.UserAssetValueAmount,
+			COALESCE(m.ValuationMethod, 'manual'), COALESCE(m.ValuationRate, 0),
+			COALESCE(m.UsefulLifeYears, 0), COALESCE(m.SalvageValue, 0)
+		FROM UserAsset ua
+		LEFT JOIN UserAssetValuationModel m ON m.UserAssetID = ua.UserAssetID
+		WHERE ua.UserAssetID = $2 AND ua.UserProfileID = $1`, userID, assetID).Scan(
+		&result.AssetTypeID, &acquired, &value,
+		&result.Model.Method, &result.Model.Rate, &result.Model.UsefulLifeYears, &result.Model.SalvageValue)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The first valuation is the acquisition cost, the latest one up to today anchors the projection
+	now := time.Now()
+	cost, anchor := value, valuation.Anchor{Date: acquired, Amount: value}
+	rows, err := database.Query(`
+		SELECT UserAssetValuationDate, UserAssetValuationAmount
+		FROM UserAssetValuation
+		WHERE UserAssetID = $1
+		ORDER BY UserAssetValuationDate`, assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	first := true
+	for rows.Next() {
+		var v valuation.Anchor
+		if err := rows.Scan(&v.Date, &v.Amount); err != nil {
+			return nil, err
+		}
+		if first {
+			cost, anchor, first = v.Amount, v, false
+		}
+		if !v.Date.After(now) {
+			anchor = v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.AllowedMethods = valuation.MethodsFor(result.AssetTypeID)
+	result.Cost = cost
+	result.CurrentValue = result.Model.ValueAt(cost, anchor, now)
+	result.Projection = result.Model.Schedule(cost, anchor, now, months)
+	return &result, nil
+}
+
+// UpdateAssetValuationModel sets how the value of an asset changes over time
+func UpdateAssetValuationModel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Retrieve user from context
+	user, ok := r.Context().Value("user").(models.UserProfile)
+	if !ok {
+		log.Println("UpdateAssetValuationModel: Unauthorized access - no user found in context.")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		UserAssetID int `json:"userAssetId"`
+		valuation.Model
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		log.Println("UpdateAssetValuationModel: Error decoding request body:", err)
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.UserAssetID == 0 {
+		http.Error(w, "UserAssetID is required", http.StatusBadRequest)
+		return
+	}
+
+	// Get database connection
+	database := db.GetDB()
+
+	// The current model and valuations give the asset type and the acquisition cost
+	current, err := loadAssetValuation(database, user.UserProfileID, payload.UserAssetID, 0)
+	if err != nil {
+		log.Println("UpdateAssetValuationModel: Error fetching asset:", err)
+		http.Error(w, "Failed to update valuation model", http.StatusInternalServerError)
+		return
+	}
+	if current == nil {
+		http.Error(w, "Asset not found or unauthorized", http.StatusNotFound)
+		return
+	}
+
+	if err := payload.Model.Validate(current.AssetTypeID, current.Cost); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if payload.Method != valuation.Manual {
+		derived, err := assetHasHoldings(database, payload.UserAssetID)
+		if err != nil {
+			log.Println("UpdateAssetValuationModel: Error checking holdings:", err)
+			http.Error(w, "Failed to update valuation model", http.StatusInternalServerError)
+			return
+		}
+		if derived {
+			http.Error(w, "The value of this asset is derived from its holdings", http.StatusConflict)
+			return
+		}
+	}
+
+	// Only the parameters used by the method are stored
+	var rate sql.NullFloat64
+	var usefulLife sql.NullInt64
+	switch payload.Method {
+	case valuation.DecliningBalance, valuation.FixedAppreciation:
+		rate = sql.NullFloat64{Float64: payload.Rate, Valid: true}
+	case valuation.StraightLine:
+		usefulLife = sql.NullInt64{Int64: int64(payload.UsefulLifeYears), Valid: true}
+	}
+
+	_, err = database.Exec(`
+		INSERT INTO UserAssetValuationModel (UserAssetID, ValuationMethod, ValuationRate, UsefulLifeYears, SalvageValue)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (UserAssetID) DO UPDATE SET ValuationMethod = EXCLUDED.ValuationMethod,
+			ValuationRate = EXCLUDED.ValuationRate, UsefulLifeYears = EXCLUDED.UsefulLifeYears,
+			SalvageValue = EXCLUDED.SalvageValue`,
+		payload.UserAssetID, payload.Method, rate, usefulLife, payload.SalvageValue)
+	if err != nil {
+		log.Println("UpdateAssetValuationModel: Error saving model:", err)
+		http.Error(w, "Failed to update valuation model", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Valuation model updated successfully"})
+}
@@ -0,0 +1,120 @@
+// Package ical writes RFC 5545 calendars of all-day events, used by the calendar feed of forecast occurrences
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before it's folded, without the CRLF
+const maxLineOctets = 75
+
+// Event is an all-day event, repeated by RRule when it's set
+type Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	Categories  []string
+	RRule       string // RRULE value without the "RRULE:" prefix
+	Transparent bool   // doesn't block time in the user's agenda
+}
+
+// Calendar is a VCALENDAR with its events
+type Calendar struct {
+	ProductID string // PRODID, e.g. "-//FinanApp//Forecast feed//EN"
+	Name      string // shown by the clients that read X-WR-CALNAME
+	Refresh   time.Duration
+	Events    []Event
+}
+
+// Write renders the calendar. Stamp is the DTSTAMP of every event, the time the feed was generated.
+func (c Calendar) Write(w io.Writer, stamp time.Time) error {
+	out := bufio.NewWriter(w)
+	line := func(name, value string) {
+		out.WriteString(fold(name + ":" + value))
+		out.WriteString("\r\n")
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", c.ProductID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME", Escape(c.Name))
+	}
+	if c.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", duration(c.Refresh))
+		line("X-PUBLISHED-TTL", duration(c.Refresh))
+	}
+
+	dtstamp := stamp.UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", dtstamp)
+		line("DTSTART;VALUE=DATE", e.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE", e.Date.AddDate(0, 0, 1).Format("20060102"))
+		if e.RRule != "" {
+			line("RRULE", e.RRule)
+		}
+		line("SUMMARY", Escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", Escape(e.Description))
+		}
+		if len(e.Categories) > 0 {
+			categories := make([]string, len(e.Categories))
+			for i, category := range e.Categories {
+				categories[i] = Escape(category)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		if e.Transparent {
+			line("TRANSP", "TRANSPARENT")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+
+	return out.Flush()
+}
+
+// Escape makes text safe for a TEXT property value
+func Escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// fold splits a content line in lines of at most 75 octets, the next ones starting with a space. Lines are only
+// split between characters, never inside a multi-byte one.
+func fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// duration writes a DURATION value in whole hours or minutes
+func duration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("PT%dH", int(d/time.Hour))
+	}
+	return fmt.Sprintf("PT%dM", int(d/time.Minute))
+}
@@ -0,0 +1,81 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestWrite(t *testing.T) {
+	c := Calendar{
+		ProductID: "-//FinanApp//Test//EN",
+		Name:      "Bills",
+		Refresh:   6 * time.Hour,
+		Events: []Event{
+			{UID: "a@test", Date: day("2025-03-10"), Summary: "Rent; flat, 2", Description: "Line 1\nLine 2",
+				Categories: []string{"Expense"}, RRule: "FREQ=MONTHLY;UNTIL=20251231", Transparent: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := c.Write(&buf, time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//FinanApp//Test//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Bills",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT6H",
+		"X-PUBLISHED-TTL:PT6H",
+		"BEGIN:VEVENT",
+		"UID:a@test",
+		"DTSTAMP:20250301T123000Z",
+		"DTSTART;VALUE=DATE:20250310",
+		"DTEND;VALUE=DATE:20250311",
+		"RRULE:FREQ=MONTHLY;UNTIL=20251231",
+		`SUMMARY:Rent\; flat\, 2`,
+		`DESCRIPTION:Line 1\nLine 2`,
+		"CATEGORIES:Expense",
+		"TRANSP:TRANSPARENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestFold(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 60)
+	folded := fold(line)
+	parts := strings.Split(folded, "\r\n")
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 lines, got %q", folded)
+	}
+	for i, part := range parts {
+		if len(part) > maxLineOctets {
+			t.Errorf("line %d has %d octets", i, len(part))
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Errorf("line %d doesn't start with a space", i)
+		}
+		if !strings.HasSuffix(strings.TrimPrefix(part, " "), "é") {
+			t.Errorf("line %d splits a character: %q", i, part)
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("unfolded line differs: %q", unfolded)
+	}
+	if fold("UID:short") != "UID:short" {
+		t.Error("short lines should be kept")
+	}
+}
@@ -0,0 +1,234 @@
+// Package loan builds amortization schedules for loans and mortgages, including extra prepayments.
+package loan
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Amortization systems
+const (
+	Price        = "price"         // French system: constant installment
+	SAC          = "sac"           // constant amortization, decreasing installments
+	InterestOnly = "interest_only" // interest every month, the principal on the last installment
+)
+
+// Prepayment modes
+const (
+	ShortenTerm       = "shorten_term"       // keep the installment (Price) or the amortization (SAC) and finish earlier
+	ReduceInstallment = "reduce_installment" // keep the term and recompute the installments
+)
+
+// Prepayment is an extra payment of principal
+type Prepayment struct {
+	Date   time.Time
+	Amount float64
+	Mode   string
+}
+
+// Loan holds the contract terms. AnnualRate is the effective annual rate in percent, converted to the
+// equivalent monthly rate; the first installment is due one month after Start.
+type Loan struct {
+	Principal   float64
+	AnnualRate  float64
+	Months      int
+	Start       time.Time
+	System      string
+	Prepayments []Prepayment
+}
+
+// Installment is one row of the amortization table
+type Installment struct {
+	Number     int     `json:"number"`
+	Date       string  `json:"date"`
+	Payment    float64 `json:"payment"`    // interest + principal
+	Interest   float64 `json:"interest"`   // interest of the month
+	Principal  float64 `json:"principal"`  // amortization of the month
+	Prepayment float64 `json:"prepayment"` // extra principal paid with this installment
+	Total      float64 `json:"total"`      // payment + prepayment, the amount forecast for the date
+	Balance    float64 `json:"balance"`    // outstanding balance after the installment
+}
+
+// Summary totals a schedule
+type Summary struct {
+	Installments  int     `json:"installments"`
+	TotalPaid     float64 `json:"totalPaid"`
+	TotalInterest float64 `json:"totalInterest"`
+	LastDate      string  `json:"lastDate"`
+}
+
+// Validate checks the loan terms
+func (l Loan) Validate() error {
+	if l.Principal <= 0 {
+		return fmt.Errorf("principal must be greater than zero")
+	}
+	if l.AnnualRate < 0 {
+		return fmt.Errorf("annual rate can't be negative")
+	}
+	if l.Months <= 0 || l.Months > 600 {
+		return fmt.Errorf("term must be between 1 and 600 months")
+	}
+	if l.System != Price && l.System != SAC && l.System != InterestOnly {
+		return fmt.Errorf("system must be %s, %s or %s", Price, SAC, InterestOnly)
+	}
+	for _, p := range l.Prepayments {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a prepayment
+func (p Prepayment) Validate() error {
+	if p.Amount <= 0 {
+		return fmt.Errorf("prepayment amount must be greater than zero")
+	}
+	if p.Mode != ShortenTerm && p.Mode != ReduceInstallment {
+		return fmt.Errorf("prepayment mode must be %s or %s", ShortenTerm, ReduceInstallment)
+	}
+	return nil
+}
+
+// MonthlyRate converts an effective annual rate in percent to the equivalent monthly rate
+func MonthlyRate(annualRate float64) float64 {
+	return math.Pow(1+annualRate/100, 1.0/12) - 1
+}
+
+// DueDate returns the date of the nth installment, keeping the day of Start and clamping it to the month end
+func DueDate(start time.Time, n int) time.Time {
+	first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Schedule builds the amortization table. Each prepayment is paid with the first installment on or after
+// its date; prepayments after the loan is paid off are ignored.
+func Schedule(l Loan) ([]Installment, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	prepayments := append([]Prepayment(nil), l.Prepayments...)
+	sort.SliceStable(prepayments, func(i, j int) bool { return prepayments[i].Date.Before(prepayments[j].Date) })
+
+	rate := MonthlyRate(l.AnnualRate)
+	balance := l.Principal
+	term := l.Months
+	installment := pricePayment(balance, rate, term)
+	amortization := balance / float64(term)
+
+	var schedule []Installment
+	for k := 1; k <= term && balance > 0.004; k++ {
+		due := DueDate(l.Start, k)
+		row := Installment{Number: k, Date: due.Format("2006-01-02"), Interest: round2(balance * rate)}
+
+		switch l.System {
+		case Price:
+			row.Principal = round2(installment - row.Interest)
+		case SAC:
+			row.Principal = round2(amortization)
+		case InterestOnly:
+			row.Principal = 0
+		}
+		if k == term || row.Principal > balance {
+			row.Principal = round2(balance)
+		}
+		row.Payment = round2(row.Interest + row.Principal)
+		balance = round2(balance - row.Principal)
+
+		for len(prepayments) > 0 && !prepayments[0].Date.After(due) {
+			p := prepayments[0]
+			prepayments = prepayments[1:]
+			if balance <= 0 {
+				continue
+			}
+			amount := round2(math.Min(p.Amount, balance))
+			row.Prepayment = round2(row.Prepayment + amount)
+			balance = round2(balance - amount)
+			if balance <= 0 || l.System == InterestOnly {
+				continue
+			}
+
+			remaining := term - k
+			if p.Mode == ReduceInstallment {
+				installment = pricePayment(balance, rate, remaining)
+				amortization = balance / float64(remaining)
+				continue
+			}
+			// Shorten the term keeping the current installment or amortization
+			periods := int(math.Ceil(balance/amortization - 1e-9))
+			if l.System == Price {
+				periods = pricePeriods(balance, rate, installment)
+			}
+			term = k + min(periods, remaining)
+		}
+
+		row.Total = round2(row.Payment + row.Prepayment)
+		row.Balance = balance
+		schedule = append(schedule, row)
+	}
+	return schedule, nil
+}
+
+// Summarize totals the schedule
+func Summarize(schedule []Installment) Summary {
+	var s Summary
+	for _, row := range schedule {
+		s.TotalPaid += row.Total
+		s.TotalInterest += row.Interest
+	}
+	s.Installments = len(schedule)
+	s.TotalPaid = round2(s.TotalPaid)
+	s.TotalInterest = round2(s.TotalInterest)
+	if len(schedule) > 0 {
+		s.LastDate = schedule[len(schedule)-1].Date
+	}
+	return s
+}
+
+// BalanceOn returns the outstanding balance after the last installment paid on or before the date
+func BalanceOn(l Loan, schedule []Installment, on time.Time) float64 {
+	balance := l.Principal
+	day := on.Format("2006-01-02")
+	for _, row := range schedule {
+		if row.Date > day {
+			break
+		}
+		balance = row.Balance
+	}
+	return balance
+}
+
+// pricePayment is the constant installment that pays balance in n months at the monthly rate
+func pricePayment(balance, rate float64, n int) float64 {
+	if n <= 0 {
+		return balance
+	}
+	if rate == 0 {
+		return balance / float64(n)
+	}
+	return balance * rate / (1 - math.Pow(1+rate, -float64(n)))
+}
+
+// pricePeriods is the number of installments of the given amount needed to pay balance
+func pricePeriods(balance, rate, payment float64) int {
+	if rate == 0 {
+		return int(math.Ceil(balance/payment - 1e-9))
+	}
+	if payment <= balance*rate {
+		// The installment doesn't cover the interest anymore, it can't shorten the term
+		return math.MaxInt32
+	}
+	return int(math.Ceil(-math.Log(1-balance*rate/payment)/math.Log(1+rate) - 1e-9))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,114 @@
+package loan
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// onePercent is the effective annual rate equivalent to 1% a month
+var onePercent = (math.Pow(1.01, 12) - 1) * 100
+
+func TestPrice(t *testing.T) {
+	schedule, err := Schedule(Loan{Principal: 100000, AnnualRate: onePercent, Months: 12, Start: day("2025-01-31"), System: Price})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 12 {
+		t.Fatalf("expected 12 installments, got %d", len(schedule))
+	}
+	first, last := schedule[0], schedule[11]
+	if first.Payment != 8884.88 || first.Interest != 1000 || first.Date != "2025-02-28" {
+		t.Errorf("unexpected first installment %+v", first)
+	}
+	if math.Abs(last.Payment-8884.88) > 0.05 || last.Balance != 0 || last.Date != "2026-01-31" {
+		t.Errorf("unexpected last installment %+v", last)
+	}
+}
+
+func TestSAC(t *testing.T) {
+	schedule, _ := Schedule(Loan{Principal: 120000, AnnualRate: onePercent, Months: 12, Start: day("2025-01-10"), System: SAC})
+	if schedule[0].Payment != 11200 || schedule[11].Payment != 10100 {
+		t.Errorf("unexpected installments %+v ... %+v", schedule[0], schedule[11])
+	}
+	if s := Summarize(schedule); s.TotalInterest != 7800 || s.TotalPaid != 127800 || s.LastDate != "2026-01-10" {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
+
+func TestInterestOnly(t *testing.T) {
+	schedule, _ := Schedule(Loan{Principal: 50000, AnnualRate: onePercent, Months: 3, Start: day("2025-01-10"), System: InterestOnly})
+	if schedule[0].Payment != 500 || schedule[1].Principal != 0 || schedule[2].Payment != 50500 || schedule[2].Balance != 0 {
+		t.Errorf("unexpected schedule %+v", schedule)
+	}
+}
+
+func TestSACPrepayment(t *testing.T) {
+	base := Loan{Principal: 120000, AnnualRate: onePercent, Months: 12, Start: day("2025-01-10"), System: SAC}
+
+	shorten := base
+	shorten.Prepayments = []Prepayment{{Date: day("2025-03-01"), Amount: 20000, Mode: ShortenTerm}}
+	schedule, _ := Schedule(shorten)
+	if len(schedule) != 10 || schedule[1].Prepayment != 20000 || schedule[1].Balance != 80000 || schedule[2].Principal != 10000 {
+		t.Errorf("shorten term: unexpected schedule %+v", schedule[:3])
+	}
+
+	reduce := base
+	reduce.Prepayments = []Prepayment{{Date: day("2025-03-01"), Amount: 20000, Mode: ReduceInstallment}}
+	schedule, _ = Schedule(reduce)
+	if len(schedule) != 12 || schedule[2].Principal != 8000 {
+		t.Errorf("reduce installment: unexpected schedule %+v", schedule[:3])
+	}
+}
+
+func TestPricePrepayment(t *testing.T) {
+	base := Loan{Principal: 100000, AnnualRate: onePercent, Months: 24, Start: day("2025-01-10"), System: Price}
+	plain, _ := Schedule(base)
+
+	shorten := base
+	shorten.Prepayments = []Prepayment{{Date: day("2025-06-10"), Amount: 30000, Mode: ShortenTerm}}
+	schedule, _ := Schedule(shorten)
+	if len(schedule) >= 24 || schedule[6].Payment != plain[6].Payment {
+		t.Errorf("shorten term: got %d installments paying %v", len(schedule), schedule[6].Payment)
+	}
+	if schedule[len(schedule)-1].Balance != 0 {
+		t.Errorf("shorten term: loan not paid off %+v", schedule[len(schedule)-1])
+	}
+
+	reduce := base
+	reduce.Prepayments = []Prepayment{{Date: day("2025-06-10"), Amount: 30000, Mode: ReduceInstallment}}
+	schedule, _ = Schedule(reduce)
+	if len(schedule) != 24 || schedule[6].Payment >= plain[6].Payment || schedule[23].Balance != 0 {
+		t.Errorf("reduce installment: unexpected schedule, %d installments paying %v", len(schedule), schedule[6].Payment)
+	}
+
+	if s, p := Summarize(schedule), Summarize(plain); s.TotalInterest >= p.TotalInterest {
+		t.Errorf("prepayment should reduce interest: %v >= %v", s.TotalInterest, p.TotalInterest)
+	}
+}
+
+func TestPrepaymentPaysOff(t *testing.T) {
+	l := Loan{Principal: 1000, Months: 4, Start: day("2025-01-10"), System: Price,
+		Prepayments: []Prepayment{{Date: day("2025-02-01"), Amount: 5000, Mode: ShortenTerm}}}
+	schedule, _ := Schedule(l)
+	if len(schedule) != 1 || schedule[0].Total != 1000 || schedule[0].Prepayment != 750 {
+		t.Errorf("unexpected schedule %+v", schedule)
+	}
+	if BalanceOn(l, schedule, day("2025-01-20")) != 1000 || BalanceOn(l, schedule, day("2025-03-01")) != 0 {
+		t.Error("unexpected balance")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if _, err := Schedule(Loan{Principal: 1000, Months: 12, System: "german"}); err == nil {
+		t.Error("expected error on unknown system")
+	}
+	if _, err := Schedule(Loan{Principal: 1000, Months: 12, System: SAC, Prepayments: []Prepayment{{Amount: 10, Mode: "later"}}}); err == nil {
+		t.Error("expected error on unknown prepayment mode")
+	}
+}
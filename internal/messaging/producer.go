@@ -32,3 +32,14 @@ func (p *Producer) UserRegistration(message []byte) error {
 	log.Printf("Message published to topic '%s'. Message: '%s' ", topic, message)
 	return nil
 }
+
+// BudgetAlert publishes a message to the 'budget_alerts' topic in NSQ
+func (p *Producer) BudgetAlert(message []byte) error {
+	topic := "budget_alerts" // Name of the topic where the budget threshold alerts are published
+	err := p.producer.Publish(topic, message)
+	if err != nil {
+		return fmt.Errorf("error publishing message to NSQ: %v", err)
+	}
+	log.Printf("Message published to topic '%s'. Message: '%s' ", topic, message)
+	return nil
+}
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"finanapp/internal/models"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// AdminMiddleware only lets through the users whose email is listed in ADMIN_EMAILS (comma separated).
+// It must run after AuthMiddleware, which puts the user in the context.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(models.UserProfile)
+		if !ok {
+			log.Println("ADMIN-MID: Unauthorized access - no user found in context")
+			unauthorized(w, r)
+			return
+		}
+
+		for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+			if strings.TrimSpace(email) != "" && strings.EqualFold(strings.TrimSpace(email), user.EmailAddress) {
+				next(w, r)
+				return
+			}
+		}
+
+		log.Printf("ADMIN-MID: Forbidden - %s is not an admin\n", user.EmailAddress)
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}
+}
@@ -6,10 +6,15 @@ import (
 	"finanapp/internal/auth"
 	"finanapp/internal/db"
 	"finanapp/internal/models"
+	"finanapp/internal/utils"
 	"log"
 	"net/http"
+	"regexp"
 )
 
+// validRequestID is what a request ID sent by the client or a proxy in X-Request-ID can look like
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
 // AuthMiddleware checks if the user is authenticated and passes the data to the context
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -70,6 +75,7 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		log.Printf("AUTH-MID: User authenticated - %s %s (ID: %d)\n", user.FirstName, user.LastName, user.UserProfileID)
 		ctx := context.WithValue(r.Context(), "authenticated", true)
 		ctx = context.WithValue(ctx, "user", user)
+		ctx = context.WithValue(ctx, "request_id", requestID(w, r))
 
 		// 7. Call the next handler, passing the updated context
 		log.Println("AUTH-MID: Passing control to the next handler")
@@ -80,3 +86,13 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 func unauthorized(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Unauthorized", http.StatusUnauthorized)
 }
+
+// requestID keeps the X-Request-ID of the request when it's valid, or makes a new one, and echoes it in the response
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	if !validRequestID.MatchString(id) {
+		id = utils.GenerateToken(16)
+	}
+	w.Header().Set("X-Request-ID", id)
+	return id
+}
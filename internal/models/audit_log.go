@@ -0,0 +1,19 @@
+package models
+
+import "encoding/json"
+
+// AuditLog is one change to a financial record, with the row before and after it
+type AuditLog struct {
+	AuditLogID         int64           `json:"auditLogId"`
+	EntityName         string          `json:"entity"` // table of the record, e.g. FinancialUserItem
+	EntityRecordID     int             `json:"recordId"`
+	AuditAction        string          `json:"action"` // INSERT, UPDATE or DELETE
+	ActorUserProfileID *int            `json:"actorUserProfileId"`
+	ActorName          *string         `json:"actorName"`
+	BeforeData         json.RawMessage `json:"before"`
+	AfterData          json.RawMessage `json:"after"`
+	ChangedFields      []string        `json:"changedFields"`
+	RequestID          *string         `json:"requestId"`
+	SourceIP           *string         `json:"sourceIp"`
+	ChangedAt          string          `json:"changedAt"`
+}
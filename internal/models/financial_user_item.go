@@ -1,21 +1,29 @@
 package models
 
 type FinancialUserItem struct {
-	FinancialUserItemID       int    `json:"financialUserItemId"`
-	FinancialUserItemName     string `json:"financialUserItemName"`
-	EntityID                  int    `json:"entityId"`
-	UserEntityID              int    `json:"userEntityId"`
-	RecurrencyID              string `json:"recurrencyId"`
-	FinancialUserEntityItemID int    `json:"financialUserEntityItemId"`
-	ParentFinancialUserItemID int    `json:"parentFinancialUserItemId"`
-	IsActive                  bool   `json:"isActive"`
-	CreatedAt                 string `json:"createdAt"`
+	FinancialUserItemID       int     `json:"financialUserItemId"`
+	FinancialUserItemName     string  `json:"financialUserItemName"`
+	EntityID                  int     `json:"entityId"`
+	UserEntityID              int     `json:"userEntityId"`
+	RecurrencyID              string  `json:"recurrencyId"`
+	FinancialUserEntityItemID int     `json:"financialUserEntityItemId"`
+	ParentFinancialUserItemID int     `json:"parentFinancialUserItemId"`
+	RecurrencyRule            *string `json:"recurrencyRule,omitempty"`
+	RecurrencyEndDate         *string `json:"recurrencyEndDate,omitempty"`
+	IsActive                  bool    `json:"isActive"`
+	CreatedAt                 string  `json:"createdAt"`
 
 	// Relation
 	EntityType     string `json:"entityType"`
 	RecurrencyName string `json:"recurrencyName"`
 	IncomeTypeName string `json:"incomeTypeName"`
 
+	// Current forecast amount of the item, converted when requested in another currency (?currency=)
+	CurrentAmount     *float64 `json:"currentAmount,omitempty"`
+	CurrentCurrencyID *int     `json:"currentCurrencyId,omitempty"`
+	ConvertedAmount   *float64 `json:"convertedAmount,omitempty"`
+	ConvertedCurrency string   `json:"convertedCurrency,omitempty"`
+
 	// Aditional field for the Create function]
 	Amount     string `json:"amount"`
 	CurrencyID string `json:"currencyId"`
@@ -0,0 +1,17 @@
+package models
+
+// ForecastOccurrence is one expected payment of a FinancialUserItem, either stored in
+// UserFinancialForecast or projected by the recurrence engine
+type ForecastOccurrence struct {
+	FinancialUserItemID       int     `json:"financialUserItemId"`
+	FinancialUserItemName     string  `json:"financialUserItemName"`
+	EntityID                  int     `json:"entityId"`
+	EntityType                string  `json:"entityType"`
+	ParentFinancialUserItemID *int    `json:"parentFinancialUserItemId,omitempty"`
+	RecurrencyName            string  `json:"recurrencyName"`
+	OccurrenceDate            string  `json:"occurrenceDate"`
+	Amount                    float64 `json:"amount"`
+	CurrencyID                int     `json:"currencyId"`
+	UserFinancialForecastID   *int    `json:"userFinancialForecastId,omitempty"` // nil when projected
+	Projected                 bool    `json:"projected"`
+}
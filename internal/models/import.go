@@ -0,0 +1,45 @@
+package models
+
+import "encoding/json"
+
+// ImportBatch is an uploaded statement file
+type ImportBatch struct {
+	ImportBatchID int     `json:"importBatchId"`
+	ImportSource  string  `json:"source"`
+	FileName      *string `json:"fileName"`
+	CreatedAt     string  `json:"createdAt"`
+	CommittedAt   *string `json:"committedAt"`
+	Pending       int     `json:"pending"`
+	Committed     int     `json:"committed"`
+	Skipped       int     `json:"skipped"`
+}
+
+// ImportedTransaction is a statement line waiting to be committed as a UserFinancialActual
+type ImportedTransaction struct {
+	ImportedTransactionID  int     `json:"importedTransactionId"`
+	ImportBatchID          int     `json:"importBatchId"`
+	AccountKey             string  `json:"accountKey"`
+	ExternalID             string  `json:"externalId"`
+	TransactionDate        string  `json:"date"`
+	TransactionAmount      float64 `json:"amount"`
+	TransactionDescription string  `json:"description"`
+	TransactionType        *string `json:"type"`
+	CurrencyID             int     `json:"currencyId"`
+	FinancialUserItemID    *int    `json:"financialUserItemId"`
+	FinancialUserItemName  *string `json:"financialUserItemName"`
+	UserCategoryID         *int    `json:"userCategoryId"`
+	UserCategoryName       *string `json:"userCategoryName"`
+	MatchSource            *string `json:"matchSource"`
+	TransactionNote        *string `json:"note"`
+	ImportStatus           string  `json:"status"`
+	UserFinancialActualID  *int    `json:"userFinancialActualId"`
+	DuplicateActualID      *int    `json:"duplicateActualId"`
+}
+
+// UserImportProfile is a saved CSV column mapping
+type UserImportProfile struct {
+	UserImportProfileID int             `json:"userImportProfileId"`
+	ProfileName         string          `json:"profileName"`
+	ProfileMapping      json.RawMessage `json:"mapping"`
+	CreatedAt           string          `json:"createdAt"`
+}
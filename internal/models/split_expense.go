@@ -0,0 +1,70 @@
+package models
+
+// SplitExpense is an expense paid by one user and divided between several users
+type SplitExpense struct {
+	SplitExpenseID      int                 `json:"splitExpenseId"`
+	SplitExpenseName    string              `json:"splitExpenseName"`
+	PaidByUserProfileID int                 `json:"paidByUserProfileId"`
+	PaidByName          string              `json:"paidByName"`
+	UserGroupID         *int                `json:"userGroupId"`
+	FinancialUserItemID *int                `json:"financialUserItemId"`
+	SplitExpenseAmount  float64             `json:"splitExpenseAmount"`
+	CurrencyID          int                 `json:"currencyId"`
+	SplitExpenseDate    string              `json:"splitExpenseDate"`
+	SplitMode           string              `json:"splitMode"`
+	CreatedAt           string              `json:"createdAt"`
+	Shares              []SplitExpenseShare `json:"shares"`
+}
+
+// SplitExpenseShare is the portion of a split expense a participant is responsible for
+type SplitExpenseShare struct {
+	UserProfileID int      `json:"userProfileId"`
+	UserName      string   `json:"userName"`
+	ShareValue    *float64 `json:"shareValue"`
+	ShareAmount   float64  `json:"shareAmount"`
+}
+
+// SplitExpensePayload is the body used to create a split expense. Participants are identified by
+// userProfileId or emailAddress; value depends on the split mode.
+type SplitExpensePayload struct {
+	SplitExpenseName    string  `json:"splitExpenseName"`
+	PaidByUserProfileID int     `json:"paidByUserProfileId"` // defaults to the logged-in user
+	UserGroupID         *int    `json:"userGroupId"`
+	FinancialUserItemID *int    `json:"financialUserItemId"` // the payer's own expense item, optional
+	Amount              float64 `json:"amount"`
+	CurrencyID          int     `json:"currencyId"`
+	Date                string  `json:"date"` // "YYYY-MM-DD"
+	SplitMode           string  `json:"splitMode"`
+	Participants        []struct {
+		UserProfileID int     `json:"userProfileId"`
+		EmailAddress  string  `json:"emailAddress"`
+		Value         float64 `json:"value"`
+	} `json:"participants"`
+}
+
+// SplitSettlement is a settle-up payment between two users and the actuals booked for both sides
+type SplitSettlement struct {
+	SplitSettlementID         int     `json:"splitSettlementId"`
+	FromUserProfileID         int     `json:"fromUserProfileId"`
+	FromName                  string  `json:"fromName"`
+	ToUserProfileID           int     `json:"toUserProfileId"`
+	ToName                    string  `json:"toName"`
+	UserGroupID               *int    `json:"userGroupId"`
+	SettlementAmount          float64 `json:"settlementAmount"`
+	CurrencyID                int     `json:"currencyId"`
+	SettlementDate            string  `json:"settlementDate"`
+	FromUserFinancialActualID *int    `json:"fromUserFinancialActualId"`
+	ToUserFinancialActualID   *int    `json:"toUserFinancialActualId"`
+	CreatedAt                 string  `json:"createdAt"`
+}
+
+// UserSplitContact is a request to split expenses outside a group, seen from the logged-in user
+type UserSplitContact struct {
+	UserSplitContactID   int    `json:"userSplitContactId"`
+	Direction            string `json:"direction"` // sent or received
+	ContactUserProfileID *int   `json:"contactUserProfileId"`
+	ContactName          string `json:"contactName"`
+	EmailAddress         string `json:"emailAddress"`
+	ContactStatus        string `json:"contactStatus"` // pending, accepted or declined
+	CreatedAt            string `json:"createdAt"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// TrashItem is a deleted FinancialUserItem waiting in the trash, with what a restore brings back
+type TrashItem struct {
+	FinancialUserItemID   int    `json:"financialUserItemId"`
+	FinancialUserItemName string `json:"financialUserItemName"`
+	EntityID              int    `json:"entityId"`
+	EntityType            string `json:"entityType"`
+	DeletedAt             string `json:"deletedAt"`
+	PurgeAt               string `json:"purgeAt"` // when the retention job deletes it for good
+	Children              int    `json:"children"`
+	Forecasts             int    `json:"forecasts"`
+	Actuals               int    `json:"actuals"`
+}
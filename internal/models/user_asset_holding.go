@@ -0,0 +1,23 @@
+package models
+
+// UserAssetHolding is a security held under an investment UserAsset
+type UserAssetHolding struct {
+	UserAssetHoldingID int    `json:"userAssetHoldingId"`
+	UserAssetID        int    `json:"userAssetId"`
+	UserSecurityID     int    `json:"userSecurityId"`
+	Ticker             string `json:"ticker"`
+	SecurityName       string `json:"securityName"`
+	CostMethod         string `json:"costMethod"`
+	CreatedAt          string `json:"createdAt"`
+}
+
+// UserAssetHoldingTransaction is a buy or a sell of a holding
+type UserAssetHoldingTransaction struct {
+	UserAssetHoldingTransactionID int     `json:"userAssetHoldingTransactionId"`
+	UserAssetHoldingID            int     `json:"userAssetHoldingId"`
+	TransactionDate               string  `json:"date"`
+	TransactionSide               string  `json:"side"`
+	Quantity                      float64 `json:"quantity"`
+	UnitPrice                     float64 `json:"price"`
+	Fees                          float64 `json:"fees"`
+}
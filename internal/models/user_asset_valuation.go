@@ -0,0 +1,11 @@
+package models
+
+// UserAssetValuation is one entry of the valuation history of an asset
+type UserAssetValuation struct {
+	UserAssetValuationID     int     `json:"userAssetValuationId"`
+	UserAssetID              int     `json:"userAssetId"`
+	UserAssetValuationDate   string  `json:"valuationDate"`
+	UserAssetValuationAmount float64 `json:"amount"`
+	Note                     *string `json:"note,omitempty"`
+	CreatedAt                string  `json:"createdAt"`
+}
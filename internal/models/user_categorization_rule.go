@@ -0,0 +1,20 @@
+package models
+
+// UserCategorizationRule sets the category, item or note of the transactions it matches, or skips them
+type UserCategorizationRule struct {
+	UserCategorizationRuleID int      `json:"userCategorizationRuleId"`
+	RuleName                 string   `json:"ruleName"`
+	RulePriority             int      `json:"priority"`
+	DescriptionContains      *string  `json:"descriptionContains"`
+	DescriptionRegex         *string  `json:"descriptionRegex"`
+	MinAmount                *float64 `json:"minAmount"`
+	MaxAmount                *float64 `json:"maxAmount"`
+	AmountSign               *string  `json:"sign"` // debit, credit
+	AccountKey               *string  `json:"accountKey"`
+	UserCategoryID           *int     `json:"userCategoryId"`
+	FinancialUserItemID      *int     `json:"financialUserItemId"`
+	RuleNote                 *string  `json:"note"`
+	SkipTransaction          bool     `json:"skip"`
+	IsActive                 bool     `json:"isActive"`
+	CreatedAt                string   `json:"createdAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// UserDuplicatePair is two actuals that look like the same transaction, waiting for or carrying the user's decision
+type UserDuplicatePair struct {
+	UserDuplicatePairID int                   `json:"userDuplicatePairId"`
+	FirstActualID       int                   `json:"firstActualId"`
+	SecondActualID      int                   `json:"secondActualId"`
+	MatchScore          float64               `json:"score"`
+	MatchReasons        []string              `json:"reasons"`
+	PairStatus          string                `json:"status"` // pending, merged, kept, dismissed
+	KeptActualID        *int                  `json:"keptActualId"`
+	CreatedAt           string                `json:"createdAt"`
+	DecidedAt           *string               `json:"decidedAt"`
+	Actuals             []UserFinancialActual `json:"actuals,omitempty"`
+}
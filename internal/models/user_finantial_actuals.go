@@ -2,7 +2,7 @@ package models
 
 type UserFinancialActual struct {
 	UserFinancialActualID         int     `json:"UserFinancialActualID"`
-	UserCategoryID                int     `json:"UserCategoryID"`
+	UserCategoryID                *int    `json:"UserCategoryID"`
 	FinancialUserItemID           int     `json:"FinancialUserItemID"`
 	UserFinancialActualtBeginDate string  `json:"UserFinancialActualtBeginDate"`
 	UserFinancialActualEndDate    *string `json:"UserFinancialActualEndDate,omitempty"`
@@ -13,4 +13,20 @@ type UserFinancialActual struct {
 	CurrencyName                  string  `json:"CurrencyName"`
 	Note                          *string `json:"Note,omitempty"`
 	CreatedAt                     string  `json:"CreatedAt"`
+
+	// Filled when the amount is requested in another currency (?currency=)
+	ConvertedAmount   *float64 `json:"ConvertedAmount,omitempty"`
+	ConvertedCurrency string   `json:"ConvertedCurrency,omitempty"`
+}
+
+// UserFinancialActualPayload is the body used to create and update actuals
+type UserFinancialActualPayload struct {
+	UserFinancialActualID int     `json:"userFinancialActualId"`
+	FinancialUserItemID   int     `json:"financialUserItemId"`
+	UserCategoryID        *int    `json:"userCategoryId"`
+	CurrencyID            int     `json:"currencyId"`
+	Amount                float64 `json:"amount"`
+	BeginDate             string  `json:"beginDate"` // "YYYY-MM-DD"
+	EndDate               *string `json:"endDate"`   // "YYYY-MM-DD"
+	Note                  *string `json:"note"`
 }
@@ -13,4 +13,8 @@ type UserFinancialForecast struct {
 	FinancialUserItemName          string  `json:"FinancialUserItemName"`
 	CurrencyName                   string  `json:"CurrencyName"`
 	CreatedAt                      string  `json:"CreatedAt"`
+
+	// Filled when the amount is requested in another currency (?currency=)
+	ConvertedAmount   *float64 `json:"ConvertedAmount,omitempty"`
+	ConvertedCurrency string   `json:"ConvertedCurrency,omitempty"`
 }
@@ -0,0 +1,48 @@
+package models
+
+// UserGroup is a household sharing Group Income and Group Expense items
+type UserGroup struct {
+	UserGroupID            int               `json:"userGroupId"`
+	UserGroupName          string            `json:"userGroupName"`
+	CreatedByUserProfileID int               `json:"createdByUserProfileId"`
+	GroupRole              string            `json:"groupRole"` // role of the logged-in user
+	CreatedAt              string            `json:"createdAt"`
+	Members                []UserGroupMember `json:"members,omitempty"`
+}
+
+// UserGroupMember is a user of a group and their role (owner, member or viewer)
+type UserGroupMember struct {
+	UserProfileID int    `json:"userProfileId"`
+	FirstName     string `json:"firstName"`
+	LastName      string `json:"lastName"`
+	EmailAddress  string `json:"emailAddress"`
+	GroupRole     string `json:"groupRole"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// UserGroupInvite is a pending invitation to join a group
+type UserGroupInvite struct {
+	UserGroupInviteID   int    `json:"userGroupInviteId"`
+	UserGroupID         int    `json:"userGroupId"`
+	UserGroupName       string `json:"userGroupName"`
+	InvitedEmailAddress string `json:"invitedEmailAddress"`
+	InvitedBy           string `json:"invitedBy"`
+	GroupRole           string `json:"groupRole"`
+	InviteToken         string `json:"inviteToken,omitempty"`
+	InviteStatus        string `json:"inviteStatus"`
+	ExpiresAt           string `json:"expiresAt"`
+}
+
+// UserGroupItem is a group item with its forecasts and actuals
+type UserGroupItem struct {
+	FinancialUserItemID       int                     `json:"financialUserItemId"`
+	FinancialUserItemName     string                  `json:"financialUserItemName"`
+	EntityID                  int                     `json:"entityId"`
+	EntityType                string                  `json:"entityType"`
+	RecurrencyID              int                     `json:"recurrencyId"`
+	FinancialUserEntityItemID *int                    `json:"financialUserEntityItemId"`
+	ParentFinancialUserItemID *int                    `json:"parentFinancialUserItemId"`
+	IsActive                  bool                    `json:"isActive"`
+	Forecasts                 []UserFinancialForecast `json:"forecasts"`
+	Actuals                   []UserFinancialActual   `json:"actuals"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// UserLoan holds the terms of a loan or mortgage whose amortization table is forecast on a FinancialUserItem
+type UserLoan struct {
+	UserLoanID            int                  `json:"userLoanId"`
+	FinancialUserItemID   int                  `json:"financialUserItemId"`
+	FinancialUserItemName string               `json:"financialUserItemName"`
+	LoanPrincipal         float64              `json:"principal"`
+	LoanAnnualRate        float64              `json:"annualRate"`
+	LoanTermMonths        int                  `json:"termMonths"`
+	LoanStartDate         string               `json:"startDate"`
+	AmortizationSystem    string               `json:"system"`
+	CurrencyID            int                  `json:"currencyId"`
+	CreatedAt             string               `json:"createdAt"`
+	Prepayments           []UserLoanPrepayment `json:"prepayments"`
+}
+
+// UserLoanPrepayment is an extra principal payment of a loan
+type UserLoanPrepayment struct {
+	UserLoanPrepaymentID int     `json:"userLoanPrepaymentId"`
+	PrepaymentDate       string  `json:"date"`
+	PrepaymentAmount     float64 `json:"amount"`
+	PrepaymentMode       string  `json:"mode"`
+}
+
+// UserLoanPayload is the body used to create or update a loan. Without financialUserItemId a new User Expense
+// of type Mortgage is created with financialUserItemName.
+type UserLoanPayload struct {
+	FinancialUserItemID   int     `json:"financialUserItemId"`
+	FinancialUserItemName string  `json:"financialUserItemName"`
+	Principal             float64 `json:"principal"`
+	AnnualRate            float64 `json:"annualRate"`
+	TermMonths            int     `json:"termMonths"`
+	StartDate             string  `json:"startDate"` // "YYYY-MM-DD"
+	System                string  `json:"system"`
+	CurrencyID            int     `json:"currencyId"`
+	RecordDisbursement    bool    `json:"recordDisbursement"` // also forecast the principal as a Loan income on the start date
+}
@@ -0,0 +1,26 @@
+package models
+
+// UserSavingsGoal is an amount the user wants to save by a date
+type UserSavingsGoal struct {
+	UserSavingsGoalID   int     `json:"userSavingsGoalId"`
+	GoalName            string  `json:"goalName"`
+	TargetAmount        float64 `json:"targetAmount"`
+	TargetDate          string  `json:"targetDate"`
+	CurrencyID          int     `json:"currencyId"`
+	Currency            string  `json:"currency"`
+	FinancialUserItemID *int    `json:"financialUserItemId"`
+	UserAssetID         *int    `json:"userAssetId"`
+	UserCategoryID      *int    `json:"userCategoryId"`
+	CreatedAt           string  `json:"createdAt"`
+}
+
+// UserSavingsGoalPayload is the body used to create and update goals
+type UserSavingsGoalPayload struct {
+	UserSavingsGoalID int     `json:"userSavingsGoalId"`
+	GoalName          string  `json:"goalName"`
+	TargetAmount      float64 `json:"targetAmount"`
+	TargetDate        string  `json:"targetDate"` // "YYYY-MM-DD"
+	CurrencyID        int     `json:"currencyId"`
+	UserAssetID       *int    `json:"userAssetId"`
+	UserCategoryID    *int    `json:"userCategoryId"`
+}
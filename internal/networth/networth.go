@@ -0,0 +1,158 @@
+// Package networth builds the net-worth time series from asset valuations, cash movements and liabilities.
+package networth
+
+import (
+	"finanapp/internal/valuation"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Valuation is the value of an asset from a date on
+type Valuation struct {
+	AssetID int
+	Date    time.Time
+	Amount  float64
+}
+
+// Asset is held between Acquired and Disposed (nil while still owned). Between valuations its value
+// follows the model, starting from the acquisition cost.
+type Asset struct {
+	AssetID  int
+	Acquired time.Time
+	Disposed *time.Time
+	Cost     float64
+	Model    valuation.Model
+}
+
+// Movement is a realized cash movement, positive for inflows and negative for outflows
+type Movement struct {
+	Date   time.Time
+	Amount float64
+}
+
+// Liability is an obligation that is outstanding from Due until Settled (nil while unpaid)
+type Liability struct {
+	Due     time.Time
+	Settled *time.Time
+	Amount  float64
+}
+
+// Point is the position on a date
+type Point struct {
+	Date        string  `json:"date"`
+	Assets      float64 `json:"assets"`
+	Cash        float64 `json:"cash"`
+	Liabilities float64 `json:"liabilities"`
+	NetWorth    float64 `json:"netWorth"`
+}
+
+// Dates returns the closing date of each period between from and to, the last one being "to".
+// Interval is day, week, month, quarter or year.
+func Dates(from, to time.Time, interval string) ([]time.Time, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("from must be on or before to")
+	}
+
+	// Months per period, 0 for the intervals counted in days
+	months, days := 0, 0
+	switch interval {
+	case "day":
+		days = 1
+	case "week":
+		days = 7
+	case "month", "":
+		months = 1
+	case "quarter":
+		months = 3
+	case "year":
+		months = 12
+	default:
+		return nil, fmt.Errorf("invalid interval %q (expected day, week, month, quarter or year)", interval)
+	}
+
+	// The first point closes the period that contains "from"
+	first := from
+	if months > 0 {
+		closing := (int(from.Month())-1)/months*months + months
+		first = endOfMonth(time.Date(from.Year(), time.Month(closing), 1, 0, 0, 0, 0, time.UTC))
+	}
+
+	next := func(t time.Time) time.Time {
+		if months > 0 {
+			return endOfMonth(time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC))
+		}
+		return t.AddDate(0, 0, days)
+	}
+
+	var dates []time.Time
+	for d := first; d.Before(to); d = next(d) {
+		dates = append(dates, d)
+		if len(dates) > 5000 {
+			return nil, fmt.Errorf("too many points, use a larger interval")
+		}
+	}
+	return append(dates, to), nil
+}
+
+// Series computes the position on each date. The value of an asset is its latest valuation on or before
+// the date projected by its model while it's held; the cash is the sum of the movements up to the date.
+func Series(dates []time.Time, assets []Asset, valuations []Valuation, movements []Movement, liabilities []Liability) []Point {
+	byAsset := map[int][]Valuation{}
+	for _, v := range valuations {
+		byAsset[v.AssetID] = append(byAsset[v.AssetID], v)
+	}
+	for id := range byAsset {
+		list := byAsset[id]
+		sort.Slice(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+	}
+
+	sorted := append([]Movement(nil), movements...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	points := make([]Point, 0, len(dates))
+	cash, m := 0.0, 0
+	for _, d := range dates {
+		p := Point{Date: d.Format("2006-01-02")}
+
+		for _, a := range assets {
+			if a.Acquired.After(d) || (a.Disposed != nil && a.Disposed.Before(d)) {
+				continue
+			}
+			list := byAsset[a.AssetID]
+			i := sort.Search(len(list), func(i int) bool { return list[i].Date.After(d) })
+			if i > 0 {
+				last := list[i-1]
+				p.Assets += a.Model.ValueAt(a.Cost, valuation.Anchor{Date: last.Date, Amount: last.Amount}, d)
+			}
+		}
+
+		for ; m < len(sorted) && !sorted[m].Date.After(d); m++ {
+			cash += sorted[m].Amount
+		}
+		p.Cash = cash
+
+		for _, l := range liabilities {
+			if !l.Due.After(d) && (l.Settled == nil || l.Settled.After(d)) {
+				p.Liabilities += l.Amount
+			}
+		}
+
+		p.Assets = round2(p.Assets)
+		p.Cash = round2(p.Cash)
+		p.Liabilities = round2(p.Liabilities)
+		p.NetWorth = round2(p.Assets + p.Cash - p.Liabilities)
+		points = append(points, p)
+	}
+
+	return points
+}
+
+func endOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
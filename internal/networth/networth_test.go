@@ -0,0 +1,85 @@
+package networth
+
+import (
+	"finanapp/internal/valuation"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestDates(t *testing.T) {
+	dates, err := Dates(day("2025-01-15"), day("2025-04-10"), "month")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-10"}
+	if len(dates) != len(want) {
+		t.Fatalf("got %v, want %v", dates, want)
+	}
+	for i := range want {
+		if dates[i].Format("2006-01-02") != want[i] {
+			t.Errorf("date %d = %s, want %s", i, dates[i].Format("2006-01-02"), want[i])
+		}
+	}
+
+	quarters, _ := Dates(day("2025-02-01"), day("2025-12-31"), "quarter")
+	if len(quarters) != 4 || quarters[0].Format("2006-01-02") != "2025-03-31" {
+		t.Errorf("unexpected quarters %v", quarters)
+	}
+
+	if _, err := Dates(day("2025-01-01"), day("2025-02-01"), "fortnight"); err == nil {
+		t.Error("expected an error for an invalid interval")
+	}
+}
+
+func TestSeries(t *testing.T) {
+	sold := day("2025-02-15")
+	assets := []Asset{
+		{AssetID: 1, Acquired: day("2024-06-01")},
+		{AssetID: 2, Acquired: day("2024-06-01"), Disposed: &sold},
+	}
+	valuations := []Valuation{
+		{AssetID: 1, Date: day("2024-06-01"), Amount: 300000},
+		{AssetID: 1, Date: day("2025-02-01"), Amount: 320000},
+		{AssetID: 2, Date: day("2024-06-01"), Amount: 50000},
+	}
+	movements := []Movement{
+		{Date: day("2025-01-05"), Amount: 10000},
+		{Date: day("2025-01-10"), Amount: -3000},
+		{Date: day("2025-02-05"), Amount: 10000},
+	}
+	paid := day("2025-02-10")
+	liabilities := []Liability{
+		{Due: day("2025-01-20"), Settled: &paid, Amount: 1200},
+		{Due: day("2025-02-20"), Amount: 800},
+	}
+
+	points := Series([]time.Time{day("2025-01-31"), day("2025-02-28")}, assets, valuations, movements, liabilities)
+
+	jan, feb := points[0], points[1]
+	if jan.Assets != 350000 || jan.Cash != 7000 || jan.Liabilities != 1200 || jan.NetWorth != 355800 {
+		t.Errorf("unexpected January %+v", jan)
+	}
+	if feb.Assets != 320000 || feb.Cash != 17000 || feb.Liabilities != 800 || feb.NetWorth != 336200 {
+		t.Errorf("unexpected February %+v", feb)
+	}
+}
+
+func TestSeriesProjectsWithModel(t *testing.T) {
+	assets := []Asset{{
+		AssetID:  1,
+		Acquired: day("2024-01-01"),
+		Cost:     100000,
+		Model:    valuation.Model{Method: valuation.FixedAppreciation, Rate: 0.1},
+	}}
+	valuations := []Valuation{{AssetID: 1, Date: day("2024-01-01"), Amount: 100000}}
+
+	points := Series([]time.Time{day("2025-01-01"), day("2026-01-01")}, assets, valuations, nil, nil)
+	if points[0].Assets != 110000 || points[1].Assets != 121000 {
+		t.Errorf("unexpected projected values %+v", points)
+	}
+}
@@ -0,0 +1,198 @@
+// Package portfolio tracks the holdings of an investment asset: position, cost basis and realized and
+// unrealized gains, by average cost or FIFO lots.
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Cost methods
+const (
+	AverageCost = "average" // Brazilian default (preço médio): every sale costs the average price of the position
+	FIFO        = "fifo"    // sales consume the oldest lots first
+)
+
+// Transaction sides
+const (
+	Buy  = "buy"
+	Sell = "sell"
+)
+
+// Transaction is a buy or a sell of a security. Fees increase the cost of buys and reduce the proceeds of sells.
+type Transaction struct {
+	Date     time.Time
+	Side     string
+	Quantity float64
+	Price    float64
+	Fees     float64
+}
+
+// Validate checks a transaction
+func (t Transaction) Validate() error {
+	if t.Side != Buy && t.Side != Sell {
+		return fmt.Errorf("side must be %s or %s", Buy, Sell)
+	}
+	if t.Quantity <= 0 {
+		return fmt.Errorf("quantity must be greater than zero")
+	}
+	if t.Price < 0 || t.Fees < 0 {
+		return fmt.Errorf("price and fees can't be negative")
+	}
+	return nil
+}
+
+// Lot is a quantity bought on a date that is still held
+type Lot struct {
+	Date     string  `json:"date"`
+	Quantity float64 `json:"quantity"`
+	UnitCost float64 `json:"unitCost"` // price plus fees per unit
+}
+
+// Realization is the gain of a sale
+type Realization struct {
+	Date     string  `json:"date"`
+	Quantity float64 `json:"quantity"`
+	Proceeds float64 `json:"proceeds"`
+	Cost     float64 `json:"cost"`
+	Gain     float64 `json:"gain"`
+}
+
+// Position is what is held after a series of transactions
+type Position struct {
+	Quantity     float64       `json:"quantity"`
+	CostBasis    float64       `json:"costBasis"`
+	AverageCost  float64       `json:"averageCost"`
+	RealizedGain float64       `json:"realizedGain"`
+	Lots         []Lot         `json:"lots,omitempty"` // FIFO only
+	Sales        []Realization `json:"sales"`
+	LastPrice    float64       `json:"-"` // price of the latest transaction, used when there is no market price
+}
+
+// epsilon absorbs the float error of fractional quantities
+const epsilon = 1e-8
+
+// Build replays the transactions in date order, keeping the given order on the same date, and returns the
+// position. Selling more than is held is an error.
+func Build(method string, transactions []Transaction) (Position, error) {
+	if method != AverageCost && method != FIFO {
+		return Position{}, fmt.Errorf("cost method must be %s or %s", AverageCost, FIFO)
+	}
+
+	sorted := append([]Transaction(nil), transactions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	p := Position{Sales: []Realization{}}
+	for _, t := range sorted {
+		if err := t.Validate(); err != nil {
+			return Position{}, err
+		}
+		p.LastPrice = t.Price
+
+		if t.Side == Buy {
+			cost := t.Quantity*t.Price + t.Fees
+			p.Quantity += t.Quantity
+			p.CostBasis += cost
+			if method == FIFO {
+				p.Lots = append(p.Lots, Lot{Date: t.Date.Format("2006-01-02"), Quantity: t.Quantity, UnitCost: cost / t.Quantity})
+			}
+			continue
+		}
+
+		if t.Quantity > p.Quantity+epsilon {
+			return Position{}, fmt.Errorf("sell of %g on %s exceeds the %g held", t.Quantity, t.Date.Format("2006-01-02"), p.Quantity)
+		}
+
+		var cost float64
+		if method == AverageCost {
+			cost = p.CostBasis / p.Quantity * t.Quantity
+		} else {
+			remaining := t.Quantity
+			for remaining > epsilon && len(p.Lots) > 0 {
+				lot := &p.Lots[0]
+				used := math.Min(lot.Quantity, remaining)
+				cost += used * lot.UnitCost
+				lot.Quantity -= used
+				remaining -= used
+				if lot.Quantity <= epsilon {
+					p.Lots = p.Lots[1:]
+				}
+			}
+		}
+
+		proceeds := t.Quantity*t.Price - t.Fees
+		p.Quantity -= t.Quantity
+		p.CostBasis -= cost
+		if p.Quantity <= epsilon {
+			p.Quantity, p.CostBasis, p.Lots = 0, 0, nil
+		}
+		p.RealizedGain += proceeds - cost
+		p.Sales = append(p.Sales, Realization{
+			Date:     t.Date.Format("2006-01-02"),
+			Quantity: t.Quantity,
+			Proceeds: round2(proceeds),
+			Cost:     round2(cost),
+			Gain:     round2(proceeds - cost),
+		})
+	}
+
+	p.CostBasis = round2(p.CostBasis)
+	p.RealizedGain = round2(p.RealizedGain)
+	if p.Quantity > 0 {
+		p.AverageCost = round6(p.CostBasis / p.Quantity)
+	}
+	for i := range p.Lots {
+		p.Lots[i].UnitCost = round6(p.Lots[i].UnitCost)
+	}
+	return p, nil
+}
+
+// Valuation is a position priced at the market
+type Valuation struct {
+	Price          float64 `json:"price"`
+	PriceDate      string  `json:"priceDate"`
+	MarketValue    float64 `json:"marketValue"`
+	UnrealizedGain float64 `json:"unrealizedGain"`
+}
+
+// Value prices the position
+func Value(p Position, price float64, priceDate string) Valuation {
+	value := round2(p.Quantity * price)
+	return Valuation{Price: price, PriceDate: priceDate, MarketValue: value, UnrealizedGain: round2(value - p.CostBasis)}
+}
+
+// Price is a market close price of a security on a date
+type Price struct {
+	Date  time.Time
+	Price float64
+}
+
+// PriceOn returns the latest price on or before the date from prices sorted by date
+func PriceOn(prices []Price, on time.Time) (Price, bool) {
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Date.After(on) })
+	if i == 0 {
+		return Price{}, false
+	}
+	return prices[i-1], true
+}
+
+// Until returns the transactions made on or before the date
+func Until(transactions []Transaction, on time.Time) []Transaction {
+	var result []Transaction
+	for _, t := range transactions {
+		if !t.Date.After(on) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
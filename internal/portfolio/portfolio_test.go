@@ -0,0 +1,104 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+var trades = []Transaction{
+	{Date: day("2025-01-10"), Side: Buy, Quantity: 100, Price: 10, Fees: 5},
+	{Date: day("2025-02-10"), Side: Buy, Quantity: 100, Price: 20, Fees: 5},
+	{Date: day("2025-03-10"), Side: Sell, Quantity: 150, Price: 25, Fees: 10},
+}
+
+func TestAverageCost(t *testing.T) {
+	p, err := Build(AverageCost, trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Average cost 3010 / 200 = 15.05, the sale costs 2257.50 and brings 3740
+	if p.Quantity != 50 || p.CostBasis != 752.5 || p.AverageCost != 15.05 || p.RealizedGain != 1482.5 {
+		t.Errorf("unexpected position %+v", p)
+	}
+
+	v := Value(p, 30, "2025-04-01")
+	if v.MarketValue != 1500 || v.UnrealizedGain != 747.5 {
+		t.Errorf("unexpected valuation %+v", v)
+	}
+}
+
+func TestFIFO(t *testing.T) {
+	p, err := Build(FIFO, trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sale consumes the 100 at 10.05 and 50 at 20.05
+	if p.Quantity != 50 || p.CostBasis != 1002.5 || p.RealizedGain != 1732.5 {
+		t.Errorf("unexpected position %+v", p)
+	}
+	if len(p.Lots) != 1 || p.Lots[0].Date != "2025-02-10" || p.Lots[0].Quantity != 50 {
+		t.Errorf("unexpected lots %+v", p.Lots)
+	}
+}
+
+func TestOversell(t *testing.T) {
+	_, err := Build(AverageCost, []Transaction{
+		{Date: day("2025-01-10"), Side: Buy, Quantity: 10, Price: 10},
+		{Date: day("2025-01-05"), Side: Sell, Quantity: 5, Price: 10}, // before the buy
+	})
+	if err == nil {
+		t.Error("expected error selling before buying")
+	}
+}
+
+func TestSellEverything(t *testing.T) {
+	p, _ := Build(FIFO, []Transaction{
+		{Date: day("2025-01-10"), Side: Buy, Quantity: 0.3, Price: 100},
+		{Date: day("2025-01-11"), Side: Buy, Quantity: 0.7, Price: 100},
+		{Date: day("2025-01-12"), Side: Sell, Quantity: 1, Price: 90},
+	})
+	if p.Quantity != 0 || p.CostBasis != 0 || p.RealizedGain != -10 || len(p.Lots) != 0 {
+		t.Errorf("unexpected position %+v", p)
+	}
+}
+
+func TestPriceOn(t *testing.T) {
+	prices := []Price{{day("2025-01-02"), 10}, {day("2025-01-05"), 12}}
+	if _, ok := PriceOn(prices, day("2025-01-01")); ok {
+		t.Error("expected no price before the first one")
+	}
+	if p, _ := PriceOn(prices, day("2025-01-04")); p.Price != 10 {
+		t.Errorf("got %v, want 10", p.Price)
+	}
+	if p, _ := PriceOn(prices, day("2025-02-01")); p.Price != 12 {
+		t.Errorf("got %v, want 12", p.Price)
+	}
+}
+
+func TestParsePrices(t *testing.T) {
+	quotes, err := ParsePrices([]byte("\xef\xbb\xbfData;Ativo;Fechamento\n02/01/2025;petr4;R$ 1.234,56\n03/01/2025;PETR4;37,10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 || quotes[0].Ticker != "PETR4" || quotes[0].Price != 1234.56 || !quotes[1].Date.Equal(day("2025-01-03")) {
+		t.Errorf("unexpected quotes %+v", quotes)
+	}
+
+	quotes, err = ParsePrices([]byte("VWCE,2025-01-02,120.5\n"))
+	if err != nil || len(quotes) != 1 || quotes[0].Price != 120.5 {
+		t.Errorf("unexpected quotes %+v (%v)", quotes, err)
+	}
+
+	if _, err := ParsePrices([]byte("ticker,date,price\nABC,2025-13-01,1\n")); err == nil {
+		t.Error("expected error on invalid date")
+	}
+
+	if _, err := ParsePrices([]byte("name,ticker,date,price\nAcme,ABC,2025-01-02\n")); err == nil {
+		t.Error("expected error on a row shorter than the header")
+	}
+}
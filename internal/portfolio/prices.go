@@ -0,0 +1,140 @@
+package portfolio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quote is one parsed line of a price file
+type Quote struct {
+	Ticker string    `json:"ticker"`
+	Date   time.Time `json:"-"`
+	Price  float64   `json:"price"`
+}
+
+// priceColumns are the accepted header names, in English and Portuguese
+var priceColumns = map[string][]string{
+	"ticker": {"ticker", "symbol", "codigo", "código", "ativo"},
+	"date":   {"date", "data"},
+	"price":  {"price", "close", "fechamento", "preco", "preço", "cotacao", "cotação"},
+}
+
+// ParsePrices reads a ticker,date,price CSV. The delimiter can be a comma, a semicolon or a tab, the header is
+// optional and can name the columns in any order, dates are YYYY-MM-DD or DD/MM/YYYY and prices accept the
+// Brazilian 1.234,56 format when the delimiter isn't a comma.
+func ParsePrices(data []byte) ([]Quote, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	delimiter := detectDelimiter(data)
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	columns := map[string]int{"ticker": 0, "date": 1, "price": 2}
+	width := 3
+	var quotes []Quote
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		if line == 1 {
+			if header, ok := headerColumns(record); ok {
+				columns, width = header, 0
+				for _, i := range header {
+					width = max(width, i+1)
+				}
+				continue
+			}
+		}
+
+		if len(record) < width {
+			return nil, fmt.Errorf("line %d: expected ticker, date and price", line)
+		}
+		ticker := strings.ToUpper(strings.TrimSpace(record[columns["ticker"]]))
+		if ticker == "" {
+			return nil, fmt.Errorf("line %d: ticker is empty", line)
+		}
+		date, err := parsePriceDate(record[columns["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		price, err := parsePrice(record[columns["price"]], delimiter)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		quotes = append(quotes, Quote{Ticker: ticker, Date: date, Price: price})
+	}
+
+	if len(quotes) == 0 {
+		return nil, fmt.Errorf("no prices found")
+	}
+	return quotes, nil
+}
+
+// detectDelimiter picks the delimiter that appears most in the first line
+func detectDelimiter(data []byte) rune {
+	first := data
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		first = data[:i]
+	}
+	best, count := ',', 0
+	for _, d := range []rune{';', '\t', ','} {
+		if n := bytes.Count(first, []byte(string(d))); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+// headerColumns maps the header names to their index, ok is false when the record isn't a header
+func headerColumns(record []string) (map[string]int, bool) {
+	columns := map[string]int{}
+	for i, field := range record {
+		name := strings.ToLower(strings.TrimSpace(field))
+		for column, names := range priceColumns {
+			for _, n := range names {
+				if name == n {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	return columns, len(columns) == len(priceColumns)
+}
+
+func parsePriceDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "02/01/2006"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or DD/MM/YYYY)", s)
+}
+
+func parsePrice(s string, delimiter rune) (float64, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimPrefix(s, "R$"))
+	if delimiter != ',' && strings.Contains(s, ",") {
+		s = strings.ReplaceAll(strings.ReplaceAll(s, ".", ""), ",", ".")
+	}
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil || price < 0 {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	return price, nil
+}
@@ -0,0 +1,85 @@
+package reconcile
+
+import (
+	"math"
+	"time"
+)
+
+// Options controls how far an actual can be from a forecast to still be matched to it
+type Options struct {
+	AmountTolerance float64 `json:"amountTolerance"` // fraction of the forecast amount, 0.10 = 10%
+	DateWindowDays  int     `json:"dateWindowDays"`  // days before or after the forecast date
+}
+
+// DefaultOptions is used when matching actuals automatically on creation
+var DefaultOptions = Options{AmountTolerance: 0.10, DateWindowDays: 15}
+
+// Candidate is a forecast occurrence an actual can be matched to. ForecastID is 0 for projected
+// occurrences that don't have a UserFinancialForecast row yet.
+type Candidate struct {
+	ForecastID int
+	Date       time.Time
+	Amount     float64
+	CurrencyID int
+}
+
+// Actual is the side being matched
+type Actual struct {
+	Date       time.Time
+	Amount     float64
+	CurrencyID int
+}
+
+// BestMatch returns the candidate nearest in date to the actual that is inside the date window and the
+// amount tolerance. Ties on date are broken by the smallest amount difference.
+func BestMatch(actual Actual, candidates []Candidate, opts Options) (Candidate, bool) {
+	var best Candidate
+	found := false
+	bestDays, bestDiff := 0, 0.0
+
+	for _, c := range candidates {
+		if c.CurrencyID != actual.CurrencyID {
+			continue
+		}
+
+		days := int(math.Abs(c.Date.Sub(actual.Date).Hours() / 24))
+		if days > opts.DateWindowDays {
+			continue
+		}
+
+		diff := math.Abs(actual.Amount - c.Amount)
+		if diff > math.Abs(c.Amount)*opts.AmountTolerance+0.005 {
+			continue
+		}
+
+		if !found || days < bestDays || (days == bestDays && diff < bestDiff) {
+			best, bestDays, bestDiff, found = c, days, diff, true
+		}
+	}
+
+	return best, found
+}
+
+// Variance is the comparison between what was expected and what actually happened
+type Variance struct {
+	Expected     float64  `json:"expected"`
+	Actual       float64  `json:"actual"`
+	Delta        float64  `json:"delta"`
+	DeltaPercent *float64 `json:"deltaPercent"` // nil when nothing was expected
+}
+
+// Add accumulates amounts into the variance and refreshes the delta
+func (v *Variance) Add(expected, actual float64) {
+	v.Expected = round2(v.Expected + expected)
+	v.Actual = round2(v.Actual + actual)
+	v.Delta = round2(v.Actual - v.Expected)
+	v.DeltaPercent = nil
+	if v.Expected != 0 {
+		pct := round2(v.Delta / v.Expected * 100)
+		v.DeltaPercent = &pct
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
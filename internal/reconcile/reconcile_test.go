@@ -0,0 +1,50 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestBestMatchPicksNearestDate(t *testing.T) {
+	candidates := []Candidate{
+		{ForecastID: 1, Date: day("2025-03-05"), Amount: 1000, CurrencyID: 1},
+		{ForecastID: 2, Date: day("2025-04-05"), Amount: 1000, CurrencyID: 1},
+	}
+	match, ok := BestMatch(Actual{Date: day("2025-04-02"), Amount: 1020, CurrencyID: 1}, candidates, DefaultOptions)
+	if !ok || match.ForecastID != 2 {
+		t.Fatalf("expected forecast 2, got %+v (found=%v)", match, ok)
+	}
+}
+
+func TestBestMatchRespectsToleranceAndCurrency(t *testing.T) {
+	candidates := []Candidate{
+		{ForecastID: 1, Date: day("2025-04-05"), Amount: 1000, CurrencyID: 1},
+		{ForecastID: 2, Date: day("2025-04-05"), Amount: 1500, CurrencyID: 2},
+	}
+	if _, ok := BestMatch(Actual{Date: day("2025-04-05"), Amount: 1500, CurrencyID: 1}, candidates, DefaultOptions); ok {
+		t.Error("expected no match outside the amount tolerance")
+	}
+	if _, ok := BestMatch(Actual{Date: day("2025-05-01"), Amount: 1000, CurrencyID: 1}, candidates, DefaultOptions); ok {
+		t.Error("expected no match outside the date window")
+	}
+}
+
+func TestVariance(t *testing.T) {
+	var v Variance
+	v.Add(1000, 0)
+	v.Add(0, 1100)
+	if v.Delta != 100 || v.DeltaPercent == nil || *v.DeltaPercent != 10 {
+		t.Errorf("unexpected variance %+v", v)
+	}
+
+	var unexpected Variance
+	unexpected.Add(0, 50)
+	if unexpected.DeltaPercent != nil {
+		t.Errorf("expected nil percent when nothing was expected, got %v", *unexpected.DeltaPercent)
+	}
+}
@@ -0,0 +1,326 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base period a Rule repeats on
+type Frequency int
+
+const (
+	Once Frequency = iota
+	Daily
+	Weekly
+	Monthly
+	Yearly
+)
+
+// maxPeriods protects the expansion loop against rules that never produce a date inside the window
+const maxPeriods = 100000
+
+// ErrNoSchedule is returned for recurrencies that can't be expanded, like "Variable"
+var ErrNoSchedule = errors.New("recurrency has no fixed schedule")
+
+// WeekdayNum is a BYDAY entry. N is the ordinal inside the month (1 = first, -1 = last, 0 = every)
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule describes how an item repeats. It covers the subset of RFC 5545 RRULE used for financial schedules
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int       // 0 means no limit
+	Until      time.Time // zero means open ended
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+}
+
+// FromPeriod builds a Rule from the RecurrencyPeriod column of the Recurrency table
+// ("today", "1 month", "4 months", "1 year", "2 weeks"...). RRULE strings are also accepted.
+func FromPeriod(period string) (*Rule, error) {
+	p := strings.ToLower(strings.TrimSpace(period))
+
+	switch {
+	case p == "today" || p == "once":
+		return &Rule{Freq: Once, Interval: 1}, nil
+	case p == "" || p == "undefined":
+		return nil, ErrNoSchedule
+	case strings.HasPrefix(p, "rrule:") || strings.HasPrefix(p, "freq="):
+		return Parse(period)
+	}
+
+	fields := strings.Fields(p)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid recurrency period %q", period)
+	}
+
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid recurrency period %q", period)
+	}
+
+	rule := &Rule{Interval: n}
+	switch strings.TrimSuffix(fields[1], "s") {
+	case "day":
+		rule.Freq = Daily
+	case "week":
+		rule.Freq = Weekly
+	case "month":
+		rule.Freq = Monthly
+	case "year":
+		rule.Freq = Yearly
+	default:
+		return nil, fmt.Errorf("invalid recurrency period %q", period)
+	}
+	return rule, nil
+}
+
+// Between returns every occurrence of the rule anchored at start that falls inside [from, to].
+// Dates are truncated to the day. When no BYMONTHDAY is given, monthly and yearly rules clamp the
+// anchor day to the end of shorter months (a bill due on the 31st is due on Feb 28), instead of
+// skipping the month like RFC 5545 does.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	start, from, to = dateOnly(start), dateOnly(from), dateOnly(to)
+	if to.Before(from) {
+		return nil
+	}
+
+	if r.Freq == Once {
+		if !start.Before(from) && !start.After(to) {
+			return []time.Time{start}
+		}
+		return nil
+	}
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 1
+	}
+
+	until := to
+	if !r.Until.IsZero() && dateOnly(r.Until).Before(until) {
+		until = dateOnly(r.Until)
+	}
+
+	var result []time.Time
+	count := 0
+
+	for i := 0; i < maxPeriods; i++ {
+		candidates := r.period(start, i*interval)
+		if len(candidates) == 0 {
+			continue
+		}
+
+		// Periods are generated in order, so once a period begins after the limit we are done
+		if candidates[0].After(until) {
+			break
+		}
+
+		for _, c := range candidates {
+			if c.Before(start) {
+				continue
+			}
+			if c.After(until) {
+				return result
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return result
+			}
+			if !c.Before(from) {
+				result = append(result, c)
+			}
+		}
+	}
+
+	return result
+}
+
+// Next returns the first occurrence on or after the given date
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	limit := dateOnly(after).AddDate(50, 0, 0)
+	if r.Freq == Once {
+		limit = dateOnly(start)
+	}
+	dates := r.Between(start, after, limit)
+	if len(dates) == 0 {
+		return time.Time{}, false
+	}
+	return dates[0], true
+}
+
+// period expands the n-th period (already multiplied by the interval) into sorted candidate dates
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	var dates []time.Time
+
+	switch r.Freq {
+	case Daily:
+		d := start.AddDate(0, 0, n)
+		if r.matchesMonth(d.Month()) && r.matchesMonthDay(d) && r.matchesWeekday(d) {
+			dates = append(dates, d)
+		}
+
+	case Weekly:
+		weekStart := start.AddDate(0, 0, 7*n)
+		if len(r.ByDay) == 0 {
+			dates = append(dates, weekStart)
+			break
+		}
+		// Weeks start on Monday (RFC 5545 WKST default)
+		monday := weekStart.AddDate(0, 0, -((int(weekStart.Weekday()) + 6) % 7))
+		for _, wd := range r.ByDay {
+			d := monday.AddDate(0, 0, (int(wd.Weekday)+6)%7)
+			if r.matchesMonth(d.Month()) {
+				dates = append(dates, d)
+			}
+		}
+
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(first.Month()) {
+			dates = r.expandMonth(first, start.Day())
+		}
+
+	case Yearly:
+		year := start.Year() + n
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, m := range months {
+			first := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+			dates = append(dates, r.expandMonth(first, start.Day())...)
+		}
+	}
+
+	sortDates(dates)
+	return r.applySetPos(dates)
+}
+
+// expandMonth returns the candidates inside the month starting at first
+func (r *Rule) expandMonth(first time.Time, anchorDay int) []time.Time {
+	last := daysIn(first)
+	var dates []time.Time
+
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, md := range r.ByMonthDay {
+			day := md
+			if md < 0 {
+				day = last + md + 1
+			}
+			if day < 1 || day > last {
+				continue
+			}
+			d := first.AddDate(0, 0, day-1)
+			if r.matchesWeekday(d) {
+				dates = append(dates, d)
+			}
+		}
+
+	case len(r.ByDay) > 0:
+		for _, wd := range r.ByDay {
+			var matches []time.Time
+			for day := 1; day <= last; day++ {
+				d := first.AddDate(0, 0, day-1)
+				if d.Weekday() == wd.Weekday {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case wd.N == 0:
+				dates = append(dates, matches...)
+			case wd.N > 0 && wd.N <= len(matches):
+				dates = append(dates, matches[wd.N-1])
+			case wd.N < 0 && -wd.N <= len(matches):
+				dates = append(dates, matches[len(matches)+wd.N])
+			}
+		}
+
+	default:
+		day := anchorDay
+		if day > last {
+			day = last
+		}
+		dates = append(dates, first.AddDate(0, 0, day-1))
+	}
+
+	sortDates(dates)
+	return dates
+}
+
+func (r *Rule) applySetPos(dates []time.Time) []time.Time {
+	if len(r.BySetPos) == 0 || len(dates) == 0 {
+		return dates
+	}
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(dates) + pos
+		}
+		if idx >= 0 && idx < len(dates) {
+			selected = append(selected, dates[idx])
+		}
+	}
+	sortDates(selected)
+	return selected
+}
+
+func (r *Rule) matchesMonth(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.ByMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := daysIn(d)
+	for _, md := range r.ByMonthDay {
+		if md == d.Day() || (md < 0 && last+md+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(d time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == d.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
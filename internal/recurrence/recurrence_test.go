@@ -0,0 +1,127 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func assertDates(t *testing.T, got []time.Time, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d dates %v, got %d: %v", len(want), want, len(got), got)
+	}
+	for i := range want {
+		if got[i].Format("2006-01-02") != want[i] {
+			t.Errorf("date %d: expected %s, got %s", i, want[i], got[i].Format("2006-01-02"))
+		}
+	}
+}
+
+func TestFromPeriod(t *testing.T) {
+	cases := map[string]Frequency{
+		"today":    Once,
+		"1 day":    Daily,
+		"2 weeks":  Weekly,
+		"1 month":  Monthly,
+		"3 months": Monthly,
+		"1 year":   Yearly,
+	}
+	for period, freq := range cases {
+		rule, err := FromPeriod(period)
+		if err != nil {
+			t.Fatalf("FromPeriod(%q) returned error: %v", period, err)
+		}
+		if rule.Freq != freq {
+			t.Errorf("FromPeriod(%q): expected frequency %d, got %d", period, freq, rule.Freq)
+		}
+	}
+
+	if _, err := FromPeriod("undefined"); err != ErrNoSchedule {
+		t.Errorf("expected ErrNoSchedule for Variable recurrency, got %v", err)
+	}
+	if _, err := FromPeriod("every now and then"); err == nil {
+		t.Error("expected error for invalid period")
+	}
+}
+
+func TestQuarterly(t *testing.T) {
+	rule, _ := FromPeriod("3 months")
+	got := rule.Between(date("2025-01-15"), date("2025-01-01"), date("2025-12-31"))
+	assertDates(t, got, "2025-01-15", "2025-04-15", "2025-07-15", "2025-10-15")
+}
+
+func TestMonthlyClampsToMonthEnd(t *testing.T) {
+	rule, _ := FromPeriod("1 month")
+	got := rule.Between(date("2025-01-31"), date("2025-01-01"), date("2025-04-30"))
+	assertDates(t, got, "2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30")
+}
+
+func TestBiweeklyWindow(t *testing.T) {
+	rule, _ := FromPeriod("2 weeks")
+	got := rule.Between(date("2025-01-03"), date("2025-02-01"), date("2025-03-01"))
+	assertDates(t, got, "2025-02-14", "2025-02-28")
+}
+
+func TestOnceOutsideWindow(t *testing.T) {
+	rule, _ := FromPeriod("today")
+	if got := rule.Between(date("2024-05-01"), date("2025-01-01"), date("2025-12-31")); len(got) != 0 {
+		t.Errorf("expected no occurrences, got %v", got)
+	}
+}
+
+func TestRRuleFifthBusinessDay(t *testing.T) {
+	rule, err := Parse("RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=5")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	got := rule.Between(date("2025-01-01"), date("2025-01-01"), date("2025-03-31"))
+	assertDates(t, got, "2025-01-07", "2025-02-07", "2025-03-07")
+}
+
+func TestRRuleLastFridayWithCount(t *testing.T) {
+	rule, err := Parse("FREQ=MONTHLY;BYDAY=-1FR;COUNT=2")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	got := rule.Between(date("2025-01-01"), date("2025-01-01"), date("2025-12-31"))
+	assertDates(t, got, "2025-01-31", "2025-02-28")
+}
+
+func TestRRuleCountIncludesDatesBeforeWindow(t *testing.T) {
+	rule, _ := Parse("FREQ=WEEKLY;COUNT=4")
+	got := rule.Between(date("2025-01-06"), date("2025-01-20"), date("2025-12-31"))
+	assertDates(t, got, "2025-01-20", "2025-01-27")
+}
+
+func TestRRuleYearlyUntil(t *testing.T) {
+	rule, _ := Parse("FREQ=YEARLY;BYMONTH=6,12;BYMONTHDAY=20;UNTIL=20260101")
+	got := rule.Between(date("2025-01-10"), date("2025-01-01"), date("2030-12-31"))
+	assertDates(t, got, "2025-06-20", "2025-12-20")
+}
+
+func TestRRuleRoundTrip(t *testing.T) {
+	in := "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=-1"
+	rule, err := Parse(in)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if out := rule.String(); out != in {
+		t.Errorf("expected %q, got %q", in, out)
+	}
+}
+
+func TestRRuleRejectsUnsupportedParts(t *testing.T) {
+	for _, s := range []string{"FREQ=HOURLY", "FREQ=DAILY;BYHOUR=9", "INTERVAL=2", "FREQ=WEEKLY;BYDAY=2MO"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var frequencyNames = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+// Parse reads an RFC 5545 RRULE string such as "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=5".
+// The "RRULE:" prefix is optional. Time based parts (BYHOUR, BYMINUTE...) are not supported.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "RRULE:") {
+		s = s[len("RRULE:"):]
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty RRULE")
+	}
+
+	rule := &Rule{Interval: 1}
+	hasFreq := false
+
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+		key, value := strings.ToUpper(strings.TrimSpace(kv[0])), strings.ToUpper(strings.TrimSpace(kv[1]))
+
+		switch key {
+		case "FREQ":
+			freq, ok := frequencyNames[value]
+			if !ok {
+				return nil, fmt.Errorf("unsupported RRULE frequency %q", value)
+			}
+			rule.Freq = freq
+			hasFreq = true
+
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid RRULE interval %q", value)
+			}
+			rule.Interval = n
+
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid RRULE count %q", value)
+			}
+			rule.Count = n
+
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+
+		case "BYMONTHDAY":
+			days, err := parseIntList(value, -31, 31)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYMONTHDAY %q", value)
+			}
+			rule.ByMonthDay = days
+
+		case "BYMONTH":
+			months, err := parseIntList(value, 1, 12)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYMONTH %q", value)
+			}
+			for _, m := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+
+		case "BYSETPOS":
+			positions, err := parseIntList(value, -366, 366)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RRULE BYSETPOS %q", value)
+			}
+			rule.BySetPos = positions
+
+		case "WKST":
+			// Weeks always start on Monday here, which is the RFC 5545 default
+			if value != "MO" {
+				return nil, fmt.Errorf("unsupported RRULE WKST %q", value)
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+	}
+
+	if !hasFreq {
+		return nil, fmt.Errorf("RRULE is missing FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, fmt.Errorf("RRULE cannot have both COUNT and UNTIL")
+	}
+	for _, wd := range rule.ByDay {
+		if wd.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, fmt.Errorf("RRULE BYDAY ordinals are only valid for MONTHLY and YEARLY rules")
+		}
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return nil, fmt.Errorf("RRULE YEARLY with BYDAY requires BYMONTH")
+	}
+
+	return rule, nil
+}
+
+// String renders the rule back as an RRULE value (without the "RRULE:" prefix).
+// One time rules have no RRULE representation and return an empty string.
+func (r *Rule) String() string {
+	var freq string
+	for name, f := range frequencyNames {
+		if f == r.Freq {
+			freq = name
+		}
+	}
+	if freq == "" {
+		return ""
+	}
+
+	parts := []string{"FREQ=" + freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+	}
+	if len(r.ByMonth) > 0 {
+		var months []string
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		var days []string
+		for _, wd := range r.ByDay {
+			code := ""
+			for c, w := range weekdayCodes {
+				if w == wd.Weekday {
+					code = c
+				}
+			}
+			if wd.N != 0 {
+				code = strconv.Itoa(wd.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	return strings.Join(parts, ";")
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return dateOnly(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid RRULE UNTIL %q", value)
+}
+
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	code = strings.TrimSpace(code)
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", code)
+	}
+	wd, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", code)
+	}
+	n := 0
+	if prefix := code[:len(code)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid RRULE BYDAY %q", code)
+		}
+	}
+	return WeekdayNum{Weekday: wd, N: n}, nil
+}
+
+func parseIntList(value string, min, max int) ([]int, error) {
+	var list []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid value %q", item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+func joinInts(values []int) string {
+	var s []string
+	for _, v := range values {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ",")
+}
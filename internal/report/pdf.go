@@ -0,0 +1,262 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page in points, with the margins of the report
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	marginLeft   = 50.0
+	marginRight  = 545.0
+	marginTop    = 792.0
+	marginBottom = 60.0
+)
+
+// Right edges of the amount columns
+var amountColumns = []float64{360, 430, 485, marginRight}
+
+// helveticaWidths and helveticaBoldWidths are the widths of the characters 32 to 126, in thousandths of the font
+// size, from the AFM files of the standard fonts. Other characters are measured as 556.
+var helveticaWidths = []int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = []int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding has
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96,
+	'—': 0x97,
+}
+
+// pdfDocument writes pages of text and lines with the standard Helvetica fonts, which readers provide, so nothing
+// has to be embedded
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64 // baseline of the next line on the current page
+}
+
+func (d *pdfDocument) newPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+	d.y = marginTop
+}
+
+// ensure starts a new page when less than height is left on the current one
+func (d *pdfDocument) ensure(height float64) {
+	if d.page == nil || d.y-height < marginBottom {
+		d.newPage()
+	}
+}
+
+// text writes s with its left edge at x on the current line
+func (d *pdfDocument) text(x float64, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, pdfString(s))
+}
+
+// textRight writes s with its right edge at x on the current line
+func (d *pdfDocument) textRight(x float64, size float64, bold bool, s string) {
+	d.text(x-textWidth(s, size, bold), size, bold, s)
+}
+
+// rule draws a horizontal line under the current line
+func (d *pdfDocument) rule(width float64) {
+	fmt.Fprintf(d.page, "%.1f w %.2f %.2f m %.2f %.2f l S\n", width, marginLeft, d.y-4, marginRight, d.y-4)
+}
+
+// write assembles the document: catalog, page tree, the two fonts, then each page with its content stream
+func (d *pdfDocument) write(w io.Writer) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// pdfString encodes s in WinAnsiEncoding and escapes it for a PDF literal string
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		var c byte
+		switch {
+		case r < 0x20:
+			c = ' '
+		case r < 0x7f || (r >= 0xa0 && r <= 0xff):
+			c = byte(r)
+		default:
+			var ok bool
+			if c, ok = winAnsi[r]; !ok {
+				c = '?'
+			}
+		}
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// textWidth measures s in points
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := helveticaWidths
+	if bold {
+		widths = helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += widths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// fit shortens s with an ellipsis until it fits in width
+func fit(s string, width, size float64, bold bool) string {
+	if textWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// varianceRow writes a name with its expected, actual, delta and delta percent columns
+func (d *pdfDocument) varianceRow(name string, expected, actual, delta string, percent string, bold bool) {
+	const size = 9
+	d.ensure(14)
+	d.text(marginLeft, size, bold, fit(name, amountColumns[0]-marginLeft-80, size, bold))
+	for i, value := range []string{expected, actual, delta, percent} {
+		d.textRight(amountColumns[i], size, bold, value)
+	}
+	d.y -= 14
+}
+
+func (d *pdfDocument) varianceLine(line Line, bold bool) {
+	d.varianceRow(line.Name, FormatAmount(line.Expected), FormatAmount(line.Actual), FormatAmount(line.Delta),
+		FormatPercent(line.DeltaPercent), bold)
+}
+
+// heading writes the title of a table with the column names
+func (d *pdfDocument) heading(title string) {
+	d.ensure(60)
+	d.y -= 10
+	d.text(marginLeft, 12, true, title)
+	d.y -= 18
+	d.varianceRow("", "Forecast", "Actual", "Difference", "%", true)
+	d.y += 14
+	d.rule(0.5)
+	d.y -= 14
+}
+
+// WritePDF writes the report as a PDF document
+func WritePDF(w io.Writer, rep Report) error {
+	d := &pdfDocument{}
+	d.newPage()
+
+	title := "Monthly financial statement"
+	if rep.Kind == Annual {
+		title = "Annual financial statement"
+	}
+	d.text(marginLeft, 18, true, title)
+	d.y -= 20
+	d.text(marginLeft, 11, false, fmt.Sprintf("%s (%s to %s), amounts in %s", rep.Period, rep.From, rep.To, rep.Currency))
+	d.y -= 14
+	d.text(marginLeft, 8, false, "Generated on "+rep.GeneratedAt)
+	d.y -= 10
+
+	for _, section := range rep.Sections {
+		d.heading(section.Title)
+		if len(section.Lines) == 0 {
+			d.text(marginLeft, 9, false, "Nothing in the period")
+			d.y -= 14
+			continue
+		}
+		for _, line := range section.Lines {
+			d.varianceLine(line, false)
+		}
+		d.y += 10
+		d.rule(0.3)
+		d.y -= 10
+		d.varianceLine(Line{Name: "Total", Variance: section.Total}, true)
+	}
+
+	d.heading("Net result")
+	d.varianceLine(Line{Name: "Income and asset income less taxes and expenses", Variance: rep.Net}, true)
+
+	if len(rep.Months) > 0 {
+		d.heading("Net result by month")
+		for _, month := range rep.Months {
+			d.varianceLine(month, false)
+		}
+	}
+
+	d.heading("Largest differences from the forecast")
+	if len(rep.Variances) == 0 {
+		d.text(marginLeft, 9, false, "Everything went as forecast")
+		d.y -= 14
+	}
+	for _, line := range rep.Variances {
+		d.varianceLine(line, false)
+	}
+
+	// Page numbers
+	for i, page := range d.pages {
+		d.page, d.y = page, 30
+		d.textRight(marginRight, 8, false, fmt.Sprintf("Page %d of %d", i+1, len(d.pages)))
+	}
+	return d.write(w)
+}
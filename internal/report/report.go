@@ -0,0 +1,211 @@
+// Package report builds the monthly and annual financial statements: income by type, taxes, expenses by category,
+// asset income and the net result, each compared with what was forecast. A statement renders as HTML through a
+// template or as a PDF written in pure Go.
+package report
+
+import (
+	"finanapp/internal/reconcile"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report kinds
+const (
+	Monthly = "monthly"
+	Annual  = "annual"
+)
+
+// Sections of a report, in the order they are shown
+const (
+	SectionIncome      = "income"
+	SectionTaxes       = "taxes"
+	SectionExpenses    = "expenses"
+	SectionAssetIncome = "asset_income"
+)
+
+var sectionTitles = map[string]string{
+	SectionIncome:      "Income by type",
+	SectionTaxes:       "Taxes",
+	SectionExpenses:    "Expenses by category",
+	SectionAssetIncome: "Asset income",
+}
+
+var sectionOrder = []string{SectionIncome, SectionTaxes, SectionExpenses, SectionAssetIncome}
+
+// topVariances is how many lines the forecast variance lists
+const topVariances = 10
+
+// Period returns the first and last day of a month, or of a year for annual reports, and its label
+func Period(kind string, year, month int) (time.Time, time.Time, string, error) {
+	if year < 1900 || year > 9999 {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("invalid year")
+	}
+	switch kind {
+	case Monthly:
+		if month < 1 || month > 12 {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("month must be between 1 and 12")
+		}
+		from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, -1), from.Format("January 2006"), nil
+	case Annual:
+		from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, -1), strconv.Itoa(year), nil
+	}
+	return time.Time{}, time.Time{}, "", fmt.Errorf("period must be %s or %s", Monthly, Annual)
+}
+
+// Entry is an amount expected or booked in the period, already in the report currency
+type Entry struct {
+	Section  string
+	Group    string // income type, tax type, expense category or asset
+	Date     time.Time
+	Expected float64
+	Actual   float64
+}
+
+// Line is the variance of one group, or of one month on the monthly breakdown
+type Line struct {
+	Name string `json:"name"`
+	reconcile.Variance
+}
+
+// Section is one part of the report with its lines ordered by actual amount
+type Section struct {
+	Key   string             `json:"key"`
+	Title string             `json:"title"`
+	Lines []Line             `json:"lines"`
+	Total reconcile.Variance `json:"total"`
+}
+
+// Report is a financial statement of a period
+type Report struct {
+	Kind        string             `json:"kind"`
+	Period      string             `json:"period"`
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Currency    string             `json:"currency"`
+	GeneratedAt string             `json:"generatedAt"`
+	Sections    []Section          `json:"sections"`
+	Net         reconcile.Variance `json:"net"`              // income and asset income less taxes and expenses
+	Variances   []Line             `json:"variances"`        // largest differences from the forecast
+	Months      []Line             `json:"months,omitempty"` // net of each month, annual reports only
+}
+
+// Build adds up the entries of the period. Entries of unknown sections are ignored.
+func Build(kind, period string, from, to time.Time, currency string, entries []Entry, generatedAt time.Time) Report {
+	rep := Report{
+		Kind:        kind,
+		Period:      period,
+		From:        from.Format("2006-01-02"),
+		To:          to.Format("2006-01-02"),
+		Currency:    currency,
+		GeneratedAt: generatedAt.Format("2006-01-02 15:04"),
+		Variances:   []Line{},
+	}
+
+	groups := map[string]map[string]*Line{}
+	months := map[string]*Line{}
+	for _, key := range sectionOrder {
+		groups[key] = map[string]*Line{}
+	}
+	if kind == Annual {
+		for m := from; !m.After(to); m = m.AddDate(0, 1, 0) {
+			months[m.Format("2006-01")] = &Line{Name: m.Format("2006-01")}
+		}
+	}
+
+	for _, e := range entries {
+		lines, ok := groups[e.Section]
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(e.Group)
+		if name == "" {
+			name = "Other"
+			if e.Section == SectionExpenses {
+				name = "Uncategorized"
+			}
+		}
+		line, ok := lines[name]
+		if !ok {
+			line = &Line{Name: name}
+			lines[name] = line
+		}
+		line.Add(e.Expected, e.Actual)
+
+		sign := 1.0
+		if e.Section == SectionTaxes || e.Section == SectionExpenses {
+			sign = -1
+		}
+		rep.Net.Add(sign*e.Expected, sign*e.Actual)
+		if month, ok := months[e.Date.Format("2006-01")]; ok {
+			month.Add(sign*e.Expected, sign*e.Actual)
+		}
+	}
+
+	for _, key := range sectionOrder {
+		section := Section{Key: key, Title: sectionTitles[key], Lines: []Line{}}
+		for _, line := range groups[key] {
+			section.Lines = append(section.Lines, *line)
+			section.Total.Add(line.Expected, line.Actual)
+			rep.Variances = append(rep.Variances, Line{Name: section.Title + ": " + line.Name, Variance: line.Variance})
+		}
+		sort.Slice(section.Lines, func(i, j int) bool {
+			if section.Lines[i].Actual != section.Lines[j].Actual {
+				return section.Lines[i].Actual > section.Lines[j].Actual
+			}
+			return section.Lines[i].Name < section.Lines[j].Name
+		})
+		rep.Sections = append(rep.Sections, section)
+	}
+
+	sort.SliceStable(rep.Variances, func(i, j int) bool {
+		a, b := math.Abs(rep.Variances[i].Delta), math.Abs(rep.Variances[j].Delta)
+		if a != b {
+			return a > b
+		}
+		return rep.Variances[i].Name < rep.Variances[j].Name
+	})
+	kept := rep.Variances[:0]
+	for _, line := range rep.Variances {
+		if line.Delta != 0 && len(kept) < topVariances {
+			kept = append(kept, line)
+		}
+	}
+	rep.Variances = kept
+
+	for _, month := range months {
+		rep.Months = append(rep.Months, *month)
+	}
+	sort.Slice(rep.Months, func(i, j int) bool { return rep.Months[i].Name < rep.Months[j].Name })
+	return rep
+}
+
+// FormatAmount writes an amount with two decimals and thousand separators: -1,234.56
+func FormatAmount(v float64) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', 2, 64)
+	whole, decimals := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	if v < 0 && math.Round(v*100) != 0 {
+		b.WriteByte('-')
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String() + decimals
+}
+
+// FormatPercent writes a percentage with one decimal, or a dash when there is none
+func FormatPercent(p *float64) string {
+	if p == nil {
+		return "-"
+	}
+	return strconv.FormatFloat(*p, 'f', 1, 64) + "%"
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestPeriod(t *testing.T) {
+	from, to, label, err := Period(Monthly, 2024, 2)
+	if err != nil || !from.Equal(day("2024-02-01")) || !to.Equal(day("2024-02-29")) || label != "February 2024" {
+		t.Errorf("unexpected monthly period %v %v %s %v", from, to, label, err)
+	}
+	from, to, label, err = Period(Annual, 2025, 0)
+	if err != nil || !from.Equal(day("2025-01-01")) || !to.Equal(day("2025-12-31")) || label != "2025" {
+		t.Errorf("unexpected annual period %v %v %s %v", from, to, label, err)
+	}
+	if _, _, _, err := Period(Monthly, 2025, 13); err == nil {
+		t.Error("expected an error for month 13")
+	}
+	if _, _, _, err := Period("weekly", 2025, 1); err == nil {
+		t.Error("expected an error for an unknown period")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	entries := []Entry{
+		{Section: SectionIncome, Group: "Salary", Date: day("2025-01-05"), Expected: 5000, Actual: 5200},
+		{Section: SectionIncome, Group: "Freelance", Date: day("2025-02-10"), Actual: 800},
+		{Section: SectionTaxes, Group: "Income Tax", Date: day("2025-01-05"), Expected: 1000, Actual: 1040},
+		{Section: SectionExpenses, Group: "Rent", Date: day("2025-01-10"), Expected: 1500, Actual: 1500},
+		{Section: SectionExpenses, Group: "", Date: day("2025-02-15"), Actual: 60},
+		{Section: SectionAssetIncome, Group: "Flat", Date: day("2025-02-01"), Expected: 900, Actual: 900},
+		{Section: "unknown", Group: "x", Date: day("2025-01-01"), Actual: 1},
+	}
+	rep := Build(Annual, "2025", day("2025-01-01"), day("2025-12-31"), "BRL", entries, day("2025-12-31"))
+
+	if len(rep.Sections) != 4 || rep.Sections[0].Key != SectionIncome || rep.Sections[3].Key != SectionAssetIncome {
+		t.Fatalf("unexpected sections %+v", rep.Sections)
+	}
+	income := rep.Sections[0]
+	if income.Lines[0].Name != "Salary" || income.Total.Actual != 6000 || income.Total.Expected != 5000 {
+		t.Errorf("unexpected income %+v", income)
+	}
+	if expenses := rep.Sections[2]; expenses.Lines[1].Name != "Uncategorized" || expenses.Total.Actual != 1560 {
+		t.Errorf("unexpected expenses %+v", expenses)
+	}
+	// 5000 - 1000 - 1500 + 900 expected, 6000 - 1040 - 1560 + 900 actual
+	if rep.Net.Expected != 3400 || rep.Net.Actual != 4300 || rep.Net.Delta != 900 {
+		t.Errorf("unexpected net %+v", rep.Net)
+	}
+	if len(rep.Months) != 12 || rep.Months[0].Actual != 2660 || rep.Months[1].Actual != 1640 || rep.Months[2].Actual != 0 {
+		t.Errorf("unexpected months %+v", rep.Months)
+	}
+	if len(rep.Variances) != 4 || rep.Variances[0].Name != "Income by type: Freelance" || rep.Variances[0].Delta != 800 {
+		t.Errorf("unexpected variances %+v", rep.Variances)
+	}
+
+	monthly := Build(Monthly, "January 2025", day("2025-01-01"), day("2025-01-31"), "BRL", nil, day("2025-01-31"))
+	if monthly.Months != nil || len(monthly.Sections[0].Lines) != 0 || len(monthly.Variances) != 0 {
+		t.Errorf("unexpected empty report %+v", monthly)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for v, want := range map[float64]string{
+		0: "0.00", 12.5: "12.50", 999.999: "1,000.00", 1234567.891: "1,234,567.89", -1500: "-1,500.00", -0.001: "0.00",
+	} {
+		if got := FormatAmount(v); got != want {
+			t.Errorf("FormatAmount(%v) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	if got := pdfString(`Café (rent) \ €5`); got != "Caf\xe9 \\(rent\\) \\\\ \x805" {
+		t.Errorf("unexpected encoding %q", got)
+	}
+}
+
+func TestFit(t *testing.T) {
+	long := strings.Repeat("Groceries ", 10)
+	got := fit(long, 100, 9, false)
+	if !strings.HasSuffix(got, "...") || textWidth(got, 9, false) > 100 {
+		t.Errorf("unexpected fit %q", got)
+	}
+	if fit("Rent", 100, 9, false) != "Rent" {
+		t.Error("short text should be kept")
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var entries []Entry
+	for i := 0; i < 80; i++ {
+		entries = append(entries, Entry{Section: SectionExpenses, Group: strings.Repeat("x", i+1), Date: day("2025-03-01"), Actual: float64(i)})
+	}
+	rep := Build(Monthly, "March 2025", day("2025-03-01"), day("2025-03-31"), "BRL", entries, day("2025-03-31"))
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatal("not a PDF document")
+	}
+	if !strings.Contains(pdf, "/Count 3 ") || !strings.Contains(pdf, "(Page 3 of 3)") {
+		t.Error("expected the expenses to run over three pages")
+	}
+
+	// Every xref offset points at its object
+	start := strings.LastIndex(pdf, "xref\n")
+	lines := strings.Split(pdf[start:], "\n")
+	for i, line := range lines[3:] {
+		if !strings.HasSuffix(line, " n ") {
+			break
+		}
+		offset, _ := strconv.Atoi(line[:10])
+		if got := strings.Split(pdf[offset:], "\n")[0]; got != strconv.Itoa(i+1)+" 0 obj" {
+			t.Errorf("xref entry %d points at %q", i+1, got)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterActualRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/actual", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateActual),
+	)))
+	mux.Handle("/api/actual-update", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateActual),
+	)))
+	mux.Handle("/api/delete-actual", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteActual),
+	)))
+	mux.Handle("/api/actual-item", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ActualsByItem),
+	)))
+	mux.Handle("/api/actuals", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ActualsByDateRange),
+	)))
+}
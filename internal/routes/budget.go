@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterBudgetRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/budget", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SaveBudget),
+	)))
+	mux.Handle("/api/delete-budget", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteBudget),
+	)))
+	mux.Handle("/api/budgets/status", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.BudgetStatus),
+	)))
+}
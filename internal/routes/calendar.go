@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterCalendarRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	// Calendar clients can't log in, the token in the URL identifies the user
+	mux.Handle("/api/calendar/{file}", corsMiddleware.Handler(http.HandlerFunc(handlers.CalendarICS)))
+	mux.Handle("/api/calendar-feed", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CalendarFeed),
+	)))
+	mux.Handle("/api/create-calendar-feed", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateCalendarFeed),
+	)))
+	mux.Handle("/api/delete-calendar-feed", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RevokeCalendarFeed),
+	)))
+}
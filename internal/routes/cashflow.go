@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterCashFlowRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/cashflow", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CashFlow),
+	)))
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterDuplicateRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/duplicates", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Duplicates),
+	)))
+	mux.Handle("/api/duplicates/scan", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ScanDuplicates),
+	)))
+	mux.Handle("/api/duplicates/{id}/resolve", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ResolveDuplicate),
+	)))
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterExchangeRateRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/admin/exchange-rates/import", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(middlewares.AdminMiddleware(handlers.ImportExchangeRates)),
+	)))
+}
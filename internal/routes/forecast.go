@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterForecastRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/forecast/occurrences", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ForecastOccurrences),
+	)))
+	mux.Handle("/api/forecast/item-recurrence", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateItemRecurrence),
+	)))
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterGoalRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/goal", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateGoal),
+	)))
+	mux.Handle("/api/goals", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Goals),
+	)))
+	mux.Handle("/api/goal/{id}", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.GoalDetail),
+	)))
+	mux.Handle("/api/goal-update", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateGoal),
+	)))
+	mux.Handle("/api/delete-goal", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteGoal),
+	)))
+	mux.Handle("/api/goal-contribution", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.AddGoalContribution),
+	)))
+}
@@ -0,0 +1,52 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterGroupRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/group", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateGroup),
+	)))
+	mux.Handle("/api/groups", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Groups),
+	)))
+	mux.Handle("/api/group/{id}", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.GroupDetail),
+	)))
+	mux.Handle("/api/delete-group", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteGroup),
+	)))
+	mux.Handle("/api/group-invite", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.InviteGroupMember),
+	)))
+	mux.Handle("/api/group-invites", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.GroupInvites),
+	)))
+	mux.Handle("/api/group-invite/respond", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RespondGroupInvite),
+	)))
+	mux.Handle("/api/revoke-group-invite", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RevokeGroupInvite),
+	)))
+	mux.Handle("/api/group-member-role", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateGroupMemberRole),
+	)))
+	mux.Handle("/api/remove-group-member", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RemoveGroupMember),
+	)))
+	mux.Handle("/api/group-item", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateGroupItem),
+	)))
+	mux.Handle("/api/group-item-update", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateGroupItem),
+	)))
+	mux.Handle("/api/delete-group-item", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteGroupItem),
+	)))
+}
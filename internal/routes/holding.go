@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterHoldingRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/holding", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateHolding),
+	)))
+	mux.Handle("/api/holdings", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Holdings),
+	)))
+	mux.Handle("/api/holding-cost-method", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateHoldingCostMethod),
+	)))
+	mux.Handle("/api/delete-holding", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteHolding),
+	)))
+	mux.Handle("/api/holding-transaction", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.AddHoldingTransaction),
+	)))
+	mux.Handle("/api/delete-holding-transaction", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteHoldingTransaction),
+	)))
+	mux.Handle("/api/security-prices/import", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportSecurityPrices),
+	)))
+}
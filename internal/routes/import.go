@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterImportRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/import/ofx", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportOFX),
+	)))
+	mux.Handle("/api/import/csv", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportCSV),
+	)))
+	mux.Handle("/api/import-profile", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SaveImportProfile),
+	)))
+	mux.Handle("/api/import-profiles", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportProfiles),
+	)))
+	mux.Handle("/api/delete-import-profile", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteImportProfile),
+	)))
+	mux.Handle("/api/imports", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportBatches),
+	)))
+	mux.Handle("/api/import/{id}", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ImportPreview),
+	)))
+	mux.Handle("/api/import/{id}/commit", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CommitImport),
+	)))
+	mux.Handle("/api/import-transaction", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateImportedTransaction),
+	)))
+	mux.Handle("/api/delete-import", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DiscardImport),
+	)))
+}
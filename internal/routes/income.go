@@ -34,4 +34,7 @@ func RegisterIncomeRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
 	mux.Handle("/api/create-income-expense", corsMiddleware.Handler(http.HandlerFunc(
 		middlewares.AuthMiddleware(handlers.CreateIncomeExpense),
 	)))
+	mux.Handle("/api/income/{id}/compute-withholding", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ComputeWithholding),
+	)))
 }
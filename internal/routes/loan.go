@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterLoanRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/loan", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SaveLoan),
+	)))
+	mux.Handle("/api/loans", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Loans),
+	)))
+	mux.Handle("/api/loan/{id}", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.LoanDetail),
+	)))
+	mux.Handle("/api/loan/simulate", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SimulateLoan),
+	)))
+	mux.Handle("/api/delete-loan", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteLoan),
+	)))
+	mux.Handle("/api/loan-prepayment", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.AddLoanPrepayment),
+	)))
+	mux.Handle("/api/delete-loan-prepayment", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteLoanPrepayment),
+	)))
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterNetWorthRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/asset-valuation", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RecordAssetValuation),
+	)))
+	mux.Handle("/api/asset-valuations", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.AssetValuations),
+	)))
+	mux.Handle("/api/delete-asset-valuation", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteAssetValuation),
+	)))
+	mux.Handle("/api/asset-valuation-model", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateAssetValuationModel),
+	)))
+	mux.Handle("/api/net-worth", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.NetWorth),
+	)))
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterReconciliationRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/reconciliation/auto", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.AutoReconcile),
+	)))
+	mux.Handle("/api/reconciliation/link", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.LinkActual),
+	)))
+	mux.Handle("/api/reconciliation/unlink", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UnlinkActual),
+	)))
+	mux.Handle("/api/reconciliation/variance", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.VarianceReport),
+	)))
+}
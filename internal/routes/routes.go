@@ -14,6 +14,27 @@ func RegisterRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
 	RegisterIncomeRoutes(mux, corsMiddleware)
 	RegisterAssetRoutes(mux, corsMiddleware)
 	RegisterExpenseRoutes(mux, corsMiddleware)
+	RegisterForecastRoutes(mux, corsMiddleware)
+	RegisterActualRoutes(mux, corsMiddleware)
+	RegisterReconciliationRoutes(mux, corsMiddleware)
+	RegisterExchangeRateRoutes(mux, corsMiddleware)
+	RegisterCashFlowRoutes(mux, corsMiddleware)
+	RegisterNetWorthRoutes(mux, corsMiddleware)
+	RegisterTaxRoutes(mux, corsMiddleware)
+	RegisterBudgetRoutes(mux, corsMiddleware)
+	RegisterGroupRoutes(mux, corsMiddleware)
+	RegisterSplitRoutes(mux, corsMiddleware)
+	RegisterLoanRoutes(mux, corsMiddleware)
+	RegisterHoldingRoutes(mux, corsMiddleware)
+	RegisterGoalRoutes(mux, corsMiddleware)
+	RegisterImportRoutes(mux, corsMiddleware)
+	RegisterRuleRoutes(mux, corsMiddleware)
+	RegisterDuplicateRoutes(mux, corsMiddleware)
+	RegisterExportRoutes(mux, corsMiddleware)
+	RegisterReportRoutes(mux, corsMiddleware)
+	RegisterCalendarRoutes(mux, corsMiddleware)
+	RegisterTrashRoutes(mux, corsMiddleware)
+	RegisterAuditRoutes(mux, corsMiddleware)
 
 	// Static
 	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterRuleRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/rules", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Rules),
+	)))
+	mux.Handle("/api/rule", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateRule),
+	)))
+	mux.Handle("/api/rule-update", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.UpdateRule),
+	)))
+	mux.Handle("/api/delete-rule", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteRule),
+	)))
+	mux.Handle("/api/rules/apply", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.ApplyRules),
+	)))
+	mux.Handle("/api/rules/suggestions", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SuggestRules),
+	)))
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterSplitRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/split-expense", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateSplitExpense),
+	)))
+	mux.Handle("/api/split-expenses", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SplitExpenses),
+	)))
+	mux.Handle("/api/delete-split-expense", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteSplitExpense),
+	)))
+	mux.Handle("/api/splits/balances", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SplitBalances),
+	)))
+	mux.Handle("/api/split-settlement", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.CreateSplitSettlement),
+	)))
+	mux.Handle("/api/split-settlements", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SplitSettlements),
+	)))
+	mux.Handle("/api/delete-split-settlement", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteSplitSettlement),
+	)))
+	mux.Handle("/api/split-contact", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SplitContactRequest),
+	)))
+	mux.Handle("/api/split-contacts", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.SplitContacts),
+	)))
+	mux.Handle("/api/split-contact/respond", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RespondSplitContact),
+	)))
+	mux.Handle("/api/delete-split-contact", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.DeleteSplitContact),
+	)))
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterTaxRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/admin/tax-rule", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(middlewares.AdminMiddleware(handlers.UpdateTaxRule)),
+	)))
+
+	mux.Handle("/api/admin/delete-tax-rule", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(middlewares.AdminMiddleware(handlers.DeleteTaxRule)),
+	)))
+}
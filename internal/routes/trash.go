@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"finanapp/internal/handlers"
+	"finanapp/internal/middlewares"
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+func RegisterTrashRoutes(mux *http.ServeMux, corsMiddleware *cors.Cors) {
+
+	mux.Handle("/api/trash", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.Trash),
+	)))
+	mux.Handle("/api/trash/{id}/restore", corsMiddleware.Handler(http.HandlerFunc(
+		middlewares.AuthMiddleware(handlers.RestoreTrashItem),
+	)))
+}
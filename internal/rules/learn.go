@@ -0,0 +1,102 @@
+package rules
+
+import (
+	"finanapp/internal/statement"
+	"sort"
+)
+
+// Example is a transaction the user already categorized
+type Example struct {
+	Description string
+	Amount      float64 // negative for debits
+	ItemID      int
+	CategoryID  *int
+}
+
+// Suggestion is a rule learned from examples
+type Suggestion struct {
+	DescriptionContains string   `json:"descriptionContains"`
+	Sign                string   `json:"sign"`
+	ItemID              int      `json:"financialUserItemId"`
+	CategoryID          *int     `json:"userCategoryId"`
+	Support             int      `json:"support"`    // examples that agree with the suggestion
+	Confidence          float64  `json:"confidence"` // share of the examples with the same description that agree
+	Examples            []string `json:"examples"`
+}
+
+// Learn groups the examples by normalized description and sign and suggests a rule for every group where at least
+// minSupport examples, and at least minConfidence of the group, went to the same item and category.
+func Learn(examples []Example, minSupport int, minConfidence float64) []Suggestion {
+	type outcome struct {
+		itemID     int
+		categoryID int // 0 without category
+	}
+	type group struct {
+		description string
+		sign        string
+		total       int
+		outcomes    map[outcome]int
+		samples     map[outcome][]string
+	}
+
+	groups := map[string]*group{}
+	var order []string
+	for _, ex := range examples {
+		description := statement.Normalize(ex.Description)
+		if description == "" {
+			continue
+		}
+		sign := Credit
+		if ex.Amount < 0 {
+			sign = Debit
+		}
+		key := sign + "|" + description
+		g, ok := groups[key]
+		if !ok {
+			g = &group{description: description, sign: sign, outcomes: map[outcome]int{}, samples: map[outcome][]string{}}
+			groups[key] = g
+			order = append(order, key)
+		}
+		o := outcome{itemID: ex.ItemID}
+		if ex.CategoryID != nil {
+			o.categoryID = *ex.CategoryID
+		}
+		g.total++
+		g.outcomes[o]++
+		if len(g.samples[o]) < 3 {
+			g.samples[o] = append(g.samples[o], ex.Description)
+		}
+	}
+
+	suggestions := []Suggestion{}
+	for _, key := range order {
+		g := groups[key]
+		var best outcome
+		count := 0
+		for o, n := range g.outcomes {
+			if n > count || (n == count && (o.itemID < best.itemID || (o.itemID == best.itemID && o.categoryID < best.categoryID))) {
+				best, count = o, n
+			}
+		}
+		confidence := float64(count) / float64(g.total)
+		if count < minSupport || confidence < minConfidence {
+			continue
+		}
+		s := Suggestion{
+			DescriptionContains: g.description,
+			Sign:                g.sign,
+			ItemID:              best.itemID,
+			Support:             count,
+			Confidence:          float64(int(confidence*1000+0.5)) / 1000,
+			Examples:            g.samples[best],
+		}
+		if best.categoryID != 0 {
+			categoryID := best.categoryID
+			s.CategoryID = &categoryID
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].Support > suggestions[j].Support })
+	return suggestions
+}
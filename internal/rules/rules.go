@@ -0,0 +1,158 @@
+// Package rules categorizes transactions with the user's rules and learns new rules from past categorizations.
+package rules
+
+import (
+	"finanapp/internal/statement"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Amount signs
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// Rule matches transactions by description, amount and account, and sets their category, item and note or skips
+// them. Empty conditions match everything.
+type Rule struct {
+	ID                  int
+	Priority            int    // lower runs first
+	DescriptionContains string // compared normalized: case, accents, digits and punctuation are ignored
+	DescriptionRegex    string // case insensitive, on the raw description
+	MinAmount           *float64
+	MaxAmount           *float64 // amounts are compared without sign
+	Sign                string   // debit, credit or empty for both
+	Account             string   // account key of the statement, empty for any
+	CategoryID          *int
+	ItemID              *int
+	Note                string
+	Skip                bool
+
+	contains string
+	regex    *regexp.Regexp
+}
+
+// Validate checks the rule and compiles its regular expression
+func (r *Rule) Validate() error {
+	r.contains = statement.Normalize(r.DescriptionContains)
+	if strings.TrimSpace(r.DescriptionContains) != "" && r.contains == "" {
+		return fmt.Errorf("description must contain letters")
+	}
+	r.regex = nil
+	if r.DescriptionRegex != "" {
+		regex, err := regexp.Compile("(?i)" + r.DescriptionRegex)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: %v", err)
+		}
+		r.regex = regex
+	}
+	if r.Sign != "" && r.Sign != Debit && r.Sign != Credit {
+		return fmt.Errorf("sign must be %s or %s", Debit, Credit)
+	}
+	if (r.MinAmount != nil && *r.MinAmount < 0) || (r.MaxAmount != nil && *r.MaxAmount < 0) {
+		return fmt.Errorf("amounts can't be negative")
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return fmt.Errorf("minimum amount is greater than the maximum")
+	}
+	if r.contains == "" && r.regex == nil && r.MinAmount == nil && r.MaxAmount == nil && r.Sign == "" && r.Account == "" {
+		return fmt.Errorf("a rule needs at least one condition")
+	}
+	if r.CategoryID == nil && r.ItemID == nil && r.Note == "" && !r.Skip {
+		return fmt.Errorf("a rule needs at least one action")
+	}
+	return nil
+}
+
+// Transaction is what the rules look at
+type Transaction struct {
+	Description string
+	Amount      float64 // negative for debits
+	Account     string
+}
+
+// Matches tells whether the transaction meets every condition of a validated rule
+func (r *Rule) Matches(t Transaction) bool {
+	if r.contains != "" && !strings.Contains(" "+statement.Normalize(t.Description)+" ", " "+r.contains+" ") {
+		return false
+	}
+	if r.regex != nil && !r.regex.MatchString(t.Description) {
+		return false
+	}
+	amount := math.Abs(t.Amount)
+	if (r.MinAmount != nil && amount < *r.MinAmount) || (r.MaxAmount != nil && amount > *r.MaxAmount) {
+		return false
+	}
+	if (r.Sign == Debit && t.Amount >= 0) || (r.Sign == Credit && t.Amount < 0) {
+		return false
+	}
+	return r.Account == "" || r.Account == t.Account
+}
+
+// Result is what the matching rules set
+type Result struct {
+	CategoryID *int
+	ItemID     *int
+	Note       string
+	Skip       bool
+	RuleIDs    []int // matching rules, in the order they ran
+}
+
+// Matched tells whether any rule matched
+func (r Result) Matched() bool {
+	return len(r.RuleIDs) > 0
+}
+
+// Engine runs the rules in priority order
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine validates the rules and sorts them by priority, then by ID
+func NewEngine(rules []Rule) (*Engine, error) {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	for i := range sorted {
+		if err := sorted[i].Validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", sorted[i].ID, err)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return &Engine{rules: sorted}, nil
+}
+
+// Apply runs the rules on the transaction. Each action is taken from the first matching rule that sets it, so a
+// rule only fills what the rules before it left empty; a matching skip rule stops the evaluation.
+func (e *Engine) Apply(t Transaction) Result {
+	var result Result
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.Matches(t) {
+			continue
+		}
+		result.RuleIDs = append(result.RuleIDs, r.ID)
+		if r.Skip {
+			result.Skip = true
+			return result
+		}
+		if result.CategoryID == nil {
+			result.CategoryID = r.CategoryID
+		}
+		if result.ItemID == nil {
+			result.ItemID = r.ItemID
+		}
+		if result.Note == "" {
+			result.Note = r.Note
+		}
+	}
+	return result
+}
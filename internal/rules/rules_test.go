@@ -0,0 +1,66 @@
+package rules
+
+import "testing"
+
+func intp(v int) *int { return &v }
+
+func floatp(v float64) *float64 { return &v }
+
+func TestApplyPriority(t *testing.T) {
+	engine, err := NewEngine([]Rule{
+		{ID: 1, Priority: 10, DescriptionContains: "uber", CategoryID: intp(7)},
+		{ID: 2, Priority: 20, Sign: Debit, MinAmount: floatp(100), CategoryID: intp(9), Note: "large expense"},
+		{ID: 3, Priority: 5, DescriptionRegex: `^TED\b`, Skip: true},
+		{ID: 4, Priority: 30, Account: "0341/1", ItemID: intp(3)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := engine.Apply(Transaction{Description: "UBER *TRIP 1234", Amount: -150, Account: "0341/1"})
+	if r.Skip || *r.CategoryID != 7 || r.Note != "large expense" || *r.ItemID != 3 || len(r.RuleIDs) != 3 {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	if r := engine.Apply(Transaction{Description: "TED 001 João", Amount: -150}); !r.Skip || len(r.RuleIDs) != 1 {
+		t.Errorf("expected skip, got %+v", r)
+	}
+
+	// "uber" matches whole words only, credits don't meet the sign
+	if r := engine.Apply(Transaction{Description: "Uberlandia store", Amount: 150}); r.Matched() {
+		t.Errorf("expected no match, got %+v", r)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, r := range []Rule{
+		{DescriptionContains: "x", Sign: "both", Note: "n"},
+		{DescriptionRegex: "(", Note: "n"},
+		{Note: "n"},
+		{DescriptionContains: "x"},
+		{MinAmount: floatp(10), MaxAmount: floatp(5), Skip: true},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected error for %+v", r)
+		}
+	}
+}
+
+func TestLearn(t *testing.T) {
+	examples := []Example{
+		{Description: "UBER *TRIP 1234", Amount: -20, ItemID: 3, CategoryID: intp(7)},
+		{Description: "Uber *Trip 9876", Amount: -35, ItemID: 3, CategoryID: intp(7)},
+		{Description: "UBER TRIP", Amount: -12, ItemID: 3, CategoryID: intp(7)},
+		{Description: "UBER TRIP", Amount: -12, ItemID: 4},
+		{Description: "Padaria", Amount: -10, ItemID: 5},
+		{Description: "Padaria", Amount: -10, ItemID: 5},
+	}
+	suggestions := Learn(examples, 3, 0.7)
+	if len(suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want 1: %+v", len(suggestions), suggestions)
+	}
+	s := suggestions[0]
+	if s.DescriptionContains != "uber trip" || s.Sign != Debit || s.ItemID != 3 || *s.CategoryID != 7 || s.Support != 3 || s.Confidence != 0.75 {
+		t.Errorf("unexpected suggestion %+v", s)
+	}
+}
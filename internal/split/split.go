@@ -0,0 +1,257 @@
+// Package split divides a shared expense between users and works out who owes whom.
+package split
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Split modes
+const (
+	Equal      = "equal"
+	Percentage = "percentage"
+	Shares     = "shares"
+	Exact      = "exact"
+)
+
+// Participant is a user taking part in an expense. Value is ignored by Equal, it is the percentage for
+// Percentage, the number of shares for Shares and the amount for Exact.
+type Participant struct {
+	UserID int     `json:"userProfileId"`
+	Value  float64 `json:"value"`
+}
+
+// Portion is the part of the expense a participant is responsible for
+type Portion struct {
+	UserID int     `json:"userProfileId"`
+	Amount float64 `json:"amount"`
+}
+
+// Split divides total between the participants. Amounts are rounded to cents and the leftover cents go to
+// the first participants, so the portions always add up to the total.
+func Split(total float64, mode string, participants []Participant) ([]Portion, error) {
+	if total <= 0 {
+		return nil, fmt.Errorf("amount must be greater than zero")
+	}
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("at least one participant is required")
+	}
+	seen := map[int]bool{}
+	for _, p := range participants {
+		if seen[p.UserID] {
+			return nil, fmt.Errorf("user %d appears more than once", p.UserID)
+		}
+		seen[p.UserID] = true
+	}
+
+	weights := make([]float64, len(participants))
+	switch mode {
+	case Equal:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case Percentage:
+		sum := 0.0
+		for i, p := range participants {
+			if p.Value < 0 {
+				return nil, fmt.Errorf("percentages can't be negative")
+			}
+			weights[i] = p.Value
+			sum += p.Value
+		}
+		if math.Abs(sum-100) > 0.001 {
+			return nil, fmt.Errorf("percentages must add up to 100, got %g", sum)
+		}
+	case Shares:
+		sum := 0.0
+		for i, p := range participants {
+			if p.Value < 0 {
+				return nil, fmt.Errorf("shares can't be negative")
+			}
+			weights[i] = p.Value
+			sum += p.Value
+		}
+		if sum == 0 {
+			return nil, fmt.Errorf("at least one share is required")
+		}
+	case Exact:
+		sum := int64(0)
+		portions := make([]Portion, len(participants))
+		for i, p := range participants {
+			if p.Value < 0 {
+				return nil, fmt.Errorf("amounts can't be negative")
+			}
+			portions[i] = Portion{UserID: p.UserID, Amount: p.Value}
+			sum += cents(p.Value)
+		}
+		if sum != cents(total) {
+			return nil, fmt.Errorf("amounts must add up to %.2f, got %.2f", total, float64(sum)/100)
+		}
+		return portions, nil
+	default:
+		return nil, fmt.Errorf("mode must be %s, %s, %s or %s", Equal, Percentage, Shares, Exact)
+	}
+
+	return allocate(cents(total), participants, weights), nil
+}
+
+// allocate distributes the cents proportionally to the weights, the remainder one cent at a time
+func allocate(totalCents int64, participants []Participant, weights []float64) []Portion {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+
+	portions := make([]Portion, len(participants))
+	allocated := int64(0)
+	shares := make([]int64, len(participants))
+	for i, w := range weights {
+		shares[i] = int64(math.Floor(float64(totalCents) * w / sum))
+		allocated += shares[i]
+	}
+	for i := 0; allocated < totalCents; i = (i + 1) % len(shares) {
+		if weights[i] > 0 {
+			shares[i]++
+			allocated++
+		}
+	}
+	for i, p := range participants {
+		portions[i] = Portion{UserID: p.UserID, Amount: float64(shares[i]) / 100}
+	}
+	return portions
+}
+
+// Debt is an amount the debtor owes the creditor: the portion of an expense paid by someone else, or a
+// settlement in the opposite direction with the payer as creditor
+type Debt struct {
+	DebtorID   int
+	CreditorID int
+	Amount     float64
+}
+
+// ExpenseDebts turns the portions of an expense into the debts of the participants towards the payer
+func ExpenseDebts(payerID int, portions []Portion) []Debt {
+	var debts []Debt
+	for _, p := range portions {
+		if p.UserID != payerID && p.Amount != 0 {
+			debts = append(debts, Debt{DebtorID: p.UserID, CreditorID: payerID, Amount: p.Amount})
+		}
+	}
+	return debts
+}
+
+// SettlementDebt is the effect of a settlement paid by from to to: it reduces what from owes to
+func SettlementDebt(fromID, toID int, amount float64) Debt {
+	return Debt{DebtorID: toID, CreditorID: fromID, Amount: amount}
+}
+
+// Balances returns the net position of every user: positive means the user is owed money, negative means
+// the user owes it. Users that are even are left out.
+func Balances(debts []Debt) map[int]float64 {
+	net := map[int]int64{}
+	for _, d := range debts {
+		c := cents(d.Amount)
+		net[d.DebtorID] -= c
+		net[d.CreditorID] += c
+	}
+	balances := map[int]float64{}
+	for user, c := range net {
+		if c != 0 {
+			balances[user] = float64(c) / 100
+		}
+	}
+	return balances
+}
+
+// Pairwise returns, for each counterpart of the user, the net amount between them: positive means the
+// counterpart owes the user
+func Pairwise(userID int, debts []Debt) map[int]float64 {
+	net := map[int]int64{}
+	for _, d := range debts {
+		switch userID {
+		case d.CreditorID:
+			net[d.DebtorID] += cents(d.Amount)
+		case d.DebtorID:
+			net[d.CreditorID] -= cents(d.Amount)
+		}
+	}
+	pairs := map[int]float64{}
+	for user, c := range net {
+		if c != 0 && user != userID {
+			pairs[user] = float64(c) / 100
+		}
+	}
+	return pairs
+}
+
+// Transfer is a payment that settles part of the balances
+type Transfer struct {
+	FromUserID int     `json:"fromUserProfileId"`
+	ToUserID   int     `json:"toUserProfileId"`
+	Amount     float64 `json:"amount"`
+}
+
+// Simplify returns transfers that settle every balance. Debtors and creditors with the same amount are
+// paired first, then the largest debtor pays the largest creditor until everyone is even, which needs at
+// most one transfer less than the number of users with a balance.
+func Simplify(balances map[int]float64) []Transfer {
+	type position struct {
+		userID int
+		cents  int64
+	}
+	var debtors, creditors []position
+	for user, amount := range balances {
+		c := cents(amount)
+		if c < 0 {
+			debtors = append(debtors, position{user, -c})
+		} else if c > 0 {
+			creditors = append(creditors, position{user, c})
+		}
+	}
+	byAmount := func(p []position) {
+		sort.Slice(p, func(i, j int) bool {
+			if p[i].cents != p[j].cents {
+				return p[i].cents > p[j].cents
+			}
+			return p[i].userID < p[j].userID
+		})
+	}
+	byAmount(debtors)
+	byAmount(creditors)
+
+	var transfers []Transfer
+	pay := func(d, c *position, amount int64) {
+		transfers = append(transfers, Transfer{FromUserID: d.userID, ToUserID: c.userID, Amount: float64(amount) / 100})
+		d.cents -= amount
+		c.cents -= amount
+	}
+
+	// Exact matches settle two users with a single transfer
+	for i := range debtors {
+		for j := range creditors {
+			if debtors[i].cents > 0 && debtors[i].cents == creditors[j].cents {
+				pay(&debtors[i], &creditors[j], debtors[i].cents)
+				break
+			}
+		}
+	}
+
+	for {
+		byAmount(debtors)
+		byAmount(creditors)
+		if len(debtors) == 0 || len(creditors) == 0 || debtors[0].cents == 0 || creditors[0].cents == 0 {
+			break
+		}
+		amount := debtors[0].cents
+		if creditors[0].cents < amount {
+			amount = creditors[0].cents
+		}
+		pay(&debtors[0], &creditors[0], amount)
+	}
+	return transfers
+}
+
+func cents(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
@@ -0,0 +1,101 @@
+package split
+
+import "testing"
+
+func amounts(portions []Portion) []float64 {
+	var out []float64
+	for _, p := range portions {
+		out = append(out, p.Amount)
+	}
+	return out
+}
+
+func TestSplitModes(t *testing.T) {
+	three := []Participant{{UserID: 1}, {UserID: 2}, {UserID: 3}}
+
+	tests := []struct {
+		name         string
+		mode         string
+		total        float64
+		participants []Participant
+		want         []float64
+	}{
+		{"equal with leftover cents", Equal, 100, three, []float64{33.34, 33.33, 33.33}},
+		{"percentage", Percentage, 1500, []Participant{{1, 60}, {2, 40}}, []float64{900, 600}},
+		{"shares", Shares, 90, []Participant{{1, 2}, {2, 1}}, []float64{60, 30}},
+		{"zero share", Shares, 10, []Participant{{1, 1}, {2, 0}}, []float64{10, 0}},
+		{"exact", Exact, 50, []Participant{{1, 20.5}, {2, 29.5}}, []float64{20.5, 29.5}},
+	}
+	for _, tt := range tests {
+		portions, err := Split(tt.total, tt.mode, tt.participants)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := amounts(portions)
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	if _, err := Split(100, Percentage, []Participant{{1, 50}, {2, 40}}); err == nil {
+		t.Error("expected error when percentages don't add up to 100")
+	}
+	if _, err := Split(100, Exact, []Participant{{1, 50}, {2, 40}}); err == nil {
+		t.Error("expected error when exact amounts don't add up to the total")
+	}
+	if _, err := Split(100, Equal, []Participant{{UserID: 1}, {UserID: 1}}); err == nil {
+		t.Error("expected error on duplicated participant")
+	}
+	if _, err := Split(100, "thirds", []Participant{{UserID: 1}}); err == nil {
+		t.Error("expected error on unknown mode")
+	}
+}
+
+func TestBalancesAndSettlement(t *testing.T) {
+	// 1 pays a 300 rent split with 2 and 3, 2 pays a 60 dinner split with 1
+	rent, _ := Split(300, Equal, []Participant{{UserID: 1}, {UserID: 2}, {UserID: 3}})
+	dinner, _ := Split(60, Equal, []Participant{{UserID: 1}, {UserID: 2}})
+	debts := append(ExpenseDebts(1, rent), ExpenseDebts(2, dinner)...)
+
+	b := Balances(debts)
+	if b[1] != 170 || b[2] != -70 || b[3] != -100 {
+		t.Errorf("unexpected balances %v", b)
+	}
+	if p := Pairwise(1, debts); p[2] != 70 || p[3] != 100 {
+		t.Errorf("unexpected pairwise balances %v", p)
+	}
+
+	debts = append(debts, SettlementDebt(3, 1, 100))
+	b = Balances(debts)
+	if _, ok := b[3]; ok || b[1] != 70 {
+		t.Errorf("settlement not applied: %v", b)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// A chain 2 -> 1 -> 3 collapses into a single transfer
+	transfers := Simplify(Balances([]Debt{{DebtorID: 2, CreditorID: 1, Amount: 50}, {DebtorID: 1, CreditorID: 3, Amount: 50}}))
+	if len(transfers) != 1 || transfers[0] != (Transfer{FromUserID: 2, ToUserID: 3, Amount: 50}) {
+		t.Errorf("unexpected transfers %v", transfers)
+	}
+
+	balances := map[int]float64{1: 40, 2: 30, 3: -30, 4: -25, 5: -15}
+	transfers = Simplify(balances)
+	if len(transfers) != 3 {
+		t.Errorf("expected 3 transfers, got %v", transfers)
+	}
+	for _, tr := range transfers {
+		balances[tr.FromUserID] += tr.Amount
+		balances[tr.ToUserID] -= tr.Amount
+	}
+	for user, amount := range balances {
+		if cents(amount) != 0 {
+			t.Errorf("user %d still has %v after the transfers", user, amount)
+		}
+	}
+}
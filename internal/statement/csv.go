@@ -0,0 +1,369 @@
+package statement
+
+import (
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVMapping says how to read the columns of a bank CSV export. Columns are given by header name or by 1-based
+// position. The amount is either one signed column, one column with a debit/credit indicator column, or separate
+// debit and credit columns.
+type CSVMapping struct {
+	Account           string `json:"account"`   // identifies the account, the lines are deduplicated per account
+	Delimiter         string `json:"delimiter"` // ",", ";", "\t" or "|", detected when empty
+	Encoding          string `json:"encoding"`  // "utf-8", "latin1" or "windows-1252", detected when empty
+	SkipRows          int    `json:"skipRows"`  // lines before the header or the first transaction
+	HasHeader         bool   `json:"hasHeader"`
+	DateColumn        string `json:"dateColumn"`
+	DateFormat        string `json:"dateFormat"`        // DD/MM/YYYY, YYYY-MM-DD, MM/DD/YYYY..., detected when empty
+	DescriptionColumn string `json:"descriptionColumn"` // several columns can be joined with "+"
+	AmountColumn      string `json:"amountColumn"`
+	TypeColumn        string `json:"typeColumn"` // D/C, Débito/Crédito or Debit/Credit next to an unsigned amount
+	DebitColumn       string `json:"debitColumn"`
+	CreditColumn      string `json:"creditColumn"`
+	DecimalSeparator  string `json:"decimalSeparator"` // "," or ".", detected per value when empty
+	InvertSign        bool   `json:"invertSign"`       // for credit card exports that list purchases as positive
+}
+
+// dateFormats are the accepted date formats and their layouts
+var dateFormats = map[string]string{
+	"DD/MM/YYYY": "02/01/2006",
+	"DD/MM/YY":   "02/01/06",
+	"YYYY-MM-DD": "2006-01-02",
+	"DD-MM-YYYY": "02-01-2006",
+	"DD.MM.YYYY": "02.01.2006",
+	"MM/DD/YYYY": "01/02/2006",
+	"YYYYMMDD":   "20060102",
+}
+
+// detectedDateFormats are tried in order when the mapping has no date format, MM/DD/YYYY is never guessed
+var detectedDateFormats = []string{"DD/MM/YYYY", "YYYY-MM-DD", "DD-MM-YYYY", "DD.MM.YYYY", "DD/MM/YY", "YYYYMMDD"}
+
+// Validate checks the mapping
+func (m CSVMapping) Validate() error {
+	switch m.Delimiter {
+	case "", ",", ";", "\t", "|":
+	default:
+		return fmt.Errorf("delimiter must be a comma, a semicolon, a tab or a pipe")
+	}
+	switch strings.ToLower(m.Encoding) {
+	case "", "utf-8", "utf8", "latin1", "iso-8859-1", "windows-1252":
+	default:
+		return fmt.Errorf("encoding must be utf-8, latin1 or windows-1252")
+	}
+	if m.DateFormat != "" {
+		if _, ok := dateFormats[strings.ToUpper(m.DateFormat)]; !ok {
+			return fmt.Errorf("unknown date format %q", m.DateFormat)
+		}
+	}
+	if m.DecimalSeparator != "" && m.DecimalSeparator != "," && m.DecimalSeparator != "." {
+		return fmt.Errorf("decimal separator must be a comma or a dot")
+	}
+	if m.SkipRows < 0 {
+		return fmt.Errorf("skipRows can't be negative")
+	}
+	if m.DateColumn == "" || m.DescriptionColumn == "" {
+		return fmt.Errorf("date and description columns are required")
+	}
+	if (m.AmountColumn == "") == (m.DebitColumn == "" && m.CreditColumn == "") {
+		return fmt.Errorf("either the amount column or the debit and credit columns are required")
+	}
+	if m.TypeColumn != "" && m.AmountColumn == "" {
+		return fmt.Errorf("the type column goes with the amount column")
+	}
+	return nil
+}
+
+// ParseCSV reads a bank CSV export with the mapping. Rows that can't be read, like balance or total lines, are
+// skipped and reported as warnings; it fails only when the mapping doesn't fit the file or no row can be read.
+func ParseCSV(data []byte, m CSVMapping) (Account, []string, error) {
+	account := Account{AccountID: m.Account}
+	if err := m.Validate(); err != nil {
+		return account, nil, err
+	}
+
+	text := DecodeText(data, m.Encoding)
+	lines := strings.SplitAfter(text, "\n")
+	if m.SkipRows >= len(lines) {
+		return account, nil, fmt.Errorf("the file has no rows after the %d skipped", m.SkipRows)
+	}
+	text = strings.Join(lines[m.SkipRows:], "")
+
+	delimiter := detectDelimiter(text)
+	if m.Delimiter != "" {
+		delimiter = rune(m.Delimiter[0])
+	}
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	var columns csvColumns
+	var warnings []string
+	seen := map[string]int{}
+	for row := m.SkipRows + 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return account, warnings, fmt.Errorf("row %d: %v", row, err)
+		}
+		if blank(record) {
+			continue
+		}
+
+		if columns == nil {
+			if m.HasHeader && header == nil {
+				header = record
+			}
+			if columns, err = m.columns(header); err != nil {
+				return account, warnings, err
+			}
+			if m.HasHeader {
+				continue
+			}
+		}
+
+		line, err := m.line(record, columns, delimiter)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("row %d skipped: %v", row, err))
+			continue
+		}
+		line.ExternalID = generatedID(seen, line.Date, line.Amount, line.Description)
+		account.Lines = append(account.Lines, line)
+	}
+
+	if len(account.Lines) == 0 {
+		return account, warnings, fmt.Errorf("no transaction found")
+	}
+	return account, warnings, nil
+}
+
+// csvColumns maps the mapping fields to the indexes of the record, -1 when unused
+type csvColumns map[string][]int
+
+// columns resolves the column references of the mapping against the header
+func (m CSVMapping) columns(header []string) (csvColumns, error) {
+	columns := csvColumns{}
+	for field, ref := range map[string]string{
+		"date": m.DateColumn, "description": m.DescriptionColumn, "amount": m.AmountColumn,
+		"type": m.TypeColumn, "debit": m.DebitColumn, "credit": m.CreditColumn,
+	} {
+		if ref == "" {
+			continue
+		}
+		for _, part := range strings.Split(ref, "+") {
+			index, err := columnIndex(strings.TrimSpace(part), header)
+			if err != nil {
+				return nil, fmt.Errorf("%s column: %v", field, err)
+			}
+			columns[field] = append(columns[field], index)
+		}
+	}
+	return columns, nil
+}
+
+// columnIndex finds a column by 1-based position or by header name, ignoring case and accents
+func columnIndex(ref string, header []string) (int, error) {
+	if position, err := strconv.Atoi(ref); err == nil {
+		if position < 1 {
+			return 0, fmt.Errorf("positions start at 1")
+		}
+		return position - 1, nil
+	}
+	for i, name := range header {
+		if Normalize(name) == Normalize(ref) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no column named %q", ref)
+}
+
+// line reads one record
+func (m CSVMapping) line(record []string, columns csvColumns, delimiter rune) (Line, error) {
+	field := func(name string) string {
+		var parts []string
+		for _, i := range columns[name] {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				parts = append(parts, strings.TrimSpace(record[i]))
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	var line Line
+	date, err := parseCSVDate(field("date"), m.DateFormat)
+	if err != nil {
+		return line, err
+	}
+	line.Date = date
+
+	line.Description = strings.Join(strings.Fields(field("description")), " ")
+	if line.Description == "" {
+		return line, fmt.Errorf("description is empty")
+	}
+
+	separator := m.DecimalSeparator
+	if separator == "" && delimiter == ',' {
+		// A comma-separated file can't hold unquoted decimal commas
+		separator = "."
+	}
+	if m.AmountColumn != "" {
+		if line.Amount, err = ParseAmount(field("amount"), separator); err != nil {
+			return line, err
+		}
+		if m.TypeColumn != "" {
+			switch kind := Normalize(field("type")); {
+			case strings.HasPrefix(kind, "d"):
+				line.Amount = -abs(line.Amount)
+			case strings.HasPrefix(kind, "c"):
+				line.Amount = abs(line.Amount)
+			default:
+				return line, fmt.Errorf("unknown debit/credit indicator %q", field("type"))
+			}
+		}
+	} else {
+		debit, credit := field("debit"), field("credit")
+		switch {
+		case debit != "" && credit == "":
+			amount, err := ParseAmount(debit, separator)
+			if err != nil {
+				return line, err
+			}
+			line.Amount = -abs(amount)
+		case credit != "" && debit == "":
+			amount, err := ParseAmount(credit, separator)
+			if err != nil {
+				return line, err
+			}
+			line.Amount = abs(amount)
+		default:
+			return line, fmt.Errorf("expected either a debit or a credit")
+		}
+	}
+	if m.InvertSign {
+		line.Amount = -line.Amount
+	}
+	return line, nil
+}
+
+func parseCSVDate(s, format string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	// Some exports add the time after the date
+	if i := strings.IndexAny(s, " T"); i > 0 {
+		s = s[:i]
+	}
+	formats := detectedDateFormats
+	if format != "" {
+		formats = []string{strings.ToUpper(format)}
+	}
+	for _, f := range formats {
+		if t, err := time.Parse(dateFormats[f], s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+var thousandsOnly = regexp.MustCompile(`^\d{1,3}(\.\d{3}){2,}$`)
+
+// ParseAmount reads an amount like 1.234,56, 1,234.56, R$ -12,50, (12,50) or 12,50- with the given decimal
+// separator. When the separator is empty, the last of "," and "." is the decimal one, and a lone comma is decimal.
+func ParseAmount(s, decimalSeparator string) (float64, error) {
+	value := strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		negative, value = true, value[1:len(value)-1]
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "R$"))
+	value = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\u00a0' {
+			return -1
+		}
+		return r
+	}, value)
+	if strings.HasSuffix(value, "-") {
+		negative, value = !negative, strings.TrimSuffix(value, "-")
+	}
+	if value == "" {
+		return 0, fmt.Errorf("amount is empty")
+	}
+
+	separator := decimalSeparator
+	if separator == "" {
+		comma, dot := strings.LastIndex(value, ","), strings.LastIndex(value, ".")
+		switch {
+		case comma > dot:
+			separator = ","
+		case dot >= 0 && comma < 0 && thousandsOnly.MatchString(strings.TrimLeft(value, "+-")):
+			separator = ","
+		default:
+			separator = "."
+		}
+	}
+	if separator == "," {
+		value = strings.ReplaceAll(strings.ReplaceAll(value, ".", ""), ",", ".")
+	} else {
+		value = strings.ReplaceAll(value, ",", "")
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || !validAmount(amount) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// detectDelimiter picks the delimiter that appears most in the first line
+func detectDelimiter(text string) rune {
+	first := text
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		first = text[:i]
+	}
+	best, count := ';', 0
+	for _, d := range []rune{';', '\t', ',', '|'} {
+		if n := strings.Count(first, string(d)); n > count {
+			best, count = d, n
+		}
+	}
+	return best
+}
+
+func blank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// generatedID identifies a line of a file without transaction IDs by its content, numbered when the same content
+// repeats in the file, so importing the same file twice finds the same IDs
+func generatedID(seen map[string]int, date time.Time, amount float64, description string) string {
+	content := fmt.Sprintf("%s|%.2f|%s", date.Format("20060102"), amount, Normalize(description))
+	seen[content]++
+	sum := sha1.Sum([]byte(content + "|" + strconv.Itoa(seen[content])))
+	return "gen:" + hex.EncodeToString(sum[:8])
+}
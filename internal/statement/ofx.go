@@ -0,0 +1,234 @@
+package statement
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxNode is an element of an OFX document. Leaves hold a value, aggregates hold children.
+type ofxNode struct {
+	name     string
+	value    string
+	children []*ofxNode
+	parent   *ofxNode
+	closed   bool // had a closing tag or was self-closing
+}
+
+// child returns the first direct child with the name
+func (n *ofxNode) child(name string) *ofxNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// get returns the value of the descendant at the path of names, empty when missing
+func (n *ofxNode) get(path ...string) string {
+	for _, name := range path {
+		if n = n.child(name); n == nil {
+			return ""
+		}
+	}
+	return n.value
+}
+
+// find collects the descendants with the name, in document order
+func (n *ofxNode) find(name string, found []*ofxNode) []*ofxNode {
+	for _, c := range n.children {
+		if c.name == name {
+			found = append(found, c)
+		}
+		found = c.find(name, found)
+	}
+	return found
+}
+
+var ofxCharset = regexp.MustCompile(`(?i)(?:CHARSET:\s*|encoding\s*=\s*["'])([\w-]+)`)
+
+// ParseOFX reads an OFX statement, either 1.x (SGML, leaf elements are not closed) or 2.x (XML), with bank
+// (STMTRS) and credit card (CCSTMTRS) statements. A file can hold several accounts.
+func ParseOFX(data []byte) ([]Account, error) {
+	start := strings.Index(string(data[:min(len(data), 4096)]), "<")
+	if start < 0 {
+		return nil, fmt.Errorf("not an OFX file")
+	}
+	charset := ""
+	if m := ofxCharset.FindSubmatch(data[:min(len(data), 4096)]); m != nil {
+		charset = string(m[1])
+	}
+	root := parseOFXTree(DecodeText(data, charset))
+
+	var accounts []Account
+	for _, st := range root.find("STMTRS", nil) {
+		from := st.child("BANKACCTFROM")
+		if from == nil {
+			from = &ofxNode{}
+		}
+		account := Account{
+			BankID:    from.get("BANKID"),
+			AccountID: from.get("ACCTID"),
+			Type:      strings.ToUpper(from.get("ACCTTYPE")),
+			Currency:  strings.ToUpper(st.get("CURDEF")),
+		}
+		if err := ofxLines(st, &account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	for _, st := range root.find("CCSTMTRS", nil) {
+		account := Account{
+			AccountID: st.get("CCACCTFROM", "ACCTID"),
+			Type:      "CREDITCARD",
+			Currency:  strings.ToUpper(st.get("CURDEF")),
+		}
+		if err := ofxLines(st, &account); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no bank or credit card statement found")
+	}
+	return accounts, nil
+}
+
+// parseOFXTree builds the element tree. An element that received a value is a leaf and is closed by the next tag,
+// which is how SGML files omit the closing tags; closing tags close every element still open below them. An SGML
+// leaf without a value can't be told from an aggregate until the end, see hoistEmptyLeaves.
+func parseOFXTree(body string) *ofxNode {
+	root := &ofxNode{}
+	current := root
+	for {
+		open := strings.IndexByte(body, '<')
+		if open < 0 {
+			break
+		}
+		if text := strings.TrimSpace(body[:open]); text != "" && current != root {
+			current.value += html.UnescapeString(text)
+		}
+		end := strings.IndexByte(body[open:], '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.TrimSpace(body[open+1 : open+end])
+		body = body[open+end+1:]
+
+		switch {
+		case tag == "" || tag[0] == '?' || tag[0] == '!':
+			// XML declaration, OFX processing instruction or comment
+		case tag[0] == '/':
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			for n := current; n != root; n = n.parent {
+				if n.name == name {
+					n.closed = true
+					current = n.parent
+					break
+				}
+			}
+		default:
+			if current != root && current.value != "" {
+				current = current.parent
+			}
+			selfClosing := strings.HasSuffix(tag, "/")
+			name := strings.ToUpper(strings.Fields(strings.TrimSuffix(tag, "/") + " ")[0])
+			node := &ofxNode{name: name, parent: current, closed: selfClosing}
+			current.children = append(current.children, node)
+			if !selfClosing {
+				current = node
+			}
+		}
+	}
+	hoistEmptyLeaves(root)
+	return root
+}
+
+// hoistEmptyLeaves moves the children of the elements without value that were never closed up to their parent,
+// right after them. SGML always closes aggregates, so such an element is a leaf sent empty (e.g. <MEMO><NAME>x)
+// and the elements parsed under it are its siblings.
+func hoistEmptyLeaves(n *ofxNode) {
+	children := make([]*ofxNode, 0, len(n.children))
+	for _, c := range n.children {
+		hoistEmptyLeaves(c)
+		children = append(children, c)
+		if !c.closed && c.value == "" && len(c.children) > 0 {
+			for _, sibling := range c.children {
+				sibling.parent = n
+			}
+			children = append(children, c.children...)
+			c.children = nil
+		}
+	}
+	n.children = children
+}
+
+// ofxLines reads the STMTTRN of a statement into the account. Lines without FITID get a hash of their content,
+// numbered when the same content repeats in the file.
+func ofxLines(statement *ofxNode, account *Account) error {
+	seen := map[string]int{}
+	for _, trn := range statement.find("STMTTRN", nil) {
+		fitid := strings.TrimSpace(trn.get("FITID"))
+		date, err := parseOFXDate(trn.get("DTPOSTED"))
+		if err != nil {
+			return fmt.Errorf("transaction %q: %v", fitid, err)
+		}
+		amount, err := parseOFXAmount(trn.get("TRNAMT"))
+		if err != nil {
+			return fmt.Errorf("transaction %q: %v", fitid, err)
+		}
+
+		name, memo := strings.TrimSpace(trn.get("NAME")), strings.TrimSpace(trn.get("MEMO"))
+		description := name
+		switch {
+		case description == "":
+			description = memo
+		case memo != "" && !strings.EqualFold(memo, name):
+			description = name + " - " + memo
+		}
+
+		if fitid == "" {
+			fitid = generatedID(seen, date, amount, description)
+		}
+
+		account.Lines = append(account.Lines, Line{
+			ExternalID:  fitid,
+			Date:        date,
+			Amount:      amount,
+			Description: description,
+			Type:        strings.ToUpper(trn.get("TRNTYPE")),
+		})
+	}
+	return nil
+}
+
+// parseOFXDate reads the date of YYYYMMDD[HHMMSS[.XXX]][[-3:BRT]], ignoring the time
+func parseOFXDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	date, err := time.Parse("20060102", s[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return date, nil
+}
+
+// parseOFXAmount reads TRNAMT, accepting the decimal comma some Brazilian banks use
+func parseOFXAmount(s string) (float64, error) {
+	value := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if strings.Contains(value, ",") {
+		value = strings.ReplaceAll(strings.ReplaceAll(value, ".", ""), ",", ".")
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || !validAmount(amount) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
+}
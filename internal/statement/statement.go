@@ -0,0 +1,91 @@
+// Package statement parses bank statements into transaction lines, ready to be previewed and booked as actuals.
+package statement
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Line is a transaction of a statement
+type Line struct {
+	ExternalID  string // FITID of OFX files, or a hash of the line when the source has none
+	Date        time.Time
+	Amount      float64 // negative for debits
+	Description string
+	Type        string // OFX TRNTYPE (DEBIT, CREDIT, PAYMENT...), empty for CSV
+}
+
+// maxAmount bounds the amounts of the lines, the actuals store them as NUMERIC(15,2)
+const maxAmount = 1e13
+
+// validAmount reports whether the amount is finite and fits an actual
+func validAmount(amount float64) bool {
+	return !math.IsNaN(amount) && math.Abs(amount) < maxAmount
+}
+
+// Account is the statement of one bank account or credit card
+type Account struct {
+	BankID    string
+	AccountID string
+	Type      string // CHECKING, SAVINGS, CREDITCARD...
+	Currency  string // ISO code, empty when the statement doesn't say
+	Lines     []Line
+}
+
+// Key identifies the account among the user's statements, FITIDs are only unique within an account
+func (a Account) Key() string {
+	if a.BankID == "" {
+		return a.AccountID
+	}
+	return a.BankID + "/" + a.AccountID
+}
+
+// windows1252 maps the 0x80-0x9F range of Windows-1252, the rest of the bytes are the Latin-1 code points
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// DecodeText converts the file to UTF-8. Brazilian bank exports are often Latin-1 or Windows-1252: they are
+// decoded as such when the charset says so or when the data isn't valid UTF-8.
+func DecodeText(data []byte, charset string) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	charset = strings.ToLower(strings.TrimSpace(charset))
+	latin := strings.Contains(charset, "1252") || strings.Contains(charset, "8859") || strings.Contains(charset, "latin")
+	if !latin && utf8.Valid(data) {
+		return string(data)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, b := range data {
+		if b >= 0x80 && b < 0xA0 {
+			sb.WriteRune(windows1252[b-0x80])
+		} else {
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+// accents folds the accented letters of Portuguese descriptions
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a",
+	"é", "e", "ê", "e", "è", "e",
+	"í", "i", "î", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ü", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// Normalize reduces a description to lowercase words without accents, digits or punctuation, so the same payee
+// matches across statements ("PIX ENVIADO 0312 João" and "Pix enviado 0415 JOAO" both give "pix enviado joao")
+func Normalize(description string) string {
+	folded := accents.Replace(strings.ToLower(description))
+	words := strings.FieldsFunc(folded, func(r rune) bool { return !unicode.IsLetter(r) })
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,219 @@
+package statement
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// SGML statement in Windows-1252, as exported by most Brazilian banks
+var sgml = "OFXHEADER:100\r\nDATA:OFXSGML\r\nVERSION:102\r\nENCODING:USASCII\r\nCHARSET:1252\r\n\r\n" +
+	"<OFX><SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20250131</SONRS></SIGNONMSGSRSV1>" +
+	"<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS><CURDEF>BRL<BANKACCTFROM><BANKID>0341<ACCTID>12345-6<ACCTTYPE>CHECKING</BANKACCTFROM>" +
+	"<BANKTRANLIST><DTSTART>20250101<DTEND>20250131\r\n" +
+	"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250105120000[-3:BRT]<TRNAMT>-1.234,56<FITID>A1<MEMO>PAGTO CONTA LUZ S\xc3O PAULO</STMTTRN>\r\n" +
+	"<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250106<TRNAMT>5000.00<FITID>A2<NAME>SALARIO<MEMO>EMPRESA &amp; CIA</STMTTRN>\r\n" +
+	"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250107<TRNAMT>-10.00<MEMO>TARIFA</STMTTRN>\r\n" +
+	"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250107<TRNAMT>-10.00<MEMO>TARIFA</STMTTRN>\r\n" +
+	"</BANKTRANLIST><LEDGERBAL><BALAMT>100.00<DTASOF>20250131</LEDGERBAL></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>"
+
+var xml = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>BRL</CURDEF>
+        <CCACCTFROM><ACCTID>5555********1234</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>PAYMENT</TRNTYPE>
+            <DTPOSTED>20250210000000[-3:BRT]</DTPOSTED>
+            <TRNAMT>-89.90</TRNAMT>
+            <FITID>cc-1</FITID>
+            <MEMO>Padaria Pão Quente</MEMO>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>`
+
+func TestParseOFXSGML(t *testing.T) {
+	accounts, err := ParseOFX([]byte(sgml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("got %d accounts, want 1", len(accounts))
+	}
+	a := accounts[0]
+	if a.Key() != "0341/12345-6" || a.Type != "CHECKING" || a.Currency != "BRL" || len(a.Lines) != 4 {
+		t.Fatalf("unexpected account %+v", a)
+	}
+
+	first := a.Lines[0]
+	if first.ExternalID != "A1" || !first.Date.Equal(date("2025-01-05")) || first.Amount != -1234.56 ||
+		first.Description != "PAGTO CONTA LUZ SÃO PAULO" || first.Type != "DEBIT" {
+		t.Errorf("unexpected line %+v", first)
+	}
+	if a.Lines[1].Description != "SALARIO - EMPRESA & CIA" || a.Lines[1].Amount != 5000 {
+		t.Errorf("unexpected line %+v", a.Lines[1])
+	}
+	// Identical lines without FITID get distinct generated IDs
+	if a.Lines[2].ExternalID == "" || a.Lines[2].ExternalID == a.Lines[3].ExternalID {
+		t.Errorf("unexpected generated IDs %q and %q", a.Lines[2].ExternalID, a.Lines[3].ExternalID)
+	}
+}
+
+func TestParseOFXEmptySGMLLeaf(t *testing.T) {
+	// MEMO is sent without a value, so NAME, TRNAMT and FITID are its siblings and not its children
+	data := "<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>BRL<BANKACCTFROM><BANKID>001<ACCTID>999</BANKACCTFROM><BANKTRANLIST>" +
+		"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250301<MEMO><NAME>MERCADO<TRNAMT>-50.00<FITID>B1</STMTTRN>" +
+		"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250302<TRNAMT>-7.50<FITID>B2<NAME></STMTTRN>" +
+		"</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>"
+	accounts, err := ParseOFX([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || len(accounts[0].Lines) != 2 {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+	first := accounts[0].Lines[0]
+	if first.ExternalID != "B1" || first.Amount != -50 || first.Description != "MERCADO" {
+		t.Errorf("unexpected line %+v", first)
+	}
+	if second := accounts[0].Lines[1]; second.ExternalID != "B2" || second.Amount != -7.5 {
+		t.Errorf("unexpected line %+v", second)
+	}
+}
+
+func TestParseOFXInvalidAmount(t *testing.T) {
+	for _, amount := range []string{"NaN", "-Inf", "1e13", "99999999999999,00"} {
+		data := "<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKACCTFROM><ACCTID>999</BANKACCTFROM><BANKTRANLIST>" +
+			"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250301<TRNAMT>" + amount + "<FITID>C1</STMTTRN>" +
+			"</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>"
+		if _, err := ParseOFX([]byte(data)); err == nil {
+			t.Errorf("expected error for amount %q", amount)
+		}
+	}
+}
+
+func TestParseOFXXML(t *testing.T) {
+	accounts, err := ParseOFX([]byte(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Type != "CREDITCARD" || accounts[0].Key() != "5555********1234" {
+		t.Fatalf("unexpected accounts %+v", accounts)
+	}
+	line := accounts[0].Lines[0]
+	if line.ExternalID != "cc-1" || line.Amount != -89.9 || line.Description != "Padaria Pão Quente" || !line.Date.Equal(date("2025-02-10")) {
+		t.Errorf("unexpected line %+v", line)
+	}
+}
+
+func TestParseOFXInvalid(t *testing.T) {
+	if _, err := ParseOFX([]byte("date,amount\n")); err == nil {
+		t.Error("expected error on a non OFX file")
+	}
+	if _, err := ParseOFX([]byte("<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>")); err == nil {
+		t.Error("expected error without statements")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize("PIX ENVIADO 0312 João-Silva"); got != "pix enviado joao silva" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseCSVBrazilian(t *testing.T) {
+	// Latin-1 export with a title line, a header, debit/credit columns and a balance line
+	data := "Extrato conta corrente\n" +
+		"Data;Hist\xf3rico;Documento;D\xe9bito;Cr\xe9dito\n" +
+		"02/01/2025;Padaria P\xe3o Quente;123;1.234,56;\n" +
+		"03/01/2025;Sal\xe1rio;;;R$ 5.000,00\n" +
+		"03/01/2025;Saldo do dia;;;\n"
+	account, warnings, err := ParseCSV([]byte(data), CSVMapping{
+		Account: "itau", SkipRows: 1, HasHeader: true, DateColumn: "data", DescriptionColumn: "Histórico",
+		DebitColumn: "Débito", CreditColumn: "Crédito",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(account.Lines) != 2 || len(warnings) != 1 || account.Key() != "itau" {
+		t.Fatalf("unexpected result %+v %v", account, warnings)
+	}
+	first := account.Lines[0]
+	if !first.Date.Equal(date("2025-01-02")) || first.Amount != -1234.56 || first.Description != "Padaria Pão Quente" || first.ExternalID == "" {
+		t.Errorf("unexpected line %+v", first)
+	}
+	if account.Lines[1].Amount != 5000 {
+		t.Errorf("unexpected line %+v", account.Lines[1])
+	}
+
+	// Importing the same file again gives the same IDs
+	again, _, _ := ParseCSV([]byte(data), CSVMapping{
+		Account: "itau", SkipRows: 1, HasHeader: true, DateColumn: "1", DescriptionColumn: "2", DebitColumn: "4", CreditColumn: "5",
+	})
+	if len(again.Lines) != 2 || again.Lines[0].ExternalID != first.ExternalID {
+		t.Errorf("unexpected IDs %+v", again.Lines)
+	}
+}
+
+func TestParseCSVTypeColumn(t *testing.T) {
+	data := "2025-01-02,Coffee,12.50,D\n2025-01-03,Refund,1,234.00,C\n"
+	account, warnings, err := ParseCSV([]byte(data), CSVMapping{
+		DateColumn: "1", DescriptionColumn: "2", AmountColumn: "3", TypeColumn: "4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The unquoted thousands separator of the second row shifts its columns
+	if len(account.Lines) != 1 || account.Lines[0].Amount != -12.5 || len(warnings) != 1 {
+		t.Errorf("unexpected result %+v %v", account.Lines, warnings)
+	}
+}
+
+func TestParseCSVMapping(t *testing.T) {
+	if _, _, err := ParseCSV([]byte("a;b\n"), CSVMapping{DateColumn: "1", DescriptionColumn: "2"}); err == nil {
+		t.Error("expected error without amount columns")
+	}
+	if _, _, err := ParseCSV([]byte("Data;Valor\n"), CSVMapping{HasHeader: true, DateColumn: "Data", DescriptionColumn: "Historico", AmountColumn: "Valor"}); err == nil {
+		t.Error("expected error on a missing column")
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	for _, tc := range []struct {
+		in        string
+		separator string
+		want      float64
+	}{
+		{"1.234,56", "", 1234.56},
+		{"1,234.56", "", 1234.56},
+		{"-12,5", "", -12.5},
+		{"R$ -1.000,00", "", -1000},
+		{"(12,50)", "", -12.5},
+		{"12,50-", "", -12.5},
+		{"1.234.567", "", 1234567},
+		{"1.234", ",", 1234},
+		{"1.234", "", 1.234},
+	} {
+		got, err := ParseAmount(tc.in, tc.separator)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseAmount(%q, %q) = %v, %v; want %v", tc.in, tc.separator, got, err, tc.want)
+		}
+	}
+
+	for _, in := range []string{"NaN", "Inf", "-infinity", "10.000.000.000.000,00"} {
+		if _, err := ParseAmount(in, ""); err == nil {
+			t.Errorf("ParseAmount(%q) should fail", in)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tax
+
+import (
+	"math"
+	"time"
+)
+
+// Dependents and the simplified discount only apply to the salary of CLT employees, whose INSS contribution
+// is also deducted from the IRPF base. Both tables are versioned: the table in effect on a date is the latest
+// one that started on or before it, and dates before the first table use the first one.
+
+// INSSTable is the monthly employee contribution table in effect from a date. Each slice of the salary pays
+// the rate of its bracket; the salary above the ceiling (the last limit) pays nothing.
+type INSSTable struct {
+	Effective time.Time
+	Brackets  []Bracket
+}
+
+// IRPFReduction is the monthly reduction of the withholding introduced by Lei 15.270/2025: the tax is
+// reduced by up to Maximum for a taxable income up to FullUpTo, then by Constant - Factor * income up to UpTo
+type IRPFReduction struct {
+	Maximum  float64
+	FullUpTo float64
+	Constant float64
+	Factor   float64
+	UpTo     float64
+}
+
+// IRPFTable is the monthly withholding table in effect from a date. The official table is written as a rate
+// and an amount to deduct per bracket, which is the same as taxing each slice at the rate of its bracket.
+type IRPFTable struct {
+	Effective          time.Time
+	Brackets           []Bracket
+	DependentDeduction float64 // per dependent
+	SimplifiedDiscount float64 // replaces the legal deductions when it is higher
+	Reduction          *IRPFReduction
+}
+
+func effective(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+// INSSTables are ordered by effective date
+var INSSTables = []INSSTable{
+	{Effective: effective("2024-01-01"), Brackets: []Bracket{
+		{UpTo: 1412.00, Percentage: 7.5}, {UpTo: 2666.68, Percentage: 9}, {UpTo: 4000.03, Percentage: 12}, {UpTo: 7786.02, Percentage: 14},
+	}},
+	{Effective: effective("2025-01-01"), Brackets: []Bracket{
+		{UpTo: 1518.00, Percentage: 7.5}, {UpTo: 2793.88, Percentage: 9}, {UpTo: 4190.83, Percentage: 12}, {UpTo: 8157.41, Percentage: 14},
+	}},
+	{Effective: effective("2026-01-01"), Brackets: []Bracket{
+		{UpTo: 1621.00, Percentage: 7.5}, {UpTo: 2902.84, Percentage: 9}, {UpTo: 4354.27, Percentage: 12}, {UpTo: 8475.55, Percentage: 14},
+	}},
+}
+
+// IRPFTables are ordered by effective date
+var IRPFTables = []IRPFTable{
+	{Effective: effective("2024-02-01"), DependentDeduction: 189.59, SimplifiedDiscount: 564.80, Brackets: []Bracket{
+		{UpTo: 2259.20, Percentage: 0}, {UpTo: 2826.65, Percentage: 7.5}, {UpTo: 3751.05, Percentage: 15}, {UpTo: 4664.68, Percentage: 22.5}, {Percentage: 27.5},
+	}},
+	{Effective: effective("2025-05-01"), DependentDeduction: 189.59, SimplifiedDiscount: 607.20, Brackets: []Bracket{
+		{UpTo: 2428.80, Percentage: 0}, {UpTo: 2826.65, Percentage: 7.5}, {UpTo: 3751.05, Percentage: 15}, {UpTo: 4664.68, Percentage: 22.5}, {Percentage: 27.5},
+	}},
+	{Effective: effective("2026-01-01"), DependentDeduction: 189.59, SimplifiedDiscount: 607.20, Brackets: []Bracket{
+		{UpTo: 2428.80, Percentage: 0}, {UpTo: 2826.65, Percentage: 7.5}, {UpTo: 3751.05, Percentage: 15}, {UpTo: 4664.68, Percentage: 22.5}, {Percentage: 27.5},
+	}, Reduction: &IRPFReduction{Maximum: 312.89, FullUpTo: 5000, Constant: 978.62, Factor: 0.133145, UpTo: 7350}},
+}
+
+// INSSTableOn returns the INSS table in effect on a date
+func INSSTableOn(on time.Time) INSSTable {
+	table := INSSTables[0]
+	for _, t := range INSSTables {
+		if !t.Effective.After(on) {
+			table = t
+		}
+	}
+	return table
+}
+
+// IRPFTableOn returns the IRPF table in effect on a date
+func IRPFTableOn(on time.Time) IRPFTable {
+	table := IRPFTables[0]
+	for _, t := range IRPFTables {
+		if !t.Effective.After(on) {
+			table = t
+		}
+	}
+	return table
+}
+
+// Contribution is the INSS paid on a monthly salary
+func (t INSSTable) Contribution(salary float64) float64 {
+	return Progressive{Brackets: t.Brackets}.Compute(salary)
+}
+
+// Withholding is the breakdown of the taxes withheld from a monthly income
+type Withholding struct {
+	Date       string  `json:"date"`
+	Gross      float64 `json:"gross"`
+	INSS       float64 `json:"inss"`
+	Deductions float64 `json:"deductions"` // legal deductions or the simplified discount
+	Simplified bool    `json:"simplified"`
+	Base       float64 `json:"base"`
+	Reduction  float64 `json:"reduction"`
+	IRPF       float64 `json:"irpf"`
+	Net        float64 `json:"net"`
+}
+
+// Withhold computes the INSS and IRPF withheld from a monthly income on a date. The INSS is only withheld
+// and deducted from the IRPF base for salaries.
+func Withhold(on time.Time, gross float64, dependents int, salary bool) Withholding {
+	w := Withholding{Date: on.Format("2006-01-02"), Gross: gross}
+	if salary {
+		w.INSS = INSSTableOn(on).Contribution(gross)
+	}
+
+	table := IRPFTableOn(on)
+	w.Deductions = round2(w.INSS + float64(dependents)*table.DependentDeduction)
+	if table.SimplifiedDiscount > w.Deductions {
+		w.Deductions, w.Simplified = table.SimplifiedDiscount, true
+	}
+	w.Base = round2(math.Max(gross-w.Deductions, 0))
+	w.IRPF = Progressive{Brackets: table.Brackets}.Compute(w.Base)
+
+	if r := table.Reduction; r != nil && w.IRPF > 0 {
+		switch {
+		case gross <= r.FullUpTo:
+			w.Reduction = math.Min(w.IRPF, r.Maximum)
+		case gross <= r.UpTo:
+			w.Reduction = math.Min(w.IRPF, math.Max(r.Constant-r.Factor*gross, 0))
+		}
+		w.Reduction = round2(w.Reduction)
+		w.IRPF = round2(w.IRPF - w.Reduction)
+	}
+
+	w.Net = round2(gross - w.INSS - w.IRPF)
+	return w
+}
+
+// INSS is the employee contribution computed with the table in effect on the date
+type INSS struct{}
+
+func (INSS) Compute(base float64) float64 {
+	return INSS{}.ComputeOn(base, time.Now())
+}
+
+func (INSS) ComputeOn(base float64, on time.Time) float64 {
+	return INSSTableOn(on).Contribution(base)
+}
+
+// IRPFWithholding is the monthly IRPF withheld from an income with the table in effect on the date.
+// Salary deducts the INSS contribution from the base.
+type IRPFWithholding struct {
+	Salary     bool
+	Dependents int
+}
+
+func (w IRPFWithholding) Compute(base float64) float64 {
+	return w.ComputeOn(base, time.Now())
+}
+
+func (w IRPFWithholding) ComputeOn(base float64, on time.Time) float64 {
+	return Withhold(on, base, w.Dependents, w.Salary).IRPF
+}
+
+func init() {
+	Register("Brazil", "Federal", "INSS", INSS{})
+	Register("Brazil", "Federal", "IRPF", IRPFWithholding{})
+}
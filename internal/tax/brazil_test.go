@@ -0,0 +1,61 @@
+package tax
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestWithholdSimplified(t *testing.T) {
+	// The INSS (509.60) is lower than the simplified discount, which is used instead
+	w := Withhold(day("2025-06-01"), 5000, 0, true)
+	if w.INSS != 509.60 || !w.Simplified || w.Base != 4392.80 || w.IRPF != 312.89 || w.Net != 4177.51 {
+		t.Errorf("unexpected withholding %+v", w)
+	}
+
+	// From 2026 the reduction zeroes the tax up to 5000
+	w = Withhold(day("2026-03-01"), 5000, 0, true)
+	if w.INSS != 501.51 || w.Reduction != 312.89 || w.IRPF != 0 {
+		t.Errorf("unexpected 2026 withholding %+v", w)
+	}
+
+	// and decreases up to 7350
+	w = Withhold(day("2026-03-01"), 6000, 0, true)
+	if w.Reduction != 179.75 {
+		t.Errorf("reduction = %v, want 179.75", w.Reduction)
+	}
+}
+
+func TestWithholdLegalDeductions(t *testing.T) {
+	// The salary is above the INSS ceiling and the INSS plus two dependents beat the simplified discount
+	w := Withhold(day("2024-03-15"), 10000, 2, true)
+	if w.INSS != 908.86 || w.Simplified || w.Deductions != 1288.04 || w.IRPF != 1499.79 {
+		t.Errorf("unexpected withholding %+v", w)
+	}
+
+	// Without salary there is no INSS
+	if w := Withhold(day("2024-03-15"), 10000, 0, false); w.INSS != 0 || w.Base != 9435.20 {
+		t.Errorf("unexpected withholding without INSS %+v", w)
+	}
+}
+
+func TestTablesAreVersioned(t *testing.T) {
+	if got := INSSTableOn(day("2025-12-31")).Effective; !got.Equal(day("2025-01-01")) {
+		t.Errorf("INSS table on 2025-12-31 starts on %v", got)
+	}
+	if got := IRPFTableOn(day("2025-04-30")).Effective; !got.Equal(day("2024-02-01")) {
+		t.Errorf("IRPF table on 2025-04-30 starts on %v", got)
+	}
+	if got := IRPFTableOn(day("2020-01-01")).Effective; !got.Equal(day("2024-02-01")) {
+		t.Errorf("dates before the first table should use it, got %v", got)
+	}
+
+	rule, ok, err := Resolve(TaxType{TaxTypeName: "INSS", TaxCountry: "Brazil", TaxJurisdiction: "Federal"})
+	if err != nil || !ok || ComputeOn(rule, 1518, day("2025-02-01")) != 113.85 {
+		t.Errorf("expected the registered INSS rule, got %v %v %v", rule, ok, err)
+	}
+}
@@ -0,0 +1,58 @@
+package tax
+
+import (
+	"strings"
+	"sync"
+)
+
+// TaxType is the part of a TaxType row the engine needs to find its rule
+type TaxType struct {
+	TaxTypeID       int
+	TaxTypeName     string
+	TaxCountry      string
+	TaxJurisdiction string
+	TaxPercentage   *float64
+	Definition      []byte // TaxTypeRule.RuleDefinition, nil when there is none
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Rule{}
+)
+
+func key(country, jurisdiction, name string) string {
+	return strings.ToLower(strings.TrimSpace(country)) + "|" +
+		strings.ToLower(strings.TrimSpace(jurisdiction)) + "|" +
+		strings.ToLower(strings.TrimSpace(name))
+}
+
+// Register sets the rule of the TaxTypes with the given country, jurisdiction and name
+func Register(country, jurisdiction, name string, rule Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[key(country, jurisdiction, name)] = rule
+}
+
+// Resolve returns the rule of a TaxType: its stored definition first, then the rule registered in code,
+// then a flat rule from TaxPercentage. ok is false when the tax has to be typed by hand.
+func Resolve(t TaxType) (rule Rule, ok bool, err error) {
+	if len(t.Definition) > 0 {
+		rule, err := ParseDefinition(t.Definition)
+		if err != nil {
+			return nil, false, err
+		}
+		return rule, true, nil
+	}
+
+	mu.RLock()
+	rule, ok = registry[key(t.TaxCountry, t.TaxJurisdiction, t.TaxTypeName)]
+	mu.RUnlock()
+	if ok {
+		return rule, true, nil
+	}
+
+	if t.TaxPercentage != nil {
+		return Flat{Percentage: *t.TaxPercentage}, true, nil
+	}
+	return nil, false, nil
+}
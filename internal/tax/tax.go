@@ -0,0 +1,179 @@
+// Package tax computes the amount of a tax from the amount it applies to. Rules are registered per TaxType,
+// either in code (by country, jurisdiction and name) or as a JSON definition stored for the TaxTypeID.
+package tax
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule computes the tax due on a base amount
+type Rule interface {
+	Compute(base float64) float64
+}
+
+// DatedRule is a rule whose parameters depend on the date the tax is due, like the official yearly tables
+type DatedRule interface {
+	Rule
+	ComputeOn(base float64, on time.Time) float64
+}
+
+// ComputeOn computes the tax due on a date: dated rules use the parameters in effect on that date
+func ComputeOn(rule Rule, base float64, on time.Time) float64 {
+	if dated, ok := rule.(DatedRule); ok {
+		return dated.ComputeOn(base, on)
+	}
+	return rule.Compute(base)
+}
+
+// Flat charges a percentage of the base
+type Flat struct {
+	Percentage float64 `json:"percentage"`
+}
+
+func (f Flat) Compute(base float64) float64 {
+	return round2(base * f.Percentage / 100)
+}
+
+// Fixed charges the same amount whatever the base
+type Fixed struct {
+	Amount float64 `json:"amount"`
+}
+
+func (f Fixed) Compute(base float64) float64 {
+	return round2(f.Amount)
+}
+
+// Bracket taxes the slice of the base up to UpTo (0 means no limit) at Percentage
+type Bracket struct {
+	UpTo       float64 `json:"upTo"`
+	Percentage float64 `json:"percentage"`
+}
+
+// Progressive taxes each slice of the base at the percentage of its bracket. Brackets are ordered by UpTo
+// and the last one usually has no limit; the base above the last limit isn't taxed.
+type Progressive struct {
+	Brackets []Bracket `json:"brackets"`
+}
+
+func (p Progressive) Compute(base float64) float64 {
+	total, lower := 0.0, 0.0
+	for _, b := range p.Brackets {
+		if base <= lower {
+			break
+		}
+		upper := base
+		if b.UpTo > 0 && b.UpTo < base {
+			upper = b.UpTo
+		}
+		total += (upper - lower) * b.Percentage / 100
+		if b.UpTo == 0 {
+			break
+		}
+		lower = b.UpTo
+	}
+	return round2(total)
+}
+
+// Deduction reduces the base before the tax is computed, by an amount or a percentage of the base
+type Deduction struct {
+	Name       string  `json:"name"`
+	Amount     float64 `json:"amount,omitempty"`
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+// WithDeductions applies a rule on the base reduced by the deductions, never below zero
+type WithDeductions struct {
+	Rule       Rule
+	Deductions []Deduction
+}
+
+func (d WithDeductions) Compute(base float64) float64 {
+	reduced := base
+	for _, deduction := range d.Deductions {
+		reduced -= deduction.Amount + base*deduction.Percentage/100
+	}
+	return d.Rule.Compute(math.Max(reduced, 0))
+}
+
+// Definition is the JSON form of a rule, as stored in TaxTypeRule.RuleDefinition:
+//
+//	{"kind": "flat", "percentage": 27.5}
+//	{"kind": "fixed", "amount": 150}
+//	{"kind": "progressive", "brackets": [{"upTo": 2259.20, "percentage": 0}, {"upTo": 0, "percentage": 7.5}]}
+//
+// Any kind accepts "deductions": [{"name": "Dependents", "amount": 189.59}].
+type Definition struct {
+	Kind       string      `json:"kind"`
+	Percentage float64     `json:"percentage,omitempty"`
+	Amount     float64     `json:"amount,omitempty"`
+	Brackets   []Bracket   `json:"brackets,omitempty"`
+	Deductions []Deduction `json:"deductions,omitempty"`
+}
+
+// Rule validates the definition and builds its rule
+func (d Definition) Rule() (Rule, error) {
+	var rule Rule
+	switch strings.ToLower(d.Kind) {
+	case "flat":
+		if d.Percentage < 0 || d.Percentage > 100 {
+			return nil, fmt.Errorf("percentage must be between 0 and 100")
+		}
+		rule = Flat{Percentage: d.Percentage}
+	case "fixed":
+		if d.Amount < 0 {
+			return nil, fmt.Errorf("amount cannot be negative")
+		}
+		rule = Fixed{Amount: d.Amount}
+	case "progressive":
+		if len(d.Brackets) == 0 {
+			return nil, fmt.Errorf("progressive rules need at least one bracket")
+		}
+		brackets := append([]Bracket(nil), d.Brackets...)
+		sort.SliceStable(brackets, func(i, j int) bool {
+			// The bracket without limit goes last
+			if brackets[i].UpTo == 0 || brackets[j].UpTo == 0 {
+				return brackets[j].UpTo == 0 && brackets[i].UpTo != 0
+			}
+			return brackets[i].UpTo < brackets[j].UpTo
+		})
+		for i, b := range brackets {
+			if b.Percentage < 0 || b.Percentage > 100 {
+				return nil, fmt.Errorf("bracket percentages must be between 0 and 100")
+			}
+			if b.UpTo == 0 && i != len(brackets)-1 {
+				return nil, fmt.Errorf("only one bracket can be unlimited")
+			}
+		}
+		rule = Progressive{Brackets: brackets}
+	default:
+		return nil, fmt.Errorf("unknown rule kind %q (expected flat, fixed or progressive)", d.Kind)
+	}
+
+	if len(d.Deductions) > 0 {
+		for _, deduction := range d.Deductions {
+			if deduction.Amount < 0 || deduction.Percentage < 0 {
+				return nil, fmt.Errorf("deductions cannot be negative")
+			}
+		}
+		rule = WithDeductions{Rule: rule, Deductions: d.Deductions}
+	}
+	return rule, nil
+}
+
+// ParseDefinition builds the rule of a JSON definition
+func ParseDefinition(data []byte) (Rule, error) {
+	var d Definition
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid rule definition: %w", err)
+	}
+	return d.Rule()
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,69 @@
+package tax
+
+import "testing"
+
+func TestProgressive(t *testing.T) {
+	rule, err := ParseDefinition([]byte(`{"kind": "progressive", "brackets": [
+		{"upTo": 0, "percentage": 20},
+		{"upTo": 1000, "percentage": 0},
+		{"upTo": 3000, "percentage": 10}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[float64]float64{
+		500:  0,
+		2000: 100,
+		5000: 600, // 2000 * 10% + 2000 * 20%
+	}
+	for base, want := range cases {
+		if got := rule.Compute(base); got != want {
+			t.Errorf("Compute(%v) = %v, want %v", base, got, want)
+		}
+	}
+}
+
+func TestDeductions(t *testing.T) {
+	rule, err := ParseDefinition([]byte(`{"kind": "flat", "percentage": 10,
+		"deductions": [{"name": "Dependents", "amount": 200}, {"name": "Simplified", "percentage": 20}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (1000 - 200 - 200) * 10%
+	if got := rule.Compute(1000); got != 60 {
+		t.Errorf("Compute = %v, want 60", got)
+	}
+	if got := rule.Compute(100); got != 0 {
+		t.Errorf("Compute below the deductions = %v, want 0", got)
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	Register("Testland", "Federal", "TT", Fixed{Amount: 50})
+	percentage := 5.0
+
+	rule, ok, err := Resolve(TaxType{TaxCountry: "testland", TaxJurisdiction: "federal", TaxTypeName: "tt", TaxPercentage: &percentage})
+	if err != nil || !ok || rule.Compute(1000) != 50 {
+		t.Errorf("expected the registered rule, got %v %v %v", rule, ok, err)
+	}
+
+	rule, ok, _ = Resolve(TaxType{TaxCountry: "Testland", TaxJurisdiction: "Federal", TaxTypeName: "TT",
+		Definition: []byte(`{"kind": "flat", "percentage": 1}`)})
+	if !ok || rule.Compute(1000) != 10 {
+		t.Errorf("expected the stored definition to win, got %v", rule)
+	}
+
+	rule, ok, _ = Resolve(TaxType{TaxTypeName: "Other", TaxPercentage: &percentage})
+	if !ok || rule.Compute(1000) != 50 {
+		t.Errorf("expected a flat rule from TaxPercentage, got %v", rule)
+	}
+
+	if _, ok, _ := Resolve(TaxType{TaxTypeName: "Other"}); ok {
+		t.Error("expected no rule without definition, registration or percentage")
+	}
+
+	if _, _, err := Resolve(TaxType{Definition: []byte(`{"kind": "lottery"}`)}); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"finanapp/internal/db"
+	"finanapp/internal/handlers"
+	"finanapp/internal/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Usuário dono dos itens criados nos testes da lixeira
+const trashTestUserID = 1
+
+// Cria um parent income com um child tax e um child expense, retornando o ID do parent e dos childs
+func createTrashTestIncome(t *testing.T, database *sql.DB) (int, []int) {
+	t.Helper()
+
+	name := fmt.Sprintf("Salário Lixeira %d", time.Now().UnixNano())
+	var response string
+	err := database.QueryRow("CALL CreateUserParentIncome($1, $2, $3, $4, $5, $6, $7)",
+		trashTestUserID, name, 1, 5, 8000.00, "2025-04-05", &response).Scan(&response)
+	if err != nil {
+		t.Fatalf("Erro ao criar o parent income: %v", err)
+	}
+	LogProcedureResponse(t, response)
+
+	var parentID int
+	err = database.QueryRow(`SELECT MAX(FinancialUserItemID) FROM FinancialUserItem
+		WHERE EntityID = 5 AND UserEntityID = $1 AND FinancialUserItemName = $2`, trashTestUserID, name).Scan(&parentID)
+	if err != nil {
+		t.Fatalf("Erro ao buscar o parent income: %v", err)
+	}
+
+	for _, child := range []struct {
+		procedure string
+		entityID  int
+	}{{"CreateUserChildIncomeTax", 7}, {"CreateUserChildIncomeExpense", 8}} {
+		err := database.QueryRow("CALL "+child.procedure+"($1, $2, $3, $4, $5, $6)",
+			trashTestUserID, name+" child", 1, child.entityID, parentID, &response).Scan(&response)
+		if err != nil {
+			t.Fatalf("Erro ao executar a procedure %s: %v", child.procedure, err)
+		}
+		LogProcedureResponse(t, response)
+	}
+
+	childIDs := []int{}
+	rows, err := database.Query(`SELECT FinancialUserItemID FROM FinancialUserItem WHERE ParentFinancialUserItemID = $1
+		ORDER BY FinancialUserItemID`, parentID)
+	if err != nil {
+		t.Fatalf("Erro ao buscar os childs: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Erro ao ler os childs: %v", err)
+		}
+		childIDs = append(childIDs, id)
+	}
+	if len(childIDs) != 2 {
+		t.Fatalf("Esperava 2 childs, encontrou %d", len(childIDs))
+	}
+	return parentID, childIDs
+}
+
+// Move o parent income para a lixeira pela procedure DeleteUserParentIncome
+func trashTestIncome(t *testing.T, database *sql.DB, parentID int) {
+	t.Helper()
+
+	var response string
+	err := database.QueryRow("CALL DeleteUserParentIncome($1, $2, $3)", parentID, trashTestUserID, &response).Scan(&response)
+	if err != nil {
+		t.Fatalf("Erro ao executar a procedure DeleteUserParentIncome: %v", err)
+	}
+	LogProcedureResponse(t, response)
+}
+
+// Executa um handler da lixeira autenticado como o usuário dos testes
+func serveTrashHandler(handler http.HandlerFunc, method, target string, itemID int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if itemID != 0 {
+		req.SetPathValue("id", strconv.Itoa(itemID))
+	}
+	req = req.WithContext(context.WithValue(req.Context(), "user", models.UserProfile{UserProfileID: trashTestUserID}))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+// Testa o ciclo da lixeira: delete, listagem e restore, com os childs compartilhando o DeletedAt do parent
+func TestTrashListAndRestore(t *testing.T) {
+	database := getTestDB(t)
+	db.DB = database // os handlers usam a mesma conexão dos testes
+
+	parentID, childIDs := createTrashTestIncome(t, database)
+	trashTestIncome(t, database, parentID)
+
+	// O parent e os childs vão para a lixeira com o mesmo DeletedAt
+	var trashed, deletedAts int
+	err := database.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT DeletedAt) FROM FinancialUserItem
+		WHERE (FinancialUserItemID = $1 OR ParentFinancialUserItemID = $1) AND DeletedAt IS NOT NULL AND IsActive = FALSE`,
+		parentID).Scan(&trashed, &deletedAts)
+	if err != nil {
+		t.Fatalf("Erro ao verificar a lixeira: %v", err)
+	}
+	if trashed != 3 || deletedAts != 1 {
+		t.Fatalf("Esperava 3 itens com o mesmo DeletedAt, encontrou %d itens e %d DeletedAt", trashed, deletedAts)
+	}
+
+	// A lixeira lista o parent com seus childs, que não aparecem sozinhos
+	rec := serveTrashHandler(handlers.Trash, http.MethodGet, "/api/trash", 0)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/trash retornou %d: %s", rec.Code, rec.Body.String())
+	}
+	var items []models.TrashItem
+	if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+		t.Fatalf("Erro ao interpretar a lixeira: %v", err)
+	}
+	var listed *models.TrashItem
+	for i, item := range items {
+		if item.FinancialUserItemID == parentID {
+			listed = &items[i]
+		}
+		for _, childID := range childIDs {
+			if item.FinancialUserItemID == childID {
+				t.Errorf("O child %d foi listado fora do parent", childID)
+			}
+		}
+	}
+	if listed == nil {
+		t.Fatalf("O parent %d não foi listado na lixeira", parentID)
+	}
+	if listed.Children != 2 {
+		t.Errorf("Esperava 2 childs no parent da lixeira, encontrou %d", listed.Children)
+	}
+
+	// Um child não pode ser restaurado enquanto o parent está na lixeira
+	rec = serveTrashHandler(handlers.RestoreTrashItem, http.MethodPost, "/api/trash/restore", childIDs[0])
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Restore do child com o parent na lixeira retornou %d, esperava %d", rec.Code, http.StatusConflict)
+	}
+
+	// O restore do parent traz de volta os childs deletados com ele
+	rec = serveTrashHandler(handlers.RestoreTrashItem, http.MethodPost, "/api/trash/restore", parentID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Restore do parent retornou %d: %s", rec.Code, rec.Body.String())
+	}
+	var restored int
+	err = database.QueryRow(`SELECT COUNT(*) FROM FinancialUserItem
+		WHERE (FinancialUserItemID = $1 OR ParentFinancialUserItemID = $1) AND DeletedAt IS NULL AND DeletedWasActive IS NULL`,
+		parentID).Scan(&restored)
+	if err != nil {
+		t.Fatalf("Erro ao verificar o restore: %v", err)
+	}
+	if restored != 3 {
+		t.Errorf("Esperava 3 itens restaurados, encontrou %d", restored)
+	}
+}
+
+// Testa que o job de retenção só purga os itens que passaram do prazo, com childs e forecasts
+func TestPurgeTrashAfterRetention(t *testing.T) {
+	database := getTestDB(t)
+
+	parentID, _ := createTrashTestIncome(t, database)
+	trashTestIncome(t, database, parentID)
+
+	countItems := func() (int, int) {
+		var items, forecasts int
+		err := database.QueryRow(`SELECT COUNT(DISTINCT fui.FinancialUserItemID), COUNT(uff.UserFinancialForecastID)
+			FROM FinancialUserItem fui
+			LEFT JOIN UserFinancialForecast uff ON uff.FinancialUserItemID = fui.FinancialUserItemID
+			WHERE fui.FinancialUserItemID = $1 OR fui.ParentFinancialUserItemID = $1`, parentID).Scan(&items, &forecasts)
+		if err != nil {
+			t.Fatalf("Erro ao contar os itens: %v", err)
+		}
+		return items, forecasts
+	}
+
+	// Dentro da retenção nada é purgado
+	if _, err := handlers.PurgeTrash(database, handlers.DefaultTrashRetentionDays); err != nil {
+		t.Fatalf("Erro ao purgar a lixeira: %v", err)
+	}
+	if items, forecasts := countItems(); items != 3 || forecasts == 0 {
+		t.Fatalf("Itens dentro da retenção foram purgados: %d itens e %d forecasts restantes", items, forecasts)
+	}
+
+	// Depois da retenção o parent, os childs e os forecasts são deletados
+	_, err := database.Exec(`UPDATE FinancialUserItem SET DeletedAt = DeletedAt - make_interval(days => $2)
+		WHERE FinancialUserItemID = $1 OR ParentFinancialUserItemID = $1`, parentID, handlers.DefaultTrashRetentionDays+1)
+	if err != nil {
+		t.Fatalf("Erro ao antecipar o DeletedAt: %v", err)
+	}
+	purged, err := handlers.PurgeTrash(database, handlers.DefaultTrashRetentionDays)
+	if err != nil {
+		t.Fatalf("Erro ao purgar a lixeira: %v", err)
+	}
+	if purged < 3 {
+		t.Errorf("Esperava ao menos 3 itens purgados, purgou %d", purged)
+	}
+	if items, forecasts := countItems(); items != 0 || forecasts != 0 {
+		t.Errorf("Restaram %d itens e %d forecasts depois do purge", items, forecasts)
+	}
+}
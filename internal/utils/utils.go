@@ -61,3 +61,13 @@ func GenerateResetToken() string {
 	}
 	return hex.EncodeToString(token)
 }
+
+// GenerateToken generates a random hex token of size bytes, for invite and feed links
+func GenerateToken(size int) string {
+	token := make([]byte, size)
+	_, err := rand.Read(token)
+	if err != nil {
+		log.Fatal("Error generating token:", err)
+	}
+	return hex.EncodeToString(token)
+}
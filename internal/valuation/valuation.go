@@ -0,0 +1,137 @@
+// Package valuation projects the value of an asset over time with depreciation and appreciation models.
+package valuation
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Valuation methods
+const (
+	Manual            = "manual"             // the value only changes when a valuation is recorded
+	StraightLine      = "straight_line"      // loses (cost - salvage) / useful life every year
+	DecliningBalance  = "declining_balance"  // loses Rate of its value every year
+	FixedAppreciation = "fixed_appreciation" // gains Rate of its value every year
+)
+
+// AssetType IDs from the AssetType seed
+const (
+	AssetTypeRealEstate = 1
+	AssetTypeVehicle    = 4
+)
+
+// Model is the valuation model of an asset
+type Model struct {
+	Method          string  `json:"method"`
+	Rate            float64 `json:"rate"`            // yearly fraction for declining balance and appreciation, 0.15 = 15%
+	UsefulLifeYears int     `json:"usefulLifeYears"` // straight-line only
+	SalvageValue    float64 `json:"salvageValue"`    // depreciation never goes below it
+}
+
+// MethodsFor returns the methods allowed for an AssetType: vehicles depreciate, real estate appreciates
+// and the other types are manual only
+func MethodsFor(assetTypeID int) []string {
+	switch assetTypeID {
+	case AssetTypeVehicle:
+		return []string{Manual, StraightLine, DecliningBalance}
+	case AssetTypeRealEstate:
+		return []string{Manual, FixedAppreciation}
+	}
+	return []string{Manual}
+}
+
+// Validate checks the model parameters and that the method is allowed for the AssetType. Cost is the acquisition
+// value, the depreciation methods need a salvage value below it.
+func (m Model) Validate(assetTypeID int, cost float64) error {
+	allowed := false
+	for _, method := range MethodsFor(assetTypeID) {
+		if method == m.Method {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("method %q is not allowed for this asset type (allowed: %v)", m.Method, MethodsFor(assetTypeID))
+	}
+	if m.SalvageValue < 0 {
+		return fmt.Errorf("salvage value cannot be negative")
+	}
+
+	switch m.Method {
+	case StraightLine, DecliningBalance:
+		if m.SalvageValue >= cost {
+			return fmt.Errorf("salvage value must be lower than the acquisition cost")
+		}
+	}
+
+	switch m.Method {
+	case StraightLine:
+		if m.UsefulLifeYears <= 0 {
+			return fmt.Errorf("useful life must be at least one year")
+		}
+	case DecliningBalance:
+		if m.Rate <= 0 || m.Rate >= 1 {
+			return fmt.Errorf("rate must be between 0 and 1")
+		}
+	case FixedAppreciation:
+		if m.Rate <= 0 || m.Rate > 1 {
+			return fmt.Errorf("rate must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+// Anchor is a known value of the asset: its acquisition cost or a recorded valuation
+type Anchor struct {
+	Date   time.Time
+	Amount float64
+}
+
+// ValueAt projects the value on a date from the latest known value. Cost is the acquisition value,
+// used by the straight-line method to keep the same yearly depreciation after a revaluation.
+func (m Model) ValueAt(cost float64, anchor Anchor, on time.Time) float64 {
+	years := yearsBetween(anchor.Date, on)
+	if years <= 0 {
+		return anchor.Amount
+	}
+
+	var value float64
+	switch m.Method {
+	case StraightLine:
+		yearly := (cost - m.SalvageValue) / float64(m.UsefulLifeYears)
+		value = math.Max(anchor.Amount-yearly*years, math.Min(anchor.Amount, m.SalvageValue))
+	case DecliningBalance:
+		value = math.Max(anchor.Amount*math.Pow(1-m.Rate, years), math.Min(anchor.Amount, m.SalvageValue))
+	case FixedAppreciation:
+		value = anchor.Amount * math.Pow(1+m.Rate, years)
+	default:
+		value = anchor.Amount
+	}
+
+	return math.Round(value*100) / 100
+}
+
+// Point is the projected value on a date
+type Point struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// Schedule projects the value at the end of each of the next months, starting on the month of from
+func (m Model) Schedule(cost float64, anchor Anchor, from time.Time, months int) []Point {
+	points := make([]Point, 0, months)
+	for i := 0; i < months; i++ {
+		d := time.Date(from.Year(), from.Month()+time.Month(i)+1, 0, 0, 0, 0, 0, time.UTC)
+		points = append(points, Point{Date: d.Format("2006-01-02"), Value: m.ValueAt(cost, anchor, d)})
+	}
+	return points
+}
+
+// yearsBetween counts the elapsed whole months as a fraction of a year, so the value changes once a month
+func yearsBetween(from, to time.Time) float64 {
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	if to.Day() < from.Day() {
+		months--
+	}
+	return float64(months) / 12
+}
@@ -0,0 +1,70 @@
+package valuation
+
+import (
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func TestStraightLine(t *testing.T) {
+	m := Model{Method: StraightLine, UsefulLifeYears: 5, SalvageValue: 10000}
+	acquisition := Anchor{Date: day("2020-01-10"), Amount: 60000}
+
+	cases := map[string]float64{
+		"2020-01-09": 60000, // before the acquisition
+		"2021-01-10": 50000,
+		"2022-07-10": 35000,
+		"2030-01-10": 10000, // never below the salvage value
+	}
+	for date, want := range cases {
+		if got := m.ValueAt(60000, acquisition, day(date)); got != want {
+			t.Errorf("ValueAt(%s) = %v, want %v", date, got, want)
+		}
+	}
+
+	// After a revaluation the yearly depreciation stays the same
+	if got := m.ValueAt(60000, Anchor{Date: day("2022-01-10"), Amount: 45000}, day("2023-01-10")); got != 35000 {
+		t.Errorf("ValueAt after revaluation = %v, want 35000", got)
+	}
+}
+
+func TestDecliningBalanceAndAppreciation(t *testing.T) {
+	anchor := Anchor{Date: day("2024-03-01"), Amount: 100000}
+
+	declining := Model{Method: DecliningBalance, Rate: 0.2}
+	if got := declining.ValueAt(100000, anchor, day("2026-03-01")); got != 64000 {
+		t.Errorf("declining balance = %v, want 64000", got)
+	}
+
+	appreciation := Model{Method: FixedAppreciation, Rate: 0.05}
+	if got := appreciation.ValueAt(100000, anchor, day("2026-03-01")); got != 110250 {
+		t.Errorf("appreciation = %v, want 110250", got)
+	}
+
+	schedule := appreciation.Schedule(100000, anchor, day("2025-03-15"), 3)
+	if len(schedule) != 3 || schedule[0].Date != "2025-03-31" || schedule[0].Value != 105000 {
+		t.Errorf("unexpected schedule %+v", schedule)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Model{Method: FixedAppreciation, Rate: 0.04}).Validate(AssetTypeVehicle, 50000); err == nil {
+		t.Error("expected appreciation to be rejected for vehicles")
+	}
+	if err := (Model{Method: StraightLine}).Validate(AssetTypeVehicle, 50000); err == nil {
+		t.Error("expected a missing useful life to be rejected")
+	}
+	if err := (Model{Method: StraightLine, UsefulLifeYears: 5, SalvageValue: 50000}).Validate(AssetTypeVehicle, 50000); err == nil {
+		t.Error("expected a salvage value equal to the cost to be rejected")
+	}
+	if err := (Model{Method: DecliningBalance, Rate: 0.15, SalvageValue: 60000}).Validate(AssetTypeVehicle, 50000); err == nil {
+		t.Error("expected a salvage value above the cost to be rejected")
+	}
+	if err := (Model{Method: Manual}).Validate(2, 0); err != nil {
+		t.Errorf("expected manual to be allowed, got %v", err)
+	}
+}